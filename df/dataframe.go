@@ -96,6 +96,35 @@ type DataFrame interface {
 	GetValue(rowIndx, colIndx int) Value
 }
 
+// RowStream reads rows one at a time, Next returns io.EOF once all the rows are read
+type RowStream interface {
+	Next() (Row, error)
+	Close() error
+}
+
+// StreamingDataFrame DataFrame whose rows can be read sequentially from underlying source without loading them in memory
+type StreamingDataFrame interface {
+	DataFrame
+	Stream() (RowStream, error)
+	// Err returns error encountered while reading source by operations which do not return error (ex - Len, ForEachRow),
+	// such operations behave as if source has no more rows after error
+	Err() error
+}
+
+// Filter condition on column which can be evaluated by the source, Op is one of =, <>, <, <=, >, >=, isnull, notnull
+type Filter struct {
+	Column int
+	Op     string
+	Value  any
+}
+
+// PushdownDataFrame StreamingDataFrame which can apply filters and column selection while reading from source
+type PushdownDataFrame interface {
+	StreamingDataFrame
+	// StreamWith returns rows matching all the filters, only given columns are read (nil for all) and rest of the values are nil
+	StreamWith(cols []int, filters []Filter) (RowStream, error)
+}
+
 type GroupedDataFrame interface {
 	GetGroupColumns() []string
 	Get(index Row) DataFrame
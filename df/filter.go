@@ -0,0 +1,118 @@
+package df
+
+import (
+	"strings"
+)
+
+// compareFilterValue compares values as numbers for numeric formats and as strings for rest of the formats
+func compareFilterValue(format Format, a any, b any) (c int, ok bool) {
+	if format == IntegerFormat || format == DoubleFormat {
+		x, err := i2double(a)
+		if err != nil {
+			return c, false
+		}
+		y, err := i2double(b)
+		if err != nil {
+			return c, false
+		}
+		if x < y {
+			return -1, true
+		} else if x > y {
+			return 1, true
+		}
+		return 0, true
+	}
+
+	x, err := i2str(a)
+	if err != nil {
+		return c, false
+	}
+	y, err := i2str(b)
+	if err != nil {
+		return c, false
+	}
+	return strings.Compare(x, y), true
+}
+
+// Match returns true if value of column satisfies the filter, nil values only match isnull filter
+func (t Filter) Match(format Format, v any) bool {
+	switch t.Op {
+	case "isnull":
+		return v == nil
+	case "notnull":
+		return v != nil
+	}
+	if v == nil || t.Value == nil {
+		return false
+	}
+
+	c, ok := compareFilterValue(format, v, t.Value)
+	if !ok {
+		return t.Op == "<>" || t.Op == "!="
+	}
+	switch t.Op {
+	case "=":
+		return c == 0
+	case "<>", "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return true
+}
+
+// MatchRow returns true if row satisfies the filter
+func (t Filter) MatchRow(r Row) bool {
+	v := r.Get(t.Column)
+	if v == nil || v.IsNil() {
+		return t.Match(r.Schema().Get(t.Column).Format, nil)
+	}
+	return t.Match(r.Schema().Get(t.Column).Format, v.Get())
+}
+
+// MatchRange returns false if none of the values in a block (ex - parquet row group) can satisfy the filter,
+// min/max are nil if statistics are not available
+func (t Filter) MatchRange(format Format, min any, max any, hasNulls bool, allNulls bool) bool {
+	switch t.Op {
+	case "isnull":
+		return hasNulls
+	case "notnull":
+		return !allNulls
+	}
+	if allNulls {
+		return false
+	}
+	if min == nil || max == nil || t.Value == nil {
+		return true
+	}
+
+	cmin, ok := compareFilterValue(format, min, t.Value)
+	if !ok {
+		return true
+	}
+	cmax, ok := compareFilterValue(format, max, t.Value)
+	if !ok {
+		return true
+	}
+	switch t.Op {
+	case "=":
+		return cmin <= 0 && cmax >= 0
+	case "<>", "!=":
+		return !(cmin == 0 && cmax == 0)
+	case "<":
+		return cmin < 0
+	case "<=":
+		return cmin <= 0
+	case ">":
+		return cmax > 0
+	case ">=":
+		return cmax >= 0
+	}
+	return true
+}
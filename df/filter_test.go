@@ -0,0 +1,30 @@
+package df
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterMatch(t *testing.T) {
+	assert.True(t, Filter{Op: "=", Value: "2"}.Match(IntegerFormat, int64(2)))
+	assert.True(t, Filter{Op: "<", Value: 2.5}.Match(IntegerFormat, int64(2)))
+	assert.False(t, Filter{Op: ">=", Value: int64(3)}.Match(DoubleFormat, 2.5))
+	assert.True(t, Filter{Op: "<>", Value: "a"}.Match(StringFormat, "b"))
+	assert.False(t, Filter{Op: "=", Value: "abc"}.Match(IntegerFormat, int64(1)))
+	assert.False(t, Filter{Op: "=", Value: "a"}.Match(StringFormat, nil))
+	assert.True(t, Filter{Op: "isnull"}.Match(StringFormat, nil))
+	assert.False(t, Filter{Op: "notnull"}.Match(StringFormat, nil))
+}
+
+func TestFilterMatchRange(t *testing.T) {
+	assert.True(t, Filter{Op: "=", Value: int64(5)}.MatchRange(IntegerFormat, int64(1), int64(5), false, false))
+	assert.False(t, Filter{Op: "=", Value: int64(6)}.MatchRange(IntegerFormat, int64(1), int64(5), false, false))
+	assert.False(t, Filter{Op: ">", Value: int64(5)}.MatchRange(IntegerFormat, int64(1), int64(5), false, false))
+	assert.False(t, Filter{Op: "<", Value: int64(1)}.MatchRange(IntegerFormat, int64(1), int64(5), false, false))
+	assert.False(t, Filter{Op: "<>", Value: "a"}.MatchRange(StringFormat, "a", "a", false, false))
+	assert.True(t, Filter{Op: "=", Value: "b"}.MatchRange(StringFormat, "a", "c", false, false))
+	assert.True(t, Filter{Op: "=", Value: "b"}.MatchRange(StringFormat, nil, nil, false, false))
+	assert.False(t, Filter{Op: "=", Value: "b"}.MatchRange(StringFormat, nil, nil, true, true))
+	assert.False(t, Filter{Op: "isnull"}.MatchRange(StringFormat, "a", "c", false, false))
+}
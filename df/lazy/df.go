@@ -0,0 +1,432 @@
+package lazy
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+)
+
+// lazyDataFrame reads rows from source only when required, Stream reads rows directly from source,
+// rest of the operations load source in memory once and delegate to inmemory dataframe.
+// errors while loading source are kept in err (see Err) and rows read before the error are used
+type lazyDataFrame struct {
+	name   string
+	schema df.DataFrameSchema
+	open   func() (df.RowStream, error)
+	once   sync.Once
+	data   df.DataFrame
+	err    error
+}
+
+func (t *lazyDataFrame) materialize() df.DataFrame {
+	t.once.Do(func() {
+		rows := []df.Row{}
+		stream, err := t.open()
+		if err == nil {
+			rows, err = readRows(stream)
+			stream.Close()
+		}
+		if err != nil {
+			t.err = err
+		}
+		t.data = inmemory.NewDataframeFromRowAndName(t.name, t.schema, &rows)
+	})
+	return t.data
+}
+
+func (t *lazyDataFrame) Err() error {
+	return t.err
+}
+
+func (t *lazyDataFrame) isMaterialized() bool {
+	return t.data != nil
+}
+
+func (t *lazyDataFrame) Stream() (df.RowStream, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	if t.isMaterialized() {
+		return NewRowStream(t.data)
+	}
+	return t.open()
+}
+
+func (t *lazyDataFrame) Schema() df.DataFrameSchema {
+	return t.schema
+}
+
+func (t *lazyDataFrame) Name() string {
+	return t.name
+}
+
+func (t *lazyDataFrame) Len() int64 {
+	return t.materialize().Len()
+}
+
+func (t *lazyDataFrame) Rename(name string, inplace bool) df.DataFrame {
+	if inplace {
+		t.name = name
+		if t.isMaterialized() {
+			t.data.Rename(name, true)
+		}
+		return t
+	}
+	if t.isMaterialized() {
+		return t.data.Rename(name, false)
+	}
+	return NewDataframe(name, t.schema, t.open)
+}
+
+func (t *lazyDataFrame) Limit(offset int, size int) df.DataFrame {
+	return t.materialize().Limit(offset, size)
+}
+
+func (t *lazyDataFrame) Sort(order ...df.SortByIndex) df.DataFrame {
+	return t.materialize().Sort(order...)
+}
+
+func (t *lazyDataFrame) SortByName(order ...df.SortByName) df.DataFrame {
+	return t.materialize().SortByName(order...)
+}
+
+func (t *lazyDataFrame) Select(e ...df.Expr) df.DataFrame {
+	return t.materialize().Select(e...)
+}
+
+func (t *lazyDataFrame) SelectBySeriesIndex(index ...int) df.DataFrame {
+	return t.materialize().SelectBySeriesIndex(index...)
+}
+
+func (t *lazyDataFrame) SelectBySeriesName(col ...string) df.DataFrame {
+	return t.materialize().SelectBySeriesName(col...)
+}
+
+func (t *lazyDataFrame) MapRow(schema df.DataFrameSchema, f func(df.Row) df.Row) df.DataFrame {
+	return t.materialize().MapRow(schema, f)
+}
+
+func (t *lazyDataFrame) FlatMapRow(schema df.DataFrameSchema, f func(df.Row) []df.Row) df.DataFrame {
+	return t.materialize().FlatMapRow(schema, f)
+}
+
+func (t *lazyDataFrame) WhereRow(f func(df.Row) bool) df.DataFrame {
+	return t.materialize().WhereRow(f)
+}
+
+func (t *lazyDataFrame) WhenNil(d map[string]df.Value) df.DataFrame {
+	return t.materialize().WhenNil(d)
+}
+
+func (t *lazyDataFrame) When(d map[string]map[any]df.Value) df.DataFrame {
+	return t.materialize().When(d)
+}
+
+func (t *lazyDataFrame) AsFormat(d map[string]df.Format) df.DataFrame {
+	return t.materialize().AsFormat(d)
+}
+
+func (t *lazyDataFrame) GetSeries(index int) df.Series {
+	return t.materialize().GetSeries(index)
+}
+
+func (t *lazyDataFrame) GetSeriesByName(s string) df.Series {
+	return t.materialize().GetSeriesByName(s)
+}
+
+func (t *lazyDataFrame) GetSeriesExprByName(s string) df.Expr {
+	return t.materialize().GetSeriesExprByName(s)
+}
+
+func (t *lazyDataFrame) AddSeries(name string, series df.Series) df.DataFrame {
+	return t.materialize().AddSeries(name, series)
+}
+
+func (t *lazyDataFrame) UpdateSeries(index int, series df.Series) df.DataFrame {
+	return t.materialize().UpdateSeries(index, series)
+}
+
+func (t *lazyDataFrame) UpdateSeriesByName(name string, series df.Series) df.DataFrame {
+	return t.materialize().UpdateSeriesByName(name, series)
+}
+
+func (t *lazyDataFrame) RenameSeries(index int, name string, inplace bool) df.DataFrame {
+	return t.materialize().RenameSeries(index, name, inplace)
+}
+
+func (t *lazyDataFrame) RenameSeriesByName(col string, name string, inplace bool) df.DataFrame {
+	return t.materialize().RenameSeriesByName(col, name, inplace)
+}
+
+func (t *lazyDataFrame) RemoveSeries(index int) df.DataFrame {
+	return t.materialize().RemoveSeries(index)
+}
+
+func (t *lazyDataFrame) RemoveSeriesByName(s string) df.DataFrame {
+	return t.materialize().RemoveSeriesByName(s)
+}
+
+func (t *lazyDataFrame) GetRow(i int64) df.Row {
+	return t.materialize().GetRow(i)
+}
+
+func (t *lazyDataFrame) ForEachRow(f func(df.Row)) {
+	if t.isMaterialized() {
+		t.data.ForEachRow(f)
+		return
+	}
+
+	stream, err := t.open()
+	if err != nil {
+		t.err = err
+		return
+	}
+	defer stream.Close()
+	for {
+		r, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.err = err
+			return
+		}
+		f(r)
+	}
+}
+
+func (t *lazyDataFrame) Group(others ...string) df.GroupedDataFrame {
+	return t.materialize().Group(others...)
+}
+
+func (t *lazyDataFrame) Append(d df.DataFrame) df.DataFrame {
+	return t.materialize().Append(d)
+}
+
+func (t *lazyDataFrame) Distinct(cols ...string) df.DataFrame {
+	return t.materialize().Distinct(cols...)
+}
+
+func (t *lazyDataFrame) Join(schema df.DataFrameSchema, d df.DataFrame, jointype df.JoinType, cols map[string]string, f func(df.Row, df.Row) []df.Row) df.DataFrame {
+	return t.materialize().Join(schema, d, jointype, cols, f)
+}
+
+func (t *lazyDataFrame) Union(d df.DataFrame) df.DataFrame {
+	return t.materialize().Union(d)
+}
+
+func (t *lazyDataFrame) Intersection(d df.DataFrame, col ...string) df.DataFrame {
+	return t.materialize().Intersection(d, col...)
+}
+
+func (t *lazyDataFrame) Except(d df.DataFrame, col ...string) df.DataFrame {
+	return t.materialize().Except(d, col...)
+}
+
+func (t *lazyDataFrame) GetValue(rowIndx, colIndx int) df.Value {
+	return t.materialize().GetValue(rowIndx, colIndx)
+}
+
+// rowStream iterates over rows of non streaming dataframe
+type rowStream struct {
+	data  df.DataFrame
+	len   int64
+	index int64
+}
+
+func (t *rowStream) Next() (r df.Row, err error) {
+	if t.index >= t.len {
+		return r, io.EOF
+	}
+	r = t.data.GetRow(t.index)
+	t.index = t.index + 1
+	return r, err
+}
+
+func (t *rowStream) Close() error {
+	return nil
+}
+
+// mergedStream reads given dataframes one after another
+type mergedStream struct {
+	dfs     []df.DataFrame
+	open    func(df.DataFrame) (df.RowStream, error)
+	current df.RowStream
+}
+
+func (t *mergedStream) Next() (r df.Row, err error) {
+	for {
+		if t.current == nil {
+			if len(t.dfs) == 0 {
+				return r, io.EOF
+			}
+			t.current, err = t.open(t.dfs[0])
+			if err != nil {
+				return r, err
+			}
+			t.dfs = t.dfs[1:]
+		}
+
+		r, err = t.current.Next()
+		if err != io.EOF {
+			return r, err
+		}
+		err = t.current.Close()
+		t.current = nil
+		if err != nil {
+			return r, err
+		}
+	}
+}
+
+func (t *mergedStream) Close() (err error) {
+	t.dfs = nil
+	if t.current != nil {
+		err = t.current.Close()
+		t.current = nil
+	}
+	return err
+}
+
+// filterStream returns rows of underlying stream which satisfy all the filters
+type filterStream struct {
+	df.RowStream
+	filters []df.Filter
+}
+
+func (t *filterStream) Next() (r df.Row, err error) {
+	for {
+		r, err = t.RowStream.Next()
+		if err != nil {
+			return r, err
+		}
+		matched := true
+		for _, f := range t.filters {
+			if !f.MatchRow(r) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return r, err
+		}
+	}
+}
+
+// NewFilterStream returns stream of rows which satisfy all the filters, sources which can only skip
+// blocks of rows (ex - parquet row groups) use it to return exact rows
+func NewFilterStream(stream df.RowStream, filters []df.Filter) df.RowStream {
+	if len(filters) == 0 {
+		return stream
+	}
+	return &filterStream{RowStream: stream, filters: filters}
+}
+
+func readRows(stream df.RowStream) (rows []df.Row, err error) {
+	rows = make([]df.Row, 0, 1000)
+	for {
+		r, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, r)
+	}
+	return rows, nil
+}
+
+// NewRowStream returns stream of rows for given dataframe, streaming dataframes are read from their source
+func NewRowStream(data df.DataFrame) (df.RowStream, error) {
+	if s, ok := data.(df.StreamingDataFrame); ok {
+		return s.Stream()
+	}
+	return &rowStream{data: data, len: data.Len()}, nil
+}
+
+// Materialize reads rows of streaming dataframe in memory so that source is not read again, other dataframes are returned as is
+func Materialize(data df.DataFrame) (df.DataFrame, error) {
+	s, ok := data.(df.StreamingDataFrame)
+	if !ok {
+		return data, nil
+	}
+	stream, err := s.Stream()
+	if err != nil {
+		return data, err
+	}
+	defer stream.Close()
+	rows, err := readRows(stream)
+	if err != nil {
+		return data, err
+	}
+	return inmemory.NewDataframeFromRowAndName(data.Name(), data.Schema(), &rows), nil
+}
+
+var dfCounter = 0
+
+// NewDataframe Create Dataframe based on given schema, open is called everytime rows are read from source
+func NewDataframe(name string, schema df.DataFrameSchema, open func() (df.RowStream, error)) df.StreamingDataFrame {
+	if name == "" {
+		dfCounter = dfCounter + 1
+		name = "lazy_df_" + strconv.Itoa(dfCounter)
+	}
+	return &lazyDataFrame{name: name, schema: schema, open: open}
+}
+
+// NewMergeDataframe Returns dataframe which reads given dataframes one after another
+// Schema of new dataframe will be same as first dataframe, if all the dataframes support pushdown then
+// columns and filters are passed to each of them
+func NewMergeDataframe(name string, dfs ...df.DataFrame) (output df.StreamingDataFrame, err error) {
+	if len(dfs) == 0 {
+		return output, errors.New("empty data")
+	}
+
+	pushdownDfs := make([]df.PushdownDataFrame, 0, len(dfs))
+	for _, d := range dfs {
+		if p, ok := d.(df.PushdownDataFrame); ok {
+			pushdownDfs = append(pushdownDfs, p)
+		}
+	}
+	if len(pushdownDfs) == len(dfs) {
+		return NewPushdownDataframe(name, dfs[0].Schema(), func(cols []int, filters []df.Filter) (df.RowStream, error) {
+			return &mergedStream{dfs: dfs, open: func(d df.DataFrame) (df.RowStream, error) {
+				return d.(df.PushdownDataFrame).StreamWith(cols, filters)
+			}}, nil
+		}), nil
+	}
+
+	return NewDataframe(name, dfs[0].Schema(), func() (df.RowStream, error) {
+		return &mergedStream{dfs: dfs, open: NewRowStream}, nil
+	}), nil
+}
+
+// pushdownDataFrame lazy dataframe whose source can filter rows and select columns
+type pushdownDataFrame struct {
+	*lazyDataFrame
+	openWith func(cols []int, filters []df.Filter) (df.RowStream, error)
+}
+
+func (t *pushdownDataFrame) StreamWith(cols []int, filters []df.Filter) (df.RowStream, error) {
+	return t.openWith(cols, filters)
+}
+
+func (t *pushdownDataFrame) Rename(name string, inplace bool) df.DataFrame {
+	if inplace {
+		t.lazyDataFrame.Rename(name, inplace)
+		return t
+	}
+	return NewPushdownDataframe(name, t.schema, t.openWith)
+}
+
+// NewPushdownDataframe Create Dataframe based on given schema, open is called with selected columns and filters everytime rows are read from source
+func NewPushdownDataframe(name string, schema df.DataFrameSchema, open func(cols []int, filters []df.Filter) (df.RowStream, error)) df.PushdownDataFrame {
+	lazyDf := NewDataframe(name, schema, func() (df.RowStream, error) {
+		return open(nil, nil)
+	}).(*lazyDataFrame)
+	return &pushdownDataFrame{lazyDataFrame: lazyDf, openWith: open}
+}
@@ -0,0 +1,177 @@
+package lazy
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCountingDf(name string, opened *int) df.StreamingDataFrame {
+	data := inmemory.NewDataframeWithNameFromSeries(name, []string{"c1", "c2"}, &[]df.Series{
+		inmemory.NewIntSeriesVarArg(1, 2, 3),
+		inmemory.NewStringSeriesVarArg("a1", "a2", "a3"),
+	})
+	return NewDataframe(name, data.Schema(), func() (df.RowStream, error) {
+		*opened = *opened + 1
+		return NewRowStream(data)
+	})
+}
+
+func TestLazyDfStream(t *testing.T) {
+	opened := 0
+	data := newCountingDf("df1", &opened)
+	assert.Equal(t, "df1", data.Name())
+	assert.Equal(t, 2, data.Schema().Len())
+	assert.Equal(t, 0, opened)
+
+	stream, err := data.Stream()
+	assert.NoError(t, err)
+	rows, err := readRows(stream)
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Close())
+	assert.Equal(t, 3, len(rows))
+	assert.Equal(t, "a2", rows[1].GetByName("c2").GetAsString())
+
+	// ForEachRow reads from source without caching
+	cnt := 0
+	data.ForEachRow(func(r df.Row) {
+		cnt++
+	})
+	assert.Equal(t, 3, cnt)
+	assert.Equal(t, 2, opened)
+}
+
+func TestLazyDfMaterialize(t *testing.T) {
+	opened := 0
+	data := newCountingDf("df1", &opened)
+
+	assert.Equal(t, int64(3), data.Len())
+	assert.Equal(t, int64(2), data.GetRow(1).Get(0).GetAsInt())
+	assert.Equal(t, int64(1), data.WhereRow(func(r df.Row) bool {
+		return r.GetByName("c2").GetAsString() == "a3"
+	}).Len())
+	assert.Equal(t, 1, opened)
+
+	// once materialized, stream reads cached rows
+	stream, err := data.Stream()
+	assert.NoError(t, err)
+	r, err := stream.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), r.Get(0).GetAsInt())
+	assert.Equal(t, 1, opened)
+}
+
+// errorStream returns error after rows of stream
+type errorStream struct {
+	df.RowStream
+	err error
+}
+
+func (t *errorStream) Next() (df.Row, error) {
+	r, err := t.RowStream.Next()
+	if err == io.EOF {
+		return r, t.err
+	}
+	return r, err
+}
+
+func TestLazyDfError(t *testing.T) {
+	opened := 0
+	source := newCountingDf("df1", &opened)
+	readErr := errors.New("read failed")
+	newErrorDf := func() df.StreamingDataFrame {
+		return NewDataframe("df1", source.Schema(), func() (df.RowStream, error) {
+			stream, err := source.Stream()
+			return &errorStream{RowStream: stream, err: readErr}, err
+		})
+	}
+
+	data := newErrorDf()
+	assert.NoError(t, data.Err())
+	assert.Equal(t, int64(3), data.Len())
+	assert.Equal(t, readErr, data.Err())
+	_, err := data.Stream()
+	assert.Equal(t, readErr, err)
+
+	data = newErrorDf()
+	cnt := 0
+	data.ForEachRow(func(r df.Row) {
+		cnt++
+	})
+	assert.Equal(t, 3, cnt)
+	assert.Equal(t, readErr, data.Err())
+
+	data = NewDataframe("df1", source.Schema(), func() (df.RowStream, error) {
+		return nil, readErr
+	})
+	assert.Equal(t, int64(0), data.Len())
+	assert.Equal(t, readErr, data.Err())
+}
+
+func TestLazyMaterialize(t *testing.T) {
+	opened := 0
+	data, err := Materialize(newCountingDf("df1", &opened))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, opened)
+	_, ok := data.(df.StreamingDataFrame)
+	assert.False(t, ok)
+	assert.Equal(t, "df1", data.Name())
+	assert.Equal(t, int64(3), data.Len())
+	assert.Equal(t, "a3", data.GetRow(2).GetRaw(1))
+	assert.Equal(t, 1, opened)
+
+	readErr := errors.New("read failed")
+	_, err = Materialize(NewDataframe("df1", data.Schema(), func() (df.RowStream, error) {
+		stream, err := NewRowStream(data)
+		return &errorStream{RowStream: stream, err: readErr}, err
+	}))
+	assert.Equal(t, readErr, err)
+}
+
+func TestLazyDfRename(t *testing.T) {
+	opened := 0
+	data := newCountingDf("df1", &opened)
+
+	data2 := data.Rename("df2", false)
+	assert.Equal(t, "df2", data2.Name())
+	assert.Equal(t, "df1", data.Name())
+	assert.Equal(t, 0, opened)
+
+	data.Rename("df3", true)
+	assert.Equal(t, "df3", data.Name())
+}
+
+func TestLazyMergeDf(t *testing.T) {
+	opened := 0
+	data1 := newCountingDf("df1", &opened)
+	data2 := newCountingDf("df2", &opened)
+
+	_, err := NewMergeDataframe("m")
+	assert.Error(t, err)
+
+	data, err := NewMergeDataframe("m", data1, data2)
+	assert.NoError(t, err)
+	assert.Equal(t, "m", data.Name())
+	assert.Equal(t, 2, data.Schema().Len())
+
+	stream, err := data.Stream()
+	assert.NoError(t, err)
+	cnt := 0
+	for {
+		_, err = stream.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		cnt++
+	}
+	assert.NoError(t, stream.Close())
+	assert.Equal(t, 6, cnt)
+	assert.Equal(t, 2, opened)
+
+	assert.Equal(t, int64(6), data.Len())
+}
@@ -10,3 +10,9 @@ type DataFrameSource interface {
 	Write(context context.Context, data DataFrame, path string, args map[string]string) error
 	IsSupported(protocol string) bool
 }
+
+// MultiDataFrameSource sources which can read multiple dataframes from single path (ex - all the tables of database)
+type MultiDataFrameSource interface {
+	DataFrameSource
+	ReadAll(context context.Context, path string, args map[string]string) ([]DataFrame, error)
+}
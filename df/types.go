@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
@@ -168,15 +169,22 @@ func i2datetime(v any) (datetime time.Time, err error) {
 	if v == nil {
 		return datetime, err
 	}
-	datetime, ok := v.(time.Time)
-	if ok {
+	switch d := v.(type) {
+	case time.Time:
+		return d, err
+	case *time.Time:
+		if d != nil {
+			return *d, err
+		}
+		return datetime, err
+	case string:
+		datetime, err = time.Parse(time.RFC3339Nano, d)
+		if err != nil {
+			err = errors.New("unsupported datetime value - " + d)
+		}
 		return datetime, err
 	}
-
-	if reflect.TypeOf(v).String() == "time.Time" {
-		datetime = v.(time.Time)
-	}
-	return datetime, err
+	return datetime, errors.New("unsupported type - " + reflect.TypeOf(v).String())
 }
 
 func i2str(v any) (str string, err error) {
@@ -236,9 +244,15 @@ func i2int(v any) (i int64, err error) {
 
 	vt := t.Kind()
 	switch vt {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		rv := reflect.ValueOf(v)
 		i = rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := reflect.ValueOf(v).Uint()
+		if u > math.MaxInt64 {
+			return i, errors.New("value out of range of integer - " + strconv.FormatUint(u, 10))
+		}
+		i = int64(u)
 	case reflect.Float32, reflect.Float64:
 		rv := reflect.ValueOf(v)
 		i = int64(rv.Float())
@@ -285,9 +299,12 @@ func i2double(v any) (f float64, err error) {
 
 	vt := reflect.TypeOf(v).Kind()
 	switch vt {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		rv := reflect.ValueOf(v)
 		f = float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		rv := reflect.ValueOf(v)
+		f = float64(rv.Uint())
 	case reflect.Float32, reflect.Float64:
 		rv := reflect.ValueOf(v)
 		f = rv.Float()
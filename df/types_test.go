@@ -1,8 +1,10 @@
 package df
 
 import (
+	"math"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -45,6 +47,13 @@ func TestIntType(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, c, int64(0))
 
+	c, err = f.Convert(uint32(7))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), c)
+
+	_, err = f.Convert(uint64(math.MaxUint64))
+	assert.Error(t, err)
+
 	c, err = f.Convert(nil)
 	assert.Nil(t, c)
 	assert.Nil(t, err)
@@ -72,6 +81,10 @@ func TestDoubleType(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, c, float64(0))
 
+	c, err = f.Convert(uint64(math.MaxUint64))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(math.MaxUint64), c)
+
 	c, err = f.Convert(nil)
 	assert.Nil(t, c)
 	assert.Nil(t, err)
@@ -152,3 +165,21 @@ func TestNewSchema(t *testing.T) {
 	assert.Equal(t, "c3", s.Get(2).Name)
 	assert.Equal(t, 4, s.Len())
 }
+
+func TestI2Datetime(t *testing.T) {
+	d := time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC)
+	v, err := i2datetime(d)
+	assert.NoError(t, err)
+	assert.Equal(t, d, v)
+
+	v, err = i2datetime("2022-01-02T00:00:00Z")
+	assert.NoError(t, err)
+	assert.Equal(t, d, v)
+
+	_, err = i2datetime("x")
+	assert.Error(t, err)
+	_, err = i2datetime(int64(1))
+	assert.Error(t, err)
+	_, err = i2datetime(1.0)
+	assert.Error(t, err)
+}
@@ -1,16 +1,14 @@
 package engine
 
 import (
-	"strings"
 	"sync"
-	"time"
 
 	"github.com/blue4209211/pq/df"
-	"github.com/blue4209211/pq/internal/log"
 )
 
 type queryEngine interface {
 	Query(query string) (df.DataFrame, error)
+	Exec(query string) error
 	RegisterDataFrame(df.DataFrame) error
 	Close()
 }
@@ -20,8 +18,7 @@ func modifyQuery(query string) string {
 		return query
 	}
 
-	query2 := strings.ToLower(query)
-	if !(strings.HasPrefix(query2, "select") || strings.HasPrefix(query2, "with")) {
+	if !IsQuery(query) {
 		query = "select " + query
 	}
 	return query
@@ -29,27 +26,11 @@ func modifyQuery(query string) string {
 
 // QueryDataFrames on given files or directories
 func QueryDataFrames(query string, dfs []df.DataFrame, config map[string]string) (data df.DataFrame, err error) {
-	startTime := time.Now()
-
-	defer func() {
-		log.Debug("Query Execution Time ", time.Since(startTime).String())
-	}()
-
-	log.Debug("Starting Querying engine")
-	engine, err := newSQLiteEngine(config, dfs)
-
+	session, err := NewSession(config, dfs...)
 	if err != nil {
 		return data, err
 	}
-
-	defer engine.Close()
-
-	for _, d := range dfs {
-		err = engine.RegisterDataFrame(d)
-		if err != nil {
-			return data, err
-		}
-	}
+	defer session.Close()
 
 	// some kind of DB issue which is not working correctly when  using multiple channels
 
@@ -83,7 +64,7 @@ func QueryDataFrames(query string, dfs []df.DataFrame, config map[string]string)
 	// 	}
 	// }
 
-	return engine.Query(modifyQuery(query))
+	return session.Query(query)
 }
 
 func registerDfAsync(qe *queryEngine, jobs <-chan df.DataFrame, results chan<- error, wg *sync.WaitGroup, config *map[string]string) {
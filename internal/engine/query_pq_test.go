@@ -1,12 +1,17 @@
 package engine
 
 import (
+	"database/sql"
+	"errors"
+	"io"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/blue4209211/pq/df"
 	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/blue4209211/pq/df/lazy"
 	"github.com/blue4209211/pq/sources/fs/formats"
 	"github.com/stretchr/testify/assert"
 )
@@ -52,6 +57,152 @@ func TestQueryFilterOpPQ(t *testing.T) {
 
 }
 
+func TestQueryStreamingFilePQ(t *testing.T) {
+	dataframe, err := queryFiles("select * from csv1 where c2 = 'b' limit 1", []string{"../../testdata/csv1.csv"}, map[string]string{
+		ConfigEngineStorage: "pq",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), dataframe.Len())
+	assert.Equal(t, "b", dataframe.GetSeriesByName("c2").Get(0).Get())
+
+	// table is scanned multiple times
+	dataframe, err = queryFiles("select a.c1, b.c2 from csv1 a join csv1 b on a.c1 = b.c1 order by a.c1 desc", []string{"../../testdata/csv1.csv"}, map[string]string{
+		ConfigEngineStorage: "pq",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), dataframe.Len())
+	assert.Equal(t, "d", dataframe.GetSeriesByName("c2").Get(0).Get())
+}
+
+func TestQueryPushdownPQ(t *testing.T) {
+	data := inmemory.NewDataframeWithNameFromSeries("t1", []string{"c1", "c2", "c3"}, &[]df.Series{
+		inmemory.NewIntSeriesVarArg(1, 2, 3),
+		inmemory.NewStringSeriesVarArg("a", "b", "c"),
+		inmemory.NewStringSeriesVarArg("x", "y", "z"),
+	})
+
+	var pushedCols []int
+	var pushedFilters []df.Filter
+	pushdownData := lazy.NewPushdownDataframe("t1", data.Schema(), func(cols []int, filters []df.Filter) (df.RowStream, error) {
+		pushedCols = cols
+		pushedFilters = filters
+		return lazy.NewRowStream(data.WhereRow(func(r df.Row) bool {
+			for _, f := range filters {
+				if f.Op != "=" || r.GetRaw(f.Column) != f.Value {
+					return false
+				}
+			}
+			return true
+		}))
+	})
+
+	dataframe, err := QueryDataFrames("select c1 from t1 where c2 = 'b' and c3 like 'y%'", []df.DataFrame{pushdownData}, map[string]string{
+		ConfigEngineStorage: "pq",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), dataframe.Len())
+	assert.Equal(t, int64(2), dataframe.GetRow(0).GetRaw(0))
+	assert.Equal(t, []int{0, 1, 2}, pushedCols)
+	assert.Equal(t, []df.Filter{{Column: 1, Op: "=", Value: "b"}}, pushedFilters)
+
+	dataframe, err = QueryDataFrames("select c1 from t1 where c2 = 'c'", []df.DataFrame{pushdownData}, map[string]string{
+		ConfigEngineStorage: "pq",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), dataframe.Len())
+	assert.Equal(t, []int{0, 1}, pushedCols)
+}
+
+// errorStream returns error after rows of stream
+type errorStream struct {
+	df.RowStream
+}
+
+func (t *errorStream) Next() (df.Row, error) {
+	r, err := t.RowStream.Next()
+	if err == io.EOF {
+		return r, errors.New("read failed")
+	}
+	return r, err
+}
+
+func TestQueryReadErrorPQ(t *testing.T) {
+	data := inmemory.NewDataframeWithNameFromSeries("t1", []string{"c1"}, &[]df.Series{
+		inmemory.NewIntSeriesVarArg(1, 2, 3),
+	})
+	opened := 0
+	errorData := lazy.NewDataframe("t1", data.Schema(), func() (df.RowStream, error) {
+		opened++
+		stream, err := lazy.NewRowStream(data)
+		if opened == 1 {
+			return stream, err
+		}
+		return &errorStream{RowStream: stream}, err
+	})
+
+	// first scan reads stream, second scan loads rows in memory and fails
+	_, err := QueryDataFrames("select c1 from t1 union all select c1 from t1", []df.DataFrame{errorData}, map[string]string{
+		ConfigEngineStorage: "pq",
+	})
+	assert.ErrorContains(t, err, "read failed")
+}
+
+func TestQueryDatabaseTablesPQ(t *testing.T) {
+	tempfile, err := os.CreateTemp("", "test*.sql")
+	assert.NoError(t, err)
+	defer os.Remove(tempfile.Name())
+	tempfile.Close()
+
+	db, err := sql.Open("sqlite3", tempfile.Name())
+	assert.NoError(t, err)
+	_, err = db.Exec("create table customers (id integer, name text); insert into customers values (1, 'c1'), (2, 'c2');" +
+		"create table orders (id integer, customer_id integer, amount double); insert into orders values (1, 1, 10.5), (2, 1, 2), (3, 2, 4);")
+	assert.NoError(t, err)
+	db.Close()
+
+	dataframe, err := queryFiles("select c.name, sum(o.amount) from customers c join orders o on c.id = o.customer_id where o.amount > 3 group by c.name order by c.name",
+		[]string{"sqlite:" + tempfile.Name()}, map[string]string{
+			ConfigEngineStorage: "pq",
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), dataframe.Len())
+	assert.Equal(t, "c1", dataframe.GetRow(0).GetRaw(0))
+	assert.Equal(t, 10.5, dataframe.GetRow(0).GetAsDouble(1))
+}
+
+func TestQueryParquetPushdownPQ(t *testing.T) {
+	dataframe, err := queryFiles("select d from parquet1 where a >= 2 and c = ''", []string{"../../testdata/parquet1.parquet"}, map[string]string{
+		ConfigEngineStorage: "pq",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), dataframe.Len())
+	assert.Equal(t, "d2", dataframe.GetRow(0).GetRaw(0))
+
+	dataframe, err = queryFiles("select a, b from parquet1 where d = 'd,2' or a = 3 order by a", []string{"../../testdata/parquet1.parquet"}, map[string]string{
+		ConfigEngineStorage: "pq",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), dataframe.Len())
+	assert.Equal(t, int64(2), dataframe.GetRow(0).GetRaw(0))
+	assert.Equal(t, 2.0, dataframe.GetRow(0).GetRaw(1))
+}
+
+func TestReferencedColumns(t *testing.T) {
+	schema := df.NewSchema([]df.SeriesSchema{
+		{Name: "c1", Format: df.IntegerFormat},
+		{Name: "C 2", Format: df.StringFormat},
+		{Name: "c3", Format: df.StringFormat},
+		{Name: "prénom", Format: df.StringFormat},
+	})
+
+	assert.Nil(t, referencedColumns("select * from t1", schema))
+	assert.Nil(t, referencedColumns("select a.c1, b.* from t1 a, t2 b", schema))
+	assert.Nil(t, referencedColumns("select c1 from t1 natural join t2", schema))
+	assert.Equal(t, []int{}, referencedColumns("select count(*) from t1", schema))
+	assert.Equal(t, []int{0, 1}, referencedColumns(`select C1, "c 2" from t1`, schema))
+	assert.Equal(t, []int{3}, referencedColumns("select prénom from t1", schema))
+}
+
 func TestQuerySingleCSVFilePQ(t *testing.T) {
 	dataframe, err := queryFiles("select * from csv1", []string{"../../testdata/csv1.csv"}, map[string]string{
 		ConfigEngineStorage: "pq",
@@ -86,6 +237,27 @@ func TestQuerySingleCSVFilePQ(t *testing.T) {
 
 }
 
+func TestQueryEmptyCSVValuePQ(t *testing.T) {
+	file := t.TempDir() + "/empty.csv"
+	assert.NoError(t, os.WriteFile(file, []byte("a,b,c\n1,x,2.5\n3,z,\n4,n,\\N\n"), 0o644))
+
+	// empty values are text, only configured null values are null
+	for _, c := range []struct {
+		nullValues string
+		expected   []any
+	}{
+		{"", []any{"text", "text"}},
+		{`\N`, []any{"text", "null"}},
+	} {
+		dataframe, err := queryFiles("select typeof(c) from empty where b in ('z', 'n') order by a", []string{file}, map[string]string{
+			ConfigEngineStorage:         "pq",
+			formats.ConfigCsvNullValues: c.nullValues,
+		})
+		assert.NoError(t, err, c.nullValues)
+		assert.Equal(t, c.expected, []any{dataframe.GetRow(0).GetRaw(0), dataframe.GetRow(1).GetRaw(0)}, c.nullValues)
+	}
+}
+
 func TestQuerySingleJSONFilePQ(t *testing.T) {
 	dataframe, err := queryFiles("select * from json1", []string{"../../testdata/json1.json"}, map[string]string{
 		formats.ConfigJSONSingleLine: "false",
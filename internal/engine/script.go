@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"strings"
+)
+
+// queryKeywords statements starting with these keywords return rows
+var queryKeywords = []string{"select", "with", "values", "pragma", "explain"}
+
+// SplitStatements splits script into statements separated by ';', separators within quotes and comments are ignored.
+// comments before statement are part of statement, statements having only comments are removed
+func SplitStatements(script string) []string {
+	statements := make([]string, 0)
+	start := 0
+	for i := 0; i < len(script); i++ {
+		switch script[i] {
+		case '\'', '"', '`':
+			i = skipUntil(script, i+1, string(script[i]))
+		case '[':
+			i = skipUntil(script, i+1, "]")
+		case '-':
+			if strings.HasPrefix(script[i:], "--") {
+				i = skipUntil(script, i+2, "\n")
+			}
+		case '/':
+			if strings.HasPrefix(script[i:], "/*") {
+				i = skipUntil(script, i+2, "*/") + 1
+			}
+		case ';':
+			statements = appendStatement(statements, script[start:i])
+			start = i + 1
+		}
+	}
+	return appendStatement(statements, script[start:])
+}
+
+// skipUntil returns index of end string, length of script is returned if end is not found
+func skipUntil(script string, from int, end string) int {
+	if from > len(script) {
+		return len(script)
+	}
+	i := strings.Index(script[from:], end)
+	if i < 0 {
+		return len(script)
+	}
+	return from + i
+}
+
+func appendStatement(statements []string, stmt string) []string {
+	stmt = strings.TrimSpace(stmt)
+	if stripComments(stmt) == "" {
+		return statements
+	}
+	return append(statements, stmt)
+}
+
+// stripComments removes comments at start of statement
+func stripComments(stmt string) string {
+	for {
+		stmt = strings.TrimSpace(stmt)
+		if strings.HasPrefix(stmt, "--") {
+			stmt = stmt[skipUntil(stmt, 2, "\n"):]
+		} else if strings.HasPrefix(stmt, "/*") {
+			stmt = stmt[min(skipUntil(stmt, 2, "*/")+2, len(stmt)):]
+		} else {
+			return stmt
+		}
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// IsQuery returns true if statement returns rows (ex - select/with/values/pragma), other statements
+// (ex - create view/table) should be executed using Session.Exec
+func IsQuery(stmt string) bool {
+	stmt = strings.ToLower(stripComments(stmt))
+	for _, k := range queryKeywords {
+		if strings.HasPrefix(stmt, k) && (len(stmt) == len(k) || !isIdentifierChar(stmt[len(k)])) {
+			return true
+		}
+	}
+	return false
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
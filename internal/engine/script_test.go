@@ -0,0 +1,33 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- first; statement
+create view v as select 'a;b' as "c;1" from t1;
+/* second; */ select [x;y], ` + "`z;`" + ` from v ;
+-- only comment;
+;
+select 1`
+
+	assert.Equal(t, []string{
+		"-- first; statement\ncreate view v as select 'a;b' as \"c;1\" from t1",
+		"/* second; */ select [x;y], `z;` from v",
+		"select 1",
+	}, SplitStatements(script))
+	assert.Equal(t, []string{"select 'a"}, SplitStatements("select 'a"))
+	assert.Empty(t, SplitStatements(" ; -- c"))
+}
+
+func TestIsQuery(t *testing.T) {
+	assert.True(t, IsQuery("select 1"))
+	assert.True(t, IsQuery(" -- c\n/* c */ WITH a as (select 1) select * from a"))
+	assert.True(t, IsQuery("pragma table_info('t1')"))
+	assert.False(t, IsQuery("create temp view v as select 1"))
+	assert.False(t, IsQuery("selected_col"))
+	assert.Equal(t, "select 1+1", modifyQuery("1+1"))
+}
@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"errors"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/lazy"
+	"github.com/blue4209211/pq/internal/log"
+)
+
+// Session keeps dataframes registered in engine, so that multiple queries can be executed without reading sources again
+type Session struct {
+	engine queryEngine
+	data   []df.DataFrame
+	// cached sessions read streaming dataframes once while registering, otherwise each query reads from source
+	cached bool
+}
+
+// NewSession creates engine based on config and registers given dataframes
+func NewSession(config map[string]string, dfs ...df.DataFrame) (session *Session, err error) {
+	log.Debug("Starting Querying engine")
+	engine, err := newSQLiteEngine(config, dfs)
+	if err != nil {
+		return session, err
+	}
+	return newSession(engine, false, dfs)
+}
+
+// NewCachedSession creates session whose dataframes are read once while registering, used by interactive mode
+// where sources are not read again for every query
+func NewCachedSession(config map[string]string, dfs ...df.DataFrame) (session *Session, err error) {
+	log.Debug("Starting Querying engine")
+	engine, err := newSQLiteEngine(config, dfs)
+	if err != nil {
+		return session, err
+	}
+	return newSession(engine, true, dfs)
+}
+
+func newSession(engine queryEngine, cached bool, dfs []df.DataFrame) (session *Session, err error) {
+	session = &Session{engine: engine, data: make([]df.DataFrame, 0, len(dfs)), cached: cached}
+	err = session.Register(dfs...)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	return session, nil
+}
+
+// Register registers dataframes as tables, existing tables with same name are replaced
+func (t *Session) Register(dfs ...df.DataFrame) (err error) {
+	for _, d := range dfs {
+		if t.cached {
+			d, err = lazy.Materialize(d)
+			if err != nil {
+				return err
+			}
+		}
+		if _, ok := t.Table(d.Name()); ok {
+			err = t.engine.Exec(`drop table "` + d.Name() + `"`)
+			if err != nil {
+				return err
+			}
+			t.data = removeTable(t.data, d.Name())
+		}
+
+		err = t.engine.RegisterDataFrame(d)
+		if err != nil {
+			return err
+		}
+		t.data = append(t.data, d)
+	}
+	return err
+}
+
+// Query executes select query on registered tables
+func (t *Session) Query(query string) (data df.DataFrame, err error) {
+	if t.engine == nil {
+		return data, errors.New("session is closed")
+	}
+
+	startTime := time.Now()
+	defer func() {
+		log.Debug("Query Execution Time ", time.Since(startTime).String())
+	}()
+	return t.engine.Query(modifyQuery(query))
+}
+
+// Exec executes statement which doesnt return rows (ex - create view/table), tables created by statements
+// can be used in later queries
+func (t *Session) Exec(stmt string) (err error) {
+	if t.engine == nil {
+		return errors.New("session is closed")
+	}
+	return t.engine.Exec(stmt)
+}
+
+// Tables returns registered tables in order of registration
+func (t *Session) Tables() []df.DataFrame {
+	return t.data
+}
+
+// Table returns registered table with given name
+func (t *Session) Table(name string) (df.DataFrame, bool) {
+	for _, d := range t.data {
+		if d.Name() == name {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+// Close closes engine, session can not be used after close
+func (t *Session) Close() {
+	if t.engine != nil {
+		t.engine.Close()
+		t.engine = nil
+	}
+}
+
+func removeTable(dfs []df.DataFrame, name string) []df.DataFrame {
+	data := make([]df.DataFrame, 0, len(dfs))
+	for _, d := range dfs {
+		if d.Name() != name {
+			data = append(data, d)
+		}
+	}
+	return data
+}
@@ -0,0 +1,153 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/blue4209211/pq/df/lazy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	for _, storage := range []string{"pq", "memory"} {
+		data := inmemory.NewDataframeWithNameFromSeries("t1", []string{"c1", "c2"}, &[]df.Series{
+			inmemory.NewIntSeriesVarArg(1, 2, 3),
+			inmemory.NewStringSeriesVarArg("a", "b", "c"),
+		})
+
+		session, err := NewSession(map[string]string{ConfigEngineStorage: storage}, data)
+		assert.NoError(t, err)
+
+		dataframe, err := session.Query("select * from t1 where c1 > 1")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), dataframe.Len())
+
+		dataframe, err = session.Query("count(*) from t1")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), dataframe.Len())
+
+		// same name replaces existing table
+		data2 := inmemory.NewDataframeWithNameFromSeries("t1", []string{"c3"}, &[]df.Series{
+			inmemory.NewStringSeriesVarArg("x"),
+		})
+		data3 := data.Rename("t2", false)
+		err = session.Register(data2, data3)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(session.Tables()))
+		d, ok := session.Table("t1")
+		assert.True(t, ok)
+		assert.Equal(t, []string{"c3"}, d.Schema().Names())
+
+		dataframe, err = session.Query("select t1.c3, t2.c1 from t1, t2 order by t2.c1")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), dataframe.Len())
+		assert.Equal(t, "x", dataframe.GetRow(0).GetRaw(0))
+
+		session.Close()
+		_, err = session.Query("select * from t1")
+		assert.Error(t, err)
+	}
+}
+
+func TestSessionExec(t *testing.T) {
+	for _, storage := range []string{"pq", "memory"} {
+		data := inmemory.NewDataframeWithNameFromSeries("t1", []string{"c1", "c2"}, &[]df.Series{
+			inmemory.NewIntSeriesVarArg(1, 2, 3),
+			inmemory.NewStringSeriesVarArg("a", "b", "c"),
+		})
+		var pushedCols [][]int
+		pushdownData := lazy.NewPushdownDataframe("t1", data.Schema(), func(cols []int, filters []df.Filter) (df.RowStream, error) {
+			pushedCols = append(pushedCols, cols)
+			return lazy.NewRowStream(data)
+		})
+
+		session, err := NewSession(map[string]string{ConfigEngineStorage: storage}, pushdownData)
+		assert.NoError(t, err)
+
+		// view uses column which is not part of later query
+		err = session.Exec("create temp view v as select c1 from t1 where c2 like 'b%' or c2 glob 'c*'")
+		assert.NoError(t, err)
+		err = session.Exec("create table t2 as select c1 * 10 as c3 from v")
+		assert.NoError(t, err)
+
+		dataframe, err := session.Query("select c1 from v order by c1")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), dataframe.Len())
+		assert.Equal(t, int64(2), dataframe.GetRow(0).GetRaw(0))
+
+		dataframe, err = session.Query("select sum(c3) from t2")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(50), dataframe.GetRow(0).GetAsInt(0))
+
+		err = session.Exec("create temp view v as select 1")
+		assert.Error(t, err)
+		if storage == "pq" {
+			assert.Equal(t, []int{0, 1}, pushedCols[0])
+		}
+		session.Close()
+	}
+}
+
+func TestSessionPushdownEachQuery(t *testing.T) {
+	data := inmemory.NewDataframeWithNameFromSeries("t1", []string{"c1", "c2"}, &[]df.Series{
+		inmemory.NewIntSeriesVarArg(1, 2, 3),
+		inmemory.NewStringSeriesVarArg("a", "b", "c"),
+	})
+	var pushedFilters [][]df.Filter
+	pushdownData := lazy.NewPushdownDataframe("t1", data.Schema(), func(cols []int, filters []df.Filter) (df.RowStream, error) {
+		pushedFilters = append(pushedFilters, filters)
+		stream, err := lazy.NewRowStream(data)
+		return lazy.NewFilterStream(stream, filters), err
+	})
+
+	session, err := NewSession(map[string]string{ConfigEngineStorage: "pq"}, pushdownData)
+	assert.NoError(t, err)
+	defer session.Close()
+
+	// every query of non cached session streams from source with its own filters
+	dataframe, err := session.Query("select c2 from t1 where c1 > 1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), dataframe.Len())
+	dataframe, err = session.Query("select c2 from t1 where c1 = 3")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), dataframe.Len())
+
+	assert.Equal(t, 2, len(pushedFilters))
+	assert.Equal(t, []df.Filter{{Column: 0, Op: ">", Value: int64(1)}}, pushedFilters[0])
+	assert.Equal(t, []df.Filter{{Column: 0, Op: "=", Value: int64(3)}}, pushedFilters[1])
+}
+
+func TestCachedSession(t *testing.T) {
+	data := inmemory.NewDataframeWithNameFromSeries("t1", []string{"c1", "c2"}, &[]df.Series{
+		inmemory.NewIntSeriesVarArg(1, 2, 3),
+		inmemory.NewStringSeriesVarArg("a", "b", "c"),
+	})
+	opened := 0
+	open := func(cols []int, filters []df.Filter) (df.RowStream, error) {
+		opened++
+		stream, err := lazy.NewRowStream(data)
+		return lazy.NewFilterStream(stream, filters), err
+	}
+
+	for _, storage := range []string{"pq", "memory"} {
+		opened = 0
+		session, err := NewCachedSession(map[string]string{ConfigEngineStorage: storage}, lazy.NewPushdownDataframe("t1", data.Schema(), open))
+		assert.NoError(t, err, storage)
+
+		// source is read once while registering
+		dataframe, err := session.Query("select c2 from t1 where c1 > 1")
+		assert.NoError(t, err, storage)
+		assert.Equal(t, int64(2), dataframe.Len(), storage)
+		dataframe, err = session.Query("select c2 from t1 where c1 = 3")
+		assert.NoError(t, err, storage)
+		assert.Equal(t, "c", dataframe.GetRow(0).GetRaw(0), storage)
+
+		assert.NoError(t, session.Register(lazy.NewPushdownDataframe("t2", data.Schema(), open)), storage)
+		dataframe, err = session.Query("select t2.c2 from t1 join t2 using (c1) where t1.c2 = 'b'")
+		assert.NoError(t, err, storage)
+		assert.Equal(t, "b", dataframe.GetRow(0).GetRaw(0), storage)
+		assert.Equal(t, 2, opened, storage)
+		session.Close()
+	}
+}
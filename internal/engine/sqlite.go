@@ -4,12 +4,14 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"strings"
 
 	"github.com/blue4209211/pq/df"
 	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/blue4209211/pq/df/lazy"
 	"github.com/blue4209211/pq/internal/fns"
 	"github.com/blue4209211/pq/internal/log"
 	"github.com/mattn/go-sqlite3"
@@ -50,9 +52,6 @@ func (t *sqliteQueryEngine) createTable(tableName string, cols []df.SeriesSchema
 func (t *sqliteQueryEngine) insertData(dataFrame df.DataFrame) (err error) {
 
 	schema := dataFrame.Schema()
-	if dataFrame.Len() == 0 {
-		return
-	}
 
 	colString := ""
 	quesString := ""
@@ -66,19 +65,35 @@ func (t *sqliteQueryEngine) insertData(dataFrame df.DataFrame) (err error) {
 	quesString = quesString[0 : len(quesString)-1]
 
 	batchSize := 1000
-	totalRecords := int(dataFrame.Len())
 
-	for i := 0; i < totalRecords; i = i + batchSize {
+	// rows are read as stream, so that data is not buffered in memory before insert
+	stream, err := lazy.NewRowStream(dataFrame)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	for isEOF := false; !isEOF; {
 		valueStrings := make([]string, 0, batchSize)
 		valueArgs := make([]any, 0, batchSize*schema.Len())
 
-		for j := i; j < (i+batchSize) && j < totalRecords; j++ {
+		for len(valueStrings) < batchSize {
+			r, err := stream.Next()
+			if err == io.EOF {
+				isEOF = true
+				break
+			}
+			if err != nil {
+				return err
+			}
 			valueStrings = append(valueStrings, "("+quesString+")")
-			r := dataFrame.GetRow(int64(j))
 			for k := 0; k < r.Len(); k++ {
 				valueArgs = append(valueArgs, r.GetRaw(k))
 			}
 		}
+		if len(valueStrings) == 0 {
+			break
+		}
 		stmt := fmt.Sprintf("INSERT INTO \"%s\" (%s) VALUES %s", dataFrame.Name(), colString, strings.Join(valueStrings, ","))
 
 		_, err = t.db.Exec(stmt, valueArgs...)
@@ -118,6 +133,11 @@ func (t *sqliteQueryEngine) Query(query string) (result df.DataFrame, err error)
 	return queryInternal(t.db, query)
 }
 
+func (t *sqliteQueryEngine) Exec(query string) (err error) {
+	_, err = t.db.Exec(query)
+	return err
+}
+
 func queryInternal(db *sql.DB, query string) (result df.DataFrame, err error) {
 	rows, err := db.Query(query)
 	if err != nil {
@@ -241,7 +261,7 @@ func newSQLiteEngine(config map[string]string, data []df.DataFrame) (engine quer
 		if err != nil {
 			return engine, err
 		}
-		engine = &sqlitePQQueryEngine{&module, db}
+		engine = &sqlitePQQueryEngine{module: &module, db: db}
 	} else if format == "file" {
 		dataFile, err := ioutil.TempFile("", "pq.*.sql")
 		if err != nil {
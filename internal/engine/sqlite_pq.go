@@ -4,7 +4,9 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -17,19 +19,74 @@ import (
 type sqlitePQQueryEngine struct {
 	module *pqModule
 	db     *sql.DB
+	// statements executed on engine (ex - create view), used for deriving columns used by later queries
+	statements []string
 }
 
 func (t *sqlitePQQueryEngine) Close() {
 	t.module.data = []df.DataFrame{}
+	t.module.tables = map[string]*pqTable{}
 	t.db.Close()
 }
 
 func (t *sqlitePQQueryEngine) Query(query string) (result df.DataFrame, err error) {
+	t.prepare(query)
 	return queryInternal(t.db, query)
 }
 
+// prepare resets scans of tables and sets columns used by query, sqlite doesnt provide columns used by query to virtual tables
+// so columns are derived from query and previously executed statements (views can refer other columns)
+func (t *sqlitePQQueryEngine) prepare(query string) {
+	text := strings.Join(append(t.statements, query), ";\n")
+	for _, table := range t.module.tables {
+		// each statement starts with reading from source
+		table.scans = 0
+		table.columns = referencedColumns(text, table.data.Schema())
+	}
+}
+
+var identifierRegex = regexp.MustCompile("[\\p{L}_][\\p{L}\\p{N}_$]*|\"(?:[^\"]|\"\")*\"|`[^`]*`|\\[[^\\]]*\\]")
+var allColumnsRegex = regexp.MustCompile(`(?i)(\bselect\s+(distinct\s+|all\s+)?|,\s*|\.)\*|\bnatural\b`)
+
+// referencedColumns returns index of columns whose names appear in query, nil is returned if all the columns
+// are required (ex - select *), names are matched without considering table so list can contain additional columns.
+// this is best-effort - sqlite3 driver does not expose columns used by a scan (colUsed of xBestIndex), so query is scanned
+// for identifiers without parsing it. names inside string literals or comments are also treated as referenced, which only
+// reads additional columns
+func referencedColumns(query string, schema df.DataFrameSchema) []int {
+	if allColumnsRegex.MatchString(query) {
+		return nil
+	}
+
+	names := map[string]bool{}
+	for _, id := range identifierRegex.FindAllString(query, -1) {
+		if len(id) > 1 && (id[0] == '"' || id[0] == '`' || id[0] == '[') {
+			id = strings.ReplaceAll(id[1:len(id)-1], `""`, `"`)
+		}
+		names[strings.ToLower(id)] = true
+	}
+
+	cols := make([]int, 0, schema.Len())
+	for i, c := range schema.Series() {
+		if names[strings.ToLower(c.Name)] {
+			cols = append(cols, i)
+		}
+	}
+	return cols
+}
+
+func (t *sqlitePQQueryEngine) Exec(query string) (err error) {
+	t.prepare(query)
+	_, err = t.db.Exec(query)
+	if err == nil {
+		t.statements = append(t.statements, query)
+	}
+	return err
+}
+
 func (t *sqlitePQQueryEngine) RegisterDataFrame(dataFrame df.DataFrame) error {
-	t.module.data = append(t.module.data, dataFrame)
+	// dataframe with same name replaces existing one, module looks up dataframe by name while creating table
+	t.module.data = append(removeTable(t.module.data, dataFrame.Name()), dataFrame)
 
 	schema := dataFrame.Schema()
 	if schema.Len() == 0 {
@@ -61,7 +118,8 @@ func (t *sqlitePQQueryEngine) createTable(tableName string, cols []df.SeriesSche
 }
 
 type pqModule struct {
-	data []df.DataFrame
+	data   []df.DataFrame
+	tables map[string]*pqTable
 }
 
 func (t *pqModule) createTable(c *sqlite3.SQLiteConn, tableName string, cols []df.SeriesSchema) (err error) {
@@ -90,7 +148,12 @@ func (t *pqModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, e
 			if err != nil {
 				return nil, err
 			}
-			return &pqTable{data: &d}, nil
+			table := &pqTable{data: d}
+			if t.tables == nil {
+				t.tables = map[string]*pqTable{}
+			}
+			t.tables[d.Name()] = table
+			return table, nil
 		}
 	}
 
@@ -104,11 +167,47 @@ func (t *pqModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab,
 func (t *pqModule) DestroyModule() {}
 
 type pqTable struct {
-	data *df.DataFrame
+	data df.DataFrame
+	// scans number of scans started on table by current statement, only first scan of streaming dataframe reads
+	// directly from source, following scans (ex - inner table of join) use data loaded in memory
+	scans int
+	// columns referenced by current query, nil if all the columns are required
+	columns []int
+}
+
+func (t *pqTable) isStreaming() bool {
+	_, ok := t.data.(df.StreamingDataFrame)
+	return ok
+}
+
+// pushdownOps operators which can be evaluated by source
+var pushdownOps = map[string]string{
+	"=":       "=",
+	"<":       "<",
+	"<=":      "<=",
+	">":       ">",
+	">=":      ">=",
+	"not":     "<>",
+	"isnull":  "isnull",
+	"notnull": "notnull",
+}
+
+// pushdown splits filters into filters evaluated by source and remaining filters evaluated on rows
+func pushdown(ops []filterOp, vals []any) (filters []df.Filter, localOps []filterOp, localVals []any) {
+	for i, o := range ops {
+		op, ok := pushdownOps[o.op]
+		if ok && (vals[i] != nil || op == "isnull" || op == "notnull") {
+			filters = append(filters, df.Filter{Column: o.idx, Op: op, Value: vals[i]})
+		} else {
+			localOps = append(localOps, o)
+			localVals = append(localVals, vals[i])
+		}
+	}
+	return filters, localOps, localVals
 }
 
 func (t *pqTable) Open() (cur sqlite3.VTabCursor, err error) {
-	return &pqCursor{0, t.data}, nil
+	return &pqCursor{table: t, data: t.data}, nil
 }
 
 func opToString(o sqlite3.Op) string {
@@ -154,18 +253,35 @@ func (t *pqTable) BestIndex(cstl []sqlite3.InfoConstraint, obl []sqlite3.InfoOrd
 
 	for c, cst := range cstl {
 		if cst.Usable {
-			used[c] = true
+			// unsupported constraints (ex - limit/offset) are left to sqlite
 			opStr := opToString(cst.Op)
 			if opStr == "" {
-				log.Warn("No operator found for - ", cstl, obl)
-				idxStr = ""
-				break
+				log.Debug("No operator found for - ", cst, cstl, obl)
+				continue
+			}
+			// datetime/bool columns are exposed as text/int, comparison is done by sqlite
+			if cst.Column >= 0 {
+				format := t.data.Schema().Get(cst.Column).Format
+				if format == df.DateTimeFormat || format == df.BoolFormat {
+					continue
+				}
+				// range on strings (ex - generated for like 'a%') depends on collation, comparison is done by sqlite
+				if format == df.StringFormat && (opStr == "<" || opStr == "<=" || opStr == ">" || opStr == ">=") {
+					continue
+				}
+				// empty strings are exposed as NULL by sqlite, null checks on strings are done by sqlite
+				if format == df.StringFormat && (opStr == "isnull" || opStr == "notnull") {
+					continue
+				}
 			}
+			used[c] = true
 			idxStr = idxStr + strconv.Itoa(cst.Column) + ":" + opStr + ","
 		}
 	}
 
-	if len(obl) > 0 {
+	// streaming tables are not sorted, sqlite sorts the rows
+	alreadyOrdered := !t.isStreaming()
+	if len(obl) > 0 && alreadyOrdered {
 		idxStr = idxStr + ";"
 		for _, ob := range obl {
 			idxStr = idxStr + strconv.Itoa(ob.Column) + ":" + strconv.FormatBool(ob.Desc) + ","
@@ -180,29 +296,41 @@ func (t *pqTable) BestIndex(cstl []sqlite3.InfoConstraint, obl []sqlite3.InfoOrd
 		IdxNum:         0,
 		IdxStr:         idxStr,
 		Used:           used,
-		AlreadyOrdered: true,
+		AlreadyOrdered: alreadyOrdered,
 	}, nil
 }
 
 func (t *pqTable) Disconnect() error { return nil }
 func (t *pqTable) Destroy() error    { return nil }
 
+// sqliteEmptyText empty string backed by data, so that empty values are returned as text instead of null
+var sqliteEmptyText = string([]byte{0})[:0]
+
 type pqCursor struct {
-	index int
-	data  *df.DataFrame
+	table  *pqTable
+	index  int
+	data   df.DataFrame
+	stream df.RowStream
+	filter func(df.Row) bool
+	row    df.Row
+	eof    bool
 }
 
-func (t pqCursor) Column(c *sqlite3.SQLiteContext, col int) (err error) {
-	cType := (*t.data).Schema().Get(col)
-	//i, _ := cType.For  mat.Convert((*t.data)[t.index][col])
-	i := (*t.data).GetRow(int64(t.index)).Get(col)
+func (t *pqCursor) Column(c *sqlite3.SQLiteContext, col int) (err error) {
+	cType := t.data.Schema().Get(col)
+	i := t.row.Get(col)
 	if i == nil || i.IsNil() {
 		c.ResultNull()
 		return err
 	}
 	switch cType.Format {
 	case df.StringFormat:
-		c.ResultText(i.GetAsString())
+		str := i.GetAsString()
+		if str == "" {
+			// sqlite reads text without backing data as null
+			str = sqliteEmptyText
+		}
+		c.ResultText(str)
 	case df.IntegerFormat:
 		c.ResultInt64(i.GetAsInt())
 	case df.DoubleFormat:
@@ -210,7 +338,7 @@ func (t pqCursor) Column(c *sqlite3.SQLiteContext, col int) (err error) {
 	case df.BoolFormat:
 		c.ResultBool(i.GetAsBool())
 	case df.DateTimeFormat:
-		c.ResultText(i.GetAsDatetime().String())
+		c.ResultText(i.GetAsDatetime().Format(sqlite3.SQLiteTimestampFormats[0]))
 	}
 	return nil
 }
@@ -221,130 +349,178 @@ type filterOp struct {
 	schema df.Format
 }
 
-func (t *pqCursor) Filter(idxNum int, filterOrderStr string, vals []any) error {
+// filterValue converts numeric constraint values to column format, ex - 2 for double column is compared as 2.0
+func filterValue(format df.Format, val any) any {
+	if format != df.IntegerFormat && format != df.DoubleFormat {
+		return val
+	}
+	switch val.(type) {
+	case int64, float64:
+		v, err := format.Convert(val)
+		if err == nil {
+			return v
+		}
+	}
+	return val
+}
 
-	if filterOrderStr == "" {
-		t.index = 0
-		return nil
+func filterRow(dfr df.Row, colIdxAndOps []filterOp, vals []any) bool {
+	f := true
+	for i, colOp := range colIdxAndOps {
+		switch colOp.op {
+		case "is", "=":
+			f = f && (dfr.Get(colOp.idx).Get() == filterValue(colOp.schema, vals[i]))
+		case "isnot", "not":
+			f = f && (dfr.Get(colOp.idx).Get() != filterValue(colOp.schema, vals[i]))
+		case "isnull":
+			f = f && (dfr.Get(colOp.idx).Get() == nil)
+		case "notnull":
+			f = f && (dfr.Get(colOp.idx).Get() != nil)
+		case "match":
+			if vals[i] == nil || dfr.Get(colOp.idx) == nil {
+				f = false
+			}
+			f = f && (fns.Matches(vals[i].(string), dfr.GetAsString(colOp.idx)))
+		case "regexp":
+			if vals[i] == nil || dfr.Get(colOp.idx) == nil {
+				f = false
+			}
+			f = f && (fns.Regexp(vals[i].(string), dfr.GetAsString(colOp.idx)))
+		case "like":
+			if vals[i] == nil || dfr.Get(colOp.idx) == nil {
+				f = false
+			}
+			f = f && (fns.Like(vals[i].(string), dfr.GetAsString(colOp.idx)))
+		case "glob":
+			if vals[i] == nil || dfr.Get(colOp.idx) == nil {
+				f = false
+			}
+			f = f && (fns.Glob(dfr.GetAsString(colOp.idx), vals[i].(string)))
+		case "<":
+			if colOp.schema.Type() == reflect.Int64 {
+				v, e := df.IntegerFormat.Convert(vals[i])
+				if e != nil {
+					f = false
+					break
+				}
+				f = f && (dfr.GetAsInt(colOp.idx) < v.(int64))
+			} else {
+				v, e := df.DoubleFormat.Convert(vals[i])
+				if e != nil {
+					f = false
+					break
+				}
+				f = f && (dfr.Get(colOp.idx).GetAsDouble() < v.(float64))
+			}
+		case "<=":
+			if colOp.schema.Type() == reflect.Int64 {
+				v, e := df.IntegerFormat.Convert(vals[i])
+				if e != nil {
+					f = false
+					break
+				}
+				f = f && (dfr.GetAsInt(colOp.idx) <= v.(int64))
+			} else {
+				v, e := df.DoubleFormat.Convert(vals[i])
+				if e != nil {
+					f = false
+					break
+				}
+				f = f && (dfr.GetAsDouble(colOp.idx) <= v.(float64))
+			}
+		case ">":
+			if colOp.schema.Type() == reflect.Int64 {
+				v, e := df.IntegerFormat.Convert(vals[i])
+				if e != nil {
+					f = false
+					break
+				}
+				f = f && (dfr.GetAsInt(colOp.idx) > v.(int64))
+			} else {
+				v, e := df.DoubleFormat.Convert(vals[i])
+				if e != nil {
+					f = false
+					break
+				}
+				f = f && (dfr.GetAsDouble(colOp.idx) > v.(float64))
+			}
+		case ">=":
+			if colOp.schema.Type() == reflect.Int64 {
+				v, e := df.IntegerFormat.Convert(vals[i])
+				if e != nil {
+					f = false
+					break
+				}
+				f = f && (dfr.GetAsInt(colOp.idx) >= v.(int64))
+			} else {
+				v, e := df.DoubleFormat.Convert(vals[i])
+				if e != nil {
+					f = false
+					break
+				}
+				f = f && (dfr.GetAsDouble(colOp.idx) >= v.(float64))
+			}
+
+		}
+	}
+	return f
+}
+
+func (t *pqCursor) Filter(idxNum int, filterOrderStr string, vals []any) (err error) {
+	err = t.closeStream()
+	if err != nil {
+		return err
 	}
+	t.data = t.table.data
+	t.filter = nil
+	t.index = 0
+	t.eof = false
 
 	filterOrderStrArr := strings.Split(filterOrderStr, ";")
 
 	filterStr := filterOrderStrArr[0]
+	colIdxAndOps := make([]filterOp, 0)
 	if filterStr != "" {
 		filterArr := strings.Split(filterStr, ",")
-		colIdxAndOps := make([]filterOp, len(filterArr))
+		colIdxAndOps = make([]filterOp, len(filterArr))
 
 		for i, idxStr := range filterArr {
 			colIdxAndOp := strings.Split(idxStr, ":")
 			idx, _ := strconv.Atoi(colIdxAndOp[0])
-			colIdxAndOps[i] = filterOp{idx: idx, op: colIdxAndOp[1], schema: (*t.data).Schema().Get(idx).Format}
+			colIdxAndOps[i] = filterOp{idx: idx, op: colIdxAndOp[1], schema: t.data.Schema().Get(idx).Format}
 		}
 
-		d := (*t.data).WhereRow(func(dfr df.Row) bool {
-			f := true
-			for i, colOp := range colIdxAndOps {
-				switch colOp.op {
-				case "is", "=":
-					f = f && (dfr.Get(colOp.idx).Get() == vals[i])
-				case "isnot", "not":
-					f = f && (dfr.Get(colOp.idx).Get() != vals[i])
-				case "isnull":
-					f = f && (dfr.Get(colOp.idx).Get() == nil)
-				case "notnull":
-					f = f && (dfr.Get(colOp.idx).Get() != nil)
-				case "match":
-					if vals[i] == nil || dfr.Get(colOp.idx) == nil {
-						f = false
-					}
-					f = f && (fns.Matches(vals[i].(string), dfr.GetAsString(colOp.idx)))
-				case "regexp":
-					if vals[i] == nil || dfr.Get(colOp.idx) == nil {
-						f = false
-					}
-					f = f && (fns.Regexp(vals[i].(string), dfr.GetAsString(colOp.idx)))
-				case "like":
-					if vals[i] == nil || dfr.Get(colOp.idx) == nil {
-						f = false
-					}
-					f = f && (fns.Like(vals[i].(string), dfr.GetAsString(colOp.idx)))
-				case "glob":
-					if vals[i] == nil || dfr.Get(colOp.idx) == nil {
-						f = false
-					}
-					f = f && (fns.Glob(dfr.GetAsString(colOp.idx), vals[i].(string)))
-				case "<":
-					if colOp.schema.Type() == reflect.Int64 {
-						v, e := df.IntegerFormat.Convert(vals[i])
-						if e != nil {
-							f = false
-							break
-						}
-						f = f && (dfr.GetAsInt(colOp.idx) < v.(int64))
-					} else {
-						v, e := df.DoubleFormat.Convert(vals[i])
-						if e != nil {
-							f = false
-							break
-						}
-						f = f && (dfr.Get(colOp.idx).GetAsDouble() < v.(float64))
-					}
-				case "<=":
-					if colOp.schema.Type() == reflect.Int64 {
-						v, e := df.IntegerFormat.Convert(vals[i])
-						if e != nil {
-							f = false
-							break
-						}
-						f = f && (dfr.GetAsInt(colOp.idx) <= v.(int64))
-					} else {
-						v, e := df.DoubleFormat.Convert(vals[i])
-						if e != nil {
-							f = false
-							break
-						}
-						f = f && (dfr.GetAsDouble(colOp.idx) <= v.(float64))
-					}
-				case ">":
-					if colOp.schema.Type() == reflect.Int64 {
-						v, e := df.IntegerFormat.Convert(vals[i])
-						if e != nil {
-							f = false
-							break
-						}
-						f = f && (dfr.GetAsInt(colOp.idx) > v.(int64))
-					} else {
-						v, e := df.DoubleFormat.Convert(vals[i])
-						if e != nil {
-							f = false
-							break
-						}
-						f = f && (dfr.GetAsDouble(colOp.idx) > v.(float64))
-					}
-				case ">=":
-					if colOp.schema.Type() == reflect.Int64 {
-						v, e := df.IntegerFormat.Convert(vals[i])
-						if e != nil {
-							f = false
-							break
-						}
-						f = f && (dfr.GetAsInt(colOp.idx) >= v.(int64))
-					} else {
-						v, e := df.DoubleFormat.Convert(vals[i])
-						if e != nil {
-							f = false
-							break
-						}
-						f = f && (dfr.GetAsDouble(colOp.idx) >= v.(float64))
-					}
+		t.filter = func(dfr df.Row) bool {
+			return filterRow(dfr, colIdxAndOps, vals)
+		}
+	}
 
-				}
+	t.table.scans = t.table.scans + 1
+	if pushdownData, ok := t.data.(df.PushdownDataFrame); ok && t.table.scans == 1 {
+		filters, localOps, localVals := pushdown(colIdxAndOps, vals)
+		t.filter = nil
+		if len(localOps) > 0 {
+			t.filter = func(dfr df.Row) bool {
+				return filterRow(dfr, localOps, localVals)
 			}
-			return f
-		})
+		}
+		t.stream, err = pushdownData.StreamWith(t.table.columns, filters)
+		if err != nil {
+			return err
+		}
+		return t.next()
+	}
+
+	if t.table.isStreaming() && t.table.scans == 1 {
+		t.stream, err = t.data.(df.StreamingDataFrame).Stream()
+		if err != nil {
+			return err
+		}
+		return t.next()
+	}
 
-		t.data = &d
-		t.index = 0
+	if t.filter != nil {
+		t.data = t.data.WhereRow(t.filter)
 	}
 
 	if len(filterOrderStrArr) == 2 {
@@ -361,22 +537,67 @@ func (t *pqCursor) Filter(idxNum int, filterOrderStr string, vals []any) error {
 			orderOps[i] = df.SortByIndex{Series: idx, Order: order}
 		}
 
-		d := (*t.data).Sort(orderOps...)
-
-		t.data = &d
-		t.index = 0
+		t.data = t.data.Sort(orderOps...)
+	}
+	err = dataErr(t.table.data)
+	if err != nil {
+		return err
 	}
 
+	return t.next()
+}
+
+// dataErr returns error encountered while loading rows of streaming dataframe
+func dataErr(data df.DataFrame) error {
+	if s, ok := data.(df.StreamingDataFrame); ok {
+		return s.Err()
+	}
 	return nil
 }
 
+// next moves cursor to next row, streams are read till next row matching filter is found
+func (t *pqCursor) next() (err error) {
+	if t.stream == nil {
+		t.eof = t.index >= int(t.data.Len())
+		if err = dataErr(t.data); err != nil {
+			return err
+		}
+		if !t.eof {
+			t.row = t.data.GetRow(int64(t.index))
+		}
+		return nil
+	}
+
+	for {
+		t.row, err = t.stream.Next()
+		if err == io.EOF {
+			t.eof = true
+			return t.closeStream()
+		}
+		if err != nil {
+			return err
+		}
+		if t.filter == nil || t.filter(t.row) {
+			return nil
+		}
+	}
+}
+
+func (t *pqCursor) closeStream() (err error) {
+	if t.stream != nil {
+		err = t.stream.Close()
+		t.stream = nil
+	}
+	return err
+}
+
 func (t *pqCursor) Next() error {
 	t.index++
-	return nil
+	return t.next()
 }
 
 func (t *pqCursor) EOF() bool {
-	return t.index >= int((*t.data).Len())
+	return t.eof
 }
 
 func (t *pqCursor) Rowid() (int64, error) {
@@ -384,5 +605,5 @@ func (t *pqCursor) Rowid() (int64, error) {
 }
 
 func (t *pqCursor) Close() error {
-	return nil
+	return t.closeStream()
 }
@@ -25,25 +25,77 @@ func main() {
 		log.Debug("Execution Time ", elaspedTime)
 	}()
 
-	confInputCSVSep := flag.String("input."+formats.ConfigCsvSep, ",", "CSV File Seprator")
+	confInputCSVSep := flag.String("input."+formats.ConfigCsvSep, ",", "CSV File Seprator, auto detects seprator, quote and header from data")
 	confInputCSVHeader := flag.Bool("input."+formats.ConfigCsvHeader, true, "First Line as Header")
+	confInputCSVInferSchema := flag.Bool("input."+formats.ConfigCsvInferSchema, false, "Derive CSV column types from sampled records")
+	confInputCSVQuote := flag.String("input."+formats.ConfigCsvQuote, `"`, "CSV Quote character, empty disables quoting")
+	confInputCSVEscape := flag.String("input."+formats.ConfigCsvEscape, "", "CSV character escaping quotes inside quoted values, defaults to doubled quotes")
+	confInputCSVComment := flag.String("input."+formats.ConfigCsvComment, "", "Skip CSV lines starting with this prefix")
+	confInputCSVLazyQuotes := flag.Bool("input."+formats.ConfigCsvLazyQuotes, false, "Allow quotes inside unquoted and quoted CSV values")
+	confInputCSVSkipLines := flag.Int("input."+formats.ConfigCsvSkipLines, 0, "Number of lines to skip before reading CSV header/records")
+	confInputCSVNullValues := flag.String("input."+formats.ConfigCsvNullValues, "", "Comma separated CSV values read as null, ex - \\N,NULL")
+	confInputCSVEncoding := flag.String("input."+formats.ConfigCsvEncoding, "utf-8", "CSV input encoding - utf-8/latin1/windows-1252/utf-16/utf-16le/utf-16be")
 	confInputJSONSingleLine := flag.Bool("input."+formats.ConfigJSONSingleLine, true, "Parse JSON in multiline mode")
 	confInputJSONRootNode := flag.String("input."+formats.ConfigJSONRootNode, "", "RootNode to use for JSON")
+	confInputJSONFlattenDepth := flag.Int("input."+formats.ConfigJSONFlattenDepth, 0, "Levels of nested JSON objects flattened into dotted columns (a.b.c), -1 for all levels")
+	confInputXlsxSheet := flag.String("input."+formats.ConfigXlsxSheet, "", "Excel sheet name or 1 based index, defaults to first sheet")
+	confInputXlsxHeader := flag.Bool("input."+formats.ConfigXlsxHeader, true, "First row of Excel range as Header")
+	confInputXlsxRange := flag.String("input."+formats.ConfigXlsxRange, "", "Excel cell range to read, ex - A1:D100, defaults to whole sheet")
+	confInputYAMLRootNode := flag.String("input."+formats.ConfigYAMLRootNode, "", "RootNode (dot separated path) of list to read from YAML")
+	confInputTOMLRootNode := flag.String("input."+formats.ConfigTOMLRootNode, "", "RootNode (dot separated path) of array of tables to read from TOML")
+	confInputTextPattern := flag.String("input."+formats.ConfigTextPattern, "", "Regex with named groups or grok pattern to split text lines into columns, built-in - apache/nginx/combined, common, syslog, json")
+	confInputTextMultilineStart := flag.String("input."+formats.ConfigTextMultilineStart, "", "Regex matching first line of text record, other lines are appended to previous record")
+	confInputTextInferSchema := flag.Bool("input."+formats.ConfigTextInferSchema, false, "Derive types of text pattern columns from sampled records")
+	confInputFixedSpec := flag.String("input."+formats.ConfigFixedSpec, "", "Fixed width columns as name:start:length[:format[:layout]] list (1 based start) or spec file path")
+	confInputFixedTrim := flag.Bool("input."+formats.ConfigFixedTrim, true, "Trim spaces around fixed width values")
+	confInputFixedSkipLines := flag.Int("input."+formats.ConfigFixedSkipLines, 0, "Number of lines to skip before reading fixed width records")
+	confInputFixedRecordLength := flag.Int("input."+formats.ConfigFixedRecordLength, 0, "Expected length of fixed width records, 0 disables validation")
+	confInputTableNilValue := flag.String("input."+formats.ConfigTableNilValue, "<nil>", "Text of null values while reading table/box/markdown")
 	confInputStdType := flag.String("input."+std.ConfigStdType, "json", "Format for Reading from Std(console)")
 	confInputXMLElementName := flag.String("input."+formats.ConfigXMLElementName, "element", "XML Element to use for Parsing XML file")
 	confInputXMLSingleLine := flag.Bool("input."+formats.ConfigXMLSingleLine, true, "Read Xml element from each line")
+	confInputXMLInferSchema := flag.Bool("input."+formats.ConfigXMLInferSchema, false, "Derive XML column types from sampled elements")
 	confDBQuery := flag.String("input."+rdbms.ConfigDBQuery, "", "Rdbms Query")
+	confDBTable := flag.String("input."+rdbms.ConfigDBTable, "", "Rdbms Table, all the tables are read if query and table are not provided")
+	confInputMode := flag.String("input."+formats.ConfigMode, formats.ModeFailFast, "Malformed record handling - failfast/dropmalformed/permissive")
+	confInputSchema := flag.String("input."+formats.ConfigSchema, "", "Schema file (json/yaml) with column name, format, nullable and datetime layout")
+	confInputSchemaSampleSize := flag.Int("input."+formats.ConfigSchemaSampleSize, 1000, "Number of records used to derive JSON/XML schema, <= 0 for all records")
+	confInputSchemaDatetimeFormats := flag.String("input."+formats.ConfigSchemaDatetimeFormats, "", "Comma separated datetime layouts used while inferring schema, defaults to RFC3339, 2006-01-02 15:04:05, 2006-01-02")
 
 	confOutputStdType := flag.String("output."+std.ConfigStdType, "table", "Format for Writing to Std(console)")
 	confOutputCSVSep := flag.String("output."+formats.ConfigCsvSep, ",", "CSV File Seprator")
 	confOutputCSVHeader := flag.Bool("output."+formats.ConfigCsvHeader, true, "First Line as Header")
+	confOutputCSVQuote := flag.String("output."+formats.ConfigCsvQuote, `"`, "CSV Quote character")
+	confOutputCSVEscape := flag.String("output."+formats.ConfigCsvEscape, "", "CSV character escaping quotes and separators, defaults to doubled quotes")
+	confOutputCSVQuoteMode := flag.String("output."+formats.ConfigCsvQuoteMode, "minimal", "CSV values to quote - minimal/all/nonnumeric/none")
+	confOutputCSVNullValues := flag.String("output."+formats.ConfigCsvNullValues, "", "CSV text written for null values")
 	confOutputJSONSingleLine := flag.Bool("output."+formats.ConfigJSONSingleLine, true, "Parse JSON in multiline mode")
 	confOutputXMLElementName := flag.String("output."+formats.ConfigXMLElementName, "element", "XML Element to use for Writing XML file")
 	confOutputXMLSingleLine := flag.Bool("output."+formats.ConfigXMLSingleLine, true, "Write 1 row per each line")
+	confOutputArrowFormat := flag.String("output."+formats.ConfigArrowFormat, "", "Arrow IPC format - file (feather)/stream, defaults to stream for .arrows and file for rest")
+	confOutputArrowCompression := flag.String("output."+formats.ConfigArrowCompression, "none", "Arrow IPC compression - none/lz4/zstd")
+	confOutputAvroCodec := flag.String("output."+formats.ConfigAvroCodec, "deflate", "Avro codec - null/deflate/snappy")
+	confOutputXlsxSheet := flag.String("output."+formats.ConfigXlsxSheet, "Sheet1", "Excel sheet name")
+	confOutputYAMLRootNode := flag.String("output."+formats.ConfigYAMLRootNode, "", "RootNode (dot separated path) to nest YAML rows under")
+	confOutputTOMLRootNode := flag.String("output."+formats.ConfigTOMLRootNode, "rows", "RootNode (dot separated path) of TOML array of tables")
+	confOutputFixedSpec := flag.String("output."+formats.ConfigFixedSpec, "", "Fixed width columns as name:start:length list (1 based start) or spec file path, defaults to length of longest value")
+	confOutputTableMaxWidth := flag.Int("output."+formats.ConfigTableMaxWidth, 0, "Max characters of table/box/markdown/html values, longer values are truncated, <= 0 disables truncation")
+	confOutputTableNilValue := flag.String("output."+formats.ConfigTableNilValue, "<nil>", "Text used to render null values in table/box/markdown/html")
+	confOutputParquetCompression := flag.String("output."+formats.ConfigParquetCompression, "snappy", "Parquet compression - uncompressed/snappy/gzip/zstd/brotli")
+	confOutputParquetRowGroupSize := flag.Int("output."+formats.ConfigParquetRowGroupSize, 100000, "Max number of rows in each Parquet row group")
+	confOutputParquetDictionary := flag.Bool("output."+formats.ConfigParquetDictionary, true, "Use dictionary encoding for Parquet columns")
+	confOutputParquetStatistics := flag.Bool("output."+formats.ConfigParquetStatistics, true, "Write min/max/null count statistics for Parquet columns")
+	confOutputParquetDatetimeType := flag.String("output."+formats.ConfigParquetDatetimeType, "timestamp_micros", "Parquet type for datetime columns - timestamp_millis/timestamp_micros/date")
+	confOutputParquetNestedJSON := flag.Bool("output."+formats.ConfigParquetNestedJSON, false, "Write string columns having JSON objects/arrays as nested parquet columns")
+
+	confOutputDBMode := flag.String("output."+rdbms.ConfigDBMode, "", "Table write mode - append/overwrite/upsert (default append)")
+	confOutputDBUpsertKeys := flag.String("output."+rdbms.ConfigDBUpsertKeys, "", "Comma separated key columns for upsert mode")
 
 	confOutputfile := flag.String("output", "-", "Resoult Output, Defaults to Stdout")
 	confLoggerName := flag.String("logger", "info", "Logger - debug/info/warning/error")
 	confEngineStorage := flag.String(engine.ConfigEngineStorage, "pq", "Logger - memory/file")
+	confInteractive := flag.Bool("i", false, "Interactive mode, sources are loaded once and queries are read from console")
+	confScript := flag.String("f", "", "SQL script file with statements separated by ';', result of each query is written to output given in '-- output: <url>' comment before query")
 
 	flag.Parse()
 
@@ -51,33 +103,88 @@ func main() {
 
 	remainingArgs := flag.Args()
 
-	if len(remainingArgs) < 1 {
+	if len(remainingArgs) < 1 && !*confInteractive && *confScript == "" {
 		fmt.Println("Usage: pq [-args] <query> [files...or using - for stdin]")
+		fmt.Println("       pq -i [-args] [files...]")
+		fmt.Println("       pq -f <script.sql> [-args] [files...]")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
-	query := remainingArgs[0]
-	fileNames := remainingArgs[1:]
+	query := ""
+	fileNames := remainingArgs
+	if !*confInteractive && *confScript == "" {
+		query = remainingArgs[0]
+		fileNames = remainingArgs[1:]
+	}
 	inputConfig := map[string]string{}
 	inputConfig[formats.ConfigCsvSep] = *confInputCSVSep
 	inputConfig[formats.ConfigCsvHeader] = strconv.FormatBool(*confInputCSVHeader)
+	inputConfig[formats.ConfigCsvInferSchema] = strconv.FormatBool(*confInputCSVInferSchema)
+	inputConfig[formats.ConfigCsvQuote] = *confInputCSVQuote
+	inputConfig[formats.ConfigCsvEscape] = *confInputCSVEscape
+	inputConfig[formats.ConfigCsvComment] = *confInputCSVComment
+	inputConfig[formats.ConfigCsvLazyQuotes] = strconv.FormatBool(*confInputCSVLazyQuotes)
+	inputConfig[formats.ConfigCsvSkipLines] = strconv.Itoa(*confInputCSVSkipLines)
+	inputConfig[formats.ConfigCsvNullValues] = *confInputCSVNullValues
+	inputConfig[formats.ConfigCsvEncoding] = *confInputCSVEncoding
 	inputConfig[formats.ConfigJSONSingleLine] = strconv.FormatBool(*confInputJSONSingleLine)
 	inputConfig[formats.ConfigJSONRootNode] = *confInputJSONRootNode
+	inputConfig[formats.ConfigJSONFlattenDepth] = strconv.Itoa(*confInputJSONFlattenDepth)
 	inputConfig[std.ConfigStdType] = *confInputStdType
 	inputConfig[engine.ConfigEngineStorage] = *confEngineStorage
 	inputConfig[formats.ConfigXMLElementName] = *confInputXMLElementName
 	inputConfig[formats.ConfigXMLSingleLine] = strconv.FormatBool(*confInputXMLSingleLine)
+	inputConfig[formats.ConfigXMLInferSchema] = strconv.FormatBool(*confInputXMLInferSchema)
+	inputConfig[formats.ConfigXlsxSheet] = *confInputXlsxSheet
+	inputConfig[formats.ConfigXlsxHeader] = strconv.FormatBool(*confInputXlsxHeader)
+	inputConfig[formats.ConfigXlsxRange] = *confInputXlsxRange
+	inputConfig[formats.ConfigYAMLRootNode] = *confInputYAMLRootNode
+	inputConfig[formats.ConfigTOMLRootNode] = *confInputTOMLRootNode
+	inputConfig[formats.ConfigTextPattern] = *confInputTextPattern
+	inputConfig[formats.ConfigTextMultilineStart] = *confInputTextMultilineStart
+	inputConfig[formats.ConfigTextInferSchema] = strconv.FormatBool(*confInputTextInferSchema)
+	inputConfig[formats.ConfigFixedSpec] = *confInputFixedSpec
+	inputConfig[formats.ConfigFixedTrim] = strconv.FormatBool(*confInputFixedTrim)
+	inputConfig[formats.ConfigFixedSkipLines] = strconv.Itoa(*confInputFixedSkipLines)
+	inputConfig[formats.ConfigFixedRecordLength] = strconv.Itoa(*confInputFixedRecordLength)
+	inputConfig[formats.ConfigTableNilValue] = *confInputTableNilValue
 	inputConfig[rdbms.ConfigDBQuery] = *confDBQuery
+	inputConfig[rdbms.ConfigDBTable] = *confDBTable
+	inputConfig[formats.ConfigSchema] = *confInputSchema
+	inputConfig[formats.ConfigMode] = *confInputMode
+	inputConfig[formats.ConfigSchemaSampleSize] = strconv.Itoa(*confInputSchemaSampleSize)
+	inputConfig[formats.ConfigSchemaDatetimeFormats] = *confInputSchemaDatetimeFormats
 
 	outputConfig := map[string]string{}
 	outputConfig[formats.ConfigCsvSep] = *confOutputCSVSep
 	outputConfig[formats.ConfigCsvHeader] = strconv.FormatBool(*confOutputCSVHeader)
+	outputConfig[formats.ConfigCsvQuote] = *confOutputCSVQuote
+	outputConfig[formats.ConfigCsvEscape] = *confOutputCSVEscape
+	outputConfig[formats.ConfigCsvQuoteMode] = *confOutputCSVQuoteMode
+	outputConfig[formats.ConfigCsvNullValues] = *confOutputCSVNullValues
 	outputConfig[formats.ConfigJSONSingleLine] = strconv.FormatBool(*confOutputJSONSingleLine)
 	outputConfig[std.ConfigStdType] = *confOutputStdType
 	outputConfig[engine.ConfigEngineStorage] = *confEngineStorage
 	outputConfig[formats.ConfigXMLElementName] = *confOutputXMLElementName
 	outputConfig[formats.ConfigXMLSingleLine] = strconv.FormatBool(*confOutputXMLSingleLine)
+	outputConfig[formats.ConfigArrowFormat] = *confOutputArrowFormat
+	outputConfig[formats.ConfigArrowCompression] = *confOutputArrowCompression
+	outputConfig[formats.ConfigAvroCodec] = *confOutputAvroCodec
+	outputConfig[formats.ConfigXlsxSheet] = *confOutputXlsxSheet
+	outputConfig[formats.ConfigYAMLRootNode] = *confOutputYAMLRootNode
+	outputConfig[formats.ConfigTOMLRootNode] = *confOutputTOMLRootNode
+	outputConfig[formats.ConfigFixedSpec] = *confOutputFixedSpec
+	outputConfig[formats.ConfigTableMaxWidth] = strconv.Itoa(*confOutputTableMaxWidth)
+	outputConfig[formats.ConfigTableNilValue] = *confOutputTableNilValue
+	outputConfig[formats.ConfigParquetCompression] = *confOutputParquetCompression
+	outputConfig[formats.ConfigParquetRowGroupSize] = strconv.Itoa(*confOutputParquetRowGroupSize)
+	outputConfig[formats.ConfigParquetDictionary] = strconv.FormatBool(*confOutputParquetDictionary)
+	outputConfig[formats.ConfigParquetStatistics] = strconv.FormatBool(*confOutputParquetStatistics)
+	outputConfig[formats.ConfigParquetDatetimeType] = *confOutputParquetDatetimeType
+	outputConfig[formats.ConfigParquetNestedJSON] = strconv.FormatBool(*confOutputParquetNestedJSON)
+	outputConfig[rdbms.ConfigDBMode] = *confOutputDBMode
+	outputConfig[rdbms.ConfigDBUpsertKeys] = *confOutputDBUpsertKeys
 
 	log.Debug("input configs - ", inputConfig)
 	for i, f := range fileNames {
@@ -93,6 +200,38 @@ func main() {
 
 	log.Debug("files - ", fileNames)
 
+	if *confInteractive || *confScript != "" {
+		script := ""
+		if *confScript != "" {
+			data, err := os.ReadFile(*confScript)
+			if err != nil {
+				log.Error("Unable to Read Script - ", err)
+				os.Exit(1)
+			}
+			script = string(data)
+		}
+
+		session, err := sql.NewSession(inputConfig, fileNames...)
+		if err != nil {
+			log.Error("Error - ", err)
+			os.Exit(1)
+		}
+		defer session.Close()
+
+		if *confInteractive {
+			runRepl(session, inputConfig, outputConfig)
+			return
+		}
+
+		err = runScript(session, script, outputConfig, *confOutputfile)
+		if err != nil {
+			log.Error("Error - ", err)
+			session.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
 	df, err := sql.QuerySources(query, inputConfig, fileNames...)
 	if err != nil {
 		log.Error("Error - ", err)
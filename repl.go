@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blue4209211/pq/internal/engine"
+	"github.com/blue4209211/pq/sources"
+	"github.com/blue4209211/pq/sources/fs/formats"
+	"github.com/blue4209211/pq/sources/std"
+	"github.com/peterh/liner"
+	"golang.org/x/exp/slices"
+)
+
+const replHelp = `Enter SQL statements terminated with ';', statements can span multiple lines
+.tables              List registered tables
+.schema <table>      Show columns of table
+.load <url>#alias    Read source and register it as table
+.output <format>     Format of results (ex - table, csv, json)
+.help                Show this help
+.quit                Exit`
+
+// replOutputFormats formats which can be printed on console, binary formats (ex - parquet) are not supported
+var replOutputFormats = []string{"table", "box", "markdown", "md", "html", "csv", "json", "xml", "yaml", "yml", "toml", "fixed", "fwf"}
+
+// repl interactive session, sources are read once and registered in engine for all the queries
+type repl struct {
+	session      *engine.Session
+	inputConfig  map[string]string
+	outputConfig map[string]string
+	out          io.Writer
+	quit         bool
+}
+
+// execute runs meta command or sql statement
+func (t *repl) execute(stmt string) (err error) {
+	stmt = strings.TrimSpace(stmt)
+	if stmt == "" {
+		return nil
+	}
+	if strings.HasPrefix(stmt, ".") {
+		return t.command(strings.TrimSuffix(stmt, ";"))
+	}
+
+	for _, s := range engine.SplitStatements(stmt) {
+		if !engine.IsQuery(s) {
+			err = t.session.Exec(s)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		data, err := t.session.Query(s)
+		if err != nil {
+			return err
+		}
+
+		handler, err := formats.GetFormatHandler(t.outputConfig[std.ConfigStdType])
+		if err != nil {
+			return err
+		}
+		writer, err := handler.Writer(data, t.outputConfig)
+		if err != nil {
+			return err
+		}
+		err = writer.Write(t.out)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *repl) command(cmd string) (err error) {
+	args := strings.Fields(cmd)
+	switch args[0] {
+	case ".tables":
+		for _, d := range t.session.Tables() {
+			fmt.Fprintln(t.out, d.Name())
+		}
+	case ".schema":
+		if len(args) < 2 {
+			return errors.New("usage - .schema <table>")
+		}
+		d, ok := t.session.Table(args[1])
+		if !ok {
+			return errors.New("table not found - " + args[1])
+		}
+		for _, c := range d.Schema().Series() {
+			fmt.Fprintln(t.out, c.Name, c.Format.Name())
+		}
+	case ".load":
+		if len(args) < 2 {
+			return errors.New("usage - .load <url>#alias")
+		}
+		dfs, err := sources.ReadSources(t.inputConfig, args[1])
+		if err != nil {
+			return err
+		}
+		err = t.session.Register(dfs...)
+		if err != nil {
+			return err
+		}
+		for _, d := range dfs {
+			fmt.Fprintln(t.out, "loaded", d.Name())
+		}
+	case ".output":
+		if len(args) < 2 {
+			fmt.Fprintln(t.out, t.outputConfig[std.ConfigStdType])
+			return nil
+		}
+		format := strings.ToLower(args[1])
+		if !slices.Contains(replOutputFormats, format) {
+			return errors.New("unsupported output format - " + args[1] + ", supported formats are " + strings.Join(replOutputFormats, ", "))
+		}
+		t.outputConfig[std.ConfigStdType] = format
+	case ".help":
+		fmt.Fprintln(t.out, replHelp)
+	case ".quit", ".exit":
+		t.quit = true
+	default:
+		return errors.New("unknown command - " + args[0] + ", use .help to list commands")
+	}
+	return nil
+}
+
+// historyFile returns path of file used for storing history of statements
+func historyFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".pq_history")
+}
+
+// runRepl reads statements from console until .quit or EOF, errors are printed and session continues
+func runRepl(session *engine.Session, inputConfig map[string]string, outputConfig map[string]string) {
+	r := &repl{session: session, inputConfig: inputConfig, outputConfig: outputConfig, out: os.Stdout}
+
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	line.SetMultiLineMode(true)
+
+	history := historyFile()
+	if f, err := os.Open(history); err == nil {
+		line.ReadHistory(f)
+		f.Close()
+	}
+	defer func() {
+		if f, err := os.Create(history); err == nil {
+			line.WriteHistory(f)
+			f.Close()
+		}
+	}()
+
+	fmt.Println("pq interactive mode, use .help for commands")
+	stmt := ""
+	for !r.quit {
+		prompt := "pq> "
+		if stmt != "" {
+			prompt = "...> "
+		}
+
+		input, err := line.Prompt(prompt)
+		if err == liner.ErrPromptAborted {
+			stmt = ""
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintln(os.Stderr, "Error -", err)
+			}
+			break
+		}
+
+		// meta commands are single line, sql statements continue till ;
+		if stmt == "" && strings.HasPrefix(strings.TrimSpace(input), ".") {
+			stmt = input
+		} else {
+			stmt = strings.TrimSpace(stmt + "\n" + input)
+			if !strings.HasSuffix(stmt, ";") {
+				continue
+			}
+		}
+
+		line.AppendHistory(strings.ReplaceAll(stmt, "\n", " "))
+		err = r.execute(stmt)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error -", err)
+		}
+		stmt = ""
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/blue4209211/pq/internal/engine"
+	"github.com/blue4209211/pq/internal/log"
+	"github.com/blue4209211/pq/sources"
+)
+
+// outputDirective comment in script which sets output of following query, ex - `-- output: /tmp/result.csv`
+var outputDirective = regexp.MustCompile(`(?m)^\s*--\s*output\s*[:=]\s*(\S+)`)
+
+// runScript executes statements of script in order, result of each query is written to output given
+// in output comment of the query, defaultOutput is used if comment is not available.
+// only one query can use defaultOutput unless it is stdout, as each query would overwrite it
+func runScript(session *engine.Session, script string, outputConfig map[string]string, defaultOutput string) error {
+	stmts := engine.SplitStatements(script)
+	if defaultOutput != "-" {
+		first := 0
+		for i, stmt := range stmts {
+			if !engine.IsQuery(stmt) || outputDirective.MatchString(stmt) {
+				continue
+			}
+			if first > 0 {
+				return fmt.Errorf("statement %d - statements %d and %d write to same output %s, use '-- output: <url>' comment to set output of each query", i+1, first, i+1, defaultOutput)
+			}
+			first = i + 1
+		}
+	}
+
+	for i, stmt := range stmts {
+		log.Debug("executing statement - ", stmt)
+		if !engine.IsQuery(stmt) {
+			err := session.Exec(stmt)
+			if err != nil {
+				return fmt.Errorf("statement %d - %w", i+1, err)
+			}
+			continue
+		}
+
+		data, err := session.Query(stmt)
+		if err != nil {
+			return fmt.Errorf("statement %d - %w", i+1, err)
+		}
+
+		output := defaultOutput
+		if m := outputDirective.FindStringSubmatch(stmt); m != nil {
+			output = m[1]
+		}
+		err = sources.WriteSource(data, outputConfig, output)
+		if err != nil {
+			return fmt.Errorf("statement %d - %w", i+1, err)
+		}
+	}
+	return nil
+}
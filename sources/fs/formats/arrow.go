@@ -0,0 +1,673 @@
+package formats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/array"
+	"github.com/apache/arrow/go/v7/arrow/ipc"
+	"github.com/apache/arrow/go/v7/arrow/memory"
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/blue4209211/pq/internal/log"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// ConfigArrowFormat While writing, arrow ipc format - file (feather v2)/stream, format is detected while reading
+const ConfigArrowFormat = "arrow.format"
+
+// ConfigArrowCompression While writing, compression of record batches - none/lz4/zstd
+const ConfigArrowCompression = "arrow.compression"
+
+// arrowBatchSize number of rows in each record batch while writing
+const arrowBatchSize = 64 * 1024
+
+// arrowFileMagic arrow ipc files start with magic bytes, streams dont have them
+const arrowFileMagic = "ARROW1"
+
+// arrowUnsupportedTypes flatbuffer ids of types which can not be read by ipc readers
+// newer types (ex - string views) are not known to ipc readers
+var arrowUnsupportedTypes = map[byte]string{
+	14: "Union",
+	19: "LargeBinary (large_binary)",
+	20: "LargeUtf8 (large_string)",
+	21: "LargeList (large_list)",
+}
+
+// arrowMaxKnownType last type id known to ipc readers
+const arrowMaxKnownType = 21
+
+var arrowConfig = map[string]string{
+	ConfigArrowFormat:      "file",
+	ConfigArrowCompression: "none",
+}
+
+var arrowKindToTypeMap = map[reflect.Kind]arrow.DataType{
+	reflect.Bool:    arrow.FixedWidthTypes.Boolean,
+	reflect.Int64:   arrow.PrimitiveTypes.Int64,
+	reflect.Float64: arrow.PrimitiveTypes.Float64,
+	reflect.String:  arrow.BinaryTypes.String,
+}
+
+// ArrowDataSource reads/writes arrow ipc files (feather v2) and streams
+type ArrowDataSource struct {
+	// stream writes ipc stream by default (ex - .arrows files)
+	stream bool
+}
+
+func (t *ArrowDataSource) Args() map[string]string {
+	return arrowConfig
+}
+
+func (t *ArrowDataSource) Name() string {
+	return "arrow"
+}
+
+func (t *ArrowDataSource) Writer(data df.DataFrame, args map[string]string) (FormatWriter, error) {
+	return &arrowDataSourceWriter{data: data, args: args, stream: t.stream}, nil
+}
+
+func (t *ArrowDataSource) Reader(reader io.Reader, args map[string]string) (FormatReader, error) {
+	streamReader, err := t.StreamReader(reader, args)
+	if err != nil {
+		return nil, err
+	}
+	return ReadAll(streamReader)
+}
+
+// StreamReader reads one record batch at a time, files are read using random access if reader supports it (ex - os.File)
+// else whole content is buffered in memory
+func (t *ArrowDataSource) StreamReader(reader io.Reader, args map[string]string) (FormatStreamReader, error) {
+	arrowReader := &arrowDataSourceReader{args: args}
+	err := arrowReader.init(reader)
+	if err != nil {
+		return nil, err
+	}
+	return newFormatStreamReader(arrowReader, args)
+}
+
+type arrowDataSourceWriter struct {
+	data   df.DataFrame
+	args   map[string]string
+	stream bool
+}
+
+func (t *arrowDataSourceWriter) Write(writer io.Writer) (err error) {
+	stream := t.stream
+	switch strings.ToLower(t.args[ConfigArrowFormat]) {
+	case "stream":
+		stream = true
+	case "file", "feather":
+		stream = false
+	case "":
+	default:
+		return errors.New("invalid " + ConfigArrowFormat + " - " + t.args[ConfigArrowFormat])
+	}
+
+	arrowSchema, nested := arrowSchemaFromDataFrame(t.data, &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}, false)
+	opts := []ipc.Option{ipc.WithSchema(arrowSchema), ipc.WithAllocator(memory.DefaultAllocator)}
+	switch strings.ToLower(t.args[ConfigArrowCompression]) {
+	case "lz4":
+		opts = append(opts, ipc.WithLZ4())
+	case "zstd":
+		opts = append(opts, ipc.WithZstd())
+	case "", "none", "uncompressed":
+	default:
+		return errors.New("unsupported arrow compression - " + t.args[ConfigArrowCompression])
+	}
+
+	var recordWriter interface {
+		Write(arrow.Record) error
+		Close() error
+	}
+	if stream {
+		recordWriter = ipc.NewWriter(writer, opts...)
+	} else {
+		recordWriter, err = ipc.NewFileWriter(&arrowPositionWriter{writer: writer}, opts...)
+		if err != nil {
+			log.Error("unable to create arrow writer ", err)
+			return err
+		}
+	}
+
+	err = arrowWriteRecords(t.data, arrowSchema, nested, arrowBatchSize, recordWriter.Write)
+	if err != nil {
+		log.Error("unable to write data", err)
+		recordWriter.Close()
+		return err
+	}
+	return recordWriter.Close()
+}
+
+// arrowPositionWriter tracks position of written data, arrow file writer only seeks to find current position
+type arrowPositionWriter struct {
+	writer io.Writer
+	pos    int64
+}
+
+func (t *arrowPositionWriter) Write(p []byte) (n int, err error) {
+	n, err = t.writer.Write(p)
+	t.pos = t.pos + int64(n)
+	return n, err
+}
+
+func (t *arrowPositionWriter) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekCurrent {
+		return t.pos, errors.New("arrow writer doesnt support seek")
+	}
+	return t.pos, nil
+}
+
+type arrowDataSourceReader struct {
+	args         map[string]string
+	fileReader   *ipc.FileReader
+	streamReader *ipc.Reader
+	cols         df.DataFrameSchema
+	values       []func(arr arrow.Array, i int) (any, error)
+	batch        int
+	record       arrow.Record
+	index        int64
+}
+
+func (t *arrowDataSourceReader) Schema() (columns df.DataFrameSchema) {
+	return t.cols
+}
+
+func (t *arrowDataSourceReader) Next() (r df.Row, err error) {
+	for t.record == nil || t.index >= t.record.NumRows() {
+		if t.record != nil {
+			t.record.Release()
+			t.record = nil
+		}
+
+		if t.fileReader != nil && t.batch < t.fileReader.NumRecords() {
+			t.record, err = t.fileReader.Record(t.batch)
+			if err != nil {
+				return r, err
+			}
+			t.batch = t.batch + 1
+		} else if t.streamReader != nil && t.streamReader.Next() {
+			t.record = t.streamReader.Record()
+		} else if t.streamReader != nil && t.streamReader.Err() != nil {
+			return r, t.streamReader.Err()
+		} else {
+			return r, io.EOF
+		}
+		t.record.Retain()
+		t.index = 0
+	}
+
+	data := make([]any, len(t.values))
+	for c, value := range t.values {
+		arr := t.record.Column(c)
+		if arr.IsNull(int(t.index)) {
+			continue
+		}
+		data[c], err = value(arr, int(t.index))
+		if err != nil {
+			return r, errors.New("arrow : column " + t.cols.Get(c).Name + ", " + err.Error())
+		}
+	}
+	t.index = t.index + 1
+	return inmemory.NewRowFromAny(&t.cols, &data), err
+}
+
+func (t *arrowDataSourceReader) Close() (err error) {
+	if t.record != nil {
+		t.record.Release()
+		t.record = nil
+	}
+	if t.fileReader != nil {
+		err = t.fileReader.Close()
+		t.fileReader = nil
+	}
+	if t.streamReader != nil {
+		t.streamReader.Release()
+		t.streamReader = nil
+	}
+	return err
+}
+
+func (t *arrowDataSourceReader) init(reader io.Reader) (err error) {
+	// ipc readers panic or fail for unsupported types (ex - large_utf8, dictionary encoded columns),
+	// schema is checked again to return column with unsupported type
+	var schema func() (flatbuffers.Table, error)
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("unable to read arrow - %v", r)
+		}
+		if err != nil && schema != nil {
+			if fields, schemaErr := schema(); schemaErr == nil {
+				if columnErr := arrowUnsupportedColumn(fields); columnErr != nil {
+					err = columnErr
+				}
+			}
+		}
+	}()
+
+	magic := make([]byte, len(arrowFileMagic))
+	source, ok := reader.(ipc.ReadAtSeeker)
+	if ok {
+		_, readErr := source.ReadAt(magic, 0)
+		// pipes (ex - stdin) dont support random access
+		ok = readErr == nil || readErr == io.EOF
+	}
+	if !ok {
+		bufferedReader := bufio.NewReader(reader)
+		magic, _ = bufferedReader.Peek(len(arrowFileMagic))
+		reader = bufferedReader
+		if string(magic) == arrowFileMagic {
+			buf, err := io.ReadAll(bufferedReader)
+			if err != nil {
+				return err
+			}
+			source = bytes.NewReader(buf)
+		}
+	}
+
+	var arrowSchema *arrow.Schema
+	if string(magic) == arrowFileMagic {
+		schema = func() (flatbuffers.Table, error) {
+			return arrowFileSchema(source)
+		}
+		t.fileReader, err = ipc.NewFileReader(source, ipc.WithAllocator(memory.DefaultAllocator))
+		if err != nil {
+			log.Error("unable to read arrow file", err)
+			return err
+		}
+		arrowSchema = t.fileReader.Schema()
+	} else {
+		recorder := &arrowRecordingReader{reader: reader}
+		schema = func() (flatbuffers.Table, error) {
+			return arrowStreamSchema(recorder.data)
+		}
+		t.streamReader, err = ipc.NewReader(recorder, ipc.WithAllocator(memory.DefaultAllocator))
+		if err != nil {
+			log.Error("unable to read arrow stream", err)
+			return err
+		}
+		recorder.stop()
+		arrowSchema = t.streamReader.Schema()
+	}
+
+	cols := make([]df.SeriesSchema, len(arrowSchema.Fields()))
+	t.values = make([]func(arr arrow.Array, i int) (any, error), len(cols))
+	for i, f := range arrowSchema.Fields() {
+		format, value := arrowColumnFormat(f.Type)
+		cols[i] = df.SeriesSchema{Name: f.Name, Format: format}
+		t.values[i] = value
+	}
+	t.cols = df.NewSchema(cols)
+	return err
+}
+
+// arrowRecordingReader keeps bytes read from stream until stopped, used to read schema message again
+type arrowRecordingReader struct {
+	reader  io.Reader
+	data    []byte
+	stopped bool
+}
+
+func (t *arrowRecordingReader) Read(p []byte) (n int, err error) {
+	n, err = t.reader.Read(p)
+	if !t.stopped {
+		t.data = append(t.data, p[:n]...)
+	}
+	return n, err
+}
+
+func (t *arrowRecordingReader) stop() {
+	t.stopped = true
+	t.data = nil
+}
+
+// arrowFileSchema returns schema table from footer of file, file ends with footer, its length and magic
+func arrowFileSchema(source ipc.ReadAtSeeker) (schema flatbuffers.Table, err error) {
+	size, err := source.Seek(0, io.SeekEnd)
+	if err != nil {
+		return schema, err
+	}
+	trailer := make([]byte, 4+len(arrowFileMagic))
+	if size < int64(len(trailer)) {
+		return schema, errors.New("invalid arrow file")
+	}
+	_, err = source.ReadAt(trailer, size-int64(len(trailer)))
+	if err != nil {
+		return schema, err
+	}
+	length := int64(binary.LittleEndian.Uint32(trailer))
+	if length < 4 || length > size-int64(len(trailer)) {
+		return schema, errors.New("invalid arrow file footer")
+	}
+	footer := make([]byte, length)
+	_, err = source.ReadAt(footer, size-int64(len(trailer))-length)
+	if err != nil {
+		return schema, err
+	}
+	return arrowTableField(arrowRootTable(footer), 1)
+}
+
+// arrowStreamSchema returns schema table from first message of stream, message starts with optional continuation marker and its length
+func arrowStreamSchema(data []byte) (schema flatbuffers.Table, err error) {
+	if len(data) >= 4 && binary.LittleEndian.Uint32(data) == 0xFFFFFFFF {
+		data = data[4:]
+	}
+	if len(data) < 4 {
+		return schema, errors.New("invalid arrow stream")
+	}
+	length := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+	if length < 4 || uint64(length) > uint64(len(data)) {
+		return schema, errors.New("invalid arrow stream message")
+	}
+	message := arrowRootTable(data[:length])
+	// header of schema message is union with type 1
+	if arrowTableByte(message, 1) != 1 {
+		return schema, errors.New("invalid arrow stream, first message should be schema")
+	}
+	return arrowTableField(message, 2)
+}
+
+// arrowUnsupportedColumn returns error with column which has unsupported type, nested fields are checked too
+func arrowUnsupportedColumn(schema flatbuffers.Table) error {
+	var unsupported func(field flatbuffers.Table) string
+	unsupported = func(field flatbuffers.Table) string {
+		if arrowTableOffset(field, 4) != 0 {
+			return "dictionary encoded"
+		}
+		typ := arrowTableByte(field, 2)
+		if name, ok := arrowUnsupportedTypes[typ]; ok {
+			return name
+		}
+		if typ > arrowMaxKnownType {
+			return fmt.Sprintf("Type(%d)", typ)
+		}
+		for _, child := range arrowTableVector(field, 5) {
+			if name := unsupported(child); name != "" {
+				return name
+			}
+		}
+		return ""
+	}
+
+	for _, field := range arrowTableVector(schema, 1) {
+		if name := unsupported(field); name != "" {
+			return fmt.Errorf("unsupported arrow column %s, %s type is not supported", arrowTableString(field, 0), name)
+		}
+	}
+	return nil
+}
+
+// flatbuffer helpers access fields by their index in table, invalid offsets return empty values instead of panic
+func arrowRootTable(data []byte) flatbuffers.Table {
+	if len(data) < 4 {
+		return flatbuffers.Table{}
+	}
+	return flatbuffers.Table{Bytes: data, Pos: flatbuffers.GetUOffsetT(data)}
+}
+
+func arrowTableOffset(table flatbuffers.Table, field int) uint64 {
+	size := int64(len(table.Bytes))
+	if int64(table.Pos)+4 > size {
+		return 0
+	}
+	vtable := int64(table.Pos) - int64(flatbuffers.GetSOffsetT(table.Bytes[table.Pos:]))
+	if vtable < 0 || vtable+2 > size || vtable+int64(flatbuffers.GetVOffsetT(table.Bytes[vtable:])) > size {
+		return 0
+	}
+	o := uint64(table.Offset(flatbuffers.VOffsetT(4 + 2*field)))
+	if o == 0 || uint64(table.Pos)+o+4 > uint64(size) {
+		return 0
+	}
+	return uint64(table.Pos) + o
+}
+
+// arrowTableIndirect returns position referred by offset at given position
+func arrowTableIndirect(data []byte, pos uint64) uint64 {
+	pos = pos + uint64(flatbuffers.GetUOffsetT(data[pos:]))
+	if pos+4 > uint64(len(data)) {
+		return 0
+	}
+	return pos
+}
+
+func arrowTableByte(table flatbuffers.Table, field int) byte {
+	o := arrowTableOffset(table, field)
+	if o == 0 {
+		return 0
+	}
+	return table.Bytes[o]
+}
+
+func arrowTableString(table flatbuffers.Table, field int) string {
+	o := arrowTableOffset(table, field)
+	if o == 0 {
+		return ""
+	}
+	o = arrowTableIndirect(table.Bytes, o)
+	if o == 0 {
+		return ""
+	}
+	length := uint64(flatbuffers.GetUOffsetT(table.Bytes[o:]))
+	if o+4+length > uint64(len(table.Bytes)) {
+		return ""
+	}
+	return string(table.Bytes[o+4 : o+4+length])
+}
+
+func arrowTableField(table flatbuffers.Table, field int) (flatbuffers.Table, error) {
+	o := arrowTableOffset(table, field)
+	if o != 0 {
+		o = arrowTableIndirect(table.Bytes, o)
+	}
+	if o == 0 {
+		return flatbuffers.Table{}, errors.New("invalid arrow metadata")
+	}
+	return flatbuffers.Table{Bytes: table.Bytes, Pos: flatbuffers.UOffsetT(o)}, nil
+}
+
+func arrowTableVector(table flatbuffers.Table, field int) (tables []flatbuffers.Table) {
+	o := arrowTableOffset(table, field)
+	if o != 0 {
+		o = arrowTableIndirect(table.Bytes, o)
+	}
+	if o == 0 {
+		return nil
+	}
+	length := uint64(flatbuffers.GetUOffsetT(table.Bytes[o:]))
+	if o+4+4*length > uint64(len(table.Bytes)) {
+		return nil
+	}
+	for i := uint64(0); i < length; i++ {
+		pos := arrowTableIndirect(table.Bytes, o+4+4*i)
+		if pos == 0 {
+			return nil
+		}
+		tables = append(tables, flatbuffers.Table{Bytes: table.Bytes, Pos: flatbuffers.UOffsetT(pos)})
+	}
+	return tables
+}
+
+// arrowColumnFormat returns format of arrow type and function which returns non null values of column in that format,
+// nested and unsupported types are returned as json text. unsigned values larger than max integer are returned as error
+func arrowColumnFormat(dataType arrow.DataType) (format df.Format, value func(arr arrow.Array, i int) (any, error)) {
+	switch t := dataType.(type) {
+	case *arrow.TimestampType:
+		unit := t.Unit
+		return df.DateTimeFormat, func(arr arrow.Array, i int) (any, error) {
+			v := int64(arr.(*array.Timestamp).Value(i))
+			switch unit {
+			case arrow.Second:
+				return time.Unix(v, 0).UTC(), nil
+			case arrow.Millisecond:
+				return time.UnixMilli(v).UTC(), nil
+			case arrow.Microsecond:
+				return time.UnixMicro(v).UTC(), nil
+			}
+			return time.Unix(0, v).UTC(), nil
+		}
+	case *arrow.Date32Type:
+		return df.DateTimeFormat, func(arr arrow.Array, i int) (any, error) {
+			return time.Unix(int64(arr.(*array.Date32).Value(i))*24*60*60, 0).UTC(), nil
+		}
+	case *arrow.Date64Type:
+		return df.DateTimeFormat, func(arr arrow.Array, i int) (any, error) {
+			return time.UnixMilli(int64(arr.(*array.Date64).Value(i))).UTC(), nil
+		}
+	case *arrow.Decimal128Type:
+		scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(t.Scale)), nil))
+		return df.DoubleFormat, func(arr arrow.Array, i int) (any, error) {
+			unscaled := arr.(*array.Decimal128).Value(i).BigInt()
+			f, _ := new(big.Float).Quo(new(big.Float).SetInt(unscaled), scale).Float64()
+			return f, nil
+		}
+	}
+
+	switch dataType.ID() {
+	case arrow.INT8, arrow.INT16, arrow.INT32, arrow.INT64, arrow.UINT8, arrow.UINT16, arrow.UINT32, arrow.UINT64:
+		format = df.IntegerFormat
+	case arrow.FLOAT32, arrow.FLOAT64:
+		format = df.DoubleFormat
+	case arrow.BOOL:
+		format = df.BoolFormat
+	case arrow.STRING, arrow.BINARY, arrow.FIXED_SIZE_BINARY:
+		format = df.StringFormat
+	default:
+		return df.StringFormat, func(arr arrow.Array, i int) (any, error) {
+			data, err := json.Marshal(arrowJSONValue(arr, i))
+			if err != nil {
+				return nil, err
+			}
+			return string(data), nil
+		}
+	}
+
+	return format, func(arr arrow.Array, i int) (any, error) {
+		return format.Convert(arrowJSONValue(arr, i))
+	}
+}
+
+// arrowSchemaFromDataFrame returns arrow schema of dataframe, if nestedJSON is true then
+// string columns with json objects/arrays are mapped to nested (struct/list) types
+func arrowSchemaFromDataFrame(data df.DataFrame, datetimeType arrow.DataType, nestedJSON bool) (arrowSchema *arrow.Schema, nested []bool) {
+	dfSchema := data.Schema()
+	fields := make([]arrow.Field, dfSchema.Len())
+	nested = make([]bool, dfSchema.Len())
+	for i, f := range dfSchema.Series() {
+		fields[i] = arrow.Field{Name: f.Name, Type: arrowKindToTypeMap[f.Format.Type()], Nullable: true}
+		if f.Format == df.DateTimeFormat {
+			fields[i].Type = datetimeType
+		}
+		if nestedJSON && f.Format == df.StringFormat {
+			if dataType := arrowJSONColumnType(data, i); dataType != nil {
+				fields[i].Type = dataType
+				nested[i] = true
+			}
+		}
+	}
+	return arrow.NewSchema(fields, nil), nested
+}
+
+// arrowJSONColumnType returns nested type of string column if all of its non empty values are json objects/arrays, nil otherwise
+func arrowJSONColumnType(data df.DataFrame, col int) (dataType arrow.DataType) {
+	for i := int64(0); i < data.Len(); i++ {
+		r := data.GetRow(i)
+		if r.IsNil(col) || r.GetAsString(col) == "" {
+			continue
+		}
+		v, err := arrowDecodeJSON(r.GetAsString(col))
+		if err != nil {
+			return nil
+		}
+		switch v.(type) {
+		case *arrowJSONObject, []any:
+		default:
+			return nil
+		}
+		dataType = arrowJSONType(dataType, v)
+	}
+
+	if dataType == nil {
+		return nil
+	}
+	dataType = arrowResolveJSONType(dataType)
+	// objects and arrays are mixed in the column
+	if dataType.ID() != arrow.STRUCT && dataType.ID() != arrow.LIST {
+		return nil
+	}
+	return dataType
+}
+
+// arrowWriteRecords converts rows of dataframe to records of batchSize rows and writes them using write
+func arrowWriteRecords(data df.DataFrame, arrowSchema *arrow.Schema, nested []bool, batchSize int64, write func(arrow.Record) error) (err error) {
+	recordBuilder := array.NewRecordBuilder(memory.DefaultAllocator, arrowSchema)
+	defer recordBuilder.Release()
+
+	writeRecord := func() error {
+		record := recordBuilder.NewRecord()
+		defer record.Release()
+		return write(record)
+	}
+
+	fields := arrowSchema.Fields()
+	for i := int64(0); i < data.Len(); i++ {
+		r := data.GetRow(i)
+		for col := range fields {
+			builder := recordBuilder.Field(col)
+			if r.IsNil(col) {
+				builder.AppendNull()
+				continue
+			}
+
+			if nested[col] {
+				v, _ := arrowDecodeJSON(r.GetAsString(col))
+				arrowAppendJSON(builder, fields[col].Type, v)
+				continue
+			}
+
+			switch b := builder.(type) {
+			case *array.BooleanBuilder:
+				b.Append(r.GetAsBool(col))
+			case *array.Int64Builder:
+				b.Append(r.GetAsInt(col))
+			case *array.StringBuilder:
+				b.Append(r.GetAsString(col))
+			case *array.Float64Builder:
+				b.Append(r.GetAsDouble(col))
+			case *array.TimestampBuilder:
+				if fields[col].Type.(*arrow.TimestampType).Unit == arrow.Millisecond {
+					b.Append(arrow.Timestamp(r.GetAsDatetime(col).UnixMilli()))
+				} else {
+					b.Append(arrow.Timestamp(r.GetAsDatetime(col).UnixMicro()))
+				}
+			case *array.Date32Builder:
+				day := r.GetAsDatetime(col).UTC().Truncate(24 * time.Hour)
+				b.Append(arrow.Date32(day.Unix() / (24 * 60 * 60)))
+			default:
+				b.AppendNull()
+			}
+		}
+
+		if (i+1)%batchSize == 0 {
+			err = writeRecord()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if data.Len() == 0 || data.Len()%batchSize != 0 {
+		err = writeRecord()
+	}
+	return err
+}
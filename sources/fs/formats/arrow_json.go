@@ -0,0 +1,349 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/array"
+)
+
+// arrowJSONObject json object which keeps order of the keys, used for struct fields
+type arrowJSONObject struct {
+	keys   []string
+	values []any
+}
+
+func (t *arrowJSONObject) get(key string) (v any, ok bool) {
+	for i, k := range t.keys {
+		if k == key {
+			return t.values[i], true
+		}
+	}
+	return nil, false
+}
+
+func (t *arrowJSONObject) MarshalJSON() ([]byte, error) {
+	var buff bytes.Buffer
+	buff.WriteByte('{')
+	for i, k := range t.keys {
+		if i > 0 {
+			buff.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(t.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buff.Write(key)
+		buff.WriteByte(':')
+		buff.Write(value)
+	}
+	buff.WriteByte('}')
+	return buff.Bytes(), nil
+}
+
+// arrowJSONValue converts value of arrow array to value which can be marshalled as json
+func arrowJSONValue(arr arrow.Array, i int) any {
+	if arr.IsNull(i) {
+		return nil
+	}
+	switch a := arr.(type) {
+	case *array.Struct:
+		structType := a.DataType().(*arrow.StructType)
+		obj := &arrowJSONObject{}
+		for f := 0; f < a.NumField(); f++ {
+			obj.keys = append(obj.keys, structType.Field(f).Name)
+			obj.values = append(obj.values, arrowJSONValue(a.Field(f), i))
+		}
+		return obj
+	case *array.Map:
+		j := i + a.Data().Offset()
+		obj := &arrowJSONObject{}
+		for k := int(a.Offsets()[j]); k < int(a.Offsets()[j+1]); k++ {
+			key := arrowJSONValue(a.Keys(), k)
+			if s, ok := key.(string); ok {
+				obj.keys = append(obj.keys, s)
+			} else {
+				obj.keys = append(obj.keys, fmt.Sprint(key))
+			}
+			obj.values = append(obj.values, arrowJSONValue(a.Items(), k))
+		}
+		return obj
+	case *array.List:
+		j := i + a.Data().Offset()
+		list := make([]any, 0, a.Offsets()[j+1]-a.Offsets()[j])
+		for k := int(a.Offsets()[j]); k < int(a.Offsets()[j+1]); k++ {
+			list = append(list, arrowJSONValue(a.ListValues(), k))
+		}
+		return list
+	case *array.Boolean:
+		return a.Value(i)
+	case *array.Int8:
+		return a.Value(i)
+	case *array.Int16:
+		return a.Value(i)
+	case *array.Int32:
+		return a.Value(i)
+	case *array.Int64:
+		return a.Value(i)
+	case *array.Uint8:
+		return a.Value(i)
+	case *array.Uint16:
+		return a.Value(i)
+	case *array.Uint32:
+		return a.Value(i)
+	case *array.Uint64:
+		return a.Value(i)
+	case *array.Float32:
+		return a.Value(i)
+	case *array.Float64:
+		return a.Value(i)
+	case *array.String:
+		return a.Value(i)
+	case *array.Binary:
+		return string(a.Value(i))
+	case *array.FixedSizeBinary:
+		return string(a.Value(i))
+	}
+
+	// remaining types (ex - timestamp, decimal) uses arrow's json representation
+	slice := array.NewSlice(arr, int64(i), int64(i+1))
+	defer slice.Release()
+	data, err := json.Marshal(slice)
+	if err != nil {
+		return nil
+	}
+	var values []any
+	if json.Unmarshal(data, &values) != nil || len(values) != 1 {
+		return nil
+	}
+	return values[0]
+}
+
+// arrowDecodeJSON decodes json text, objects are decoded as arrowJSONObject and numbers as json.Number
+func arrowDecodeJSON(text string) (v any, err error) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	v, err = arrowDecodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("invalid json - " + text)
+	}
+	return v, err
+}
+
+func arrowDecodeJSONValue(dec *json.Decoder) (v any, err error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		obj := &arrowJSONObject{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := arrowDecodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.keys = append(obj.keys, key.(string))
+			obj.values = append(obj.values, value)
+		}
+		_, err = dec.Token()
+		return obj, err
+	case json.Delim('['):
+		list := make([]any, 0)
+		for dec.More() {
+			value, err := arrowDecodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err = dec.Token()
+		return list, err
+	}
+	return token, err
+}
+
+// arrowJSONType derives arrow type of json value and merges it with type derived from previous values,
+// int and double are merged to double and other conflicting types are merged to string.
+// Null type is used for values whose type is not known yet (ex - null values, empty lists)
+func arrowJSONType(dataType arrow.DataType, v any) arrow.DataType {
+	if dataType == nil {
+		dataType = arrow.Null
+	}
+	unknown := dataType.ID() == arrow.NULL
+
+	var valueType arrow.DataType
+	switch value := v.(type) {
+	case nil:
+		return dataType
+	case *arrowJSONObject:
+		structType, ok := dataType.(*arrow.StructType)
+		if !unknown && !ok {
+			return arrow.BinaryTypes.String
+		}
+		fields := []arrow.Field{}
+		if ok {
+			fields = append(fields, structType.Fields()...)
+		}
+		for i, k := range value.keys {
+			idx := -1
+			for f := range fields {
+				if fields[f].Name == k {
+					idx = f
+					break
+				}
+			}
+			if idx < 0 {
+				fields = append(fields, arrow.Field{Name: k, Type: arrow.Null, Nullable: true})
+				idx = len(fields) - 1
+			}
+			fields[idx].Type = arrowJSONType(fields[idx].Type, value.values[i])
+		}
+		return arrow.StructOf(fields...)
+	case []any:
+		listType, ok := dataType.(*arrow.ListType)
+		if !unknown && !ok {
+			return arrow.BinaryTypes.String
+		}
+		var elemType arrow.DataType = arrow.Null
+		if ok {
+			elemType = listType.Elem()
+		}
+		for _, e := range value {
+			elemType = arrowJSONType(elemType, e)
+		}
+		return arrow.ListOf(elemType)
+	case json.Number:
+		valueType = arrow.PrimitiveTypes.Int64
+		if _, err := value.Int64(); err != nil {
+			valueType = arrow.PrimitiveTypes.Float64
+		}
+	case bool:
+		valueType = arrow.FixedWidthTypes.Boolean
+	default:
+		valueType = arrow.BinaryTypes.String
+	}
+
+	if unknown || arrow.TypeEqual(dataType, valueType) {
+		return valueType
+	}
+	isNumber := func(t arrow.DataType) bool { return t.ID() == arrow.INT64 || t.ID() == arrow.FLOAT64 }
+	if isNumber(dataType) && isNumber(valueType) {
+		return arrow.PrimitiveTypes.Float64
+	}
+	return arrow.BinaryTypes.String
+}
+
+// arrowResolveJSONType replaces unknown types (ex - fields with only null values) with string
+func arrowResolveJSONType(dataType arrow.DataType) arrow.DataType {
+	switch t := dataType.(type) {
+	case *arrow.NullType:
+		return arrow.BinaryTypes.String
+	case *arrow.StructType:
+		// parquet doesnt support groups without fields
+		if len(t.Fields()) == 0 {
+			return arrow.BinaryTypes.String
+		}
+		fields := append([]arrow.Field{}, t.Fields()...)
+		for i := range fields {
+			fields[i].Type = arrowResolveJSONType(fields[i].Type)
+		}
+		return arrow.StructOf(fields...)
+	case *arrow.ListType:
+		return arrow.ListOf(arrowResolveJSONType(t.Elem()))
+	}
+	return dataType
+}
+
+// arrowAppendJSON appends decoded json value to builder, values which dont match type of builder are added as null
+func arrowAppendJSON(builder array.Builder, dataType arrow.DataType, v any) {
+	if v == nil {
+		builder.AppendNull()
+		return
+	}
+
+	switch b := builder.(type) {
+	case *array.StructBuilder:
+		obj, ok := v.(*arrowJSONObject)
+		if !ok {
+			b.AppendNull()
+			return
+		}
+		b.Append(true)
+		for i, f := range dataType.(*arrow.StructType).Fields() {
+			value, _ := obj.get(f.Name)
+			arrowAppendJSON(b.FieldBuilder(i), f.Type, value)
+		}
+	case *array.ListBuilder:
+		list, ok := v.([]any)
+		if !ok {
+			b.AppendNull()
+			return
+		}
+		b.Append(true)
+		for _, e := range list {
+			arrowAppendJSON(b.ValueBuilder(), dataType.(*arrow.ListType).Elem(), e)
+		}
+	case *array.Int64Builder:
+		n, ok := v.(json.Number)
+		if !ok {
+			b.AppendNull()
+			return
+		}
+		i, err := n.Int64()
+		if err != nil {
+			b.AppendNull()
+			return
+		}
+		b.Append(i)
+	case *array.Float64Builder:
+		n, ok := v.(json.Number)
+		if !ok {
+			b.AppendNull()
+			return
+		}
+		f, err := n.Float64()
+		if err != nil {
+			b.AppendNull()
+			return
+		}
+		b.Append(f)
+	case *array.BooleanBuilder:
+		bv, ok := v.(bool)
+		if !ok {
+			b.AppendNull()
+			return
+		}
+		b.Append(bv)
+	case *array.StringBuilder:
+		if s, ok := v.(string); ok {
+			b.Append(s)
+			return
+		}
+		// conflicting types are written as json text
+		data, err := json.Marshal(v)
+		if err != nil {
+			b.AppendNull()
+			return
+		}
+		b.Append(string(data))
+	default:
+		builder.AppendNull()
+	}
+}
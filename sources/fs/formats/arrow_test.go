@@ -0,0 +1,302 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/array"
+	"github.com/apache/arrow/go/v7/arrow/decimal128"
+	"github.com/apache/arrow/go/v7/arrow/ipc"
+	"github.com/apache/arrow/go/v7/arrow/memory"
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+	flatbuffers "github.com/google/flatbuffers/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArrowDataSource(t *testing.T) {
+	source := ArrowDataSource{}
+	assert.Equal(t, source.Name(), "arrow")
+}
+
+func TestArrowDataSourceWriter(t *testing.T) {
+	dfSchema := df.NewSchema([]df.SeriesSchema{
+		{Name: "a", Format: df.IntegerFormat},
+		{Name: "b", Format: df.DoubleFormat},
+		{Name: "c", Format: df.StringFormat},
+		{Name: "d", Format: df.BoolFormat},
+		{Name: "e", Format: df.DateTimeFormat},
+	})
+	value := time.Date(2021, 3, 4, 5, 6, 7, 891234000, time.UTC)
+	rows := []df.Row{
+		inmemory.NewRowFromAny(&dfSchema, &([]any{int64(1), 1.5, "c1", true, value})),
+		inmemory.NewRowFromAny(&dfSchema, &([]any{nil, nil, nil, nil, nil})),
+		inmemory.NewRowFromAny(&dfSchema, &([]any{int64(3), 3.5, "c3", false, value})),
+	}
+	dataframe := inmemory.NewDataframeFromRow(dfSchema, &rows)
+
+	cases := []struct {
+		source      ArrowDataSource
+		format      string
+		compression string
+		magic       bool
+	}{
+		{ArrowDataSource{}, "", "", true},
+		{ArrowDataSource{}, "stream", "", false},
+		{ArrowDataSource{stream: true}, "", "", false},
+		{ArrowDataSource{stream: true}, "feather", "lz4", true},
+		{ArrowDataSource{}, "file", "zstd", true},
+		{ArrowDataSource{}, "stream", "zstd", false},
+	}
+	for _, c := range cases {
+		writer, err := c.source.Writer(dataframe, map[string]string{
+			ConfigArrowFormat:      c.format,
+			ConfigArrowCompression: c.compression,
+		})
+		assert.NoError(t, err)
+		var buff bytes.Buffer
+		assert.NoError(t, writer.Write(&buff))
+		assert.Equal(t, c.magic, bytes.HasPrefix(buff.Bytes(), []byte(arrowFileMagic)))
+
+		// reading from both random access and non seekable readers
+		for _, reader := range []io.Reader{bytes.NewReader(buff.Bytes()), io.MultiReader(bytes.NewReader(buff.Bytes()))} {
+			arrowReader, err := c.source.Reader(reader, map[string]string{})
+			assert.NoError(t, err)
+			assert.Equal(t, dfSchema, arrowReader.Schema())
+
+			dfData := *(arrowReader.Data())
+			assert.Equal(t, 3, len(dfData))
+			assert.Equal(t, int64(1), dfData[0].GetRaw(0))
+			assert.Equal(t, 1.5, dfData[0].GetRaw(1))
+			assert.Equal(t, "c1", dfData[0].GetRaw(2))
+			assert.Equal(t, true, dfData[0].GetRaw(3))
+			assert.Equal(t, value, dfData[0].GetRaw(4))
+			for i := 0; i < dfSchema.Len(); i++ {
+				assert.True(t, dfData[1].IsNil(i))
+			}
+			assert.Equal(t, "c3", dfData[2].GetRaw(2))
+		}
+	}
+}
+
+func TestArrowDataSourceWriterOptions(t *testing.T) {
+	dfSchema := df.NewSchema([]df.SeriesSchema{{Name: "a", Format: df.IntegerFormat}})
+	rows := []df.Row{inmemory.NewRowFromAny(&dfSchema, &([]any{int64(1)}))}
+	dataframe := inmemory.NewDataframeFromRow(dfSchema, &rows)
+	source := ArrowDataSource{}
+
+	writer, err := source.Writer(dataframe, map[string]string{ConfigArrowFormat: "parquet"})
+	assert.NoError(t, err)
+	assert.Error(t, writer.Write(new(bytes.Buffer)))
+
+	writer, err = source.Writer(dataframe, map[string]string{ConfigArrowCompression: "snappy"})
+	assert.NoError(t, err)
+	assert.Error(t, writer.Write(new(bytes.Buffer)))
+
+	_, err = source.Reader(bytes.NewReader([]byte("a,b\n1,2\n")), map[string]string{})
+	assert.Error(t, err)
+}
+
+func TestArrowDataSourceReaderTypes(t *testing.T) {
+	mem := memory.NewGoAllocator()
+	arrowSchema := arrow.NewSchema([]arrow.Field{
+		{Name: "ts", Type: &arrow.TimestampType{Unit: arrow.Millisecond}, Nullable: true},
+		{Name: "dt", Type: arrow.FixedWidthTypes.Date32, Nullable: true},
+		{Name: "dec", Type: &arrow.Decimal128Type{Precision: 10, Scale: 2}, Nullable: true},
+		{Name: "i32", Type: arrow.PrimitiveTypes.Int32, Nullable: true},
+		{Name: "list", Type: arrow.ListOf(arrow.PrimitiveTypes.Int64), Nullable: true},
+	}, nil)
+
+	builder := array.NewRecordBuilder(mem, arrowSchema)
+	defer builder.Release()
+	value := time.Date(2021, 3, 4, 5, 6, 7, 891000000, time.UTC)
+	builder.Field(0).(*array.TimestampBuilder).Append(arrow.Timestamp(value.UnixMilli()))
+	builder.Field(1).(*array.Date32Builder).Append(arrow.Date32(18690))
+	builder.Field(2).(*array.Decimal128Builder).Append(decimal128.FromI64(-12345))
+	builder.Field(3).(*array.Int32Builder).Append(7)
+	listBuilder := builder.Field(4).(*array.ListBuilder)
+	listBuilder.Append(true)
+	listBuilder.ValueBuilder().(*array.Int64Builder).AppendValues([]int64{1, 2}, nil)
+	record := builder.NewRecord()
+	defer record.Release()
+
+	var buff bytes.Buffer
+	writer := ipc.NewWriter(&buff, ipc.WithSchema(arrowSchema), ipc.WithAllocator(mem))
+	assert.NoError(t, writer.Write(record))
+	assert.NoError(t, writer.Close())
+
+	source := ArrowDataSource{}
+	reader, err := source.Reader(bytes.NewReader(buff.Bytes()), map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, df.NewSchema([]df.SeriesSchema{
+		{Name: "ts", Format: df.DateTimeFormat},
+		{Name: "dt", Format: df.DateTimeFormat},
+		{Name: "dec", Format: df.DoubleFormat},
+		{Name: "i32", Format: df.IntegerFormat},
+		{Name: "list", Format: df.StringFormat},
+	}), reader.Schema())
+
+	dfData := *(reader.Data())
+	assert.Equal(t, 1, len(dfData))
+	assert.Equal(t, value, dfData[0].GetRaw(0))
+	assert.Equal(t, time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC), dfData[0].GetRaw(1))
+	assert.Equal(t, -123.45, dfData[0].GetRaw(2))
+	assert.Equal(t, int64(7), dfData[0].GetRaw(3))
+	assert.Equal(t, "[1,2]", dfData[0].GetRaw(4))
+}
+
+func TestArrowDataSourceReaderUnsigned(t *testing.T) {
+	mem := memory.NewGoAllocator()
+	arrowSchema := arrow.NewSchema([]arrow.Field{
+		{Name: "u8", Type: arrow.PrimitiveTypes.Uint8, Nullable: true},
+		{Name: "u64", Type: arrow.PrimitiveTypes.Uint64, Nullable: true},
+	}, nil)
+
+	builder := array.NewRecordBuilder(mem, arrowSchema)
+	defer builder.Release()
+	builder.Field(0).(*array.Uint8Builder).AppendValues([]uint8{255, 1}, nil)
+	builder.Field(1).(*array.Uint64Builder).AppendValues([]uint64{math.MaxInt64, math.MaxUint64}, nil)
+	record := builder.NewRecord()
+	defer record.Release()
+
+	var buff bytes.Buffer
+	writer := ipc.NewWriter(&buff, ipc.WithSchema(arrowSchema), ipc.WithAllocator(mem))
+	assert.NoError(t, writer.Write(record))
+	assert.NoError(t, writer.Close())
+
+	source := ArrowDataSource{}
+	reader, err := source.StreamReader(bytes.NewReader(buff.Bytes()), map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, df.IntegerFormat, reader.Schema().Get(0).Format)
+	assert.Equal(t, df.IntegerFormat, reader.Schema().Get(1).Format)
+	r, err := reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(255), int64(math.MaxInt64)}, testRowValues(r))
+	// values larger than max integer are reported instead of overflowing
+	_, err = reader.Next()
+	assert.Error(t, err)
+	assert.NoError(t, reader.Close())
+}
+
+// arrowTestField field of arrow schema, type is flatbuffer type id and types without params are supported
+type arrowTestField struct {
+	name       string
+	typ        byte
+	dictionary bool
+	children   []arrowTestField
+}
+
+// arrowTestSchema writes schema table, arrow go writers dont support large and dictionary types
+func arrowTestSchema(b *flatbuffers.Builder, fields []arrowTestField) flatbuffers.UOffsetT {
+	offsets := make([]flatbuffers.UOffsetT, len(fields))
+	for i, f := range fields {
+		name := b.CreateString(f.name)
+		b.StartObject(0)
+		typ := b.EndObject()
+		var dictionary flatbuffers.UOffsetT
+		if f.dictionary {
+			b.StartObject(2)
+			b.PrependInt32Slot(0, 32, 0)
+			b.PrependBoolSlot(1, true, false)
+			indexType := b.EndObject()
+			b.StartObject(4)
+			b.PrependUOffsetTSlot(1, indexType, 0)
+			dictionary = b.EndObject()
+		}
+		children := arrowTestSchema(b, f.children)
+		b.StartObject(7)
+		b.PrependUOffsetTSlot(0, name, 0)
+		b.PrependBoolSlot(1, true, false)
+		b.PrependByteSlot(2, f.typ, 0)
+		b.PrependUOffsetTSlot(3, typ, 0)
+		if f.dictionary {
+			b.PrependUOffsetTSlot(4, dictionary, 0)
+		}
+		// children of schema table are its fields
+		b.PrependUOffsetTSlot(5, children, 0)
+		offsets[i] = b.EndObject()
+	}
+	b.StartVector(4, len(offsets), 4)
+	for i := len(offsets) - 1; i >= 0; i-- {
+		b.PrependUOffsetT(offsets[i])
+	}
+	return b.EndVector(len(offsets))
+}
+
+func arrowTestSchemaTable(b *flatbuffers.Builder, fields []arrowTestField) flatbuffers.UOffsetT {
+	vector := arrowTestSchema(b, fields)
+	b.StartObject(4)
+	b.PrependUOffsetTSlot(1, vector, 0)
+	return b.EndObject()
+}
+
+func arrowTestUint32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return b
+}
+
+// arrowTestStream writes stream with schema message and end of stream marker
+func arrowTestStream(fields []arrowTestField) []byte {
+	b := flatbuffers.NewBuilder(0)
+	schema := arrowTestSchemaTable(b, fields)
+	b.StartObject(5)
+	b.PrependInt16Slot(0, 4, 0)
+	b.PrependByteSlot(1, 1, 0)
+	b.PrependUOffsetTSlot(2, schema, 0)
+	b.Finish(b.EndObject())
+	meta := b.FinishedBytes()
+	for len(meta)%8 != 0 {
+		meta = append(meta, 0)
+	}
+
+	data := append(arrowTestUint32(0xFFFFFFFF), arrowTestUint32(uint32(len(meta)))...)
+	data = append(data, meta...)
+	return append(append(data, arrowTestUint32(0xFFFFFFFF)...), arrowTestUint32(0)...)
+}
+
+// arrowTestFile writes file with schema in footer and without record batches
+func arrowTestFile(fields []arrowTestField) []byte {
+	data := append([]byte(arrowFileMagic+"\x00\x00"), arrowTestStream(fields)...)
+	b := flatbuffers.NewBuilder(0)
+	schema := arrowTestSchemaTable(b, fields)
+	b.StartObject(4)
+	b.PrependInt16Slot(0, 4, 0)
+	b.PrependUOffsetTSlot(1, schema, 0)
+	b.Finish(b.EndObject())
+	data = append(data, b.FinishedBytes()...)
+	data = append(data, arrowTestUint32(uint32(len(b.FinishedBytes())))...)
+	return append(data, arrowFileMagic...)
+}
+
+func TestArrowDataSourceReaderUnsupportedTypes(t *testing.T) {
+	source := ArrowDataSource{}
+	utf8 := arrowTestField{name: "a", typ: 5}
+	for name, write := range map[string]func([]arrowTestField) []byte{"stream": arrowTestStream, "file": arrowTestFile} {
+		reader, err := source.Reader(bytes.NewReader(write([]arrowTestField{utf8})), map[string]string{})
+		assert.NoError(t, err, name)
+		assert.Equal(t, df.NewSchema([]df.SeriesSchema{{Name: "a", Format: df.StringFormat}}), reader.Schema(), name)
+
+		for _, c := range []struct {
+			field arrowTestField
+			err   string
+		}{
+			{arrowTestField{name: "b", typ: 20}, "unsupported arrow column b, LargeUtf8 (large_string) type is not supported"},
+			{arrowTestField{name: "b", typ: 19}, "unsupported arrow column b, LargeBinary (large_binary) type is not supported"},
+			{arrowTestField{name: "b", typ: 5, dictionary: true}, "unsupported arrow column b, dictionary encoded type is not supported"},
+			{arrowTestField{name: "b", typ: 13, children: []arrowTestField{{name: "c", typ: 20}}}, "unsupported arrow column b, LargeUtf8 (large_string) type is not supported"},
+			{arrowTestField{name: "b", typ: 24}, "unsupported arrow column b, Type(24) type is not supported"},
+		} {
+			_, err = source.Reader(bytes.NewReader(write([]arrowTestField{utf8, c.field})), map[string]string{})
+			assert.EqualError(t, err, c.err, name)
+			// stdin is not seekable
+			_, err = source.StreamReader(io.MultiReader(bytes.NewReader(write([]arrowTestField{utf8, c.field}))), map[string]string{})
+			assert.EqualError(t, err, c.err, name)
+		}
+	}
+}
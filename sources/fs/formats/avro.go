@@ -0,0 +1,851 @@
+package formats
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/blue4209211/pq/internal/log"
+	"github.com/golang/snappy"
+)
+
+// ConfigAvroCodec While writing, compression codec of data blocks - null/deflate/snappy
+const ConfigAvroCodec = "avro.codec"
+
+// avroBlockSize number of records in each data block while writing
+const avroBlockSize = 4096
+
+// avroMaxBlockSize max size of data blocks and bytes/string values, sizes are read from file so
+// invalid sizes are rejected before allocating memory
+const avroMaxBlockSize = 1 << 30
+
+// avroMagic avro object container files start with magic bytes
+const avroMagic = "Obj\x01"
+
+// avroSyncSize size of sync marker written after header and each data block
+const avroSyncSize = 16
+
+var avroConfig = map[string]string{
+	ConfigAvroCodec: "deflate",
+}
+
+// AvroDataSource reads/writes avro object container files
+type AvroDataSource struct {
+}
+
+func (t *AvroDataSource) Args() map[string]string {
+	return avroConfig
+}
+
+func (t *AvroDataSource) Name() string {
+	return "avro"
+}
+
+func (t *AvroDataSource) Writer(data df.DataFrame, args map[string]string) (FormatWriter, error) {
+	return &avroDataSourceWriter{data: data, args: args}, nil
+}
+
+func (t *AvroDataSource) Reader(reader io.Reader, args map[string]string) (FormatReader, error) {
+	streamReader, err := t.StreamReader(reader, args)
+	if err != nil {
+		return nil, err
+	}
+	return ReadAll(streamReader)
+}
+
+// StreamReader reads one data block at a time, schema is derived from writer schema stored in file header
+func (t *AvroDataSource) StreamReader(reader io.Reader, args map[string]string) (FormatStreamReader, error) {
+	avroReader := &avroDataSourceReader{reader: bufio.NewReader(reader)}
+	err := avroReader.init()
+	if err != nil {
+		return nil, err
+	}
+	return newFormatStreamReader(avroReader, args)
+}
+
+// avroSchema parsed avro schema, named types (record/enum/fixed) are shared by all the references
+type avroSchema struct {
+	kind      string
+	name      string
+	logical   string
+	precision int
+	scale     int
+	size      int
+	fields    []avroField
+	symbols   []string
+	// items type of array items and map values
+	items    *avroSchema
+	branches []*avroSchema
+}
+
+type avroField struct {
+	name   string
+	schema *avroSchema
+}
+
+// avroFullName returns namespace qualified name, names having dot are already qualified
+func avroFullName(name string, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+func avroIsPrimitive(kind string) bool {
+	switch kind {
+	case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+		return true
+	}
+	return false
+}
+
+// avroParseSchema parses schema json, named types are registered in named
+func avroParseSchema(v any, namespace string, named map[string]*avroSchema) (*avroSchema, error) {
+	switch s := v.(type) {
+	case string:
+		if avroIsPrimitive(s) {
+			return &avroSchema{kind: s}, nil
+		}
+		if n, ok := named[avroFullName(s, namespace)]; ok {
+			return n, nil
+		}
+		if n, ok := named[s]; ok {
+			return n, nil
+		}
+		return nil, errors.New("unknown avro type - " + s)
+	case []any:
+		union := &avroSchema{kind: "union"}
+		for _, b := range s {
+			branch, err := avroParseSchema(b, namespace, named)
+			if err != nil {
+				return nil, err
+			}
+			union.branches = append(union.branches, branch)
+		}
+		return union, nil
+	case map[string]any:
+		kind, ok := s["type"].(string)
+		if !ok {
+			return avroParseSchema(s["type"], namespace, named)
+		}
+		schema := &avroSchema{kind: kind}
+		schema.logical, _ = s["logicalType"].(string)
+		if precision, ok := s["precision"].(float64); ok {
+			schema.precision = int(precision)
+		}
+		if scale, ok := s["scale"].(float64); ok {
+			schema.scale = int(scale)
+		}
+
+		switch kind {
+		case "record", "error", "enum", "fixed":
+			name, _ := s["name"].(string)
+			if ns, ok := s["namespace"].(string); ok && ns != "" {
+				namespace = ns
+			}
+			schema.name = avroFullName(name, namespace)
+			// registered before fields, so that records can refer themselves
+			named[schema.name] = schema
+			if i := strings.LastIndex(schema.name, "."); i >= 0 {
+				namespace = schema.name[:i]
+			}
+
+			switch kind {
+			case "enum":
+				symbols, _ := s["symbols"].([]any)
+				for _, symbol := range symbols {
+					schema.symbols = append(schema.symbols, fmt.Sprint(symbol))
+				}
+			case "fixed":
+				size, _ := s["size"].(float64)
+				schema.size = int(size)
+			default:
+				schema.kind = "record"
+				fields, _ := s["fields"].([]any)
+				for _, f := range fields {
+					field, ok := f.(map[string]any)
+					if !ok {
+						return nil, errors.New("invalid avro record field in " + schema.name)
+					}
+					fieldSchema, err := avroParseSchema(field["type"], namespace, named)
+					if err != nil {
+						return nil, err
+					}
+					fieldName, _ := field["name"].(string)
+					schema.fields = append(schema.fields, avroField{name: fieldName, schema: fieldSchema})
+				}
+			}
+		case "array":
+			items, err := avroParseSchema(s["items"], namespace, named)
+			if err != nil {
+				return nil, err
+			}
+			schema.items = items
+		case "map":
+			values, err := avroParseSchema(s["values"], namespace, named)
+			if err != nil {
+				return nil, err
+			}
+			schema.items = values
+		default:
+			if !avroIsPrimitive(kind) {
+				return avroParseSchema(kind, namespace, named)
+			}
+		}
+		return schema, nil
+	}
+	return nil, fmt.Errorf("invalid avro schema - %v", v)
+}
+
+// avroNullable returns non null branch of union with null, branch is -1 if schema is not nullable union
+func avroNullable(s *avroSchema) (*avroSchema, int) {
+	if s.kind != "union" || len(s.branches) != 2 {
+		return s, -1
+	}
+	if s.branches[0].kind == "null" {
+		return s.branches[1], 1
+	} else if s.branches[1].kind == "null" {
+		return s.branches[0], 0
+	}
+	return s, -1
+}
+
+func avroColumnFormat(s *avroSchema) df.Format {
+	switch s.logical {
+	case "timestamp-millis", "timestamp-micros", "timestamp-nanos", "local-timestamp-millis", "local-timestamp-micros", "local-timestamp-nanos", "date":
+		if s.kind == "int" || s.kind == "long" {
+			return df.DateTimeFormat
+		}
+	case "decimal":
+		if s.kind == "bytes" || s.kind == "fixed" {
+			return df.DoubleFormat
+		}
+	}
+
+	switch s.kind {
+	case "boolean":
+		return df.BoolFormat
+	case "int", "long":
+		return df.IntegerFormat
+	case "float", "double":
+		return df.DoubleFormat
+	}
+	// string, bytes, enum, fixed and complex types as json
+	return df.StringFormat
+}
+
+// avroColumn field of the record, fields of nested records are flattened into columns named parent.child
+type avroColumn struct {
+	schema *avroSchema
+	format df.Format
+	// flatten record columns are read into children
+	flatten  bool
+	children []avroColumn
+	// branch index of record in nullable union, -1 if record is not nullable
+	branch int
+	// leaves number of columns read by this column
+	leaves int
+}
+
+// avroColumns flattens fields of records, recursive records are read as json
+func avroColumns(fields []avroField, prefix string, visiting map[string]bool) (cols []avroColumn, series []df.SeriesSchema) {
+	for _, f := range fields {
+		s, branch := avroNullable(f.schema)
+		name := prefix + f.name
+		if s.kind == "record" && !visiting[s.name] {
+			visiting[s.name] = true
+			children, childSeries := avroColumns(s.fields, name+".", visiting)
+			delete(visiting, s.name)
+			cols = append(cols, avroColumn{schema: f.schema, flatten: true, children: children, branch: branch, leaves: len(childSeries)})
+			series = append(series, childSeries...)
+			continue
+		}
+		format := avroColumnFormat(s)
+		cols = append(cols, avroColumn{schema: f.schema, format: format, branch: -1, leaves: 1})
+		series = append(series, df.SeriesSchema{Name: name, Format: format})
+	}
+	return cols, series
+}
+
+type avroByteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+func avroReadLong(r avroByteReader) (int64, error) {
+	return binary.ReadVarint(r)
+}
+
+func avroReadBytes(r avroByteReader) ([]byte, error) {
+	n, err := avroReadLong(r)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n > avroMaxBlockSize {
+		return nil, fmt.Errorf("invalid avro bytes length %d", n)
+	}
+	return avroReadN(r, n)
+}
+
+// avroReadN reads n bytes, when remaining size is unknown buffer grows with data read,
+// so that sizes beyond end of file do not allocate memory upfront
+func avroReadN(r io.Reader, n int64) ([]byte, error) {
+	if b, ok := r.(*bytes.Reader); ok {
+		if int64(b.Len()) < n {
+			return nil, io.ErrUnexpectedEOF
+		}
+		data := make([]byte, n)
+		_, err := io.ReadFull(r, data)
+		return data, err
+	}
+	var buff bytes.Buffer
+	_, err := io.CopyN(&buff, r, n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return buff.Bytes(), err
+}
+
+// avroReadBlockCount reads count of array/map block, size of block is skipped for negative counts
+func avroReadBlockCount(r avroByteReader) (int64, error) {
+	n, err := avroReadLong(r)
+	if err != nil || n >= 0 {
+		return n, err
+	}
+	_, err = avroReadLong(r)
+	return -n, err
+}
+
+// avroDecode decodes value of given schema, records and maps are decoded as ordered json objects
+func avroDecode(r avroByteReader, s *avroSchema) (v any, err error) {
+	switch s.kind {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := r.ReadByte()
+		return b != 0, err
+	case "int", "long":
+		v, err = avroReadLong(r)
+	case "float":
+		var b [4]byte
+		_, err = io.ReadFull(r, b[:])
+		v = float64(math.Float32frombits(binary.LittleEndian.Uint32(b[:])))
+	case "double":
+		var b [8]byte
+		_, err = io.ReadFull(r, b[:])
+		v = math.Float64frombits(binary.LittleEndian.Uint64(b[:]))
+	case "bytes", "string":
+		var b []byte
+		b, err = avroReadBytes(r)
+		v = string(b)
+	case "fixed":
+		b := make([]byte, s.size)
+		_, err = io.ReadFull(r, b)
+		v = string(b)
+	case "enum":
+		i, err := avroReadLong(r)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(s.symbols)) {
+			return nil, fmt.Errorf("invalid avro enum index %d for %s", i, s.name)
+		}
+		return s.symbols[i], nil
+	case "union":
+		i, err := avroReadLong(r)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(s.branches)) {
+			return nil, fmt.Errorf("invalid avro union index %d", i)
+		}
+		return avroDecode(r, s.branches[i])
+	case "array":
+		values := []any{}
+		for {
+			n, err := avroReadBlockCount(r)
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				return values, nil
+			}
+			for ; n > 0; n-- {
+				item, err := avroDecode(r, s.items)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, item)
+			}
+		}
+	case "map":
+		obj := &arrowJSONObject{}
+		for {
+			n, err := avroReadBlockCount(r)
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				return obj, nil
+			}
+			for ; n > 0; n-- {
+				key, err := avroReadBytes(r)
+				if err != nil {
+					return nil, err
+				}
+				item, err := avroDecode(r, s.items)
+				if err != nil {
+					return nil, err
+				}
+				obj.keys = append(obj.keys, string(key))
+				obj.values = append(obj.values, item)
+			}
+		}
+	case "record":
+		obj := &arrowJSONObject{}
+		for _, f := range s.fields {
+			item, err := avroDecode(r, f.schema)
+			if err != nil {
+				return nil, err
+			}
+			obj.keys = append(obj.keys, f.name)
+			obj.values = append(obj.values, item)
+		}
+		return obj, nil
+	default:
+		return nil, errors.New("unsupported avro type - " + s.kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return avroLogicalValue(s, v), nil
+}
+
+// avroLogicalValue converts timestamp/date to time (UTC) and decimal to float
+func avroLogicalValue(s *avroSchema, v any) any {
+	switch s.logical {
+	case "timestamp-millis", "local-timestamp-millis":
+		if i, ok := v.(int64); ok {
+			return time.UnixMilli(i).UTC()
+		}
+	case "timestamp-micros", "local-timestamp-micros":
+		if i, ok := v.(int64); ok {
+			return time.UnixMicro(i).UTC()
+		}
+	case "timestamp-nanos", "local-timestamp-nanos":
+		if i, ok := v.(int64); ok {
+			return time.Unix(0, i).UTC()
+		}
+	case "date":
+		if i, ok := v.(int64); ok {
+			return time.Unix(i*24*60*60, 0).UTC()
+		}
+	case "decimal":
+		if b, ok := v.(string); ok {
+			// big-endian two's complement
+			unscaled := new(big.Int).SetBytes([]byte(b))
+			if len(b) > 0 && b[0]&0x80 != 0 {
+				unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+			}
+			scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(s.scale)), nil))
+			f, _ := new(big.Float).Quo(new(big.Float).SetInt(unscaled), scale).Float64()
+			return f
+		}
+	}
+	return v
+}
+
+func avroDecompress(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case "", "null":
+		return data, nil
+	case "deflate":
+		reader := flate.NewReader(bytes.NewReader(data))
+		defer reader.Close()
+		decoded, err := io.ReadAll(io.LimitReader(reader, avroMaxBlockSize+1))
+		if err == nil && len(decoded) > avroMaxBlockSize {
+			return nil, errors.New("invalid avro deflate block, decompressed size is too large")
+		}
+		return decoded, err
+	case "snappy":
+		// snappy blocks are followed by crc32 of uncompressed data
+		if len(data) < 4 {
+			return nil, errors.New("invalid avro snappy block")
+		}
+		size, err := snappy.DecodedLen(data[:len(data)-4])
+		if err != nil {
+			return nil, err
+		}
+		if size > avroMaxBlockSize {
+			return nil, errors.New("invalid avro snappy block, decompressed size is too large")
+		}
+		decoded, err := snappy.Decode(nil, data[:len(data)-4])
+		if err != nil {
+			return nil, err
+		}
+		if crc32.ChecksumIEEE(decoded) != binary.BigEndian.Uint32(data[len(data)-4:]) {
+			return nil, errors.New("invalid avro snappy block checksum")
+		}
+		return decoded, nil
+	}
+	return nil, errors.New("unsupported avro codec - " + codec)
+}
+
+type avroDataSourceReader struct {
+	reader    *bufio.Reader
+	codec     string
+	sync      []byte
+	cols      []avroColumn
+	schema    df.DataFrameSchema
+	block     *bytes.Reader
+	remaining int64
+	index     int64
+}
+
+func (t *avroDataSourceReader) Schema() (columns df.DataFrameSchema) {
+	return t.schema
+}
+
+func (t *avroDataSourceReader) Next() (r df.Row, err error) {
+	for t.remaining == 0 {
+		err = t.readBlock()
+		if err != nil {
+			return r, err
+		}
+	}
+
+	data := make([]any, t.schema.Len())
+	_, err = t.readColumns(t.cols, data, 0)
+	if err != nil {
+		return r, fmt.Errorf("unable to read avro record %d - %w", t.index, err)
+	}
+	t.remaining = t.remaining - 1
+	t.index = t.index + 1
+	return inmemory.NewRowFromAny(&t.schema, &data), nil
+}
+
+func (t *avroDataSourceReader) Close() error {
+	t.block = nil
+	return nil
+}
+
+func (t *avroDataSourceReader) readColumns(cols []avroColumn, data []any, index int) (int, error) {
+	for _, c := range cols {
+		if c.flatten {
+			if c.branch >= 0 {
+				branch, err := avroReadLong(t.block)
+				if err != nil {
+					return index, err
+				}
+				if branch != int64(c.branch) {
+					index = index + c.leaves
+					continue
+				}
+			}
+			i, err := t.readColumns(c.children, data, index)
+			if err != nil {
+				return index, err
+			}
+			index = i
+			continue
+		}
+
+		v, err := avroDecode(t.block, c.schema)
+		if err != nil {
+			return index, err
+		}
+		if _, ok := v.(string); !ok && v != nil && c.format == df.StringFormat {
+			text, err := json.Marshal(v)
+			if err != nil {
+				return index, err
+			}
+			v = string(text)
+		}
+		data[index] = v
+		index = index + 1
+	}
+	return index, nil
+}
+
+func (t *avroDataSourceReader) readBlock() error {
+	count, err := avroReadLong(t.reader)
+	if err != nil {
+		return err
+	}
+	size, err := avroReadLong(t.reader)
+	if err != nil {
+		return err
+	}
+	if count < 0 || size < 0 || size > avroMaxBlockSize {
+		return fmt.Errorf("invalid avro data block, count %d, size %d", count, size)
+	}
+
+	data, err := avroReadN(t.reader, size)
+	if err != nil {
+		return err
+	}
+	sync := make([]byte, avroSyncSize)
+	_, err = io.ReadFull(t.reader, sync)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(sync, t.sync) {
+		return errors.New("invalid avro sync marker")
+	}
+
+	data, err = avroDecompress(t.codec, data)
+	if err != nil {
+		return err
+	}
+	t.block = bytes.NewReader(data)
+	t.remaining = count
+	return nil
+}
+
+func (t *avroDataSourceReader) init() (err error) {
+	magic := make([]byte, len(avroMagic))
+	_, err = io.ReadFull(t.reader, magic)
+	if err != nil || string(magic) != avroMagic {
+		return errors.New("invalid avro file, missing magic bytes")
+	}
+
+	meta := map[string][]byte{}
+	for {
+		n, err := avroReadBlockCount(t.reader)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		for ; n > 0; n-- {
+			key, err := avroReadBytes(t.reader)
+			if err != nil {
+				return err
+			}
+			value, err := avroReadBytes(t.reader)
+			if err != nil {
+				return err
+			}
+			meta[string(key)] = value
+		}
+	}
+	t.sync = make([]byte, avroSyncSize)
+	_, err = io.ReadFull(t.reader, t.sync)
+	if err != nil {
+		return err
+	}
+	t.codec = string(meta["avro.codec"])
+
+	var schemaJSON any
+	err = json.Unmarshal(meta["avro.schema"], &schemaJSON)
+	if err != nil {
+		log.Error("unable to read avro schema", err)
+		return err
+	}
+	schema, err := avroParseSchema(schemaJSON, "", map[string]*avroSchema{})
+	if err != nil {
+		return err
+	}
+
+	// non record values are read as single column
+	fields := []avroField{{name: "value", schema: schema}}
+	visiting := map[string]bool{}
+	if schema.kind == "record" {
+		fields = schema.fields
+		visiting[schema.name] = true
+	}
+	cols, series := avroColumns(fields, "", visiting)
+	t.cols = cols
+	t.schema = df.NewSchema(series)
+	return nil
+}
+
+type avroDataSourceWriter struct {
+	data df.DataFrame
+	args map[string]string
+}
+
+// avroFieldName replaces characters not allowed in avro names with _
+func avroFieldName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')) {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+// avroWriterSchema all the columns are written as nullable fields of record
+func avroWriterSchema(schema df.DataFrameSchema) ([]byte, error) {
+	fields := make([]map[string]any, schema.Len())
+	for i, s := range schema.Series() {
+		var fieldType any
+		switch s.Format {
+		case df.BoolFormat:
+			fieldType = "boolean"
+		case df.IntegerFormat:
+			fieldType = "long"
+		case df.DoubleFormat:
+			fieldType = "double"
+		case df.DateTimeFormat:
+			fieldType = map[string]any{"type": "long", "logicalType": "timestamp-micros"}
+		default:
+			fieldType = "string"
+		}
+		fields[i] = map[string]any{"name": avroFieldName(s.Name), "type": []any{"null", fieldType}, "default": nil}
+	}
+	return json.Marshal(map[string]any{"type": "record", "name": "Record", "fields": fields})
+}
+
+func (t *avroDataSourceWriter) Write(writer io.Writer) (err error) {
+	codec := strings.ToLower(t.args[ConfigAvroCodec])
+	switch codec {
+	case "":
+		codec = avroConfig[ConfigAvroCodec]
+	case "none", "uncompressed":
+		codec = "null"
+	case "null", "deflate", "snappy":
+	default:
+		return errors.New("unsupported avro codec - " + t.args[ConfigAvroCodec])
+	}
+
+	schema, err := avroWriterSchema(t.data.Schema())
+	if err != nil {
+		return err
+	}
+	sync := make([]byte, avroSyncSize)
+	_, err = rand.Read(sync)
+	if err != nil {
+		return err
+	}
+
+	encoder := &avroEncoder{}
+	encoder.buff.WriteString(avroMagic)
+	encoder.writeLong(2)
+	encoder.writeBytes([]byte("avro.schema"))
+	encoder.writeBytes(schema)
+	encoder.writeBytes([]byte("avro.codec"))
+	encoder.writeBytes([]byte(codec))
+	encoder.writeLong(0)
+	encoder.buff.Write(sync)
+	_, err = writer.Write(encoder.buff.Bytes())
+	if err != nil {
+		return err
+	}
+
+	block := &avroEncoder{}
+	count := int64(0)
+	for i := int64(0); i < t.data.Len(); i++ {
+		r := t.data.GetRow(i)
+		for col := 0; col < r.Len(); col++ {
+			if r.IsNil(col) {
+				block.writeLong(0)
+				continue
+			}
+			block.writeLong(1)
+			switch r.Schema().Get(col).Format {
+			case df.BoolFormat:
+				if r.GetAsBool(col) {
+					block.buff.WriteByte(1)
+				} else {
+					block.buff.WriteByte(0)
+				}
+			case df.IntegerFormat:
+				block.writeLong(r.GetAsInt(col))
+			case df.DoubleFormat:
+				var b [8]byte
+				binary.LittleEndian.PutUint64(b[:], math.Float64bits(r.GetAsDouble(col)))
+				block.buff.Write(b[:])
+			case df.DateTimeFormat:
+				block.writeLong(r.GetAsDatetime(col).UnixMicro())
+			default:
+				block.writeBytes([]byte(r.GetAsString(col)))
+			}
+		}
+		count = count + 1
+
+		if count == avroBlockSize || i == t.data.Len()-1 {
+			err = avroWriteBlock(writer, codec, count, block.buff.Bytes(), sync)
+			if err != nil {
+				log.Error("unable to write avro block", err)
+				return err
+			}
+			block.buff.Reset()
+			count = 0
+		}
+	}
+	return nil
+}
+
+func avroWriteBlock(writer io.Writer, codec string, count int64, data []byte, sync []byte) (err error) {
+	switch codec {
+	case "deflate":
+		var buff bytes.Buffer
+		flateWriter, err := flate.NewWriter(&buff, flate.DefaultCompression)
+		if err != nil {
+			return err
+		}
+		_, err = flateWriter.Write(data)
+		if err != nil {
+			return err
+		}
+		err = flateWriter.Close()
+		if err != nil {
+			return err
+		}
+		data = buff.Bytes()
+	case "snappy":
+		checksum := crc32.ChecksumIEEE(data)
+		data = snappy.Encode(nil, data)
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], checksum)
+		data = append(data, b[:]...)
+	}
+
+	header := &avroEncoder{}
+	header.writeLong(count)
+	header.writeLong(int64(len(data)))
+	_, err = writer.Write(header.buff.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(sync)
+	return err
+}
+
+type avroEncoder struct {
+	buff bytes.Buffer
+}
+
+func (t *avroEncoder) writeLong(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], v)
+	t.buff.Write(b[:n])
+}
+
+func (t *avroEncoder) writeBytes(v []byte) {
+	t.writeLong(int64(len(v)))
+	t.buff.Write(v)
+}
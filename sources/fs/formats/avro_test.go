@@ -0,0 +1,204 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAvroDataSource(t *testing.T) {
+	source := AvroDataSource{}
+	assert.Equal(t, source.Name(), "avro")
+}
+
+func TestAvroDataSourceWriter(t *testing.T) {
+	dfSchema := df.NewSchema([]df.SeriesSchema{
+		{Name: "a", Format: df.IntegerFormat},
+		{Name: "b", Format: df.DoubleFormat},
+		{Name: "c", Format: df.StringFormat},
+		{Name: "d", Format: df.BoolFormat},
+		{Name: "e", Format: df.DateTimeFormat},
+	})
+	value := time.Date(2021, 3, 4, 5, 6, 7, 891234000, time.UTC)
+	rows := make([]df.Row, 0, avroBlockSize+2)
+	for i := 0; i < avroBlockSize+1; i++ {
+		rows = append(rows, inmemory.NewRowFromAny(&dfSchema, &([]any{int64(i), 1.5, "c1", true, value})))
+	}
+	rows = append(rows, inmemory.NewRowFromAny(&dfSchema, &([]any{nil, nil, nil, nil, nil})))
+	dataframe := inmemory.NewDataframeFromRow(dfSchema, &rows)
+
+	source := AvroDataSource{}
+	for _, codec := range []string{"", "null", "deflate", "snappy"} {
+		writer, err := source.Writer(dataframe, map[string]string{ConfigAvroCodec: codec})
+		assert.NoError(t, err)
+		var buff bytes.Buffer
+		assert.NoError(t, writer.Write(&buff))
+
+		reader, err := source.Reader(bytes.NewReader(buff.Bytes()), map[string]string{})
+		assert.NoError(t, err)
+		assert.Equal(t, dfSchema, reader.Schema())
+
+		dfData := *(reader.Data())
+		assert.Equal(t, len(rows), len(dfData))
+		assert.Equal(t, int64(avroBlockSize), dfData[avroBlockSize].GetRaw(0))
+		assert.Equal(t, 1.5, dfData[0].GetRaw(1))
+		assert.Equal(t, "c1", dfData[0].GetRaw(2))
+		assert.Equal(t, true, dfData[0].GetRaw(3))
+		assert.Equal(t, value, dfData[0].GetRaw(4))
+		for i := 0; i < dfSchema.Len(); i++ {
+			assert.True(t, dfData[len(rows)-1].IsNil(i))
+		}
+	}
+
+	writer, err := source.Writer(dataframe, map[string]string{ConfigAvroCodec: "lzma"})
+	assert.NoError(t, err)
+	assert.Error(t, writer.Write(new(bytes.Buffer)))
+
+	_, err = source.Reader(bytes.NewReader([]byte("a,b\n1,2\n")), map[string]string{})
+	assert.Error(t, err)
+}
+
+func TestAvroDataSourceReader(t *testing.T) {
+	schema := `{"type": "record", "name": "Event", "namespace": "com.example", "fields": [
+		{"name": "id", "type": "int"},
+		{"name": "address", "type": ["null", {"type": "record", "name": "Address", "fields": [
+			{"name": "city", "type": "string"},
+			{"name": "zip", "type": ["null", "long"]}
+		]}]},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "attrs", "type": {"type": "map", "values": "long"}},
+		{"name": "kind", "type": {"type": "enum", "name": "Kind", "symbols": ["A", "B"]}},
+		{"name": "choice", "type": ["int", "string"]},
+		{"name": "ts", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "day", "type": {"type": "int", "logicalType": "date"}},
+		{"name": "amount", "type": {"type": "bytes", "logicalType": "decimal", "precision": 5, "scale": 2}},
+		{"name": "next", "type": ["null", "Event"]},
+		{"name": "score", "type": "float"}
+	]}`
+	sync := []byte("0123456789abcdef")
+
+	block := &avroEncoder{}
+	// row 1
+	block.writeLong(1)
+	block.writeLong(1)
+	block.writeBytes([]byte("Pune"))
+	block.writeLong(1)
+	block.writeLong(411001)
+	block.writeLong(2)
+	block.writeBytes([]byte("x"))
+	block.writeBytes([]byte("y"))
+	block.writeLong(0)
+	block.writeLong(-1)
+	block.writeLong(2)
+	block.writeBytes([]byte("k"))
+	block.writeLong(5)
+	block.writeLong(0)
+	block.writeLong(0)
+	block.writeLong(0)
+	block.writeLong(7)
+	block.writeLong(1614834367891)
+	block.writeLong(18690)
+	block.writeBytes([]byte{0xcf, 0xc7})
+	block.writeLong(0)
+	block.buff.Write([]byte{0, 0, 0xc0, 0x3f})
+	// row 2
+	block.writeLong(2)
+	block.writeLong(0)
+	block.writeLong(0)
+	block.writeLong(1)
+	block.writeBytes([]byte("s"))
+	block.writeLong(0)
+	block.writeLong(0)
+	block.writeLong(1)
+	block.writeLong(1)
+	block.writeBytes([]byte("s"))
+	block.writeLong(0)
+	block.writeLong(0)
+	block.writeBytes([]byte{})
+	// next - recursive record
+	block.writeLong(1)
+	block.writeLong(3)
+	block.writeLong(0)
+	block.writeLong(0)
+	block.writeLong(0)
+	block.writeLong(0)
+	block.writeLong(0)
+	block.writeLong(0)
+	block.writeLong(0)
+	block.writeLong(0)
+	block.writeBytes([]byte{})
+	block.writeLong(0)
+	block.buff.Write([]byte{0, 0, 0, 0})
+	block.buff.Write([]byte{0, 0, 0, 0})
+
+	file := &avroEncoder{}
+	file.buff.WriteString(avroMagic)
+	file.writeLong(1)
+	file.writeBytes([]byte("avro.schema"))
+	file.writeBytes([]byte(schema))
+	file.writeLong(0)
+	file.buff.Write(sync)
+	file.writeLong(2)
+	file.writeBytes(block.buff.Bytes())
+	file.buff.Write(sync)
+
+	source := AvroDataSource{}
+	reader, err := source.Reader(bytes.NewReader(file.buff.Bytes()), map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, df.NewSchema([]df.SeriesSchema{
+		{Name: "id", Format: df.IntegerFormat},
+		{Name: "address.city", Format: df.StringFormat},
+		{Name: "address.zip", Format: df.IntegerFormat},
+		{Name: "tags", Format: df.StringFormat},
+		{Name: "attrs", Format: df.StringFormat},
+		{Name: "kind", Format: df.StringFormat},
+		{Name: "choice", Format: df.StringFormat},
+		{Name: "ts", Format: df.DateTimeFormat},
+		{Name: "day", Format: df.DateTimeFormat},
+		{Name: "amount", Format: df.DoubleFormat},
+		{Name: "next", Format: df.StringFormat},
+		{Name: "score", Format: df.DoubleFormat},
+	}), reader.Schema())
+
+	dfData := *(reader.Data())
+	assert.Equal(t, 2, len(dfData))
+	assert.Equal(t, []any{
+		int64(1), "Pune", int64(411001), `["x","y"]`, `{"k":5}`, "A", "7",
+		time.Date(2021, 3, 4, 5, 6, 7, 891000000, time.UTC), time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC), -123.45, nil, 1.5,
+	}, testRowValues(dfData[0]))
+	assert.Equal(t, []any{
+		int64(2), nil, nil, "[]", `{"s":0}`, "B", "s",
+		time.Unix(0, 0).UTC(), time.Unix(0, 0).UTC(), 0.0,
+		`{"id":3,"address":null,"tags":[],"attrs":{},"kind":"A","choice":0,"ts":"1970-01-01T00:00:00Z","day":"1970-01-01T00:00:00Z","amount":0,"next":null,"score":0}`, 0.0,
+	}, testRowValues(dfData[1]))
+
+	// corrupted sync marker
+	data := file.buff.Bytes()
+	data[len(data)-1] = 'x'
+	_, err = source.Reader(bytes.NewReader(data), map[string]string{})
+	assert.Error(t, err)
+}
+
+func TestAvroDataSourceReaderBlockSize(t *testing.T) {
+	dfSchema := df.NewSchema([]df.SeriesSchema{{Name: "a", Format: df.IntegerFormat}})
+	source := AvroDataSource{}
+	writer, err := source.Writer(inmemory.NewDataframeFromRow(dfSchema, &[]df.Row{}), map[string]string{})
+	assert.NoError(t, err)
+	var header bytes.Buffer
+	assert.NoError(t, writer.Write(&header))
+
+	// block sizes are rejected when negative or too large, sizes beyond end of file fail while reading
+	for _, size := range []int64{-1, avroMaxBlockSize + 1, 1 << 62, avroMaxBlockSize} {
+		data := append([]byte{}, header.Bytes()...)
+		buff := make([]byte, binary.MaxVarintLen64)
+		data = append(data, buff[:binary.PutVarint(buff, 1)]...)
+		data = append(data, buff[:binary.PutVarint(buff, size)]...)
+		_, err = source.Reader(bytes.NewReader(data), map[string]string{})
+		assert.Error(t, err, size)
+	}
+}
@@ -1,12 +1,12 @@
 package formats
 
 import (
-	"encoding/csv"
+	"bufio"
 	"errors"
+	"fmt"
 	"io"
 	"strconv"
-	"time"
-	"unicode/utf8"
+	"strings"
 
 	"github.com/blue4209211/pq/df"
 	"github.com/blue4209211/pq/df/inmemory"
@@ -16,12 +16,48 @@ import (
 // ConfigCsvHeader Is First valid line header
 const ConfigCsvHeader = "csv.hasHeader"
 
-// ConfigCsvSep File Seprator, Default = ,
+// ConfigCsvSep File Seprator, can be multiple characters, auto detects seprator, quote and header from data, Default = ,
 const ConfigCsvSep = "csv.sep"
 
+// ConfigCsvInferSchema Derive column types from sampled records, Default = false (all columns are string)
+const ConfigCsvInferSchema = "csv.inferSchema"
+
+// ConfigCsvQuote Quote character, empty disables quoting, Default = "
+const ConfigCsvQuote = "csv.quote"
+
+// ConfigCsvEscape Character used to escape quote inside quoted values, empty means quotes are doubled, Default = ""
+const ConfigCsvEscape = "csv.escape"
+
+// ConfigCsvComment Lines starting with this prefix are skipped, Default = "" (disabled)
+const ConfigCsvComment = "csv.comment"
+
+// ConfigCsvLazyQuotes Allow quotes inside unquoted and quoted values, Default = false
+const ConfigCsvLazyQuotes = "csv.lazyQuotes"
+
+// ConfigCsvSkipLines Number of lines to skip before reading header or records, Default = 0
+const ConfigCsvSkipLines = "csv.skipLines"
+
+// ConfigCsvNullValues Comma separated values (ex - \N,NULL) which are read as null, first value is used to write nulls, Default = ""
+const ConfigCsvNullValues = "csv.nullValues"
+
+// ConfigCsvEncoding Encoding of input (ex - utf-8, latin1, windows-1252, utf-16, utf-16be), Default = utf-8
+const ConfigCsvEncoding = "csv.encoding"
+
+// ConfigCsvQuoteMode Values quoted by writer - minimal, all, nonnumeric or none, Default = minimal
+const ConfigCsvQuoteMode = "csv.quoteMode"
+
 var csvConfig = map[string]string{
-	ConfigCsvHeader: "true",
-	ConfigCsvSep:    ",",
+	ConfigCsvHeader:      "true",
+	ConfigCsvSep:         ",",
+	ConfigCsvInferSchema: "false",
+	ConfigCsvQuote:       `"`,
+	ConfigCsvEscape:      "",
+	ConfigCsvComment:     "",
+	ConfigCsvLazyQuotes:  "false",
+	ConfigCsvSkipLines:   "0",
+	ConfigCsvNullValues:  "",
+	ConfigCsvEncoding:    "utf-8",
+	ConfigCsvQuoteMode:   csvQuoteMinimal,
 }
 
 type CsvDataSource struct {
@@ -36,9 +72,20 @@ func (t *CsvDataSource) Name() string {
 }
 
 func (t *CsvDataSource) Reader(reader io.Reader, args map[string]string) (FormatReader, error) {
+	streamReader, err := t.StreamReader(reader, args)
+	if err != nil {
+		return nil, err
+	}
+	return ReadAll(streamReader)
+}
+
+func (t *CsvDataSource) StreamReader(reader io.Reader, args map[string]string) (FormatStreamReader, error) {
 	csvReader := csvDataSourceReader{args: args}
 	err := csvReader.init(reader)
-	return &csvReader, err
+	if err != nil {
+		return nil, err
+	}
+	return newFormatStreamReader(&csvReader, args)
 }
 
 func (t *CsvDataSource) Writer(data df.DataFrame, args map[string]string) (FormatWriter, error) {
@@ -51,111 +98,267 @@ type csvDataSourceWriter struct {
 }
 
 func (t *csvDataSourceWriter) Write(writer io.Writer) (err error) {
-	csvWriter := csv.NewWriter(writer)
-	defer csvWriter.Flush()
-
-	seprator, err := csvGetColSeprator(t.args)
+	dialect, err := csvGetDialect(t.args)
 	if err != nil {
 		return
 	}
-	csvWriter.Comma = seprator
 	isHeader, err := csvIsHeaderEnabled(t.args)
 	if err != nil {
 		return
 	}
 
+	bufWriter := bufio.NewWriter(writer)
+	schema := t.data.Schema()
 	if isHeader {
-		schema := t.data.Schema()
-		cols := make([]string, schema.Len())
-
-		for i, c := range schema.Series() {
-			cols[i] = c.Name
-		}
-		csvWriter.Write(cols)
+		bufWriter.WriteString(dialect.format(schema.Names(), nil, nil) + "\n")
 	}
 
+	numeric := make([]bool, schema.Len())
+	for i, c := range schema.Series() {
+		numeric[i] = c.Format == df.IntegerFormat || c.Format == df.DoubleFormat
+	}
 	for i := int64(0); i < t.data.Len(); i++ {
 		rowInterface := t.data.GetRow(i)
 		row := make([]string, rowInterface.Len())
+		nulls := make([]bool, rowInterface.Len())
 		for j := 0; j < rowInterface.Len(); j++ {
-			str := ""
-			if !rowInterface.IsNil(j) {
-				str = rowInterface.GetAsString(j)
+			if rowInterface.IsNil(j) {
+				nulls[j] = true
+			} else {
+				row[j] = rowInterface.GetAsString(j)
 			}
-			row[j] = str
 		}
-		csvWriter.Write(row)
+		bufWriter.WriteString(dialect.format(row, nulls, numeric) + "\n")
 	}
-	return
+	return bufWriter.Flush()
 }
 
 type csvDataSourceReader struct {
-	args     map[string]string
-	isHeader bool
-	schema   df.DataFrameSchema
-	data     []df.Row
+	args      map[string]string
+	isHeader  bool
+	schema    df.DataFrameSchema
+	layouts   []string
+	csvParser *csvParser
+	pending   []csvRecord
+	// user schema columns are mapped to record fields using indexes, rowNumber is used in conversion errors
+	userSchema *userSchema
+	indexes    []int
+	rowNumber  int
+}
+
+// csvRecord record read from csv along with line number and parse error if any, nulls marks fields matching null values
+type csvRecord struct {
+	fields []string
+	nulls  []bool
+	line   int
+	err    error
 }
 
 func (t *csvDataSourceReader) Schema() df.DataFrameSchema {
 	return t.schema
 }
 
-func (t *csvDataSourceReader) Data() *[]df.Row {
-	return &t.data
+func (t *csvDataSourceReader) Next() (r df.Row, err error) {
+	var record csvRecord
+	if len(t.pending) > 0 {
+		record = t.pending[0]
+		t.pending[0] = csvRecord{}
+		t.pending = t.pending[1:]
+	} else {
+		record, err = t.read()
+		if err != nil {
+			return r, err
+		}
+	}
+	t.rowNumber++
+	if record.err != nil {
+		return r, t.malformed(record, record.err)
+	}
+	if t.userSchema != nil {
+		row, err := t.userSchema.convertRow(t.rowNumber, func(i int, name string) any {
+			if j := t.indexes[i]; j >= 0 && j < len(record.fields) && !record.nulls[j] {
+				return record.fields[j]
+			}
+			return nil
+		})
+		if err != nil {
+			return r, t.malformed(record, err)
+		}
+		return inmemory.NewRow(&t.schema, &row), nil
+	}
+
+	row := make([]df.Value, len(record.fields))
+	for j, cell := range record.fields {
+		if record.nulls[j] {
+			var format df.Format = df.StringFormat
+			if t.layouts != nil && j < t.schema.Len() {
+				format = t.schema.Get(j).Format
+			}
+			row[j] = inmemory.NewValue(format, nil)
+			continue
+		}
+		if t.layouts == nil || j >= t.schema.Len() {
+			row[j] = inmemory.NewStringValueConst(cell)
+			continue
+		}
+		row[j], err = parseInferredValue(t.schema.Get(j).Format, t.layouts[j], cell)
+		if err != nil {
+			return r, t.malformed(record, fmt.Errorf("csv : column %s at line %d, %w", t.schema.Get(j).Name, record.line, err))
+		}
+	}
+	return inmemory.NewRow(&t.schema, &row), nil
+}
+
+// read returns next record, parse errors (ex - wrong number of fields) are kept in record so that reading can continue
+func (t *csvDataSourceReader) read() (record csvRecord, err error) {
+	fields, nulls, line, err := t.csvParser.read()
+	if err == io.EOF {
+		return record, err
+	}
+	return csvRecord{fields: fields, nulls: nulls, line: line, err: err}, nil
 }
 
-func (t *csvDataSourceReader) init(reader io.Reader) (err error) {
-	startTime := time.Now()
+// malformed returns error with record content encoded as csv
+func (t *csvDataSourceReader) malformed(record csvRecord, err error) error {
+	return &malformedRecordError{record: t.csvParser.dialect.format(record.fields, record.nulls, nil), err: err}
+}
 
-	csvReader := csv.NewReader(reader)
-	seprator, err := csvGetColSeprator(t.args)
+func (t *csvDataSourceReader) hasUserSchema() bool {
+	return t.userSchema != nil
+}
+
+func (t *csvDataSourceReader) Close() error {
+	t.pending = nil
+	return nil
+}
+
+// init reads first record to build schema, if header is disabled then record is kept for Next
+func (t *csvDataSourceReader) init(reader io.Reader) (err error) {
+	dialect, err := csvGetDialect(t.args)
+	if err != nil {
+		return
+	}
+	reader, err = csvDecoder(reader, t.args[ConfigCsvEncoding])
 	if err != nil {
 		return
 	}
-	csvReader.Comma = seprator
 	isHeader, err := csvIsHeaderEnabled(t.args)
 	if err != nil {
 		return
 	}
+	if t.args[ConfigCsvSep] == CsvSepAuto {
+		bufReader := bufio.NewReaderSize(reader, csvSniffSize)
+		dialect, isHeader, err = csvSniff(bufReader, dialect, t.args)
+		if err != nil {
+			return
+		}
+		log.Debugf("csv detected seprator (%q), quote (%q), header (%v)", dialect.sep, dialect.quote, isHeader)
+		reader = bufReader
+	}
+	t.csvParser = newCsvParser(reader, dialect)
 	t.isHeader = isHeader
-	t.data = []df.Row{}
 
-	count := 0
-	for {
-		record, err := csvReader.Read()
+	skipLines, err := fixedIntArg(t.args, ConfigCsvSkipLines)
+	if err != nil {
+		return
+	}
+	for i := 0; i < skipLines; i++ {
+		_, err = t.csvParser.readLine()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	t.userSchema, err = readUserSchema(t.args)
+	if err != nil {
+		return err
+	}
+
+	record, nulls, line, err := t.csvParser.read()
+	if err == io.EOF {
+		t.schema = df.NewSchema([]df.SeriesSchema{})
+		if t.userSchema != nil {
+			t.schema = t.userSchema.schema
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	columns := make([]df.SeriesSchema, len(record))
+	for i, col := range record {
+		if t.isHeader {
+			columns[i] = df.SeriesSchema{Name: col, Format: df.StringFormat}
+		} else {
+			columns[i] = df.SeriesSchema{Name: "c" + strconv.Itoa(i), Format: df.StringFormat}
+		}
+	}
+	if !isHeader {
+		t.pending = append(t.pending, csvRecord{fields: record, nulls: nulls, line: line})
+	}
+
+	// user schema replaces inference, values are converted from raw fields
+	if t.userSchema != nil {
+		t.schema = t.userSchema.schema
+		sourceSchema := df.NewSchema(columns)
+		t.indexes = make([]int, t.schema.Len())
+		for i, c := range t.schema.Series() {
+			t.indexes[i] = sourceSchema.GetIndexByName(c.Name)
+		}
+		log.Debug("csv columns ", t.schema.Series())
+		return nil
+	}
+
+	inferSchema, err := isInferSchemaEnabled(t.args, ConfigCsvInferSchema)
+	if err != nil {
+		return err
+	}
+	if inferSchema {
+		err = t.inferSchema(columns)
 		if err != nil {
-			if err == io.EOF {
-				break
-			} else {
-				return err
-			}
+			return err
 		}
-		if count == 0 {
-			columns := make([]df.SeriesSchema, len(record))
-			f, _ := df.GetFormat("string")
-			for i, col := range record {
-				if t.isHeader {
-					columns[i] = df.SeriesSchema{Name: col, Format: f}
-				} else {
-					columns[i] = df.SeriesSchema{Name: "c" + strconv.Itoa(i), Format: f}
-				}
-			}
-			t.schema = df.NewSchema(columns)
-			if isHeader {
-				count = count + 1
-				continue
-			}
+	}
+	t.schema = df.NewSchema(columns)
+
+	log.Debug("csv columns ", t.schema.Series())
+	return
+}
+
+// inferSchema reads sample records and updates column formats, sampled records are kept for Next
+func (t *csvDataSourceReader) inferSchema(columns []df.SeriesSchema) (err error) {
+	sampleSize, err := schemaSampleSize(t.args)
+	if err != nil {
+		return err
+	}
+	for sampleSize <= 0 || len(t.pending) < sampleSize {
+		record, err := t.read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
 		}
+		t.pending = append(t.pending, record)
+	}
 
-		row := make([]df.Value, len(record))
-		for j, cell := range record {
-			row[j] = inmemory.NewStringValueConst(cell)
+	inferer := newSchemaInferer(t.args)
+	for _, record := range t.pending {
+		if record.err != nil {
+			continue
+		}
+		for i, cell := range record.fields {
+			if i < len(columns) && !record.nulls[i] {
+				inferer.add(columns[i].Name, cell)
+			}
 		}
-		t.data = append(t.data, inmemory.NewRow(&t.schema, &row))
-		count = count + 1
 	}
 
-	log.Debug("time to read csv data ", time.Since(startTime).String()+" records ", len(t.data))
+	t.layouts = make([]string, len(columns))
+	for i, c := range columns {
+		columns[i].Format, t.layouts[i] = inferer.column(c.Name)
+	}
 	return
 }
 
@@ -172,19 +375,19 @@ func csvIsHeaderEnabled(args map[string]string) (header bool, err error) {
 	return
 }
 
-func csvGetColSeprator(args map[string]string) (sep rune, err error) {
-	seprator, ok := args[ConfigCsvSep]
+func csvGetColSeprator(args map[string]string) (sep string, err error) {
+	sep, ok := args[ConfigCsvSep]
 	if !ok {
-		seprator = ","
+		sep = ","
 	}
 
-	if len(seprator) == 1 {
-		r, _ := utf8.DecodeRuneInString(seprator)
-		sep = r
-	} else if seprator == `\t` {
-		sep = '\t'
-	} else {
-		err = errors.New("Unsupported seprator - " + seprator)
+	if sep == `\t` {
+		sep = "\t"
+	} else if sep == CsvSepAuto {
+		// reader detects seprator from data, writer uses default
+		sep = ","
+	} else if sep == "" || strings.ContainsAny(sep, "\r\n") {
+		err = errors.New("Unsupported seprator - " + sep)
 	}
 
 	return
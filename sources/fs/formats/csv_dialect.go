@@ -0,0 +1,347 @@
+package formats
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	xunicode "golang.org/x/text/encoding/unicode"
+)
+
+// csv quote modes used by writer
+const (
+	csvQuoteMinimal    = "minimal"
+	csvQuoteAll        = "all"
+	csvQuoteNonNumeric = "nonnumeric"
+	csvQuoteNone       = "none"
+)
+
+// csvDialect describes how records are separated into fields, quote is 0 when quoting is disabled
+// and escape is 0 when quotes are escaped by doubling them
+type csvDialect struct {
+	sep        string
+	quote      rune
+	escape     rune
+	comment    string
+	lazyQuotes bool
+	nullValues []string
+	quoteMode  string
+}
+
+// csvGetDialect reads dialect from args, missing args use defaults of encoding/csv
+func csvGetDialect(args map[string]string) (dialect csvDialect, err error) {
+	dialect.sep, err = csvGetColSeprator(args)
+	if err != nil {
+		return dialect, err
+	}
+
+	dialect.quote = '"'
+	if quote, ok := args[ConfigCsvQuote]; ok {
+		dialect.quote, err = csvGetRune(ConfigCsvQuote, quote)
+		if err != nil {
+			return dialect, err
+		}
+	}
+	dialect.escape, err = csvGetRune(ConfigCsvEscape, args[ConfigCsvEscape])
+	if err != nil {
+		return dialect, err
+	}
+	if dialect.escape == dialect.quote {
+		dialect.escape = 0
+	}
+	if dialect.quote != 0 && strings.ContainsRune(dialect.sep, dialect.quote) {
+		return dialect, errors.New("csv : quote can not be part of seprator")
+	}
+
+	dialect.comment = args[ConfigCsvComment]
+	if lazy := args[ConfigCsvLazyQuotes]; lazy != "" {
+		dialect.lazyQuotes, err = isInferSchemaEnabled(args, ConfigCsvLazyQuotes)
+		if err != nil {
+			return dialect, err
+		}
+	}
+	for _, v := range strings.Split(args[ConfigCsvNullValues], ",") {
+		if v != "" {
+			dialect.nullValues = append(dialect.nullValues, v)
+		}
+	}
+
+	dialect.quoteMode = strings.ToLower(args[ConfigCsvQuoteMode])
+	switch dialect.quoteMode {
+	case "":
+		dialect.quoteMode = csvQuoteMinimal
+	case csvQuoteMinimal, csvQuoteAll, csvQuoteNonNumeric, csvQuoteNone:
+	default:
+		return dialect, errors.New("invalid " + ConfigCsvQuoteMode + " - " + dialect.quoteMode)
+	}
+	return dialect, nil
+}
+
+func csvGetRune(key string, value string) (r rune, err error) {
+	if value == "" {
+		return 0, nil
+	}
+	r, size := utf8.DecodeRuneInString(value)
+	if size != len(value) || r == '\n' || r == '\r' {
+		return r, errors.New("invalid " + key + " - " + value)
+	}
+	return r, nil
+}
+
+func (t csvDialect) isNull(v string) bool {
+	for _, n := range t.nullValues {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// format encodes fields as single record, nil fields are written using first null value
+func (t csvDialect) format(fields []string, nulls []bool, numeric []bool) string {
+	var sb strings.Builder
+	for i, field := range fields {
+		if i > 0 {
+			sb.WriteString(t.sep)
+		}
+		if i < len(nulls) && nulls[i] {
+			if len(t.nullValues) > 0 {
+				sb.WriteString(t.nullValues[0])
+			}
+			continue
+		}
+
+		quote := false
+		switch t.quoteMode {
+		case csvQuoteAll:
+			quote = true
+		case csvQuoteNonNumeric:
+			quote = i >= len(numeric) || !numeric[i]
+		case csvQuoteMinimal:
+			quote = t.needsQuotes(field)
+		}
+		if quote && t.quote != 0 {
+			sb.WriteRune(t.quote)
+			for _, r := range field {
+				if r == t.quote || (t.escape != 0 && r == t.escape) {
+					if t.escape != 0 {
+						sb.WriteRune(t.escape)
+					} else {
+						sb.WriteRune(t.quote)
+					}
+				}
+				sb.WriteRune(r)
+			}
+			sb.WriteRune(t.quote)
+			continue
+		}
+
+		// without quotes, special characters are escaped when escape is available
+		if t.escape != 0 {
+			for j := 0; j < len(field); {
+				if strings.HasPrefix(field[j:], t.sep) {
+					sb.WriteRune(t.escape)
+					sb.WriteString(t.sep)
+					j = j + len(t.sep)
+					continue
+				}
+				r, size := utf8.DecodeRuneInString(field[j:])
+				if r == t.escape || r == t.quote || r == '\n' {
+					sb.WriteRune(t.escape)
+				}
+				sb.WriteRune(r)
+				j = j + size
+			}
+			continue
+		}
+		sb.WriteString(field)
+	}
+	return sb.String()
+}
+
+// needsQuotes follows encoding/csv, fields with seprator, quotes, new lines or leading space are quoted
+// and fields equal to null values are quoted so that they are not read as null
+func (t csvDialect) needsQuotes(field string) bool {
+	if field == "" {
+		return false
+	}
+	if strings.Contains(field, t.sep) || strings.ContainsAny(field, "\r\n") || (t.quote != 0 && strings.ContainsRune(field, t.quote)) ||
+		(t.escape != 0 && strings.ContainsRune(field, t.escape)) || (t.comment != "" && strings.HasPrefix(field, t.comment)) || t.isNull(field) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(field)
+	return unicode.IsSpace(r)
+}
+
+// csvParser reads records using dialect, quoted fields can span multiple lines
+type csvParser struct {
+	reader          *bufio.Reader
+	dialect         csvDialect
+	line            int
+	fieldsPerRecord int
+}
+
+func newCsvParser(reader io.Reader, dialect csvDialect) *csvParser {
+	return &csvParser{reader: bufio.NewReader(reader), dialect: dialect, fieldsPerRecord: -1}
+}
+
+// readLine returns next line without line ending
+func (p *csvParser) readLine() (line string, err error) {
+	line, err = p.reader.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return line, err
+	}
+	p.line++
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+func (p *csvParser) parseError(line int, column int, msg string) error {
+	return fmt.Errorf("csv : parse error on line %d, column %d: %s", line, column, msg)
+}
+
+// read returns fields of next record and start line of the record, fields which match null values are marked in nulls.
+// record with different number of fields than first record is returned along with error
+func (p *csvParser) read() (fields []string, nulls []bool, recordLine int, err error) {
+	var line string
+	for {
+		line, err = p.readLine()
+		if err != nil {
+			return fields, nulls, recordLine, err
+		}
+		if line != "" && (p.dialect.comment == "" || !strings.HasPrefix(line, p.dialect.comment)) {
+			break
+		}
+	}
+	recordLine = p.line
+
+	d := p.dialect
+	pos := 0
+	var field strings.Builder
+	for {
+		field.Reset()
+		if d.quote != 0 && strings.HasPrefix(line[pos:], string(d.quote)) {
+			// quoted field
+			pos = pos + utf8.RuneLen(d.quote)
+			closed := false
+			for !closed {
+				if pos >= len(line) {
+					next, err := p.readLine()
+					if err == io.EOF {
+						if d.lazyQuotes {
+							break
+						}
+						return fields, nulls, recordLine, p.parseError(recordLine, pos+1, `extraneous or missing " in quoted-field`)
+					}
+					if err != nil {
+						return fields, nulls, recordLine, err
+					}
+					field.WriteByte('\n')
+					line = next
+					pos = 0
+					continue
+				}
+
+				r, size := utf8.DecodeRuneInString(line[pos:])
+				if d.escape != 0 && r == d.escape && pos+size < len(line) {
+					escaped, escapedSize := utf8.DecodeRuneInString(line[pos+size:])
+					field.WriteRune(escaped)
+					pos = pos + size + escapedSize
+					continue
+				}
+				if r != d.quote {
+					field.WriteRune(r)
+					pos = pos + size
+					continue
+				}
+
+				// doubled quote
+				if d.escape == 0 && strings.HasPrefix(line[pos+size:], string(d.quote)) {
+					field.WriteRune(d.quote)
+					pos = pos + 2*size
+					continue
+				}
+				if pos+size == len(line) || strings.HasPrefix(line[pos+size:], d.sep) {
+					pos = pos + size
+					closed = true
+				} else if d.lazyQuotes {
+					field.WriteRune(r)
+					pos = pos + size
+				} else {
+					return fields, nulls, recordLine, p.parseError(p.line, pos+size+1, `extraneous or missing " in quoted-field`)
+				}
+			}
+			fields = append(fields, field.String())
+			nulls = append(nulls, false)
+		} else {
+			// unquoted field, escaped characters are part of field
+			start := pos
+			for pos < len(line) && !strings.HasPrefix(line[pos:], d.sep) {
+				r, size := utf8.DecodeRuneInString(line[pos:])
+				if d.escape != 0 && r == d.escape && pos+size < len(line) {
+					escaped, escapedSize := utf8.DecodeRuneInString(line[pos+size:])
+					field.WriteRune(escaped)
+					pos = pos + size + escapedSize
+					continue
+				}
+				if d.quote != 0 && r == d.quote && !d.lazyQuotes {
+					return fields, nulls, recordLine, p.parseError(p.line, pos+1, `bare " in non-quoted field`)
+				}
+				field.WriteRune(r)
+				pos = pos + size
+			}
+			fields = append(fields, field.String())
+			nulls = append(nulls, d.isNull(line[start:pos]))
+		}
+
+		if pos >= len(line) {
+			break
+		}
+		// seprator
+		pos = pos + len(d.sep)
+		if pos == len(line) {
+			fields = append(fields, "")
+			nulls = append(nulls, d.isNull(""))
+			break
+		}
+	}
+
+	if p.fieldsPerRecord < 0 {
+		p.fieldsPerRecord = len(fields)
+	} else if p.fieldsPerRecord != len(fields) {
+		return fields, nulls, recordLine, fmt.Errorf("csv : record on line %d: wrong number of fields", recordLine)
+	}
+	return fields, nulls, recordLine, nil
+}
+
+// csvDecoder returns reader which converts data from encoding to utf-8
+func csvDecoder(reader io.Reader, name string) (io.Reader, error) {
+	var enc encoding.Encoding
+	switch strings.ToLower(strings.ReplaceAll(name, "_", "-")) {
+	case "", "utf-8", "utf8":
+		return reader, nil
+	case "latin1", "latin-1", "iso-8859-1":
+		enc = charmap.ISO8859_1
+	case "utf-16", "utf16", "utf-16le":
+		enc = xunicode.UTF16(xunicode.LittleEndian, xunicode.UseBOM)
+	case "utf-16be":
+		enc = xunicode.UTF16(xunicode.BigEndian, xunicode.UseBOM)
+	default:
+		var err error
+		enc, err = htmlindex.Get(name)
+		if err != nil {
+			return reader, errors.New("invalid " + ConfigCsvEncoding + " - " + name)
+		}
+	}
+	return enc.NewDecoder().Reader(reader), nil
+}
@@ -0,0 +1,213 @@
+package formats
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/blue4209211/pq/df"
+)
+
+// CsvSepAuto value of ConfigCsvSep which detects separator, quote and header from start of the data
+const CsvSepAuto = "auto"
+
+// csvSniffSize number of bytes sampled to detect dialect
+const csvSniffSize = 64 * 1024
+
+// csvSniffRecords max number of sampled records used to detect dialect
+const csvSniffRecords = 100
+
+// csvSniffSeprators candidates in order of preference
+var csvSniffSeprators = []string{",", "\t", ";", "|"}
+
+// csvSniffQuotes candidates in order of preference
+var csvSniffQuotes = []rune{'"', '\''}
+
+// csvSniffConsistency min ratio of sampled records having same number of fields for data to be treated as csv
+const csvSniffConsistency = 0.9
+
+// CsvSniffConsistent returns true when sample at the start of reader is split into same number (> 1) of fields
+// for most of the records by one of separators, used to detect whether text files are csv
+func CsvSniffConsistent(reader io.Reader, args map[string]string) (bool, error) {
+	dialect, err := csvGetDialect(args)
+	if err != nil {
+		return false, err
+	}
+	sample, skipLines, err := csvSniffSample(bufio.NewReaderSize(reader, csvSniffSize), args)
+	if err != nil {
+		return false, err
+	}
+	dialect.quote = csvSniffQuote(sample, dialect.quote)
+	_, records, _, score := csvSniffSeprator(sample, dialect, skipLines)
+	return len(records) > 1 && score >= csvSniffConsistency, nil
+}
+
+// csvSniff detects separator, quote and header from sample at the start of reader, reader is not consumed.
+func csvSniff(reader *bufio.Reader, dialect csvDialect, args map[string]string) (csvDialect, bool, error) {
+	sample, skipLines, err := csvSniffSample(reader, args)
+	if err != nil {
+		return dialect, true, err
+	}
+
+	dialect.quote = csvSniffQuote(sample, dialect.quote)
+	dialect, records, nulls, _ := csvSniffSeprator(sample, dialect, skipLines)
+	// lazy quotes are used only for detection
+	lazyQuotes, err := isInferSchemaEnabled(args, ConfigCsvLazyQuotes)
+	if err != nil {
+		return dialect, true, err
+	}
+	dialect.lazyQuotes = lazyQuotes
+	if records == nil {
+		return dialect, true, nil
+	}
+	return dialect, csvSniffHeader(records, nulls, dialect, args), nil
+}
+
+// csvSniffSample returns complete lines from start of reader along with number of lines to skip, reader is not consumed
+func csvSniffSample(reader *bufio.Reader, args map[string]string) (sample string, skipLines int, err error) {
+	data, err := reader.Peek(csvSniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return sample, skipLines, err
+	}
+	sample = string(data)
+	// last line can be incomplete
+	if len(data) == csvSniffSize {
+		if i := strings.LastIndexByte(sample, '\n'); i > 0 {
+			sample = sample[:i+1]
+		}
+	}
+	skipLines, err = fixedIntArg(args, ConfigCsvSkipLines)
+	return sample, skipLines, err
+}
+
+// csvSniffSeprator selects separator which splits most of the records into same number (> 1) of fields,
+// score is ratio of records having that number of fields, records are nil when none of separators split the records
+func csvSniffSeprator(sample string, dialect csvDialect, skipLines int) (selected csvDialect, records [][]string, nulls [][]bool, score float64) {
+	selected = dialect
+	dialect.lazyQuotes = true
+	for _, sep := range csvSniffSeprators {
+		candidate := dialect
+		candidate.sep = sep
+		candidateRecords, candidateNulls := csvSniffRecordsOf(sample, candidate, skipLines)
+
+		counts := map[int]int{}
+		for _, r := range candidateRecords {
+			counts[len(r)]++
+		}
+		fields, frequency := 0, 0
+		for c, f := range counts {
+			if f > frequency || (f == frequency && c > fields) {
+				fields, frequency = c, f
+			}
+		}
+		if fields < 2 {
+			continue
+		}
+		if candidateScore := float64(frequency) / float64(len(candidateRecords)); candidateScore > score {
+			score = candidateScore
+			selected = candidate
+			records, nulls = candidateRecords, candidateNulls
+		}
+	}
+	return
+}
+
+// csvSniffQuote returns quote which starts most of the fields, configured quote is used if none of quotes are found
+func csvSniffQuote(sample string, quote rune) rune {
+	best := 0
+	for _, q := range csvSniffQuotes {
+		count := 0
+		for i := strings.IndexRune(sample, q); i >= 0; {
+			prev, _ := utf8.DecodeLastRuneInString(sample[:i])
+			if i == 0 || prev == '\n' || strings.ContainsRune(strings.Join(csvSniffSeprators, ""), prev) {
+				count++
+			}
+			next := strings.IndexRune(sample[i+1:], q)
+			if next < 0 {
+				break
+			}
+			i = i + 1 + next
+		}
+		if count > best {
+			best = count
+			quote = q
+		}
+	}
+	return quote
+}
+
+func csvSniffRecordsOf(sample string, dialect csvDialect, skipLines int) (records [][]string, nulls [][]bool) {
+	parser := newCsvParser(strings.NewReader(sample), dialect)
+	for i := 0; i < skipLines; i++ {
+		if _, err := parser.readLine(); err != nil {
+			return
+		}
+	}
+	for len(records) < csvSniffRecords {
+		fields, fieldNulls, _, err := parser.read()
+		if err == io.EOF {
+			break
+		}
+		// field count errors are kept as they are part of scoring
+		if fields == nil {
+			break
+		}
+		records = append(records, fields)
+		nulls = append(nulls, fieldNulls)
+	}
+	return
+}
+
+// csvSniffHeader first record is header when its values do not match types of column values in rest of records,
+// for string columns header is detected when all values have same length and header length is different
+func csvSniffHeader(records [][]string, nulls [][]bool, dialect csvDialect, args map[string]string) bool {
+	if len(records) < 2 {
+		return true
+	}
+	header := records[0]
+	inferer := newSchemaInferer(args)
+	lengths := make([]int, len(header))
+	for i := range lengths {
+		lengths[i] = -1
+	}
+	for r, record := range records[1:] {
+		if len(record) != len(header) {
+			continue
+		}
+		for i, v := range record {
+			if nulls[r+1][i] {
+				continue
+			}
+			inferer.add(strconv.Itoa(i), v)
+			if lengths[i] == -1 || lengths[i] == utf8.RuneCountInString(v) {
+				lengths[i] = utf8.RuneCountInString(v)
+			} else {
+				lengths[i] = -2
+			}
+		}
+	}
+
+	votes := 0
+	for i, v := range header {
+		if v == "" || dialect.isNull(v) {
+			continue
+		}
+		format, layout := inferer.column(strconv.Itoa(i))
+		if format != df.StringFormat {
+			if _, err := parseInferredValue(format, layout, v); err != nil {
+				votes++
+			} else {
+				votes--
+			}
+		} else if lengths[i] >= 0 {
+			if lengths[i] != utf8.RuneCountInString(v) {
+				votes++
+			} else {
+				votes--
+			}
+		}
+	}
+	return votes >= 0
+}
@@ -1,9 +1,13 @@
 package formats
 
 import (
+	"bytes"
+	"io"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/blue4209211/pq/df"
 	"github.com/blue4209211/pq/df/inmemory"
 	"github.com/stretchr/testify/assert"
 )
@@ -85,11 +89,97 @@ func TestCSVDataSourceReaderNoHeaderDifferentSep(t *testing.T) {
 	// wrong seprator
 	_, err = source.Reader(strings.NewReader(csvString), map[string]string{
 		ConfigCsvHeader: "false",
-		ConfigCsvSep:    "^^",
+		ConfigCsvSep:    "",
 	})
 	assert.Error(t, err)
 }
 
+func TestCSVDataSourceDialect(t *testing.T) {
+	source := CsvDataSource{}
+
+	csvString := "generated by export\n" +
+		"a^^b^^c\n" +
+		"# comment\n" +
+		"1^^'x^^y'^^'it\\'s'\n" +
+		"\n" +
+		"2^^'multi\nline'^^\\N\n" +
+		"3^^NULL^^''\n"
+
+	csvReader, err := source.Reader(strings.NewReader(csvString), map[string]string{
+		ConfigCsvSep:        "^^",
+		ConfigCsvQuote:      "'",
+		ConfigCsvEscape:     "\\",
+		ConfigCsvComment:    "#",
+		ConfigCsvSkipLines:  "1",
+		ConfigCsvNullValues: "\\N,NULL",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, csvReader.Schema().Names())
+	data := *(csvReader.Data())
+	assert.Equal(t, 3, len(data))
+	assert.Equal(t, []any{"1", "x^^y", "it's"}, testRowValues(data[0]))
+	assert.Equal(t, []any{"2", "multi\nline", nil}, testRowValues(data[1]))
+	assert.Equal(t, []any{"3", nil, ""}, testRowValues(data[2]))
+
+	// lazy quotes
+	csvString = "a,b\n1,x\"y\n2,\"say \"hi\" now\"\n"
+	_, err = source.Reader(strings.NewReader(csvString), map[string]string{})
+	assert.Error(t, err)
+	csvReader, err = source.Reader(strings.NewReader(csvString), map[string]string{ConfigCsvLazyQuotes: "true"})
+	assert.NoError(t, err)
+	data = *(csvReader.Data())
+	assert.Equal(t, "x\"y", data[0].GetRaw(1))
+	assert.Equal(t, "say \"hi\" now", data[1].GetRaw(1))
+
+	// nulls are skipped while inferring schema
+	csvReader, err = source.Reader(strings.NewReader("a,b\n1,NULL\nNULL,2.5\n"), map[string]string{
+		ConfigCsvInferSchema: "true",
+		ConfigCsvNullValues:  "NULL",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, df.IntegerFormat, csvReader.Schema().Get(0).Format)
+	assert.Equal(t, df.DoubleFormat, csvReader.Schema().Get(1).Format)
+	data = *(csvReader.Data())
+	assert.Equal(t, []any{int64(1), nil}, testRowValues(data[0]))
+	assert.Equal(t, []any{nil, 2.5}, testRowValues(data[1]))
+
+	for _, args := range []map[string]string{
+		{ConfigCsvQuote: "''"},
+		{ConfigCsvSep: ",\"", ConfigCsvQuote: "\""},
+		{ConfigCsvSkipLines: "x"},
+		{ConfigCsvLazyQuotes: "x"},
+		{ConfigCsvEncoding: "unknown"},
+	} {
+		_, err = source.Reader(strings.NewReader(csvString), args)
+		assert.Error(t, err, args)
+	}
+}
+
+func TestCSVDataSourceEncoding(t *testing.T) {
+	source := CsvDataSource{}
+
+	csvReader, err := source.Reader(bytes.NewReader([]byte("name\ncaf\xe9\n")), map[string]string{ConfigCsvEncoding: "latin1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "café", (*csvReader.Data())[0].GetRaw(0))
+
+	utf16 := []byte{}
+	for _, r := range "a,b\n1,é\n" {
+		utf16 = append(utf16, byte(r), byte(r>>8))
+	}
+	csvReader, err = source.Reader(bytes.NewReader(utf16), map[string]string{ConfigCsvEncoding: "utf-16"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, csvReader.Schema().Names())
+	assert.Equal(t, []any{"1", "é"}, testRowValues((*csvReader.Data())[0]))
+
+	utf16BE := []byte{0xFE, 0xFF}
+	for _, r := range "a\nü\n" {
+		utf16BE = append(utf16BE, byte(r>>8), byte(r))
+	}
+	csvReader, err = source.Reader(bytes.NewReader(utf16BE), map[string]string{ConfigCsvEncoding: "utf-16be"})
+	assert.NoError(t, err)
+	assert.Equal(t, "ü", (*csvReader.Data())[0].GetRaw(0))
+}
+
 func TestCSVDataSourceWriter(t *testing.T) {
 	source := CsvDataSource{}
 
@@ -155,3 +245,154 @@ func BenchmarkCSVParsing(b *testing.B) {
 	})
 
 }
+
+func TestCSVDataSourceStreamReader(t *testing.T) {
+	source := CsvDataSource{}
+
+	csvString :=
+		`1,2,"c1","d1"
+3,4,"c2","d,2"
+`
+
+	csvReader, err := source.StreamReader(strings.NewReader(csvString), map[string]string{
+		ConfigCsvHeader: "false",
+	})
+	assert.NoError(t, err)
+	defer csvReader.Close()
+	assert.Equal(t, 4, csvReader.Schema().Len())
+
+	r, err := csvReader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "1", r.GetRaw(0))
+	r, err = csvReader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "d,2", r.GetRaw(3))
+	_, err = csvReader.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestCSVDataSourceInferSchema(t *testing.T) {
+	source := CsvDataSource{}
+
+	csvString :=
+		`a,b,c,d,e,f
+1,2.5,true,2022-01-02,x,
+2,3,false,2022-01-03 10:11:12,y,
+,,,,z,
+4,5,true,2022-01-04,5,
+`
+
+	csvReader, err := source.Reader(strings.NewReader(csvString), map[string]string{
+		ConfigCsvInferSchema:        "true",
+		ConfigSchemaDatetimeFormats: "2006-01-02,2006-01-02 15:04:05",
+	})
+	assert.NoError(t, err)
+	schema := csvReader.Schema()
+	assert.Equal(t, df.IntegerFormat, schema.Get(0).Format)
+	assert.Equal(t, df.DoubleFormat, schema.Get(1).Format)
+	assert.Equal(t, df.BoolFormat, schema.Get(2).Format)
+	assert.Equal(t, df.StringFormat, schema.Get(3).Format)
+	assert.Equal(t, df.StringFormat, schema.Get(4).Format)
+	assert.Equal(t, df.StringFormat, schema.Get(5).Format)
+
+	data := *(csvReader.Data())
+	assert.Equal(t, 4, len(data))
+	assert.Equal(t, int64(1), data[0].GetRaw(0))
+	assert.Equal(t, 3.0, data[1].GetRaw(1))
+	assert.Equal(t, false, data[1].GetRaw(2))
+	assert.True(t, data[2].IsNil(0))
+	assert.Equal(t, "", data[2].GetRaw(5))
+
+	// datetime, values outside of sample are converted while reading
+	csvString =
+		`a,b
+1,2022-01-02
+2,2022-01-03
+x,2022-01-04
+`
+	streamReader, err := source.StreamReader(strings.NewReader(csvString), map[string]string{
+		ConfigCsvInferSchema:   "true",
+		ConfigSchemaSampleSize: "2",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, df.IntegerFormat, streamReader.Schema().Get(0).Format)
+	assert.Equal(t, df.DateTimeFormat, streamReader.Schema().Get(1).Format)
+	r, err := streamReader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC), r.GetRaw(1))
+	_, err = streamReader.Next()
+	assert.NoError(t, err)
+	_, err = streamReader.Next()
+	assert.Error(t, err)
+
+	_, err = source.Reader(strings.NewReader(csvString), map[string]string{
+		ConfigCsvInferSchema: "yes",
+	})
+	assert.Error(t, err)
+}
+
+func TestCSVDataSourceSniffer(t *testing.T) {
+	source := CsvDataSource{}
+
+	cases := []struct {
+		name     string
+		data     string
+		names    []string
+		expected []any
+	}{
+		{"semicolon", "id;name;price\n1;'a;b';1,5\n2;c;2,5\n", []string{"id", "name", "price"}, []any{"1", "a;b", "1,5"}},
+		{"tab without header", "1\tx\ttrue\n2\ty\tfalse\n", []string{"c0", "c1", "c2"}, []any{"1", "x", "true"}},
+		{"pipe", "code|city\nAB|\"x|y\"\nCD|paris\n", []string{"code", "city"}, []any{"AB", "x|y"}},
+		{"strings without header", "AB,xyz\nCD,pqr\n", []string{"c0", "c1"}, []any{"AB", "xyz"}},
+		{"single column", "name\nx\n", []string{"name"}, []any{"x"}},
+	}
+	for _, c := range cases {
+		csvReader, err := source.Reader(strings.NewReader(c.data), map[string]string{
+			ConfigCsvSep:    CsvSepAuto,
+			ConfigCsvHeader: "true",
+		})
+		assert.NoError(t, err, c.name)
+		assert.Equal(t, c.names, csvReader.Schema().Names(), c.name)
+		assert.Equal(t, c.expected, testRowValues((*csvReader.Data())[0]), c.name)
+	}
+
+	// lines skipped before header are not part of detection, sampled records are read again
+	data := "report\n" + "a,b\n" + strings.Repeat("1,2\n", csvSniffSize/4)
+	csvReader, err := source.Reader(strings.NewReader(data), map[string]string{
+		ConfigCsvSep:         CsvSepAuto,
+		ConfigCsvSkipLines:   "1",
+		ConfigCsvInferSchema: "true",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, csvReader.Schema().Names())
+	assert.Equal(t, df.IntegerFormat, csvReader.Schema().Get(0).Format)
+	assert.Equal(t, csvSniffSize/4, len(*csvReader.Data()))
+
+	// writer uses default seprator
+	writer, err := source.Writer(inmemory.NewDataframeFromRowAndName("df_1", csvReader.Schema(), csvReader.Data()), map[string]string{ConfigCsvSep: CsvSepAuto})
+	assert.NoError(t, err)
+	buff := new(strings.Builder)
+	assert.NoError(t, writer.Write(buff))
+	assert.True(t, strings.HasPrefix(buff.String(), "a,b\n1,2\n"))
+}
+
+func TestCSVSniffConsistent(t *testing.T) {
+	cases := []struct {
+		name     string
+		data     string
+		expected bool
+	}{
+		{"comma", "a,b\n1,2\n3,4\n", true},
+		{"tab", "a\tb\tc\n1\t2\t3\n", true},
+		{"quoted separator", "a,b\n\"x,y\",2\n", true},
+		{"plain text", "first line\nsecond line\nthird line\n", false},
+		{"text with some commas", "hello, world\nno commas here\nanother line\nlast, line, here\n", false},
+		{"single line", "a,b\n", false},
+		{"empty", "", false},
+	}
+	for _, c := range cases {
+		isCsv, err := CsvSniffConsistent(strings.NewReader(c.data), map[string]string{ConfigCsvSep: CsvSepAuto})
+		assert.NoError(t, err, c.name)
+		assert.Equal(t, c.expected, isCsv, c.name)
+	}
+}
@@ -0,0 +1,186 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+)
+
+// documentRecordReader reads rows from parsed documents (yaml/toml), list at rootNode is read as rows
+// and single object is read as single row, decode returns io.EOF once all the documents are read
+type documentRecordReader struct {
+	format   string
+	rootNode string
+	decode   func() (any, error)
+	pending  []any
+}
+
+func (t *documentRecordReader) next() (objMap map[string]any, err error) {
+	for len(t.pending) == 0 {
+		doc, err := t.decode()
+		if err != nil {
+			return objMap, err
+		}
+		t.pending, err = documentRows(t.format, doc, t.rootNode)
+		if err != nil {
+			return objMap, err
+		}
+	}
+	item := t.pending[0]
+	t.pending[0] = nil
+	t.pending = t.pending[1:]
+
+	obj, ok := documentObject(item)
+	if !ok {
+		return objMap, &malformedRecordError{record: fmt.Sprint(item), err: errors.New(t.format + " : row is not an object")}
+	}
+	objMap = make(map[string]any, len(obj))
+	for k, v := range obj {
+		objMap[k], err = documentValue(v)
+		if err != nil {
+			return objMap, malformedMapRecord(objMap, errors.New(t.format+" : column "+k+", "+err.Error()))
+		}
+	}
+	return objMap, nil
+}
+
+// documentRows returns items of list at rootNode (dot separated path)
+func documentRows(format string, doc any, rootNode string) (rows []any, err error) {
+	if rootNode != "" {
+		for _, key := range strings.Split(rootNode, ".") {
+			obj, ok := documentObject(doc)
+			if !ok {
+				return rows, errors.New(format + " : unable to find rootNode - " + rootNode)
+			}
+			doc, ok = obj[key]
+			if !ok {
+				return rows, errors.New(format + " : unable to find rootNode - " + rootNode)
+			}
+		}
+	}
+
+	switch d := doc.(type) {
+	case nil:
+		return rows, nil
+	case []any:
+		return d, nil
+	default:
+		return []any{d}, nil
+	}
+}
+
+// documentObject returns map with string keys, yaml uses map[any]any for mappings with non string keys
+func documentObject(v any) (obj map[string]any, ok bool) {
+	switch m := v.(type) {
+	case map[string]any:
+		return m, true
+	case map[any]any:
+		obj = make(map[string]any, len(m))
+		for k, v := range m {
+			obj[fmt.Sprint(k)] = v
+		}
+		return obj, true
+	}
+	return obj, false
+}
+
+// documentValue converts parsed value to column value, nested values are converted to JSON text
+func documentValue(v any) (any, error) {
+	switch d := v.(type) {
+	case nil, string, bool, int64, float64:
+		return d, nil
+	case int:
+		return int64(d), nil
+	case uint64:
+		if d > math.MaxInt64 {
+			return float64(d), nil
+		}
+		return int64(d), nil
+	case time.Time:
+		return d.UTC(), nil
+	case []any, map[string]any, map[any]any:
+		data, err := json.Marshal(documentJSON(d))
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	default:
+		return fmt.Sprint(d), nil
+	}
+}
+
+// documentJSON converts nested value so that it can be encoded as JSON
+func documentJSON(v any) any {
+	switch d := v.(type) {
+	case []any:
+		items := make([]any, len(d))
+		for i, item := range d {
+			items[i] = documentJSON(item)
+		}
+		return items
+	case map[string]any, map[any]any:
+		obj, _ := documentObject(d)
+		items := make(map[string]any, len(obj))
+		for k, item := range obj {
+			items[k] = documentJSON(item)
+		}
+		return items
+	case float64:
+		if math.IsInf(d, 0) || math.IsNaN(d) {
+			return fmt.Sprint(d)
+		}
+		return d
+	case time.Time:
+		return d.UTC()
+	default:
+		return d
+	}
+}
+
+// documentWriterValue returns value of column to write, JSON objects/arrays in string columns
+// are decoded so that they are written as nested values
+func documentWriterValue(format df.Format, v any) any {
+	s, ok := v.(string)
+	if !ok || format != df.StringFormat {
+		return v
+	}
+	trimmed := strings.TrimSpace(s)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') || !json.Valid([]byte(trimmed)) {
+		return v
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(trimmed)))
+	decoder.UseNumber()
+	var nested any
+	if decoder.Decode(&nested) != nil {
+		return v
+	}
+	return documentNumbers(nested)
+}
+
+// documentNumbers converts json numbers to int64/float64
+func documentNumbers(v any) any {
+	switch d := v.(type) {
+	case []any:
+		for i, item := range d {
+			d[i] = documentNumbers(item)
+		}
+	case map[string]any:
+		for k, item := range d {
+			d[k] = documentNumbers(item)
+		}
+	case json.Number:
+		if i, err := d.Int64(); err == nil {
+			return i
+		}
+		f, _ := d.Float64()
+		return f
+	}
+	return v
+}
@@ -0,0 +1,319 @@
+package formats
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+)
+
+// ConfigFixedSpec comma separated columns as name:start:length[:format[:layout]] (start is 1 based character position),
+// value without : is treated as path of spec file having one column per line
+const ConfigFixedSpec = "fixed.spec"
+
+// ConfigFixedTrim Trim spaces around values while reading, Default = true
+const ConfigFixedTrim = "fixed.trim"
+
+// ConfigFixedSkipLines Number of lines (ex - headers) to skip before reading records
+const ConfigFixedSkipLines = "fixed.skipLines"
+
+// ConfigFixedRecordLength Expected number of characters in each record, records with different length are malformed, 0 disables validation
+const ConfigFixedRecordLength = "fixed.recordLength"
+
+var fixedConfig = map[string]string{
+	ConfigFixedSpec:         "",
+	ConfigFixedTrim:         "true",
+	ConfigFixedSkipLines:    "0",
+	ConfigFixedRecordLength: "0",
+}
+
+// fixedColumn column of fixed width record, start is 0 based
+type fixedColumn struct {
+	name   string
+	start  int
+	length int
+	format df.Format
+	layout string
+}
+
+type FixedDataSource struct {
+}
+
+func (t *FixedDataSource) Args() map[string]string {
+	return fixedConfig
+}
+
+func (t *FixedDataSource) Name() string {
+	return "fixed"
+}
+
+func (t *FixedDataSource) Reader(reader io.Reader, args map[string]string) (FormatReader, error) {
+	streamReader, err := t.StreamReader(reader, args)
+	if err != nil {
+		return nil, err
+	}
+	return ReadAll(streamReader)
+}
+
+func (t *FixedDataSource) StreamReader(reader io.Reader, args map[string]string) (FormatStreamReader, error) {
+	spec, ok := args[ConfigFixedSpec]
+	if !ok || strings.TrimSpace(spec) == "" {
+		return nil, errors.New("fixed : " + ConfigFixedSpec + " is required")
+	}
+	cols, err := fixedParseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	trim := true
+	if s := args[ConfigFixedTrim]; s != "" {
+		trim, err = strconv.ParseBool(s)
+		if err != nil {
+			return nil, errors.New("invalid " + ConfigFixedTrim + " - " + s)
+		}
+	}
+	skipLines, err := fixedIntArg(args, ConfigFixedSkipLines)
+	if err != nil {
+		return nil, err
+	}
+	recordLength, err := fixedIntArg(args, ConfigFixedRecordLength)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaCols := make([]df.SeriesSchema, len(cols))
+	layouts := datetimeFormats(args)
+	for i, c := range cols {
+		schemaCols[i] = df.SeriesSchema{Name: c.name, Format: c.format}
+		if c.format == df.DateTimeFormat && c.layout == "" {
+			cols[i].layout = layouts[0]
+		}
+	}
+
+	fixedReader := &fixedDataSourceReader{
+		lineReader:   &textDataSourceReader{reader: bufio.NewReader(reader), textData: make([]byte, 0, 10000)},
+		schema:       df.NewSchema(schemaCols),
+		cols:         cols,
+		trim:         trim,
+		recordLength: recordLength,
+	}
+	for i := 0; i < skipLines; i++ {
+		_, err = fixedReader.lineReader.readLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newFormatStreamReader(fixedReader, args)
+}
+
+func (t *FixedDataSource) Writer(data df.DataFrame, args map[string]string) (FormatWriter, error) {
+	return &fixedDataSourceWriter{data: data, args: args}, nil
+}
+
+// fixedParseSpec parses columns from spec or spec file
+func fixedParseSpec(spec string) (cols []fixedColumn, err error) {
+	if !strings.Contains(spec, ":") {
+		data, err := os.ReadFile(strings.TrimSpace(spec))
+		if err != nil {
+			return cols, errors.New("fixed : unable to read spec file - " + err.Error())
+		}
+		spec = string(data)
+	}
+
+	names := map[string]bool{}
+	for _, line := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 5)
+		if len(parts) < 3 {
+			return cols, errors.New("fixed : invalid column spec, expected name:start:length[:format[:layout]] - " + line)
+		}
+		c := fixedColumn{name: strings.TrimSpace(parts[0]), format: df.StringFormat}
+		start, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || start < 1 {
+			return cols, errors.New("fixed : invalid start of column - " + line)
+		}
+		c.start = start - 1
+		c.length, err = strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err != nil || c.length < 1 {
+			return cols, errors.New("fixed : invalid length of column - " + line)
+		}
+		if len(parts) > 3 {
+			c.format, err = df.GetFormat(strings.TrimSpace(parts[3]))
+			if err != nil {
+				return cols, errors.New("fixed : invalid format of column - " + line)
+			}
+		}
+		if len(parts) > 4 {
+			c.layout = parts[4]
+		}
+		if c.name == "" || names[c.name] {
+			return cols, errors.New("fixed : invalid or duplicate column name - " + line)
+		}
+		names[c.name] = true
+		cols = append(cols, c)
+	}
+	if len(cols) == 0 {
+		return cols, errors.New("fixed : no columns found in spec")
+	}
+	return cols, nil
+}
+
+func fixedIntArg(args map[string]string, key string) (i int, err error) {
+	s := args[key]
+	if s == "" {
+		return 0, nil
+	}
+	i, err = strconv.Atoi(s)
+	if err != nil || i < 0 {
+		return i, errors.New("invalid " + key + " - " + s)
+	}
+	return i, nil
+}
+
+type fixedDataSourceReader struct {
+	lineReader   *textDataSourceReader
+	schema       df.DataFrameSchema
+	cols         []fixedColumn
+	trim         bool
+	recordLength int
+}
+
+func (t *fixedDataSourceReader) Schema() df.DataFrameSchema {
+	return t.schema
+}
+
+func (t *fixedDataSourceReader) Next() (r df.Row, err error) {
+	line, err := t.lineReader.readLine()
+	if err != nil {
+		return r, err
+	}
+	record := []rune(line)
+	if t.recordLength > 0 && len(record) != t.recordLength {
+		return r, &malformedRecordError{record: line, err: errors.New("fixed : expected record length " + strconv.Itoa(t.recordLength) + ", found " + strconv.Itoa(len(record)))}
+	}
+
+	row := make([]df.Value, len(t.cols))
+	for i, c := range t.cols {
+		// short records have nil values for missing columns
+		if c.start >= len(record) {
+			row[i] = inmemory.NewValue(c.format, nil)
+			continue
+		}
+		end := c.start + c.length
+		if end > len(record) {
+			end = len(record)
+		}
+		v := string(record[c.start:end])
+		if t.trim || c.format != df.StringFormat {
+			v = strings.TrimSpace(v)
+		}
+		row[i], err = parseInferredValue(c.format, c.layout, v)
+		if err != nil {
+			return r, &malformedRecordError{record: line, err: errors.New("fixed : column " + c.name + ", " + err.Error())}
+		}
+	}
+	return inmemory.NewRow(&t.schema, &row), nil
+}
+
+func (t *fixedDataSourceReader) Close() error {
+	return nil
+}
+
+type fixedDataSourceWriter struct {
+	data df.DataFrame
+	args map[string]string
+}
+
+// Write pads values to column length, numbers are aligned right and rest left. When spec is not provided,
+// columns are written one after another with length of longest value
+func (t *fixedDataSourceWriter) Write(writer io.Writer) (err error) {
+	schema := t.data.Schema()
+	layout := datetimeFormats(t.args)[0]
+	values := make([][]string, 0, t.data.Len())
+	t.data.ForEachRow(func(r df.Row) {
+		sa := make([]string, r.Len())
+		for i := 0; i < r.Len(); i++ {
+			if r.IsNil(i) {
+				continue
+			}
+			if d, ok := r.GetRaw(i).(time.Time); ok {
+				sa[i] = d.Format(layout)
+			} else {
+				sa[i] = r.GetAsString(i)
+			}
+		}
+		values = append(values, sa)
+	})
+
+	var cols []fixedColumn
+	if spec := t.args[ConfigFixedSpec]; strings.TrimSpace(spec) != "" {
+		cols, err = fixedParseSpec(spec)
+		if err != nil {
+			return err
+		}
+	} else {
+		start := 0
+		for i, c := range schema.Series() {
+			length := 1
+			for _, sa := range values {
+				if l := utf8.RuneCountInString(sa[i]); l > length {
+					length = l
+				}
+			}
+			cols = append(cols, fixedColumn{name: c.Name, start: start, length: length})
+			start = start + length
+		}
+	}
+
+	indexes := make([]int, len(cols))
+	recordLength := 0
+	for i, c := range cols {
+		indexes[i] = -1
+		for j, name := range schema.Names() {
+			if name == c.name {
+				indexes[i] = j
+			}
+		}
+		if indexes[i] < 0 {
+			return errors.New("fixed : column not found - " + c.name)
+		}
+		if c.start+c.length > recordLength {
+			recordLength = c.start + c.length
+		}
+	}
+
+	bufWriter := bufio.NewWriter(writer)
+	record := make([]rune, recordLength)
+	for _, sa := range values {
+		for i := range record {
+			record[i] = ' '
+		}
+		for i, c := range cols {
+			v := []rune(sa[indexes[i]])
+			if len(v) > c.length {
+				return errors.New("fixed : value of column " + c.name + " is longer than " + strconv.Itoa(c.length) + " - " + string(v))
+			}
+			offset := c.start
+			format := schema.Get(indexes[i]).Format
+			if format == df.IntegerFormat || format == df.DoubleFormat {
+				offset = c.start + c.length - len(v)
+			}
+			copy(record[offset:], v)
+		}
+		bufWriter.WriteString(string(record) + "\n")
+	}
+	return bufWriter.Flush()
+}
@@ -0,0 +1,116 @@
+package formats
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedDataSource(t *testing.T) {
+	source := FixedDataSource{}
+	assert.Equal(t, source.Name(), "fixed")
+}
+
+func TestFixedDataSourceReader(t *testing.T) {
+	source := FixedDataSource{}
+	fixedString := "ID   NAME    JOINED\n" +
+		"00001 alice  20210304\n" +
+		"   22bob     20210305\n" +
+		"xx   carl            \n" +
+		"3\n"
+
+	reader, err := source.Reader(strings.NewReader(fixedString), map[string]string{
+		ConfigFixedSpec:      "id:1:5:integer, name:6:8, joined:14:8:datetime:20060102",
+		ConfigFixedSkipLines: "1",
+		ConfigMode:           ModeDropMalformed,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, df.NewSchema([]df.SeriesSchema{
+		{Name: "id", Format: df.IntegerFormat},
+		{Name: "name", Format: df.StringFormat},
+		{Name: "joined", Format: df.DateTimeFormat},
+	}), reader.Schema())
+	data := *(reader.Data())
+	assert.Equal(t, 3, len(data))
+	assert.Equal(t, []any{int64(1), "alice", time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)}, testRowValues(data[0]))
+	assert.Equal(t, []any{int64(22), "bob", time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC)}, testRowValues(data[1]))
+	assert.Equal(t, []any{int64(3), nil, nil}, testRowValues(data[2]))
+
+	// record length validation and spaces are kept
+	reader, err = source.Reader(strings.NewReader(fixedString), map[string]string{
+		ConfigFixedSpec:         "name:6:8",
+		ConfigFixedTrim:         "false",
+		ConfigFixedRecordLength: "21",
+		ConfigMode:              ModeDropMalformed,
+	})
+	assert.NoError(t, err)
+	data = *(reader.Data())
+	assert.Equal(t, 3, len(data))
+	assert.Equal(t, " alice  ", data[0].GetRaw(0))
+	assert.Equal(t, "carl    ", data[2].GetRaw(0))
+
+	_, err = source.Reader(strings.NewReader(fixedString), map[string]string{ConfigFixedSpec: "id:1:5:integer", ConfigFixedSkipLines: "1"})
+	assert.Error(t, err)
+
+	for _, spec := range []string{"", "id:1", "id:0:5", "id:1:x", "id:1:5:short", "id:1:5,id:6:2", "missing.spec"} {
+		_, err = source.Reader(strings.NewReader(fixedString), map[string]string{ConfigFixedSpec: spec})
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestFixedDataSourceSpecFile(t *testing.T) {
+	specPath := filepath.Join(t.TempDir(), "columns.spec")
+	assert.NoError(t, os.WriteFile(specPath, []byte("# id of user\nid:1:2:integer\nts:3:8:datetime:15:04:05\n"), 0644))
+
+	source := FixedDataSource{}
+	reader, err := source.Reader(strings.NewReader(" 712:13:14\n"), map[string]string{ConfigFixedSpec: specPath})
+	assert.NoError(t, err)
+	data := *(reader.Data())
+	assert.Equal(t, []any{int64(7), time.Date(0, 1, 1, 12, 13, 14, 0, time.UTC)}, testRowValues(data[0]))
+}
+
+func TestFixedDataSourceWriter(t *testing.T) {
+	dfSchema := df.NewSchema([]df.SeriesSchema{
+		{Name: "a", Format: df.IntegerFormat},
+		{Name: "b", Format: df.StringFormat},
+		{Name: "c", Format: df.DateTimeFormat},
+	})
+	value := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	rows := []df.Row{
+		inmemory.NewRowFromAny(&dfSchema, &([]any{int64(1), "abc", value})),
+		inmemory.NewRowFromAny(&dfSchema, &([]any{int64(123), nil, nil})),
+	}
+	dataframe := inmemory.NewDataframeFromRow(dfSchema, &rows)
+
+	source := FixedDataSource{}
+	writer, err := source.Writer(dataframe, map[string]string{})
+	assert.NoError(t, err)
+	var buff bytes.Buffer
+	assert.NoError(t, writer.Write(&buff))
+	assert.Equal(t, "  1abc2021-03-04T05:06:07Z\n123                       \n", buff.String())
+
+	reader, err := source.Reader(&buff, map[string]string{ConfigFixedSpec: "a:1:3:integer,b:4:3,c:7:20:datetime"})
+	assert.NoError(t, err)
+	data := *(reader.Data())
+	assert.Equal(t, []any{int64(1), "abc", value}, testRowValues(data[0]))
+	assert.Equal(t, []any{int64(123), "", nil}, testRowValues(data[1]))
+
+	writer, err = source.Writer(dataframe, map[string]string{ConfigFixedSpec: "b:1:4,a:6:5", ConfigSchemaDatetimeFormats: "2006-01-02"})
+	assert.NoError(t, err)
+	buff.Reset()
+	assert.NoError(t, writer.Write(&buff))
+	assert.Equal(t, "abc      1\n       123\n", buff.String())
+
+	for _, spec := range []string{"a:1:2", "x:1:2"} {
+		writer, err = source.Writer(dataframe, map[string]string{ConfigFixedSpec: spec})
+		assert.NoError(t, err)
+		assert.Error(t, writer.Write(new(bytes.Buffer)), spec)
+	}
+}
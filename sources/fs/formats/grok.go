@@ -0,0 +1,148 @@
+package formats
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+)
+
+// grokPattern regex of named pattern, layouts are used to parse values of datetime fields
+type grokPattern struct {
+	expr    string
+	layouts []string
+}
+
+// grokPatterns subset of commonly used logstash grok patterns, go regex doesnt support lookarounds
+// so patterns are simplified versions of logstash patterns
+var grokPatterns = map[string]grokPattern{
+	"WORD":              {expr: `\b\w+\b`},
+	"NOTSPACE":          {expr: `\S+`},
+	"SPACE":             {expr: `\s*`},
+	"DATA":              {expr: `.*?`},
+	"GREEDYDATA":        {expr: `.*`},
+	"INT":               {expr: `[+-]?\d+`},
+	"POSINT":            {expr: `\b[1-9]\d*\b`},
+	"NONNEGINT":         {expr: `\b\d+\b`},
+	"NUMBER":            {expr: `[+-]?(?:\d+(?:\.\d*)?|\.\d+)(?:[eE][+-]?\d+)?`},
+	"BASE10NUM":         {expr: `[+-]?(?:\d+(?:\.\d*)?|\.\d+)`},
+	"QUOTEDSTRING":      {expr: `"(?:[^"\\]|\\.)*"`},
+	"QS":                {expr: `%{QUOTEDSTRING}`},
+	"UUID":              {expr: `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`},
+	"USERNAME":          {expr: `[a-zA-Z0-9._-]+`},
+	"USER":              {expr: `%{USERNAME}`},
+	"EMAILADDRESS":      {expr: `[a-zA-Z0-9!#$%&'*+/=?^_{|}~.-]+@[a-zA-Z0-9.-]+`},
+	"IPV4":              {expr: `(?:\d{1,3}\.){3}\d{1,3}`},
+	"IPV6":              {expr: `[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}(?:%\w+)?`},
+	"IP":                {expr: `(?:%{IPV4}|%{IPV6})`},
+	"HOSTNAME":          {expr: `\b[0-9A-Za-z][0-9A-Za-z_-]*(?:\.[0-9A-Za-z][0-9A-Za-z_-]*)*\.?\b`},
+	"IPORHOST":          {expr: `(?:%{IP}|%{HOSTNAME})`},
+	"HOSTPORT":          {expr: `%{IPORHOST}:%{POSINT}`},
+	"PATH":              {expr: `(?:/[^\s]*|[A-Za-z]:\\[^\s]*)`},
+	"URIPATHPARAM":      {expr: `\S+`},
+	"URI":               {expr: `[A-Za-z][A-Za-z0-9+.-]*://\S+`},
+	"LOGLEVEL":          {expr: `(?i:trace|debug|info|notice|warn(?:ing)?|err(?:or)?|crit(?:ical)?|fatal|severe|emerg(?:ency)?|alert)`},
+	"JSON":              {expr: `\{.*\}`},
+	"PROG":              {expr: `[\w._/%-]+`},
+	"HTTPDATE":          {expr: `\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`, layouts: []string{"02/Jan/2006:15:04:05 -0700"}},
+	"SYSLOGTIMESTAMP":   {expr: `\w{3} +\d{1,2} \d{2}:\d{2}:\d{2}`, layouts: []string{time.Stamp}},
+	"TIMESTAMP_ISO8601": {expr: `\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(?::\d{2}(?:[.,]\d+)?)?(?:Z|[+-]\d{2}:?\d{2})?`, layouts: []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999Z0700", "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999Z07:00", "2006-01-02 15:04:05.999999999", "2006-01-02 15:04:05,999999999", "2006-01-02T15:04", "2006-01-02 15:04"}},
+	"DATE":              {expr: `\d{4}-\d{2}-\d{2}`, layouts: []string{"2006-01-02"}},
+	"TIME":              {expr: `\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?`},
+	"COMMONAPACHELOG":   {expr: `%{IPORHOST:clientip} %{USER:ident} %{USER:auth} \[%{HTTPDATE:timestamp:datetime}\] "(?:%{WORD:verb} %{NOTSPACE:request}(?: HTTP/%{NUMBER:httpversion})?|%{DATA:rawrequest})" %{INT:response:int} (?:%{INT:bytes:int}|-)`},
+	"COMBINEDAPACHELOG": {expr: `%{COMMONAPACHELOG} "%{DATA:referrer}" "%{DATA:agent}"`},
+	"SYSLOGLINE":        {expr: `(?:<%{NONNEGINT:priority:int}>)?%{SYSLOGTIMESTAMP:timestamp} %{IPORHOST:logsource} %{PROG:program}(?:\[%{POSINT:pid:int}\])?: %{GREEDYDATA:message}`},
+}
+
+// grokPresets patterns which can be used by name as text.pattern
+var grokPresets = map[string]string{
+	"apache":   "%{COMBINEDAPACHELOG}",
+	"nginx":    "%{COMBINEDAPACHELOG}",
+	"combined": "%{COMBINEDAPACHELOG}",
+	"common":   "%{COMMONAPACHELOG}",
+	"syslog":   "%{SYSLOGLINE}",
+	"json":     `^%{DATA:prefix}\s*%{JSON:json}\s*$`,
+}
+
+var grokRefRegex = regexp.MustCompile(`%\{(\w+)(?::([\w.@-]+))?(?::(\w+))?\}`)
+
+// grokField column captured by grok expression
+type grokField struct {
+	name    string
+	format  df.Format
+	typed   bool
+	layouts []string
+}
+
+// grokCompile expands grok references (%{PATTERN:name:type}) into regex with named groups,
+// returned fields are keyed by group names used in regex
+func grokCompile(pattern string) (expr string, fields map[string]grokField, err error) {
+	if preset, ok := grokPresets[strings.ToLower(pattern)]; ok {
+		pattern = preset
+	}
+	fields = map[string]grokField{}
+	expr, err = grokExpand(pattern, fields, 0)
+	return expr, fields, err
+}
+
+func grokExpand(pattern string, fields map[string]grokField, depth int) (expr string, err error) {
+	if depth > 20 {
+		return expr, errors.New("text : grok patterns are nested too deep")
+	}
+	var sb strings.Builder
+	last := 0
+	for _, m := range grokRefRegex.FindAllStringSubmatchIndex(pattern, -1) {
+		sb.WriteString(pattern[last:m[0]])
+		last = m[1]
+
+		name := pattern[m[2]:m[3]]
+		p, ok := grokPatterns[name]
+		if !ok {
+			return expr, errors.New("text : unknown grok pattern - " + name)
+		}
+		nested, err := grokExpand(p.expr, fields, depth+1)
+		if err != nil {
+			return expr, err
+		}
+		if m[4] < 0 {
+			sb.WriteString("(?:" + nested + ")")
+			continue
+		}
+
+		field := grokField{name: pattern[m[4]:m[5]], format: df.StringFormat}
+		if m[6] >= 0 {
+			field.format, err = grokFormat(pattern[m[6]:m[7]])
+			if err != nil {
+				return expr, err
+			}
+			field.typed = true
+			if field.format == df.DateTimeFormat {
+				field.layouts = p.layouts
+			}
+		}
+		group := "_g" + strconv.Itoa(len(fields))
+		fields[group] = field
+		sb.WriteString("(?P<" + group + ">" + nested + ")")
+	}
+	sb.WriteString(pattern[last:])
+	return sb.String(), nil
+}
+
+func grokFormat(t string) (df.Format, error) {
+	switch strings.ToLower(t) {
+	case "int", "long", "integer":
+		return df.IntegerFormat, nil
+	case "float", "double":
+		return df.DoubleFormat, nil
+	case "bool", "boolean":
+		return df.BoolFormat, nil
+	case "datetime", "date", "timestamp":
+		return df.DateTimeFormat, nil
+	case "string":
+		return df.StringFormat, nil
+	}
+	return df.StringFormat, errors.New("text : unknown grok type - " + t)
+}
@@ -0,0 +1,150 @@
+package formats
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+)
+
+// ConfigSchemaDatetimeFormats Comma separated go datetime layouts tried while inferring datetime columns
+const ConfigSchemaDatetimeFormats = "schema.datetimeFormats"
+
+var defaultDatetimeFormats = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+// inferredColumn keeps track of types which can hold all the sampled values of column
+type inferredColumn struct {
+	count    int
+	isInt    bool
+	isDouble bool
+	isBool   bool
+	layouts  []string
+}
+
+func (t *inferredColumn) add(v string) {
+	if v == "" {
+		return
+	}
+	t.count = t.count + 1
+	if t.isInt {
+		_, err := strconv.ParseInt(v, 10, 64)
+		t.isInt = err == nil
+	}
+	if t.isDouble {
+		_, err := strconv.ParseFloat(v, 64)
+		t.isDouble = err == nil
+	}
+	if t.isBool {
+		t.isBool = strings.EqualFold(v, "true") || strings.EqualFold(v, "false")
+	}
+	layouts := t.layouts[:0]
+	for _, l := range t.layouts {
+		if _, err := time.Parse(l, v); err == nil {
+			layouts = append(layouts, l)
+		}
+	}
+	t.layouts = layouts
+}
+
+// format returns narrowest format for sampled values, columns without any value are treated as string
+func (t *inferredColumn) format() (df.Format, string) {
+	if t.count == 0 {
+		return df.StringFormat, ""
+	} else if t.isInt {
+		return df.IntegerFormat, ""
+	} else if t.isDouble {
+		return df.DoubleFormat, ""
+	} else if t.isBool {
+		return df.BoolFormat, ""
+	} else if len(t.layouts) > 0 {
+		return df.DateTimeFormat, t.layouts[0]
+	}
+	return df.StringFormat, ""
+}
+
+// schemaInferer derives column formats from sampled string values
+type schemaInferer struct {
+	layouts []string
+	cols    map[string]*inferredColumn
+}
+
+func newSchemaInferer(args map[string]string) *schemaInferer {
+	return &schemaInferer{layouts: datetimeFormats(args), cols: map[string]*inferredColumn{}}
+}
+
+func (t *schemaInferer) add(name string, v string) {
+	c, ok := t.cols[name]
+	if !ok {
+		layouts := make([]string, len(t.layouts))
+		copy(layouts, t.layouts)
+		c = &inferredColumn{isInt: true, isDouble: true, isBool: true, layouts: layouts}
+		t.cols[name] = c
+	}
+	c.add(v)
+}
+
+// column returns format of the column and datetime layout if column is datetime
+func (t *schemaInferer) column(name string) (df.Format, string) {
+	c, ok := t.cols[name]
+	if !ok {
+		return df.StringFormat, ""
+	}
+	return c.format()
+}
+
+// parseInferredValue converts string value to given format, empty values are treated as nil for non string formats
+func parseInferredValue(format df.Format, layout string, v string) (val df.Value, err error) {
+	if format == df.StringFormat {
+		return inmemory.NewStringValueConst(v), nil
+	}
+	if v == "" {
+		return inmemory.NewValue(format, nil), nil
+	}
+
+	var data any
+	switch format {
+	case df.IntegerFormat:
+		data, err = strconv.ParseInt(v, 10, 64)
+	case df.DoubleFormat:
+		data, err = strconv.ParseFloat(v, 64)
+	case df.BoolFormat:
+		data, err = strconv.ParseBool(v)
+	case df.DateTimeFormat:
+		data, err = time.Parse(layout, v)
+	default:
+		err = errors.New("unsupported format - " + format.Name())
+	}
+	if err != nil {
+		return val, errors.New("unable to convert '" + v + "' to " + format.Name())
+	}
+	return inmemory.NewValue(format, data), nil
+}
+
+func datetimeFormats(args map[string]string) []string {
+	layoutStr, ok := args[ConfigSchemaDatetimeFormats]
+	if !ok || strings.TrimSpace(layoutStr) == "" {
+		return defaultDatetimeFormats
+	}
+	layouts := make([]string, 0)
+	for _, l := range strings.Split(layoutStr, ",") {
+		l = strings.TrimSpace(l)
+		if l != "" {
+			layouts = append(layouts, l)
+		}
+	}
+	return layouts
+}
+
+func isInferSchemaEnabled(args map[string]string, key string) (infer bool, err error) {
+	inferStr, ok := args[key]
+	if ok && inferStr != "" {
+		infer, err = strconv.ParseBool(inferStr)
+		if err != nil {
+			return infer, errors.New("invalid " + key + " - " + inferStr)
+		}
+	}
+	return
+}
@@ -2,133 +2,21 @@ package formats
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"io"
-	"io/ioutil"
-	"reflect"
-	"sort"
 	"strconv"
 	"strings"
-	"sync"
 
 	"encoding/json"
 
 	"github.com/blue4209211/pq/df"
-	"github.com/blue4209211/pq/df/inmemory"
 )
 
-func jsonReadByLineSync(reader io.Reader, jsonRootNode string) (objMapList *[]map[string]any, err error) {
-	bufferedReader := bufio.NewReader(reader)
-	// in somecases line size gets bigger than default scanner settings
-	// so using reader to handle those scenarios
-	jsonData := make([]byte, 0, 10000)
-	objMapList1 := []map[string]any{}
-	for err == nil {
-		jsonTextArr, isPrefix, err := bufferedReader.ReadLine()
-		if isPrefix {
-			jsonData = append(jsonData, jsonTextArr...)
-			continue
-		} else {
-			if len(jsonData) == 0 {
-				jsonData = jsonTextArr
-			} else {
-				jsonData = append(jsonData, jsonTextArr...)
-			}
-		}
-
-		if err == io.EOF {
-			break
-		}
-		r, err := jsonReadToArray(&jsonData, jsonIsArray(jsonData), jsonRootNode)
-		if err != nil {
-			return objMapList, err
-		}
-		objMapList1 = append(objMapList1, r...)
-		jsonData = jsonData[:0]
-	}
-	objMapList = &objMapList1
-	return objMapList, err
-}
-
-func jsonReadByLineAsync(reader io.Reader, jsonRootNode string) (objMapList *[]map[string]any, err error) {
-	bufferedReader := bufio.NewReader(reader)
-	jobs := make(chan []byte, 1000)
-	results := make(chan jsonAsyncReadResult, 1000)
-	wg := new(sync.WaitGroup)
-	objMapListChannel := make(chan jsonAsyncReadResult, 1)
-	defer close(objMapListChannel)
-
-	for w := 0; w < 5; w++ {
-		wg.Add(1)
-		go jsonReadByArrayAsync(jobs, results, wg, jsonRootNode)
-	}
-
-	go jsonResultCollector(objMapListChannel, results)
-
-	// in somecases line size gets bigger than default scanner settings
-	// so using reader to handle those scenarios
-	jsonData := make([]byte, 0, 10000)
-	for err == nil {
-		jsonTextArr, isPrefix, err := bufferedReader.ReadLine()
-		jsonData = append(jsonData, jsonTextArr...)
-		if isPrefix {
-			continue
-		}
-		if err == io.EOF {
-			break
-		}
-
-		copiedData := make([]byte, len(jsonData))
-		copy(copiedData, jsonData)
-		jobs <- copiedData
-		jsonData = jsonData[:0]
-	}
-
-	close(jobs)
-	wg.Wait()
-	close(results)
-	for r := range objMapListChannel {
-		return r.data, r.err
-	}
-	return objMapList, errors.New("unable to read data")
-}
-
-type jsonAsyncReadResult struct {
-	data *([]map[string]any)
-	err  error
-}
-
-func jsonResultCollector(collector chan<- jsonAsyncReadResult, results <-chan jsonAsyncReadResult) {
-	objMapList := make([]map[string]any, 0)
-	sentToCollector := false
-	for r := range results {
-		if r.err != nil {
-			if !sentToCollector {
-				collector <- jsonAsyncReadResult{data: &objMapList, err: r.err}
-				sentToCollector = true
-			}
-		} else {
-			objMapList = append(objMapList, *r.data...)
-		}
-	}
-	if !sentToCollector {
-		collector <- jsonAsyncReadResult{data: &objMapList, err: nil}
-	}
-}
-
-func jsonReadByArrayAsync(jobs <-chan []byte, results chan<- jsonAsyncReadResult, wg *sync.WaitGroup, jsonRootNode string) {
-	defer wg.Done()
-
-	for data := range jobs {
-		r, e := jsonReadToArray(&data, jsonIsArray(data), jsonRootNode)
-		results <- jsonAsyncReadResult{data: &r, err: e}
-	}
-
-}
-
-// customJSONUnmarshaller is custom unmarshaller to handle only single level
+// customJSONUnmarshaller is custom unmarshaller to handle only single level, nested objects are kept as raw json
 type customJSONUnmarshaller struct {
-	data any
+	data   any
+	object bool
 }
 
 func (a *customJSONUnmarshaller) UnmarshalJSON(b []byte) (err error) {
@@ -155,6 +43,7 @@ func (a *customJSONUnmarshaller) UnmarshalJSON(b []byte) (err error) {
 	// arrays/objects
 	case '[', '{':
 		a.data = string(b)
+		a.object = b[0] == '{'
 	// numbers
 	default:
 		a.data, err = strconv.ParseFloat(string(b), 64)
@@ -162,9 +51,49 @@ func (a *customJSONUnmarshaller) UnmarshalJSON(b []byte) (err error) {
 	return err
 }
 
-func jsonReadToArray(byteArr *[]byte, isArray bool, jsonRootNode string) (objMapList []map[string]any, err error) {
+// jsonCustomToMap converts parsed object to map, nested objects are flattened into dotted keys (a.b.c) upto flattenDepth levels,
+// negative depth flattens all the levels
+func jsonCustomToMap(objMapCustom map[string]*customJSONUnmarshaller, flattenDepth int) (map[string]any, error) {
+	objMap := make(map[string]any, len(objMapCustom))
+	return objMap, jsonFlatten(objMap, "", objMapCustom, flattenDepth)
+}
+
+func jsonFlatten(objMap map[string]any, prefix string, objMapCustom map[string]*customJSONUnmarshaller, flattenDepth int) error {
+	for k, v := range objMapCustom {
+		if v == nil {
+			objMap[prefix+k] = nil
+			continue
+		}
+		if !v.object || flattenDepth == 0 {
+			objMap[prefix+k] = v.data
+			continue
+		}
+		nested := make(map[string]*customJSONUnmarshaller)
+		err := json.Unmarshal([]byte(v.data.(string)), &nested)
+		if err != nil {
+			return err
+		}
+		// empty objects are kept as they dont have any columns
+		if len(nested) == 0 {
+			objMap[prefix+k] = v.data
+			continue
+		}
+		err = jsonFlatten(objMap, prefix+k+".", nested, flattenDepth-1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonReadToArray(byteArr *[]byte, isArray bool, jsonRootNode string, flattenDepth int) (objMapList []map[string]any, err error) {
+	// objects are flattened after reaching root node
+	depth := flattenDepth
+	if jsonRootNode != "" {
+		depth = 0
+	}
 	if isArray {
-		objMapListCustom := make([]map[string]customJSONUnmarshaller, 0)
+		objMapListCustom := make([]map[string]*customJSONUnmarshaller, 0)
 		err = json.Unmarshal(*byteArr, &objMapListCustom)
 		if err != nil {
 			return objMapList, err
@@ -173,11 +102,10 @@ func jsonReadToArray(byteArr *[]byte, isArray bool, jsonRootNode string) (objMap
 		objMapList = make([]map[string]any, len(objMapListCustom))
 
 		for i, objCustom := range objMapListCustom {
-			obj := make(map[string]any)
-			for k, v := range objCustom {
-				obj[k] = v.data
+			objMapList[i], err = jsonCustomToMap(objCustom, depth)
+			if err != nil {
+				return objMapList, err
 			}
-			objMapList[i] = obj
 		}
 	} else {
 		objMapCustom := make(map[string]*customJSONUnmarshaller)
@@ -187,16 +115,10 @@ func jsonReadToArray(byteArr *[]byte, isArray bool, jsonRootNode string) (objMap
 			return objMapList, err
 		}
 
-		objMap := make(map[string]any)
-		for k, v := range objMapCustom {
-			if v == nil{					
-				objMap[k] = nil
-			}else{
-				objMap[k] = v.data
-			}
+		objMapList[0], err = jsonCustomToMap(objMapCustom, depth)
+		if err != nil {
+			return objMapList, err
 		}
-
-		objMapList[0] = objMap
 	}
 
 	if jsonRootNode != "" {
@@ -212,7 +134,7 @@ func jsonReadToArray(byteArr *[]byte, isArray bool, jsonRootNode string) (objMap
 			for k, v := range objMap {
 				if jsonRootNodeItem == k {
 					newData := []byte(v.(string))
-					newDataArr, err := jsonReadToArray(&newData, jsonIsArray(newData), jsonRootNode)
+					newDataArr, err := jsonReadToArray(&newData, jsonIsArray(newData), jsonRootNode, flattenDepth)
 					if err != nil {
 						return objMapList, err
 					}
@@ -234,9 +156,13 @@ const ConfigJSONSingleLine = "json.objectOnEachLine"
 // ConfigJSONRootNode root node to use while reading data
 const ConfigJSONRootNode = "json.rootNode"
 
+// ConfigJSONFlattenDepth Number of levels of nested objects flattened into dotted columns (a.b.c), -1 flattens all levels, Default = 0 (nested objects are json strings)
+const ConfigJSONFlattenDepth = "json.flattenDepth"
+
 var jsonConfig = map[string]string{
-	ConfigJSONSingleLine: "true",
-	ConfigJSONRootNode:   "",
+	ConfigJSONSingleLine:   "true",
+	ConfigJSONRootNode:     "",
+	ConfigJSONFlattenDepth: "0",
 }
 
 type JsonDataSource struct {
@@ -255,9 +181,39 @@ func (t *JsonDataSource) Writer(data df.DataFrame, args map[string]string) (Form
 }
 
 func (t *JsonDataSource) Reader(reader io.Reader, args map[string]string) (FormatReader, error) {
-	jsonReader := jsonDataSourceReader{args: args}
-	err := jsonReader.init(reader)
-	return &jsonReader, err
+	streamReader, err := t.StreamReader(reader, args)
+	if err != nil {
+		return nil, err
+	}
+	return ReadAll(streamReader)
+}
+
+func (t *JsonDataSource) StreamReader(reader io.Reader, args map[string]string) (FormatStreamReader, error) {
+	singlelineParse, err := jsonIsSingleLineParse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	flattenDepth := 0
+	if s := args[ConfigJSONFlattenDepth]; s != "" {
+		flattenDepth, err = strconv.Atoi(s)
+		if err != nil {
+			return nil, errors.New("invalid " + ConfigJSONFlattenDepth + " - " + s)
+		}
+	}
+
+	recordReader := &jsonRecordReader{
+		reader:       bufio.NewReader(reader),
+		lineBuffer:   make([]byte, 0, 10000),
+		singleLine:   singlelineParse,
+		rootNode:     args[ConfigJSONRootNode],
+		flattenDepth: flattenDepth,
+	}
+	mapReader, err := newMapStreamReader("json", args, false, recordReader.next)
+	if err != nil {
+		return nil, err
+	}
+	return newFormatStreamReader(mapReader, args)
 }
 
 type jsonDataSourceWriter struct {
@@ -297,112 +253,133 @@ func (t *jsonDataSourceWriter) Write(writer io.Writer) (err error) {
 	return
 }
 
-type jsonDataSourceReader struct {
-	args    map[string]string
-	schema  df.DataFrameSchema
-	records *[]df.Row
+// jsonRecordReader reads json records one by one, based on mode it reads
+// single object/array per line or array/object from whole content
+type jsonRecordReader struct {
+	reader       *bufio.Reader
+	decoder      *json.Decoder
+	singleLine   bool
+	rootNode     string
+	flattenDepth int
+	pending      []map[string]any
+	lineBuffer   []byte
+	eof          bool
 }
 
-func (t *jsonDataSourceReader) Schema() df.DataFrameSchema {
-	return t.schema
+func (t *jsonRecordReader) next() (objMap map[string]any, err error) {
+	for len(t.pending) == 0 {
+		if t.eof {
+			return objMap, io.EOF
+		}
+		if t.singleLine {
+			err = t.readLine()
+		} else {
+			err = t.readContent()
+		}
+		if err != nil {
+			return objMap, err
+		}
+	}
+	objMap = t.pending[0]
+	t.pending[0] = nil
+	t.pending = t.pending[1:]
+	return objMap, err
 }
 
-func (t *jsonDataSourceReader) Data() (data *[]df.Row) {
-	return t.records
+func (t *jsonRecordReader) readLine() (err error) {
+	// in somecases line size gets bigger than default scanner settings
+	// so using reader to handle those scenarios
+	jsonData := t.lineBuffer[:0]
+	defer func() { t.lineBuffer = jsonData[:0] }()
+	for {
+		jsonTextArr, isPrefix, err := t.reader.ReadLine()
+		if err == io.EOF {
+			t.eof = true
+			break
+		}
+		if err != nil {
+			return err
+		}
+		jsonData = append(jsonData, jsonTextArr...)
+		if !isPrefix {
+			break
+		}
+	}
+
+	line := bytes.TrimSpace(jsonData)
+	if len(line) == 0 {
+		return
+	}
+	t.pending, err = jsonReadToArray(&line, jsonIsArray(line), t.rootNode, t.flattenDepth)
+	if err != nil {
+		// lines are independent, so reading can continue with next line
+		t.pending = nil
+		return &malformedRecordError{record: string(line), err: errors.New("json : " + err.Error())}
+	}
+	return err
 }
 
-func (t *jsonDataSourceReader) readJSON(reader io.Reader) (objMapList *[]map[string]any, err error) {
+func (t *jsonRecordReader) readContent() (err error) {
+	if t.decoder == nil {
+		firstChar, err := jsonPeekFirstChar(t.reader)
+		if err == io.EOF {
+			t.eof = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
 
-	singlelineParse, err := jsonIsSingleLineParse(t.args)
-	if err != nil {
-		return objMapList, err
+		// root node requires whole object, so only top level arrays are streamed
+		if firstChar != '[' || t.rootNode != "" {
+			buf, err := io.ReadAll(t.reader)
+			if err != nil {
+				return err
+			}
+			t.eof = true
+			t.pending, err = jsonReadToArray(&buf, jsonIsArray(buf), t.rootNode, t.flattenDepth)
+			return err
+		}
+
+		t.decoder = json.NewDecoder(t.reader)
+		_, err = t.decoder.Token()
+		if err != nil {
+			return err
+		}
 	}
 
-	jsonRootNode, ok := t.args[ConfigJSONRootNode]
-	if !ok {
-		jsonRootNode = ""
+	if !t.decoder.More() {
+		t.eof = true
+		return
 	}
 
-	if singlelineParse {
-		return jsonReadByLineSync(reader, jsonRootNode)
+	objMapCustom := make(map[string]*customJSONUnmarshaller)
+	err = t.decoder.Decode(&objMapCustom)
+	if err != nil {
+		return err
 	}
-	buf, err := ioutil.ReadAll(reader)
+	objMap, err := jsonCustomToMap(objMapCustom, t.flattenDepth)
 	if err != nil {
-		return
+		return err
 	}
-	data, err := jsonReadToArray(&buf, jsonIsArray(buf), jsonRootNode)
-	return &data, err
+	t.pending = append(t.pending, objMap)
+	return
 }
 
 func jsonIsArray(data []byte) (isArray bool) {
 	return data[0] == '['
 }
 
-func (t *jsonDataSourceReader) init(reader io.Reader) (err error) {
-
-	objMapList, err := t.readJSON(reader)
-	if err != nil {
-		return err
-	}
-
-	colMap := map[string]reflect.Type{}
-
-	for _, row := range *objMapList {
-		for k, v := range row {
-			if _, ok := colMap[k]; !ok {
-				colMap[k] = reflect.TypeOf(v)
-			}
-		}
-	}
-	colMapKeys := make([]string, 0, len(colMap))
-	for k := range colMap {
-		colMapKeys = append(colMapKeys, k)
-	}
-	sort.Strings(colMapKeys)
-
-	cols := make([]df.SeriesSchema, len(colMap))
-	index := 0
-	for _, k := range colMapKeys {
-		v := colMap[k]
-		typeStr := "string"
-		if v != nil {
-			typeStr = v.Kind().String()
-		}
-		if typeStr == "slice" || typeStr == "array" || typeStr == "map" {
-			typeStr = "string"
-		}
-
-		dfFormat, err := df.GetFormat(typeStr)
+func jsonPeekFirstChar(reader *bufio.Reader) (c byte, err error) {
+	for {
+		c, err = reader.ReadByte()
 		if err != nil {
-			return errors.New("json : unable to get format for - " + k + ", " + typeStr)
+			return c, err
 		}
-		cols[index] = df.SeriesSchema{Name: k, Format: dfFormat}
-		index = index + 1
-	}
-	t.schema = df.NewSchema(cols)
-	records := make([]df.Row, len(*objMapList))
-
-	for i, objMap := range *objMapList {
-
-		row := make([]df.Value, len(cols))
-
-		for j, c := range cols {
-			if v, ok := objMap[c.Name]; ok {
-				v, err := c.Format.Convert(v)
-				if err != nil {
-					return err
-				}
-				row[j] = inmemory.NewValue(c.Format, v)
-			} else {
-				row[j] = nil
-			}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			return c, reader.UnreadByte()
 		}
-		records[i] = inmemory.NewRow(&t.schema, &row)
 	}
-
-	t.records = &records
-
-	return err
 }
 
 func jsonIsSingleLineParse(config map[string]string) (singlelineParse bool, err error) {
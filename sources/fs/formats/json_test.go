@@ -1,9 +1,11 @@
 package formats
 
 import (
+	"io"
 	"strings"
 	"testing"
 
+	"github.com/blue4209211/pq/df"
 	"github.com/blue4209211/pq/df/inmemory"
 	"github.com/stretchr/testify/assert"
 )
@@ -16,7 +18,7 @@ func TestJSONDataSource(t *testing.T) {
 func TestCustomJSONParser(t *testing.T) {
 	jsonString := `[{"a":1, "b":2.0, "c":"c11\"234", "d":false, "e":[1,2,3], "f":{"k":1}, "g":null}]`
 	jsonBytes := []byte(jsonString)
-	objMapList, err := jsonReadToArray(&jsonBytes, true, "")
+	objMapList, err := jsonReadToArray(&jsonBytes, true, "", 0)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(objMapList))
 	assert.Equal(t, float64(1), objMapList[0]["a"])
@@ -115,6 +117,49 @@ func TestJSONDataSourceWriter(t *testing.T) {
 	assert.Equal(t, jsonString, buff.String())
 }
 
+func TestJSONDataSourceStreamReader(t *testing.T) {
+	source := JsonDataSource{}
+
+	jsonString := `[
+	{"a":1, "b":"b1"},
+	{"a":2, "b":"b2", "c":true}
+]`
+
+	// c is not part of sampled records
+	jsonReader, err := source.StreamReader(strings.NewReader(jsonString), map[string]string{
+		ConfigJSONSingleLine:   "false",
+		ConfigSchemaSampleSize: "1",
+	})
+	assert.NoError(t, err)
+	defer jsonReader.Close()
+	assert.Equal(t, []string{"a", "b"}, jsonReader.Schema().Names())
+
+	r, err := jsonReader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, r.GetRaw(0))
+	r, err = jsonReader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "b2", r.GetRaw(1))
+	_, err = jsonReader.Next()
+	assert.Equal(t, io.EOF, err)
+
+	// empty lines are ignored
+	jsonReader, err = source.StreamReader(strings.NewReader("{\"a\":1}\n\n{\"a\":2, \"c\":true}\n"), map[string]string{
+		ConfigSchemaSampleSize: "0",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, jsonReader.Schema().Names())
+	data, err := ReadAll(jsonReader)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(*data.Data()))
+	assert.Equal(t, true, (*data.Data())[1].GetRaw(1))
+
+	_, err = source.StreamReader(strings.NewReader(jsonString), map[string]string{
+		ConfigSchemaSampleSize: "a",
+	})
+	assert.Error(t, err)
+}
+
 func BenchmarkJSONParsing(b *testing.B) {
 	source := JsonDataSource{}
 	jsonString := `[{"a":1, "b":2, "c":"c1", "d":"d1"},{"a":3, "b":4, "c":"c2", "d":"d,2"},{"a":5, "b":null, "c":"", "d":"d2"}]`
@@ -152,3 +197,66 @@ func BenchmarkJSONParsing(b *testing.B) {
 	})
 
 }
+
+func TestJSONDataSourceSchemaWidening(t *testing.T) {
+	source := JsonDataSource{}
+
+	jsonString := `{"a":null, "b":1, "c":1, "d":true, "e":null}
+{"a":"x", "b":2.5, "c":"y", "d":false, "e":null}
+{"a":"z", "b":3, "c":{"k":1}, "d":null}`
+
+	jsonReader, err := source.Reader(strings.NewReader(jsonString), map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, df.NewSchema([]df.SeriesSchema{
+		{Name: "a", Format: df.StringFormat},
+		{Name: "b", Format: df.DoubleFormat},
+		{Name: "c", Format: df.StringFormat},
+		{Name: "d", Format: df.BoolFormat},
+		{Name: "e", Format: df.StringFormat},
+	}), jsonReader.Schema())
+	data := *(jsonReader.Data())
+	assert.Equal(t, []any{nil, 1.0, "1", true, nil}, testRowValues(data[0]))
+	assert.Equal(t, []any{"x", 2.5, "y", false, nil}, testRowValues(data[1]))
+	assert.Equal(t, []any{"z", 3.0, `{"k":1}`, nil, nil}, testRowValues(data[2]))
+
+	// integers and doubles of yaml are widened to double
+	yamlReader, err := (&YamlDataSource{}).Reader(strings.NewReader("- a: 1\n- a: 2.5\n- a: null\n"), map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, df.DoubleFormat, yamlReader.Schema().Get(0).Format)
+	assert.Equal(t, []any{1.0}, testRowValues((*yamlReader.Data())[0]))
+}
+
+func TestJSONDataSourceFlatten(t *testing.T) {
+	source := JsonDataSource{}
+
+	jsonString := `{"id":1, "user":{"name":"a", "address":{"city":"x", "zip":"1"}, "tags":["t1"], "meta":{}}, "s":"{\"k\":1}"}
+{"id":2, "user":{"name":"b", "address":null}}`
+
+	cases := []struct {
+		depth    string
+		names    []string
+		expected []any
+	}{
+		{"0", []string{"id", "s", "user"}, []any{1.0, `{"k":1}`, `{"name":"a", "address":{"city":"x", "zip":"1"}, "tags":["t1"], "meta":{}}`}},
+		{"1", []string{"id", "s", "user.address", "user.meta", "user.name", "user.tags"}, []any{1.0, `{"k":1}`, `{"city":"x", "zip":"1"}`, "{}", "a", `["t1"]`}},
+		{"-1", []string{"id", "s", "user.address", "user.address.city", "user.address.zip", "user.meta", "user.name", "user.tags"}, []any{1.0, `{"k":1}`, nil, "x", "1", "{}", "a", `["t1"]`}},
+	}
+	for _, c := range cases {
+		jsonReader, err := source.Reader(strings.NewReader(jsonString), map[string]string{ConfigJSONFlattenDepth: c.depth})
+		assert.NoError(t, err, c.depth)
+		assert.Equal(t, c.names, jsonReader.Schema().Names(), c.depth)
+		assert.Equal(t, c.expected, testRowValues((*jsonReader.Data())[0]), c.depth)
+	}
+
+	// flattening starts from root node
+	jsonReader, err := source.Reader(strings.NewReader(`{"data":{"rows":[{"a":{"b":1}}]}}`), map[string]string{
+		ConfigJSONSingleLine:   "false",
+		ConfigJSONRootNode:     "data.rows",
+		ConfigJSONFlattenDepth: "1",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.b"}, jsonReader.Schema().Names())
+
+	_, err = source.Reader(strings.NewReader(jsonString), map[string]string{ConfigJSONFlattenDepth: "x"})
+	assert.Error(t, err)
+}
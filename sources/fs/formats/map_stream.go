@@ -0,0 +1,228 @@
+package formats
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/blue4209211/pq/internal/log"
+)
+
+// mapStreamReader converts stream of key/value records into rows,
+// schema is derived from first few records (see ConfigSchemaSampleSize) by widening formats of values,
+// when inferSchema is enabled string values are sampled to derive column types.
+// user schema (see ConfigSchema) replaces sampling, values are converted while reading
+type mapStreamReader struct {
+	format     string
+	next       func() (map[string]any, error)
+	schema     df.DataFrameSchema
+	layouts    []string
+	pending    []mapRecord
+	userSchema *userSchema
+	rowNumber  int
+}
+
+// mapRecord sampled record, err is set for malformed records so that it is returned when record is read
+type mapRecord struct {
+	data map[string]any
+	err  error
+}
+
+func newMapStreamReader(format string, args map[string]string, inferSchema bool, next func() (map[string]any, error)) (reader *mapStreamReader, err error) {
+	sampleSize, err := schemaSampleSize(args)
+	if err != nil {
+		return reader, err
+	}
+
+	reader = &mapStreamReader{format: format, next: next, pending: make([]mapRecord, 0)}
+	reader.userSchema, err = readUserSchema(args)
+	if err != nil {
+		return reader, err
+	}
+	if reader.userSchema != nil {
+		reader.schema = reader.userSchema.schema
+		return reader, nil
+	}
+
+	for sampleSize <= 0 || len(reader.pending) < sampleSize {
+		r, err := next()
+		if err == io.EOF {
+			break
+		}
+		malformed := &malformedRecordError{}
+		if errors.As(err, &malformed) {
+			reader.pending = append(reader.pending, mapRecord{err: err})
+			continue
+		}
+		if err != nil {
+			return reader, err
+		}
+		reader.pending = append(reader.pending, mapRecord{data: r})
+	}
+
+	err = reader.initSchema()
+	if err != nil || !inferSchema {
+		return reader, err
+	}
+	reader.inferSchema(newSchemaInferer(args))
+	return reader, err
+}
+
+// inferSchema updates format of string columns based on sampled values
+func (t *mapStreamReader) inferSchema(inferer *schemaInferer) {
+	for _, row := range t.pending {
+		for k, v := range row.data {
+			if s, ok := v.(string); ok {
+				inferer.add(k, s)
+			}
+		}
+	}
+
+	cols := t.schema.Series()
+	t.layouts = make([]string, len(cols))
+	for i, c := range cols {
+		if c.Format == df.StringFormat {
+			cols[i].Format, t.layouts[i] = inferer.column(c.Name)
+		}
+	}
+	t.schema = df.NewSchema(cols)
+	log.Debugf("%s : inferred schema - %v", t.format, cols)
+}
+
+// initSchema derives column formats from all the sampled records, when formats differ across records they are widened -
+// nulls take format of other values, integer and double become double and rest become string
+func (t *mapStreamReader) initSchema() (err error) {
+	colMap := map[string]df.Format{}
+
+	for _, row := range t.pending {
+		for k, v := range row.data {
+			format, err := t.valueFormat(k, v)
+			if err != nil {
+				return err
+			}
+			colMap[k] = mapWidenFormat(colMap[k], format)
+		}
+	}
+	colMapKeys := make([]string, 0, len(colMap))
+	for k := range colMap {
+		colMapKeys = append(colMapKeys, k)
+	}
+	sort.Strings(colMapKeys)
+
+	cols := make([]df.SeriesSchema, len(colMap))
+	for i, k := range colMapKeys {
+		format := colMap[k]
+		// columns having only nulls
+		if format == nil {
+			format = df.StringFormat
+		}
+		cols[i] = df.SeriesSchema{Name: k, Format: format}
+	}
+	t.schema = df.NewSchema(cols)
+	log.Debugf("%s : schema derived from (%d) records - %v", t.format, len(t.pending), cols)
+	return
+}
+
+// valueFormat returns format of value, nil is returned for null values
+func (t *mapStreamReader) valueFormat(k string, v any) (format df.Format, err error) {
+	if v == nil {
+		return nil, nil
+	}
+	vt := reflect.TypeOf(v)
+	typeStr := vt.Kind().String()
+	if typeStr == "slice" || typeStr == "array" || typeStr == "map" {
+		typeStr = "string"
+	} else if vt == reflect.TypeOf(time.Time{}) {
+		typeStr = "datetime"
+	}
+
+	format, err = df.GetFormat(typeStr)
+	if err != nil {
+		return format, errors.New(t.format + " : unable to get format for - " + k + ", " + typeStr)
+	}
+	return format, nil
+}
+
+// mapWidenFormat returns format which can hold values of both formats, nil format is used for null values
+func mapWidenFormat(f1 df.Format, f2 df.Format) df.Format {
+	if f1 == nil {
+		return f2
+	}
+	if f2 == nil || f1 == f2 {
+		return f1
+	}
+	if (f1 == df.IntegerFormat || f1 == df.DoubleFormat) && (f2 == df.IntegerFormat || f2 == df.DoubleFormat) {
+		return df.DoubleFormat
+	}
+	return df.StringFormat
+}
+
+func (t *mapStreamReader) Schema() df.DataFrameSchema {
+	return t.schema
+}
+
+func (t *mapStreamReader) Next() (r df.Row, err error) {
+	var objMap map[string]any
+	if len(t.pending) > 0 {
+		objMap = t.pending[0].data
+		err = t.pending[0].err
+		t.pending[0] = mapRecord{}
+		t.pending = t.pending[1:]
+		if err != nil {
+			return r, err
+		}
+	} else {
+		objMap, err = t.next()
+		if err == io.EOF {
+			return r, err
+		}
+		t.rowNumber++
+		if err != nil {
+			return r, err
+		}
+	}
+
+	if t.userSchema != nil {
+		row, err := t.userSchema.convertRow(t.rowNumber, func(i int, name string) any {
+			return objMap[name]
+		})
+		if err != nil {
+			return r, malformedMapRecord(objMap, err)
+		}
+		return inmemory.NewRow(&t.schema, &row), nil
+	}
+
+	row := make([]df.Value, t.schema.Len())
+	for j, c := range t.schema.Series() {
+		if v, ok := objMap[c.Name]; ok {
+			if s, ok := v.(string); ok && t.layouts != nil {
+				row[j], err = parseInferredValue(c.Format, t.layouts[j], s)
+				if err != nil {
+					return r, malformedMapRecord(objMap, errors.New(t.format+" : column "+c.Name+", "+err.Error()))
+				}
+				continue
+			}
+			v, err := c.Format.Convert(v)
+			if err != nil {
+				return r, malformedMapRecord(objMap, errors.New(t.format+" : column "+c.Name+", "+err.Error()))
+			}
+			row[j] = inmemory.NewValue(c.Format, v)
+		} else {
+			row[j] = nil
+		}
+	}
+	return inmemory.NewRow(&t.schema, &row), nil
+}
+
+func (t *mapStreamReader) hasUserSchema() bool {
+	return t.userSchema != nil
+}
+
+func (t *mapStreamReader) Close() error {
+	t.pending = nil
+	return nil
+}
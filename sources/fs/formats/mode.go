@@ -0,0 +1,153 @@
+package formats
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/blue4209211/pq/internal/log"
+)
+
+// ConfigMode How malformed records are handled while reading - failfast(default), dropmalformed, permissive
+const ConfigMode = "mode"
+
+const (
+	// ModeFailFast reading fails on first malformed record
+	ModeFailFast = "failfast"
+	// ModeDropMalformed malformed records are skipped
+	ModeDropMalformed = "dropmalformed"
+	// ModePermissive malformed records are kept in CorruptRecordColumn, other columns are null
+	ModePermissive = "permissive"
+)
+
+// CorruptRecordColumn column holding raw content of malformed records in permissive mode
+const CorruptRecordColumn = "_corrupt_record"
+
+// malformedRecordError returned by readers when record can not be parsed but reading can continue with next record
+type malformedRecordError struct {
+	record string
+	err    error
+}
+
+func (t *malformedRecordError) Error() string {
+	return t.err.Error()
+}
+
+func (t *malformedRecordError) Unwrap() error {
+	return t.err
+}
+
+// malformedMapRecord returns malformed error for record, record is kept as json text
+func malformedMapRecord(record map[string]any, err error) error {
+	data, e := json.Marshal(record)
+	if e != nil {
+		return &malformedRecordError{err: err}
+	}
+	return &malformedRecordError{record: string(data), err: err}
+}
+
+// modeStreamReader handles malformed records returned by underlying reader based on mode
+type modeStreamReader struct {
+	reader  FormatStreamReader
+	mode    string
+	schema  df.DataFrameSchema
+	dropped int
+	// corruptIndex index of CorruptRecordColumn, column is appended to source schema if source doesnt have it
+	corruptIndex int
+	appended     bool
+}
+
+func (t *modeStreamReader) Schema() df.DataFrameSchema {
+	return t.schema
+}
+
+func (t *modeStreamReader) Next() (r df.Row, err error) {
+	for {
+		r, err = t.reader.Next()
+		malformed := &malformedRecordError{}
+		if err == nil || !errors.As(err, &malformed) {
+			break
+		}
+
+		if t.mode == ModeDropMalformed {
+			t.dropped = t.dropped + 1
+			log.Debugf("dropping malformed record - %s, %s", malformed.record, err)
+			continue
+		}
+
+		// permissive
+		row := make([]df.Value, t.schema.Len())
+		for i, c := range t.schema.Series() {
+			row[i] = inmemory.NewValue(c.Format, nil)
+		}
+		row[t.corruptIndex] = inmemory.NewStringValueConst(malformed.record)
+		return inmemory.NewRow(&t.schema, &row), nil
+	}
+
+	if err != nil || !t.appended {
+		return r, err
+	}
+
+	row := make([]df.Value, t.schema.Len())
+	for i := 0; i < r.Len(); i++ {
+		row[i] = r.Get(i)
+	}
+	row[len(row)-1] = inmemory.NewStringValue(nil)
+	return inmemory.NewRow(&t.schema, &row), nil
+}
+
+func (t *modeStreamReader) Close() error {
+	if t.dropped > 0 {
+		log.Warnf("dropped %d malformed records", t.dropped)
+	}
+	t.dropped = 0
+	return t.reader.Close()
+}
+
+func readMode(args map[string]string) (mode string, err error) {
+	mode = strings.ToLower(args[ConfigMode])
+	if mode == "" {
+		return ModeFailFast, nil
+	}
+	if mode != ModeFailFast && mode != ModeDropMalformed && mode != ModePermissive {
+		return mode, errors.New("unsupported " + ConfigMode + " - " + mode)
+	}
+	return mode, nil
+}
+
+// withMode wraps reader to handle malformed records, failfast mode returns reader as it is
+func withMode(reader FormatStreamReader, args map[string]string) (FormatStreamReader, error) {
+	mode, err := readMode(args)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	if mode == ModeFailFast {
+		return reader, nil
+	}
+
+	modeReader := &modeStreamReader{reader: reader, mode: mode, schema: reader.Schema()}
+	if mode == ModePermissive {
+		modeReader.corruptIndex = modeReader.schema.GetIndexByName(CorruptRecordColumn)
+		if modeReader.corruptIndex < 0 {
+			series := make([]df.SeriesSchema, 0, modeReader.schema.Len()+1)
+			series = append(series, modeReader.schema.Series()...)
+			series = append(series, df.SeriesSchema{Name: CorruptRecordColumn, Format: df.StringFormat})
+			modeReader.schema = df.NewSchema(series)
+			modeReader.corruptIndex = len(series) - 1
+			modeReader.appended = true
+		}
+	}
+	return modeReader, nil
+}
+
+// newFormatStreamReader applies user provided schema and malformed record handling on reader
+func newFormatStreamReader(reader FormatStreamReader, args map[string]string) (FormatStreamReader, error) {
+	reader, err := withSchema(reader, args)
+	if err != nil {
+		return nil, err
+	}
+	return withMode(reader, args)
+}
@@ -0,0 +1,102 @@
+package formats
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModeCSV(t *testing.T) {
+	source := CsvDataSource{}
+	csvString := "a,b\n1,2\n3\n4,5\n6,x\n"
+
+	// failfast
+	_, err := source.Reader(strings.NewReader(csvString), map[string]string{})
+	assert.ErrorContains(t, err, "wrong number of fields")
+
+	// dropmalformed
+	reader, err := source.Reader(strings.NewReader(csvString), map[string]string{
+		ConfigMode:             ModeDropMalformed,
+		ConfigCsvInferSchema:   "true",
+		ConfigSchemaSampleSize: "3",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, reader.Schema().Len())
+	data := *reader.Data()
+	assert.Equal(t, 2, len(data))
+	assert.Equal(t, int64(4), data[1].GetRaw(0))
+
+	// permissive
+	reader, err = source.Reader(strings.NewReader(csvString), map[string]string{
+		ConfigMode:             ModePermissive,
+		ConfigCsvInferSchema:   "true",
+		ConfigSchemaSampleSize: "3",
+	})
+	assert.NoError(t, err)
+	schema := reader.Schema()
+	assert.Equal(t, []string{"a", "b", CorruptRecordColumn}, schema.Names())
+	assert.Equal(t, df.IntegerFormat, schema.Get(1).Format)
+	data = *reader.Data()
+	assert.Equal(t, 4, len(data))
+	assert.True(t, data[0].IsNil(2))
+	assert.Equal(t, "3", data[1].GetRaw(2))
+	assert.True(t, data[1].IsNil(0))
+	assert.Equal(t, "6,x", data[3].GetRaw(2))
+
+	_, err = source.Reader(strings.NewReader(csvString), map[string]string{
+		ConfigMode: "ignore",
+	})
+	assert.Error(t, err)
+}
+
+func TestModeJSON(t *testing.T) {
+	source := JsonDataSource{}
+	jsonString := `{"a":1, "b":"b1"}
+{"a":2, "b":
+{"a":"x", "b":"b3"}
+{"a":4, "b":"b4"}`
+
+	// mixed formats are widened to string, so x is malformed only when it is not part of sampled records
+	_, err := source.Reader(strings.NewReader(jsonString), map[string]string{ConfigSchemaSampleSize: "1"})
+	assert.Error(t, err)
+
+	reader, err := source.Reader(strings.NewReader(jsonString), map[string]string{
+		ConfigMode:             ModeDropMalformed,
+		ConfigSchemaSampleSize: "1",
+	})
+	assert.NoError(t, err)
+	data := *reader.Data()
+	assert.Equal(t, 2, len(data))
+	assert.Equal(t, 4.0, data[1].GetRaw(0))
+
+	reader, err = source.Reader(strings.NewReader(jsonString), map[string]string{
+		ConfigMode:             ModePermissive,
+		ConfigSchemaSampleSize: "1",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", CorruptRecordColumn}, reader.Schema().Names())
+	data = *reader.Data()
+	assert.Equal(t, 4, len(data))
+	assert.Equal(t, `{"a":2, "b":`, data[1].GetRaw(2))
+	assert.Equal(t, `{"a":"x","b":"b3"}`, data[2].GetRaw(2))
+	assert.Equal(t, "b4", data[3].GetRaw(1))
+}
+
+func TestModeXML(t *testing.T) {
+	source := XmlDataSource{}
+	xmlString := `<root><element a="1"><b>2</b></element><element a="x"><b>4</b></element></root>`
+
+	reader, err := source.Reader(strings.NewReader(xmlString), map[string]string{
+		ConfigXMLElementName:   "element",
+		ConfigXMLInferSchema:   "true",
+		ConfigSchemaSampleSize: "1",
+		ConfigMode:             ModePermissive,
+	})
+	assert.NoError(t, err)
+	data := *reader.Data()
+	assert.Equal(t, 2, len(data))
+	assert.Equal(t, int64(1), data[0].GetRaw(0))
+	assert.Equal(t, `{"_a":"x","b":"4"}`, data[1].GetRaw(2))
+}
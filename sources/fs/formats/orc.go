@@ -0,0 +1,1199 @@
+package formats
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/blue4209211/pq/internal/log"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// orcMagic orc files start with magic, it is also stored in postscript
+const orcMagic = "ORC"
+
+// orcDefaultBlockSize compression chunk size used when postscript doesnt have it
+const orcDefaultBlockSize = 256 * 1024
+
+// orcMaxBlockSize max size of compression chunks, sizes are read from file so
+// invalid sizes are rejected before allocating memory
+const orcMaxBlockSize = 1 << 30
+
+// orcMaxStripeRows max rows of a stripe, values of all the rows in stripe are kept in memory
+const orcMaxStripeRows = 1 << 26
+
+// orc compression kinds
+const (
+	orcCompressionNone   = 0
+	orcCompressionZlib   = 1
+	orcCompressionSnappy = 2
+	orcCompressionZstd   = 5
+)
+
+// orc type kinds
+const (
+	orcTypeBoolean          = 0
+	orcTypeByte             = 1
+	orcTypeShort            = 2
+	orcTypeInt              = 3
+	orcTypeLong             = 4
+	orcTypeFloat            = 5
+	orcTypeDouble           = 6
+	orcTypeString           = 7
+	orcTypeBinary           = 8
+	orcTypeTimestamp        = 9
+	orcTypeStruct           = 12
+	orcTypeDecimal          = 14
+	orcTypeDate             = 15
+	orcTypeVarchar          = 16
+	orcTypeChar             = 17
+	orcTypeTimestampInstant = 18
+)
+
+// orc stream kinds
+const (
+	orcStreamPresent        = 0
+	orcStreamData           = 1
+	orcStreamLength         = 2
+	orcStreamDictionaryData = 3
+	orcStreamSecondary      = 5
+)
+
+// orc column encodings
+const (
+	orcEncodingDirect       = 0
+	orcEncodingDictionary   = 1
+	orcEncodingDirectV2     = 2
+	orcEncodingDictionaryV2 = 3
+)
+
+// orcTimestampBase timestamps are stored as seconds from 2015-01-01, timestamp (without timezone) values are relative to
+// 2015-01-01 in writer timezone of the stripe
+var orcTimestampBase = time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+var orcConfig = map[string]string{}
+
+// OrcDataSource reads orc files, only primitive columns are supported and writing is not supported
+type OrcDataSource struct {
+}
+
+func (t *OrcDataSource) Args() map[string]string {
+	return orcConfig
+}
+
+func (t *OrcDataSource) Name() string {
+	return "orc"
+}
+
+func (t *OrcDataSource) Writer(data df.DataFrame, args map[string]string) (FormatWriter, error) {
+	return nil, errors.New("orc writer is not supported")
+}
+
+func (t *OrcDataSource) Reader(reader io.Reader, args map[string]string) (FormatReader, error) {
+	streamReader, err := t.StreamReader(reader, args)
+	if err != nil {
+		return nil, err
+	}
+	return ReadAll(streamReader)
+}
+
+// StreamReader reads one stripe at a time, if reader supports random access (ex - os.File) then it is used directly
+// else whole content is buffered in memory
+func (t *OrcDataSource) StreamReader(reader io.Reader, args map[string]string) (FormatStreamReader, error) {
+	return t.PushdownStreamReader(reader, args, nil, nil)
+}
+
+// PushdownStreamReader reads streams of only given columns from each stripe, filters are not used
+func (t *OrcDataSource) PushdownStreamReader(reader io.Reader, args map[string]string, cols []int, filters []df.Filter) (FormatStreamReader, error) {
+	orcReader := &orcDataSourceReader{}
+	// columns of user provided schema dont map to orc columns
+	if args[ConfigSchema] == "" {
+		orcReader.selected = cols
+	}
+	err := orcReader.init(reader)
+	if err != nil {
+		return nil, err
+	}
+	return newFormatStreamReader(orcReader, args)
+}
+
+type orcReaderAtSeeker interface {
+	io.ReaderAt
+	io.Seeker
+}
+
+type orcType struct {
+	kind       uint64
+	subtypes   []uint64
+	fieldNames []string
+	scale      uint64
+}
+
+type orcStripe struct {
+	offset       uint64
+	indexLength  uint64
+	dataLength   uint64
+	footerLength uint64
+	rows         uint64
+}
+
+type orcStream struct {
+	kind   uint64
+	column uint64
+	offset uint64
+	length uint64
+}
+
+// orcColumn top level column of the file
+type orcColumn struct {
+	id   uint64
+	kind uint64
+	// scale default scale of decimal columns
+	scale uint64
+}
+
+// orcParseMessage calls field for each field of protobuf message, value of varint fields is passed in v and of others in b
+func orcParseMessage(data []byte, field func(num protowire.Number, v uint64, b []byte)) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		var v uint64
+		var b []byte
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(data)
+		case protowire.BytesType:
+			b, n = protowire.ConsumeBytes(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		field(num, v, b)
+	}
+	return nil
+}
+
+// orcAppendUints appends values of repeated integer field, which can be packed
+func orcAppendUints(values []uint64, v uint64, b []byte) []uint64 {
+	if b == nil {
+		return append(values, v)
+	}
+	for len(b) > 0 {
+		i, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return values
+		}
+		values = append(values, i)
+		b = b[n:]
+	}
+	return values
+}
+
+type orcDataSourceReader struct {
+	source      orcReaderAtSeeker
+	size        uint64
+	compression uint64
+	blockSize   uint64
+	zstd        *zstd.Decoder
+	types       []orcType
+	stripes     []orcStripe
+	columns     []orcColumn
+	cols        df.DataFrameSchema
+	// total rows of the file from footer
+	numberOfRows uint64
+	// selected columns used by the query, all the columns are read if nil
+	selected []int
+	read     []bool
+	stripe   int
+	values   [][]any
+	rows     int
+	index    int
+}
+
+func (t *orcDataSourceReader) Schema() (columns df.DataFrameSchema) {
+	return t.cols
+}
+
+func (t *orcDataSourceReader) Next() (r df.Row, err error) {
+	for t.index >= t.rows {
+		if t.stripe >= len(t.stripes) {
+			return r, io.EOF
+		}
+		t.values, err = t.readStripe(t.stripes[t.stripe])
+		if err != nil {
+			return r, err
+		}
+		t.rows = int(t.stripes[t.stripe].rows)
+		t.stripe = t.stripe + 1
+		t.index = 0
+	}
+
+	data := make([]any, len(t.values))
+	for c, values := range t.values {
+		if values != nil {
+			data[c] = values[t.index]
+		}
+	}
+	t.index = t.index + 1
+	return inmemory.NewRowFromAny(&t.cols, &data), nil
+}
+
+func (t *orcDataSourceReader) Close() error {
+	t.values = nil
+	if t.zstd != nil {
+		t.zstd.Close()
+		t.zstd = nil
+	}
+	return nil
+}
+
+func (t *orcDataSourceReader) init(reader io.Reader) (err error) {
+	source, ok := reader.(orcReaderAtSeeker)
+	var size int64
+	if ok {
+		// pipes (ex - stdin) dont support random access
+		size, err = source.Seek(0, io.SeekEnd)
+		ok = err == nil
+	}
+	if !ok {
+		buf, err := io.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		source = bytes.NewReader(buf)
+		size = int64(len(buf))
+	}
+	t.source = source
+	t.size = uint64(size)
+
+	// file ends with postscript and its length in last byte
+	if size < int64(len(orcMagic))+1 {
+		return errors.New("invalid orc file")
+	}
+	last := make([]byte, 1)
+	_, err = source.ReadAt(last, size-1)
+	if err != nil {
+		return err
+	}
+	postscriptLength := int64(last[0])
+	if postscriptLength+1 > size {
+		return errors.New("invalid orc file")
+	}
+	postscript, err := t.readAt(uint64(size-1-postscriptLength), uint64(postscriptLength))
+	if err != nil {
+		return err
+	}
+
+	var footerLength uint64
+	var magic string
+	t.blockSize = orcDefaultBlockSize
+	err = orcParseMessage(postscript, func(num protowire.Number, v uint64, b []byte) {
+		switch num {
+		case 1:
+			footerLength = v
+		case 2:
+			t.compression = v
+		case 3:
+			t.blockSize = v
+		case 8000:
+			magic = string(b)
+		}
+	})
+	if err != nil || magic != orcMagic {
+		return errors.New("invalid orc file, missing magic in postscript")
+	}
+	if t.blockSize == 0 || t.blockSize > orcMaxBlockSize {
+		return fmt.Errorf("invalid orc compression block size %d", t.blockSize)
+	}
+	switch t.compression {
+	case orcCompressionNone, orcCompressionZlib, orcCompressionSnappy:
+	case orcCompressionZstd:
+		t.zstd, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(orcMaxBlockSize))
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported orc compression - %d", t.compression)
+	}
+
+	if footerLength+uint64(postscriptLength)+1 > uint64(size) {
+		return errors.New("invalid orc file footer")
+	}
+	footer, err := t.readAt(uint64(size-1-postscriptLength)-footerLength, footerLength)
+	if err != nil {
+		return err
+	}
+	footer, err = t.decompress(footer)
+	if err != nil {
+		return err
+	}
+	err = t.parseFooter(footer)
+	if err != nil {
+		log.Error("unable to read orc footer", err)
+		return err
+	}
+	err = t.validateStripes()
+	if err != nil {
+		return err
+	}
+
+	return t.initColumns()
+}
+
+func (t *orcDataSourceReader) parseFooter(footer []byte) (err error) {
+	return orcParseMessage(footer, func(num protowire.Number, v uint64, b []byte) {
+		switch num {
+		case 6:
+			t.numberOfRows = v
+		case 3:
+			stripe := orcStripe{}
+			e := orcParseMessage(b, func(num protowire.Number, v uint64, b []byte) {
+				switch num {
+				case 1:
+					stripe.offset = v
+				case 2:
+					stripe.indexLength = v
+				case 3:
+					stripe.dataLength = v
+				case 4:
+					stripe.footerLength = v
+				case 5:
+					stripe.rows = v
+				}
+			})
+			if e != nil {
+				err = e
+			}
+			t.stripes = append(t.stripes, stripe)
+		case 4:
+			orcType := orcType{}
+			e := orcParseMessage(b, func(num protowire.Number, v uint64, b []byte) {
+				switch num {
+				case 1:
+					orcType.kind = v
+				case 2:
+					orcType.subtypes = orcAppendUints(orcType.subtypes, v, b)
+				case 3:
+					orcType.fieldNames = append(orcType.fieldNames, string(b))
+				case 6:
+					orcType.scale = v
+				}
+			})
+			if e != nil {
+				err = e
+			}
+			t.types = append(t.types, orcType)
+		}
+	})
+}
+
+// validateStripes checks stripe sizes and row counts against file size and footer, as they are used for allocating memory
+func (t *orcDataSourceReader) validateStripes() error {
+	var rows uint64
+	for i, stripe := range t.stripes {
+		if stripe.offset > t.size || stripe.indexLength > t.size || stripe.dataLength > t.size || stripe.footerLength > t.size ||
+			stripe.indexLength+stripe.dataLength+stripe.footerLength > t.size-stripe.offset {
+			return fmt.Errorf("invalid orc stripe %d, offset %d is beyond file size %d", i, stripe.offset, t.size)
+		}
+		rows = rows + stripe.rows
+		if stripe.rows > orcMaxStripeRows || stripe.rows > t.numberOfRows || rows > t.numberOfRows {
+			return fmt.Errorf("invalid orc stripe %d, rows %d are more than rows %d of file", i, stripe.rows, t.numberOfRows)
+		}
+	}
+	return nil
+}
+
+// initColumns derives schema from top level struct, nested columns (list/map/struct/union) are skipped
+func (t *orcDataSourceReader) initColumns() error {
+	if len(t.types) == 0 {
+		return errors.New("invalid orc file, missing types")
+	}
+
+	ids := []uint64{0}
+	names := []string{"value"}
+	if t.types[0].kind == orcTypeStruct {
+		ids = t.types[0].subtypes
+		names = t.types[0].fieldNames
+	}
+
+	series := []df.SeriesSchema{}
+	for i, id := range ids {
+		if id >= uint64(len(t.types)) || i >= len(names) {
+			return errors.New("invalid orc file, invalid types")
+		}
+		orcType := t.types[id]
+		format := orcColumnFormat(orcType.kind)
+		if format == nil {
+			log.Warnf("skipping orc column %s, only primitive columns are supported", names[i])
+			continue
+		}
+		t.columns = append(t.columns, orcColumn{id: id, kind: orcType.kind, scale: orcType.scale})
+		series = append(series, df.SeriesSchema{Name: names[i], Format: format})
+	}
+	t.cols = df.NewSchema(series)
+
+	t.read = make([]bool, len(t.columns))
+	for c := range t.read {
+		t.read[c] = t.selected == nil
+	}
+	for _, c := range t.selected {
+		if c < len(t.read) {
+			t.read[c] = true
+		}
+	}
+	return nil
+}
+
+func orcColumnFormat(kind uint64) df.Format {
+	switch kind {
+	case orcTypeBoolean:
+		return df.BoolFormat
+	case orcTypeByte, orcTypeShort, orcTypeInt, orcTypeLong:
+		return df.IntegerFormat
+	case orcTypeFloat, orcTypeDouble, orcTypeDecimal:
+		return df.DoubleFormat
+	case orcTypeString, orcTypeBinary, orcTypeVarchar, orcTypeChar:
+		return df.StringFormat
+	case orcTypeTimestamp, orcTypeTimestampInstant, orcTypeDate:
+		return df.DateTimeFormat
+	}
+	return nil
+}
+
+func (t *orcDataSourceReader) readAt(offset uint64, length uint64) ([]byte, error) {
+	if offset > t.size || length > t.size-offset {
+		return nil, fmt.Errorf("invalid orc file, offset %d and length %d are beyond file size %d", offset, length, t.size)
+	}
+	data := make([]byte, length)
+	n, err := t.source.ReadAt(data, int64(offset))
+	if err == io.EOF && uint64(n) == length {
+		err = nil
+	}
+	return data, err
+}
+
+// decompress decompresses chunks of stream, each chunk has 3 byte header with length and flag for uncompressed chunk
+func (t *orcDataSourceReader) decompress(data []byte) ([]byte, error) {
+	if t.compression == orcCompressionNone {
+		return data, nil
+	}
+
+	out := make([]byte, 0, len(data))
+	for len(data) > 0 {
+		if len(data) < 3 {
+			return nil, errors.New("invalid orc compression chunk")
+		}
+		header := uint64(data[0]) | uint64(data[1])<<8 | uint64(data[2])<<16
+		length := header >> 1
+		data = data[3:]
+		if uint64(len(data)) < length {
+			return nil, errors.New("invalid orc compression chunk")
+		}
+		chunk := data[:length]
+		data = data[length:]
+
+		if header&1 == 1 {
+			out = append(out, chunk...)
+			continue
+		}
+		switch t.compression {
+		case orcCompressionZlib:
+			reader := flate.NewReader(bytes.NewReader(chunk))
+			decoded, err := io.ReadAll(io.LimitReader(reader, int64(t.blockSize)+1))
+			reader.Close()
+			if err != nil {
+				return nil, err
+			}
+			if uint64(len(decoded)) > t.blockSize {
+				return nil, errors.New("invalid orc compression chunk, decompressed size is too large")
+			}
+			out = append(out, decoded...)
+		case orcCompressionSnappy:
+			length, err := snappy.DecodedLen(chunk)
+			if err != nil {
+				return nil, err
+			}
+			if uint64(length) > t.blockSize {
+				return nil, errors.New("invalid orc compression chunk, decompressed size is too large")
+			}
+			decoded, err := snappy.Decode(nil, chunk)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, decoded...)
+		case orcCompressionZstd:
+			decoded, err := t.zstd.DecodeAll(chunk, nil)
+			if err != nil {
+				return nil, err
+			}
+			if uint64(len(decoded)) > t.blockSize {
+				return nil, errors.New("invalid orc compression chunk, decompressed size is too large")
+			}
+			out = append(out, decoded...)
+		}
+	}
+	return out, nil
+}
+
+// readStripe reads values of selected columns, streams of other columns are not read
+func (t *orcDataSourceReader) readStripe(stripe orcStripe) (values [][]any, err error) {
+	footer, err := t.readAt(stripe.offset+stripe.indexLength+stripe.dataLength, stripe.footerLength)
+	if err != nil {
+		return nil, err
+	}
+	footer, err = t.decompress(footer)
+	if err != nil {
+		return nil, err
+	}
+
+	streams := map[uint64][]orcStream{}
+	encodings := []uint64{}
+	timezone := ""
+	offset := stripe.offset
+	err = orcParseMessage(footer, func(num protowire.Number, v uint64, b []byte) {
+		switch num {
+		case 1:
+			stream := orcStream{offset: offset}
+			_ = orcParseMessage(b, func(num protowire.Number, v uint64, b []byte) {
+				switch num {
+				case 1:
+					stream.kind = v
+				case 2:
+					stream.column = v
+				case 3:
+					stream.length = v
+				}
+			})
+			offset = offset + stream.length
+			streams[stream.column] = append(streams[stream.column], stream)
+		case 2:
+			var encoding uint64
+			_ = orcParseMessage(b, func(num protowire.Number, v uint64, b []byte) {
+				if num == 1 {
+					encoding = v
+				}
+			})
+			encodings = append(encodings, encoding)
+		case 3:
+			timezone = string(b)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	loc := time.UTC
+	if timezone != "" {
+		loc, err = time.LoadLocation(timezone)
+		if err != nil {
+			return nil, errors.New("unsupported orc writer timezone - " + timezone)
+		}
+	}
+
+	values = make([][]any, len(t.columns))
+	for c, col := range t.columns {
+		if !t.read[c] {
+			continue
+		}
+		encoding := uint64(orcEncodingDirect)
+		if col.id < uint64(len(encodings)) {
+			encoding = encodings[col.id]
+		}
+		values[c], err = t.readColumn(col, streams[col.id], encoding, int(stripe.rows), loc)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read orc column %s - %w", t.cols.Get(c).Name, err)
+		}
+	}
+	return values, nil
+}
+
+// readColumn reads values of column in stripe, loc is writer timezone of the stripe
+func (t *orcDataSourceReader) readColumn(col orcColumn, streams []orcStream, encoding uint64, rows int, loc *time.Location) (values []any, err error) {
+	stream := func(kind uint64) (*bytes.Reader, error) {
+		for _, s := range streams {
+			if s.kind == kind {
+				data, err := t.readAt(s.offset, s.length)
+				if err != nil {
+					return nil, err
+				}
+				data, err = t.decompress(data)
+				return bytes.NewReader(data), err
+			}
+		}
+		return bytes.NewReader(nil), nil
+	}
+
+	present := make([]bool, rows)
+	count := rows
+	presentStream, err := stream(orcStreamPresent)
+	if err != nil {
+		return nil, err
+	}
+	if presentStream.Len() > 0 {
+		present, err = orcReadBools(presentStream, rows)
+		if err != nil {
+			return nil, err
+		}
+		count = 0
+		for _, p := range present {
+			if p {
+				count = count + 1
+			}
+		}
+	} else {
+		for i := range present {
+			present[i] = true
+		}
+	}
+
+	data, err := stream(orcStreamData)
+	if err != nil {
+		return nil, err
+	}
+	v2 := encoding == orcEncodingDirectV2 || encoding == orcEncodingDictionaryV2
+
+	decoded := make([]any, count)
+	switch col.kind {
+	case orcTypeBoolean:
+		bools, err := orcReadBools(data, count)
+		if err != nil {
+			return nil, err
+		}
+		for i, b := range bools {
+			decoded[i] = b
+		}
+	case orcTypeByte:
+		bytes, err := orcReadBytes(data, count)
+		if err != nil {
+			return nil, err
+		}
+		for i, b := range bytes {
+			decoded[i] = int64(int8(b))
+		}
+	case orcTypeShort, orcTypeInt, orcTypeLong, orcTypeDate:
+		ints, err := orcReadInts(data, count, true, v2)
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range ints {
+			if col.kind == orcTypeDate {
+				decoded[i] = time.Unix(v*24*60*60, 0).UTC()
+			} else {
+				decoded[i] = v
+			}
+		}
+	case orcTypeFloat, orcTypeDouble:
+		width := 8
+		if col.kind == orcTypeFloat {
+			width = 4
+		}
+		b := make([]byte, width)
+		for i := range decoded {
+			_, err = io.ReadFull(data, b)
+			if err != nil {
+				return nil, err
+			}
+			if width == 4 {
+				decoded[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+			} else {
+				decoded[i] = math.Float64frombits(binary.LittleEndian.Uint64(b))
+			}
+		}
+	case orcTypeString, orcTypeBinary, orcTypeVarchar, orcTypeChar:
+		lengthStream, err := stream(orcStreamLength)
+		if err != nil {
+			return nil, err
+		}
+		if encoding == orcEncodingDictionary || encoding == orcEncodingDictionaryV2 {
+			dictionaryStream, err := stream(orcStreamDictionaryData)
+			if err != nil {
+				return nil, err
+			}
+			lengths, err := orcReadInts(lengthStream, -1, false, v2)
+			if err != nil {
+				return nil, err
+			}
+			dictionary, err := orcReadStrings(dictionaryStream, lengths)
+			if err != nil {
+				return nil, err
+			}
+			indexes, err := orcReadInts(data, count, false, v2)
+			if err != nil {
+				return nil, err
+			}
+			for i, index := range indexes {
+				if index < 0 || index >= int64(len(dictionary)) {
+					return nil, errors.New("invalid orc dictionary index")
+				}
+				decoded[i] = dictionary[index]
+			}
+		} else {
+			lengths, err := orcReadInts(lengthStream, count, false, v2)
+			if err != nil {
+				return nil, err
+			}
+			strs, err := orcReadStrings(data, lengths)
+			if err != nil {
+				return nil, err
+			}
+			for i, s := range strs {
+				decoded[i] = s
+			}
+		}
+	case orcTypeTimestamp, orcTypeTimestampInstant:
+		secondary, err := stream(orcStreamSecondary)
+		if err != nil {
+			return nil, err
+		}
+		seconds, err := orcReadInts(data, count, true, v2)
+		if err != nil {
+			return nil, err
+		}
+		nanos, err := orcReadInts(secondary, count, false, v2)
+		if err != nil {
+			return nil, err
+		}
+		// instants are relative to utc, timestamps keep wall clock of writer timezone
+		base := orcTimestampBase
+		if col.kind == orcTypeTimestamp {
+			base = time.Date(2015, 1, 1, 0, 0, 0, 0, loc).Unix()
+		}
+		for i := range decoded {
+			// trailing zeros of nanos are stored in last 3 bits
+			nano := nanos[i] >> 3
+			if zeros := nanos[i] & 7; zeros != 0 {
+				for z := int64(0); z <= zeros; z++ {
+					nano = nano * 10
+				}
+			}
+			second := seconds[i] + base
+			if second < 0 && nano > 999999 {
+				second = second - 1
+			}
+			value := time.Unix(second, nano).In(loc)
+			if col.kind == orcTypeTimestamp {
+				value = time.Date(value.Year(), value.Month(), value.Day(), value.Hour(), value.Minute(), value.Second(), value.Nanosecond(), time.UTC)
+			}
+			decoded[i] = value.UTC()
+		}
+	case orcTypeDecimal:
+		secondary, err := stream(orcStreamSecondary)
+		if err != nil {
+			return nil, err
+		}
+		scales, err := orcReadInts(secondary, count, true, v2)
+		if err != nil {
+			return nil, err
+		}
+		for i := range decoded {
+			unscaled, err := orcReadBigVarint(data)
+			if err != nil {
+				return nil, err
+			}
+			scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(scales[i]), nil))
+			decoded[i], _ = new(big.Float).Quo(new(big.Float).SetInt(unscaled), scale).Float64()
+		}
+	}
+
+	if count == rows {
+		return decoded, nil
+	}
+	values = make([]any, rows)
+	index := 0
+	for i, p := range present {
+		if p {
+			values[i] = decoded[index]
+			index = index + 1
+		}
+	}
+	return values, nil
+}
+
+func orcReadStrings(r *bytes.Reader, lengths []int64) ([]string, error) {
+	strs := make([]string, len(lengths))
+	for i, length := range lengths {
+		if length < 0 || length > int64(r.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		b := make([]byte, length)
+		_, err := io.ReadFull(r, b)
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = string(b)
+	}
+	return strs, nil
+}
+
+// orcReadBigVarint reads unbounded zigzag encoded base 128 varint
+func orcReadBigVarint(r *bytes.Reader) (*big.Int, error) {
+	value := new(big.Int)
+	shift := uint(0)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		value.Or(value, new(big.Int).Lsh(big.NewInt(int64(b&0x7f)), shift))
+		shift = shift + 7
+		if b < 0x80 {
+			break
+		}
+	}
+	negative := value.Bit(0) == 1
+	value.Rsh(value, 1)
+	if negative {
+		value.Add(value, big.NewInt(1))
+		value.Neg(value)
+	}
+	return value, nil
+}
+
+// orcReadBytes decodes byte run length encoding
+func orcReadBytes(r *bytes.Reader, count int) ([]byte, error) {
+	values := make([]byte, 0, count)
+	for len(values) < count {
+		control, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if control < 0x80 {
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			for i := 0; i < int(control)+3; i++ {
+				values = append(values, b)
+			}
+		} else {
+			for i := 0; i < 0x100-int(control); i++ {
+				b, err := r.ReadByte()
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, b)
+			}
+		}
+	}
+	return values[:count], nil
+}
+
+// orcReadBools decodes bits (msb first) of byte run length encoding
+func orcReadBools(r *bytes.Reader, count int) ([]bool, error) {
+	b, err := orcReadBytes(r, (count+7)/8)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]bool, count)
+	for i := range values {
+		values[i] = b[i/8]&(0x80>>(i%8)) != 0
+	}
+	return values, nil
+}
+
+// orcReadInts decodes count integers of run length encoding v1/v2, all the integers are read if count < 0
+func orcReadInts(r *bytes.Reader, count int, signed bool, v2 bool) ([]int64, error) {
+	values := make([]int64, 0)
+	for count < 0 || len(values) < count {
+		if count < 0 && r.Len() == 0 {
+			break
+		}
+		var err error
+		if v2 {
+			values, err = orcReadIntRunV2(r, values, signed)
+		} else {
+			values, err = orcReadIntRunV1(r, values, signed)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if count >= 0 {
+		values = values[:count]
+	}
+	return values, nil
+}
+
+func orcReadVarint(r *bytes.Reader, signed bool) (int64, error) {
+	if signed {
+		return binary.ReadVarint(r)
+	}
+	v, err := binary.ReadUvarint(r)
+	return int64(v), err
+}
+
+func orcZigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func orcReadIntRunV1(r *bytes.Reader, values []int64, signed bool) ([]int64, error) {
+	control, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if control < 0x80 {
+		delta, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		base, err := orcReadVarint(r, signed)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < int(control)+3; i++ {
+			values = append(values, base+int64(i)*int64(int8(delta)))
+		}
+		return values, nil
+	}
+	for i := 0; i < 0x100-int(control); i++ {
+		v, err := orcReadVarint(r, signed)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// orcDecodeWidth returns bit width of 5 bit encoded width
+func orcDecodeWidth(code byte) int {
+	switch {
+	case code < 24:
+		return int(code) + 1
+	case code == 24:
+		return 26
+	case code == 25:
+		return 28
+	case code == 26:
+		return 30
+	case code == 27:
+		return 32
+	case code == 28:
+		return 40
+	case code == 29:
+		return 48
+	case code == 30:
+		return 56
+	}
+	return 64
+}
+
+// orcClosestWidth returns nearest supported bit width
+func orcClosestWidth(width int) int {
+	switch {
+	case width == 0:
+		return 1
+	case width <= 24:
+		return width
+	case width <= 26:
+		return 26
+	case width <= 28:
+		return 28
+	case width <= 30:
+		return 30
+	case width <= 32:
+		return 32
+	case width <= 40:
+		return 40
+	case width <= 48:
+		return 48
+	case width <= 56:
+		return 56
+	}
+	return 64
+}
+
+// orcReadBitPacked reads count big endian bit packed values, values start at byte boundary
+func orcReadBitPacked(r *bytes.Reader, count int, width int) ([]uint64, error) {
+	values := make([]uint64, count)
+	var current uint64
+	bits := 0
+	for i := range values {
+		var v uint64
+		for need := width; need > 0; {
+			if bits == 0 {
+				b, err := r.ReadByte()
+				if err != nil {
+					return nil, err
+				}
+				current = uint64(b)
+				bits = 8
+			}
+			take := need
+			if take > bits {
+				take = bits
+			}
+			v = v<<take | (current>>(bits-take))&(1<<take-1)
+			bits = bits - take
+			need = need - take
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func orcReadBigEndian(r *bytes.Reader, width int) (uint64, error) {
+	var v uint64
+	for i := 0; i < width; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+func orcReadIntRunV2(r *bytes.Reader, values []int64, signed bool) ([]int64, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch header >> 6 {
+	case 0:
+		// short repeat
+		width := int(header>>3&7) + 1
+		repeat := int(header&7) + 3
+		v, err := orcReadBigEndian(r, width)
+		if err != nil {
+			return nil, err
+		}
+		value := int64(v)
+		if signed {
+			value = orcZigzag(v)
+		}
+		for i := 0; i < repeat; i++ {
+			values = append(values, value)
+		}
+		return values, nil
+	case 1:
+		// direct
+		width := orcDecodeWidth(header >> 1 & 0x1f)
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		length := (int(header&1)<<8 | int(b)) + 1
+		packed, err := orcReadBitPacked(r, length, width)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range packed {
+			if signed {
+				values = append(values, orcZigzag(v))
+			} else {
+				values = append(values, int64(v))
+			}
+		}
+		return values, nil
+	case 2:
+		// patched base
+		width := orcDecodeWidth(header >> 1 & 0x1f)
+		h := make([]byte, 3)
+		_, err := io.ReadFull(r, h)
+		if err != nil {
+			return nil, err
+		}
+		length := (int(header&1)<<8 | int(h[0])) + 1
+		baseWidth := int(h[1]>>5&7) + 1
+		patchWidth := orcDecodeWidth(h[1] & 0x1f)
+		patchGapWidth := int(h[2]>>5&7) + 1
+		patchLength := int(h[2] & 0x1f)
+
+		v, err := orcReadBigEndian(r, baseWidth)
+		if err != nil {
+			return nil, err
+		}
+		// msb of base is sign bit
+		signBit := uint64(1) << (baseWidth*8 - 1)
+		base := int64(v &^ signBit)
+		if v&signBit != 0 {
+			base = -base
+		}
+
+		packed, err := orcReadBitPacked(r, length, width)
+		if err != nil {
+			return nil, err
+		}
+		patches, err := orcReadBitPacked(r, patchLength, orcClosestWidth(patchWidth+patchGapWidth))
+		if err != nil {
+			return nil, err
+		}
+		patchMask := uint64(1)<<patchWidth - 1
+		position := 0
+		for _, patch := range patches {
+			position = position + int(patch>>patchWidth)
+			if position >= length {
+				return nil, errors.New("invalid orc patched base run")
+			}
+			packed[position] = packed[position] | (patch&patchMask)<<width
+		}
+		for _, v := range packed {
+			values = append(values, base+int64(v))
+		}
+		return values, nil
+	}
+
+	// delta
+	width := 0
+	if code := header >> 1 & 0x1f; code != 0 {
+		width = orcDecodeWidth(code)
+	}
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length := (int(header&1)<<8 | int(b)) + 1
+	base, err := orcReadVarint(r, signed)
+	if err != nil {
+		return nil, err
+	}
+	delta, err := binary.ReadVarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	values = append(values, base)
+	if length == 1 {
+		return values, nil
+	}
+	current := base + delta
+	values = append(values, current)
+	if width == 0 {
+		for i := 2; i < length; i++ {
+			current = current + delta
+			values = append(values, current)
+		}
+		return values, nil
+	}
+	deltas, err := orcReadBitPacked(r, length-2, width)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deltas {
+		if delta < 0 {
+			current = current - int64(d)
+		} else {
+			current = current + int64(d)
+		}
+		values = append(values, current)
+	}
+	return values, nil
+}
@@ -0,0 +1,377 @@
+package formats
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestOrcDataSource(t *testing.T) {
+	source := OrcDataSource{}
+	assert.Equal(t, source.Name(), "orc")
+	_, err := source.Writer(nil, map[string]string{})
+	assert.Error(t, err)
+}
+
+func TestOrcReadInts(t *testing.T) {
+	// examples from orc specification
+	cases := []struct {
+		data     []byte
+		signed   bool
+		v2       bool
+		expected []int64
+	}{
+		{[]byte{0x61, 0x00, 0x07}, false, false, append(make([]int64, 0), orcTestRepeat(7, 100)...)},
+		{[]byte{0xfb, 0x02, 0x03, 0x06, 0x07, 0x0b}, false, false, []int64{2, 3, 6, 7, 11}},
+		{[]byte{0x0a, 0x27, 0x10}, false, true, []int64{10000, 10000, 10000, 10000, 10000}},
+		{[]byte{0x5e, 0x03, 0x5c, 0xa1, 0xab, 0x1e, 0xde, 0xad, 0xbe, 0xef}, false, true, []int64{23713, 43806, 57005, 48879}},
+		{[]byte{0xc6, 0x09, 0x02, 0x02, 0x22, 0x42, 0x42, 0x46}, false, true, []int64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29}},
+		{
+			[]byte{0x8e, 0x13, 0x2b, 0x21, 0x07, 0xd0, 0x1e, 0x00, 0x14, 0x70, 0x28, 0x32, 0x3c, 0x46, 0x50, 0x5a, 0x64, 0x6e, 0x78, 0x82, 0x8c, 0x96, 0xa0, 0xaa, 0xb4, 0xbe, 0xfc, 0xe8},
+			true, true,
+			[]int64{2030, 2000, 2020, 1000000, 2040, 2050, 2060, 2070, 2080, 2090, 2100, 2110, 2120, 2130, 2140, 2150, 2160, 2170, 2180, 2190},
+		},
+		{[]byte{0xc0, 0x04, 0x03, 0x03}, true, true, []int64{-2, -4, -6, -8, -10}},
+	}
+	for _, c := range cases {
+		values, err := orcReadInts(bytes.NewReader(c.data), len(c.expected), c.signed, c.v2)
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, values)
+	}
+
+	values, err := orcReadInts(bytes.NewReader([]byte{0x0a, 0x27, 0x10, 0x0a, 0x27, 0x10}), -1, false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, len(values))
+
+	_, err = orcReadInts(bytes.NewReader([]byte{0x5e, 0x03, 0x5c}), 4, false, true)
+	assert.Error(t, err)
+
+	b, err := orcReadBytes(bytes.NewReader([]byte{0x61, 0x00, 0xfe, 0x44, 0x45}), 102)
+	assert.NoError(t, err)
+	assert.Equal(t, append(make([]byte, 100), 0x44, 0x45), b)
+}
+
+func orcTestRepeat(v int64, n int) []int64 {
+	values := make([]int64, n)
+	for i := range values {
+		values[i] = v
+	}
+	return values
+}
+
+type orcTestStream struct {
+	kind   uint64
+	column uint64
+	data   []byte
+}
+
+// orcTestCompress compresses data in chunks of given codec, data is kept as original chunk if codec is none
+func orcTestCompress(t *testing.T, compression uint64, data []byte) []byte {
+	if compression == orcCompressionNone {
+		return data
+	}
+	var compressed []byte
+	switch compression {
+	case orcCompressionZlib:
+		var buff bytes.Buffer
+		writer, err := flate.NewWriter(&buff, flate.DefaultCompression)
+		assert.NoError(t, err)
+		_, err = writer.Write(data)
+		assert.NoError(t, err)
+		assert.NoError(t, writer.Close())
+		compressed = buff.Bytes()
+	case orcCompressionSnappy:
+		compressed = snappy.Encode(nil, data)
+	case orcCompressionZstd:
+		encoder, err := zstd.NewWriter(nil)
+		assert.NoError(t, err)
+		compressed = encoder.EncodeAll(data, nil)
+		assert.NoError(t, encoder.Close())
+	}
+	header := len(compressed) << 1
+	return append([]byte{byte(header), byte(header >> 8), byte(header >> 16)}, compressed...)
+}
+
+func orcTestMessage(fields ...any) []byte {
+	var b []byte
+	for i := 0; i < len(fields); i = i + 2 {
+		num := protowire.Number(fields[i].(int))
+		switch v := fields[i+1].(type) {
+		case uint64:
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, v)
+		case string:
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendString(b, v)
+		case []byte:
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendBytes(b, v)
+		}
+	}
+	return b
+}
+
+// orcTestFile writes orc file with given types, each stripe has same streams and writer timezone
+func orcTestFile(t *testing.T, compression uint64, stripeCount int, rows uint64, types [][]byte, encodings []uint64, streams []orcTestStream, timezone string) []byte {
+	var file bytes.Buffer
+	file.WriteString(orcMagic)
+
+	stripeFooterFields := []any{}
+	for _, s := range streams {
+		s.data = orcTestCompress(t, compression, s.data)
+		stripeFooterFields = append(stripeFooterFields, 1, orcTestMessage(1, s.kind, 2, s.column, 3, uint64(len(s.data))))
+	}
+	for _, e := range encodings {
+		stripeFooterFields = append(stripeFooterFields, 2, orcTestMessage(1, e))
+	}
+	if timezone != "" {
+		stripeFooterFields = append(stripeFooterFields, 3, timezone)
+	}
+	stripeFooter := orcTestCompress(t, compression, orcTestMessage(stripeFooterFields...))
+
+	footerFields := []any{}
+	for i := 0; i < stripeCount; i++ {
+		offset := uint64(file.Len())
+		dataLength := 0
+		for _, s := range streams {
+			data := orcTestCompress(t, compression, s.data)
+			file.Write(data)
+			dataLength = dataLength + len(data)
+		}
+		file.Write(stripeFooter)
+		footerFields = append(footerFields, 3, orcTestMessage(1, offset, 2, uint64(0), 3, uint64(dataLength), 4, uint64(len(stripeFooter)), 5, rows))
+	}
+	for _, orcType := range types {
+		footerFields = append(footerFields, 4, orcType)
+	}
+	footerFields = append(footerFields, 6, rows*uint64(stripeCount))
+	footer := orcTestCompress(t, compression, orcTestMessage(footerFields...))
+	file.Write(footer)
+
+	postscript := orcTestMessage(1, uint64(len(footer)), 2, compression, 3, uint64(256*1024), 8000, orcMagic)
+	file.Write(postscript)
+	file.WriteByte(byte(len(postscript)))
+	return file.Bytes()
+}
+
+func orcTestDoubles(values ...float64) []byte {
+	b := make([]byte, 8*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint64(b[i*8:], math.Float64bits(v))
+	}
+	return b
+}
+
+func TestOrcDataSourceReader(t *testing.T) {
+	subtypes := []byte{}
+	for i := uint64(1); i <= 8; i++ {
+		subtypes = protowire.AppendVarint(subtypes, i)
+	}
+	types := [][]byte{
+		orcTestMessage(1, uint64(orcTypeStruct), 2, subtypes, 3, "id", 3, "name", 3, "score", 3, "flag", 3, "day", 3, "ts", 3, "amount", 3, "tags"),
+		orcTestMessage(1, uint64(orcTypeLong)),
+		orcTestMessage(1, uint64(orcTypeString)),
+		orcTestMessage(1, uint64(orcTypeDouble)),
+		orcTestMessage(1, uint64(orcTypeBoolean)),
+		orcTestMessage(1, uint64(orcTypeDate)),
+		orcTestMessage(1, uint64(orcTypeTimestamp)),
+		orcTestMessage(1, uint64(orcTypeDecimal), 5, uint64(10), 6, uint64(2)),
+		orcTestMessage(1, uint64(10), 2, protowire.AppendVarint(nil, 9)),
+		orcTestMessage(1, uint64(orcTypeString)),
+	}
+	encodings := []uint64{
+		orcEncodingDirect, orcEncodingDirectV2, orcEncodingDictionaryV2, orcEncodingDirect, orcEncodingDirect,
+		orcEncodingDirectV2, orcEncodingDirect, orcEncodingDirectV2, orcEncodingDirectV2, orcEncodingDirectV2,
+	}
+
+	seconds := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC).Unix() - orcTimestampBase
+	amounts := []byte{}
+	for _, v := range []uint64{24689, 200, 0, 2} {
+		amounts = protowire.AppendVarint(amounts, v)
+	}
+	// 99999999999999999999 zigzag encoded
+	amounts = append(amounts, 0xfe, 0xff, 0xff, 0xb0, 0xac, 0x8b, 0xaf, 0xc7, 0xd7, 0x15)
+	streams := []orcTestStream{
+		{orcStreamData, 1, []byte{0xc0, 0x04, 0x02, 0x02}},
+		{orcStreamPresent, 2, []byte{0xff, 0xd8}},
+		{orcStreamData, 2, []byte{0x42, 0x03, 0x12}},
+		{orcStreamDictionaryData, 2, []byte("abc")},
+		{orcStreamLength, 2, []byte{0x00, 0x01}},
+		{orcStreamData, 3, orcTestDoubles(1.5, 2.5, 3.5, 4.5, 5.5)},
+		{orcStreamData, 4, []byte{0xff, 0xb0}},
+		{orcStreamData, 5, []byte{0x0a, 0x92, 0x04}},
+		{orcStreamData, 6, append([]byte{0x02, 0x00}, protowire.AppendVarint(nil, protowire.EncodeZigZag(seconds))...)},
+		{orcStreamSecondary, 6, append([]byte{0x02, 0x00}, protowire.AppendVarint(nil, 891<<3|5)...)},
+		{orcStreamData, 7, amounts},
+		{orcStreamSecondary, 7, []byte{0x02, 0x04}},
+	}
+	expected := df.NewSchema([]df.SeriesSchema{
+		{Name: "id", Format: df.IntegerFormat},
+		{Name: "name", Format: df.StringFormat},
+		{Name: "score", Format: df.DoubleFormat},
+		{Name: "flag", Format: df.BoolFormat},
+		{Name: "day", Format: df.DateTimeFormat},
+		{Name: "ts", Format: df.DateTimeFormat},
+		{Name: "amount", Format: df.DoubleFormat},
+	})
+	ts := time.Date(2021, 3, 4, 5, 6, 7, 891000000, time.UTC)
+	day := time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	source := OrcDataSource{}
+	for _, compression := range []uint64{orcCompressionNone, orcCompressionZlib, orcCompressionSnappy, orcCompressionZstd} {
+		data := orcTestFile(t, compression, 2, 5, types, encodings, streams, "")
+
+		// reading from both random access and non seekable readers
+		for _, reader := range []io.Reader{bytes.NewReader(data), io.MultiReader(bytes.NewReader(data))} {
+			orcReader, err := source.Reader(reader, map[string]string{})
+			assert.NoError(t, err)
+			assert.Equal(t, expected, orcReader.Schema())
+
+			dfData := *(orcReader.Data())
+			assert.Equal(t, 10, len(dfData))
+			assert.Equal(t, []any{int64(1), "a", 1.5, true, day, ts, -123.45}, testRowValues(dfData[0]))
+			assert.Equal(t, []any{int64(2), "b", 2.5, false, day, ts, 1.0}, testRowValues(dfData[1]))
+			assert.Equal(t, []any{int64(3), nil, 3.5, true, day, ts, 0.0}, testRowValues(dfData[2]))
+			assert.Equal(t, "a", dfData[3].GetRaw(1))
+			assert.Equal(t, 0.01, dfData[3].GetRaw(6))
+			assert.Equal(t, "c", dfData[4].GetRaw(1))
+			assert.Equal(t, 1e18, dfData[4].GetRaw(6))
+			assert.Equal(t, int64(5), dfData[9].GetRaw(0))
+		}
+	}
+
+	// only selected columns are read
+	data := orcTestFile(t, orcCompressionZlib, 1, 5, types, encodings, streams, "")
+	streamReader, err := source.PushdownStreamReader(bytes.NewReader(data), map[string]string{}, []int{1}, nil)
+	assert.NoError(t, err)
+	r, err := streamReader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, []any{nil, "a", nil, nil, nil, nil, nil}, testRowValues(r))
+	assert.NoError(t, streamReader.Close())
+
+	_, err = source.Reader(bytes.NewReader([]byte("a,b\n1,2\n")), map[string]string{})
+	assert.Error(t, err)
+}
+
+func TestOrcDataSourceReaderTimezone(t *testing.T) {
+	subtypes := protowire.AppendVarint(protowire.AppendVarint(nil, 1), 2)
+	types := [][]byte{
+		orcTestMessage(1, uint64(orcTypeStruct), 2, subtypes, 3, "ts", 3, "instant"),
+		orcTestMessage(1, uint64(orcTypeTimestamp)),
+		orcTestMessage(1, uint64(orcTypeTimestampInstant)),
+	}
+	encodings := []uint64{orcEncodingDirect, orcEncodingDirect, orcEncodingDirect}
+
+	// timestamps are relative to 2015-01-01 in writer timezone and instants to 2015-01-01 utc
+	loc, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+	wallClock := time.Date(2021, 7, 4, 5, 6, 7, 0, loc)
+	seconds := wallClock.Unix() - time.Date(2015, 1, 1, 0, 0, 0, 0, loc).Unix()
+	instantSeconds := wallClock.Unix() - orcTimestampBase
+	run := func(v int64) []byte {
+		return append([]byte{0x02, 0x00}, protowire.AppendVarint(nil, protowire.EncodeZigZag(v))...)
+	}
+	streams := []orcTestStream{
+		{orcStreamData, 1, run(seconds)},
+		{orcStreamSecondary, 1, []byte{0x02, 0x00, 0x00}},
+		{orcStreamData, 2, run(instantSeconds)},
+		{orcStreamSecondary, 2, []byte{0x02, 0x00, 0x00}},
+	}
+
+	source := OrcDataSource{}
+	orcReader, err := source.Reader(bytes.NewReader(orcTestFile(t, orcCompressionNone, 1, 5, types, encodings, streams, "America/New_York")), map[string]string{})
+	assert.NoError(t, err)
+	dfData := *(orcReader.Data())
+	assert.Equal(t, 5, len(dfData))
+	assert.Equal(t, time.Date(2021, 7, 4, 5, 6, 7, 0, time.UTC), dfData[0].GetRaw(0))
+	assert.Equal(t, time.Date(2021, 7, 4, 9, 6, 7, 0, time.UTC), dfData[0].GetRaw(1))
+
+	_, err = source.Reader(bytes.NewReader(orcTestFile(t, orcCompressionNone, 1, 5, types, encodings, streams, "Mars/Olympus")), map[string]string{})
+	assert.ErrorContains(t, err, "unsupported orc writer timezone - Mars/Olympus")
+}
+
+func TestOrcDataSourceReaderInvalidSizes(t *testing.T) {
+	reader := orcDataSourceReader{source: bytes.NewReader(make([]byte, 10)), size: 10, blockSize: 16}
+	_, err := reader.readAt(5, 1<<40)
+	assert.ErrorContains(t, err, "beyond file size 10")
+	_, err = reader.readAt(math.MaxUint64, 2)
+	assert.ErrorContains(t, err, "beyond file size 10")
+	data, err := reader.readAt(5, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(data))
+
+	reader.numberOfRows = 10
+	reader.stripes = []orcStripe{{offset: 3, dataLength: 5, rows: 10}}
+	assert.NoError(t, reader.validateStripes())
+	reader.stripes = []orcStripe{{offset: 3, dataLength: 1 << 40, rows: 10}}
+	assert.ErrorContains(t, reader.validateStripes(), "beyond file size 10")
+	reader.stripes = []orcStripe{{offset: 3, dataLength: 5, indexLength: math.MaxUint64, rows: 10}}
+	assert.ErrorContains(t, reader.validateStripes(), "beyond file size 10")
+	reader.stripes = []orcStripe{{offset: 3, dataLength: 5, rows: 1 << 40}}
+	assert.ErrorContains(t, reader.validateStripes(), "rows 1099511627776 are more than rows 10 of file")
+	reader.stripes = []orcStripe{{offset: 3, dataLength: 2, rows: 6}, {offset: 5, dataLength: 2, rows: 6}}
+	assert.ErrorContains(t, reader.validateStripes(), "invalid orc stripe 1")
+
+	// chunks are decompressed upto compression block size
+	reader.zstd, err = zstd.NewReader(nil)
+	assert.NoError(t, err)
+	defer reader.Close()
+	for _, compression := range []uint64{orcCompressionZlib, orcCompressionSnappy, orcCompressionZstd} {
+		reader.compression = compression
+		_, err = reader.decompress(orcTestCompress(t, compression, make([]byte, 100)))
+		assert.ErrorContains(t, err, "decompressed size is too large")
+		data, err = reader.decompress(orcTestCompress(t, compression, make([]byte, 16)))
+		assert.NoError(t, err)
+		assert.Equal(t, 16, len(data))
+	}
+}
+
+func TestOrcDataSourceReaderFile(t *testing.T) {
+	expected := df.NewSchema([]df.SeriesSchema{
+		{Name: "id", Format: df.IntegerFormat},
+		{Name: "name", Format: df.StringFormat},
+		{Name: "city", Format: df.StringFormat},
+		{Name: "score", Format: df.DoubleFormat},
+		{Name: "active", Format: df.BoolFormat},
+		{Name: "ts", Format: df.DateTimeFormat},
+		{Name: "day", Format: df.DateTimeFormat},
+	})
+	names := []string{"alpha", "beta", "gamma"}
+	start := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	day := time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	source := OrcDataSource{}
+	// files have 2 stripes with index streams, 1kb compression chunks and America/Los_Angeles writer timezone
+	for _, codec := range []string{"zlib", "snappy", "zstd"} {
+		file, err := os.Open("../../../testdata/orc/orders_" + codec + ".orc")
+		assert.NoError(t, err)
+		orcReader, err := source.Reader(file, map[string]string{})
+		file.Close()
+		assert.NoError(t, err, codec)
+		assert.Equal(t, expected, orcReader.Schema())
+
+		dfData := *(orcReader.Data())
+		assert.Equal(t, 1200, len(dfData), codec)
+		for i, r := range dfData {
+			var name any = names[i%3]
+			if i%7 == 3 {
+				name = nil
+			}
+			ts := start.Add(time.Duration(i)*time.Second + time.Duration(i%1000)*time.Millisecond)
+			assert.Equal(t, []any{int64(i + 1), name, "city-" + strconv.Itoa(i%10), float64(i) * 0.5, i%2 == 0, ts, day.AddDate(0, 0, i%30)},
+				testRowValues(r), codec)
+		}
+	}
+}
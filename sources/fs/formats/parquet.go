@@ -3,231 +3,303 @@ package formats
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"io"
+	"math/big"
 	"reflect"
 	"strconv"
 	"strings"
-	"sync"
+	"time"
 
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/memory"
 	"github.com/apache/arrow/go/v7/parquet"
 	"github.com/apache/arrow/go/v7/parquet/compress"
 	"github.com/apache/arrow/go/v7/parquet/file"
+	"github.com/apache/arrow/go/v7/parquet/metadata"
+	"github.com/apache/arrow/go/v7/parquet/pqarrow"
 	"github.com/apache/arrow/go/v7/parquet/schema"
 	"github.com/blue4209211/pq/df"
 	"github.com/blue4209211/pq/df/inmemory"
 	"github.com/blue4209211/pq/internal/log"
 )
 
-func parquetReadByLine(reader io.Reader) (schema df.DataFrameSchema, data []df.Row, err error) {
-	bufferedReader := bufio.NewReader(reader)
-	jobs := make(chan string, 5)
-	results := make(chan parquetAsyncReadResult, 100)
-	wg := new(sync.WaitGroup)
-	objMapListChannel := make(chan parquetAsyncReadResult, 1)
-	defer close(objMapListChannel)
+// parquetField top level field of parquet schema, fields which are not primitive (group/repeated) are read as json text
+type parquetField struct {
+	leaves []int
+	nested bool
+	// convert converts values of logical types (timestamp/date/decimal), nil for rest of the columns
+	convert func(v any) any
+}
 
-	for w := 0; w < 3; w++ {
-		wg.Add(1)
-		go parquetReadByArrayAsync(jobs, results, wg)
+// parquetReadRowGroup reads selected fields (nil for all) of given rowgroup, returns records in row order
+func parquetReadRowGroup(fileReader *file.Reader, rowGroup int, fields []parquetField, columns []bool) (records [][]any, err error) {
+	rowGroupReader := fileReader.RowGroup(rowGroup)
+	numRows := rowGroupReader.NumRows()
+	records = make([][]any, numRows)
+	for i := range records {
+		records[i] = make([]any, len(fields))
 	}
 
-	go parquetResultCollector(objMapListChannel, results)
+	var arrowReader *pqarrow.FileReader
+	defLevels := make([]int16, numRows)
+	for c, field := range fields {
+		if columns != nil && !columns[c] {
+			continue
+		}
 
-	// in somecases line size gets bigger than default scanner settings
-	// so using reader to handle those scenarios
-	parquetText := ""
-	for err == nil {
-		parquetTextArr, isPrefix, err := bufferedReader.ReadLine()
-		parquetText = parquetText + string(parquetTextArr)
-		if isPrefix {
+		if field.nested {
+			if arrowReader == nil {
+				arrowReader, err = pqarrow.NewFileReader(fileReader, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+				if err != nil {
+					log.Error("unable to read nested columns ", err)
+					return records, err
+				}
+			}
+			values, err := parquetReadNested(arrowReader, c, field.leaves, rowGroup, numRows)
+			if err != nil {
+				log.Error("unable to read column ", fileReader.MetaData().Schema.Root().Field(c).Name(), err)
+				return records, err
+			}
+			for j := 0; j < len(values) && j < len(records); j++ {
+				records[j][c] = values[j]
+			}
 			continue
 		}
-		if err == io.EOF {
-			break
+
+		colReader := rowGroupReader.Column(field.leaves[0])
+		maxDefLevel := colReader.Descriptor().MaxDefinitionLevel()
+
+		// values are returned only for non null entries, so definition levels are used to place them
+		fill := func(get func(i int) any) {
+			valueIndex := 0
+			for j := int64(0); j < numRows; j++ {
+				if maxDefLevel > 0 && defLevels[j] < maxDefLevel {
+					records[j][c] = nil
+					continue
+				}
+				records[j][c] = get(valueIndex)
+				if field.convert != nil {
+					records[j][c] = field.convert(records[j][c])
+				}
+				valueIndex++
+			}
 		}
 
-		jobs <- parquetText
-		parquetText = ""
+		switch colReader.Descriptor().PhysicalType() {
+		case parquet.Types.FixedLenByteArray:
+			fixedByteValues := make([]parquet.FixedLenByteArray, numRows)
+			_, _, err = colReader.(*file.FixedLenByteArrayColumnChunkReader).ReadBatch(numRows, fixedByteValues, defLevels, nil)
+			fill(func(i int) any { return string(fixedByteValues[i]) })
+		case parquet.Types.Double:
+			doubleValues := make([]float64, numRows)
+			_, _, err = colReader.(*file.Float64ColumnChunkReader).ReadBatch(numRows, doubleValues, defLevels, nil)
+			fill(func(i int) any { return doubleValues[i] })
+		case parquet.Types.Float:
+			floatValues := make([]float32, numRows)
+			_, _, err = colReader.(*file.Float32ColumnChunkReader).ReadBatch(numRows, floatValues, defLevels, nil)
+			fill(func(i int) any { return float64(floatValues[i]) })
+		case parquet.Types.ByteArray:
+			byteValues := make([]parquet.ByteArray, numRows)
+			_, _, err = colReader.(*file.ByteArrayColumnChunkReader).ReadBatch(numRows, byteValues, defLevels, nil)
+			fill(func(i int) any { return string(byteValues[i]) })
+		case parquet.Types.Int32:
+			intValues := make([]int32, numRows)
+			_, _, err = colReader.(*file.Int32ColumnChunkReader).ReadBatch(numRows, intValues, defLevels, nil)
+			fill(func(i int) any { return int64(intValues[i]) })
+		case parquet.Types.Int64:
+			int64Values := make([]int64, numRows)
+			_, _, err = colReader.(*file.Int64ColumnChunkReader).ReadBatch(numRows, int64Values, defLevels, nil)
+			fill(func(i int) any { return int64Values[i] })
+		case parquet.Types.Int96:
+			int96Values := make([]parquet.Int96, numRows)
+			_, _, err = colReader.(*file.Int96ColumnChunkReader).ReadBatch(numRows, int96Values, defLevels, nil)
+			fill(func(i int) any { return int96Values[i].String() })
+		case parquet.Types.Boolean:
+			boolValues := make([]bool, numRows)
+			_, _, err = colReader.(*file.BooleanColumnChunkReader).ReadBatch(numRows, boolValues, defLevels, nil)
+			fill(func(i int) any { return boolValues[i] })
+		}
+		if err != nil {
+			log.Error("unable to read column ", colReader.Descriptor().Name(), err)
+			return records, err
+		}
 	}
 
-	close(jobs)
-	wg.Wait()
-	close(results)
-	for r := range objMapListChannel {
-		return r.schema, r.data, r.err
-	}
-	return schema, data, errors.New("unable to read data")
+	return records, err
 }
 
-type parquetAsyncReadResult struct {
-	schema df.DataFrameSchema
-	data   []df.Row
-	err    error
-}
+func parquetReadSchema(parquetReader *file.Reader) (cols df.DataFrameSchema, fields []parquetField, err error) {
+	parquetSchema := parquetReader.MetaData().Schema
+	root := parquetSchema.Root()
+	fields = make([]parquetField, root.NumFields())
+	for c := 0; c < parquetSchema.NumColumns(); c++ {
+		f := root.FieldIndexByField(parquetSchema.ColumnRoot(c))
+		fields[f].leaves = append(fields[f].leaves, c)
+	}
 
-func parquetResultCollector(collector chan<- parquetAsyncReadResult, results <-chan parquetAsyncReadResult) {
-	var schema df.DataFrameSchema
-	records := make([]df.Row, 0)
-	for r := range results {
-		if r.err != nil {
-			collector <- parquetAsyncReadResult{err: r.err}
-			break
-		} else {
-			if schema == nil {
-				schema = r.schema
+	dfSchema := make([]df.SeriesSchema, root.NumFields())
+	for f := 0; f < root.NumFields(); f++ {
+		node := root.Field(f)
+		if node.Type() == schema.Group || node.RepetitionType() == parquet.Repetitions.Repeated {
+			fields[f].nested = true
+			dfSchema[f] = df.SeriesSchema{Name: node.Name(), Format: df.StringFormat}
+			continue
+		}
+		col := parquetSchema.Column(fields[f].leaves[0])
+		dfType, convert := parquetLogicalType(col)
+		if dfType == nil {
+			dfType, err = df.GetFormatFromKind(parquetParquetTypeToKindMap[col.PhysicalType()])
+			if err != nil {
+				log.Error("unable to get schema", col.PhysicalType(), err)
+				return cols, fields, err
 			}
-			dfRecords := r.data
-			records = append(records, dfRecords...)
 		}
+		fields[f].convert = convert
+		dfSchema[f] = df.SeriesSchema{Name: col.Name(), Format: dfType}
 	}
-
-	collector <- parquetAsyncReadResult{schema: schema, data: records, err: nil}
+	return df.NewSchema(dfSchema), fields, err
 }
 
-func parquetReadByArrayAsync(jobs <-chan string, results chan<- parquetAsyncReadResult, wg *sync.WaitGroup) {
-	defer wg.Done()
-
-	for data := range jobs {
-		s, r, e := parquetReadToArray(data)
-		results <- parquetAsyncReadResult{schema: s, data: r, err: e}
+// parquetReadNested reads nested field (list/map/struct) of rowgroup, values are returned as json text
+func parquetReadNested(arrowReader *pqarrow.FileReader, field int, leaves []int, rowGroup int, numRows int64) (values []any, err error) {
+	includedLeaves := make(map[int]bool, len(leaves))
+	for _, l := range leaves {
+		includedLeaves[l] = true
 	}
 
-}
-
-func parquetReadToArray(parquetText string) (schema df.DataFrameSchema, data []df.Row, err error) {
-	parquetReader, err := file.NewParquetReader(bytes.NewReader([]byte(parquetText)))
+	colReader, err := arrowReader.GetFieldReader(context.Background(), field, includedLeaves, []int{rowGroup})
 	if err != nil {
-		log.Error("unable to read parquet", err)
-		return schema, data, err
+		return nil, err
 	}
-	defer parquetReader.Close()
+	defer colReader.Release()
 
-	dfSchema := make([]df.SeriesSchema, parquetReader.MetaData().Schema.NumColumns())
-	dataArr := make([][]any, parquetReader.NumRows())
-	for i := int64(0); i < parquetReader.NumRows(); i++ {
-		dataArr[i] = make([]any, parquetReader.MetaData().Schema.NumColumns())
+	chunked, err := colReader.NextBatch(numRows)
+	if err != nil {
+		return nil, err
 	}
-	for r := 0; r < parquetReader.NumRowGroups(); r++ {
-		rowGroupReader := parquetReader.RowGroup(r)
-		for c := 0; c < rowGroupReader.NumColumns(); c++ {
-			colReader := rowGroupReader.Column(c)
-			dfType, err := df.GetFormatFromKind(parquetParquetTypeToKindMap[colReader.Descriptor().PhysicalType()])
+	defer chunked.Release()
+
+	values = make([]any, 0, numRows)
+	for _, chunk := range chunked.Chunks() {
+		for i := 0; i < chunk.Len(); i++ {
+			v := arrowJSONValue(chunk, i)
+			if v == nil {
+				values = append(values, nil)
+				continue
+			}
+			data, err := json.Marshal(v)
 			if err != nil {
-				log.Error("unable to get schema", colReader.Descriptor().PhysicalType(), err)
-				return schema, data, err
+				return nil, err
 			}
-			dfSchema[c] = df.SeriesSchema{Name: colReader.Descriptor().Name(), Format: dfType}
-
-			switch colReader.Descriptor().PhysicalType() {
-			case parquet.Types.FixedLenByteArray:
-				fixedByteReader := colReader.(*file.FixedLenByteArrayColumnChunkReader)
-				fixedByteValues := make([]parquet.FixedLenByteArray, parquetReader.NumRows())
-				_, _, err := fixedByteReader.ReadBatch(parquetReader.NumRows(), fixedByteValues, nil, nil)
-				if err != nil {
-					log.Error("unable to read fixedbytearray", err)
-					return schema, data, err
-				}
-				for j := int64(0); j < parquetReader.NumRows(); j++ {
-					dataArr[j][c] = string(fixedByteValues[j])
-				}
-			case parquet.Types.Double:
-				doubleReader := colReader.(*file.Float64ColumnChunkReader)
-				doubleValues := make([]float64, parquetReader.NumRows())
-				_, _, err := doubleReader.ReadBatch(parquetReader.NumRows(), doubleValues, nil, nil)
-				if err != nil {
-					log.Error("unable to read double", err)
-					return schema, data, err
-				}
-				for j := int64(0); j < parquetReader.NumRows(); j++ {
-					dataArr[j][c] = doubleValues[j]
-				}
-			case parquet.Types.Float:
-				floatReader := colReader.(*file.Float32ColumnChunkReader)
-				floatValues := make([]float32, parquetReader.NumRows())
-				_, _, err := floatReader.ReadBatch(parquetReader.NumRows(), floatValues, nil, nil)
-				if err != nil {
-					log.Error("unable to read float", err)
-					return schema, data, err
-				}
-				for j := int64(0); j < parquetReader.NumRows(); j++ {
-					dataArr[j][c] = float64(floatValues[j])
-				}
-			case parquet.Types.ByteArray:
-				byteReader := colReader.(*file.ByteArrayColumnChunkReader)
-				byteValues := make([]parquet.ByteArray, parquetReader.NumRows())
-				_, _, err := byteReader.ReadBatch(parquetReader.NumRows(), byteValues, nil, nil)
-				if err != nil {
-					log.Error("unable to read bytearray", err)
-					return schema, data, err
-				}
-				for j := int64(0); j < parquetReader.NumRows(); j++ {
-					dataArr[j][c] = string(byteValues[j])
-				}
-			case parquet.Types.Int32:
-				intReader := colReader.(*file.Int32ColumnChunkReader)
-				intValues := make([]int32, parquetReader.NumRows())
-				_, _, err := intReader.ReadBatch(parquetReader.NumRows(), intValues, nil, nil)
-				if err != nil {
-					log.Error("unable to read int32", err)
-					return schema, data, err
-				}
-				for j := int64(0); j < parquetReader.NumRows(); j++ {
-					dataArr[j][c] = int64(intValues[j])
-				}
-
-			case parquet.Types.Int64:
-				int64Reader := colReader.(*file.Int64ColumnChunkReader)
-				int64Values := make([]int64, parquetReader.NumRows())
-				_, _, err := int64Reader.ReadBatch(parquetReader.NumRows(), int64Values, nil, nil)
+			values = append(values, string(data))
+		}
+	}
+	return values, err
+}
 
-				if err != nil {
-					log.Error("unable to read int64", err)
-					return schema, data, err
-				}
-				for j := int64(0); j < parquetReader.NumRows(); j++ {
-					dataArr[j][c] = int64Values[j]
-				}
-			case parquet.Types.Int96:
-				int96Reader := colReader.(*file.Int96ColumnChunkReader)
-				int96Values := make([]parquet.Int96, parquetReader.NumRows())
-				_, _, err := int96Reader.ReadBatch(parquetReader.NumRows(), int96Values, nil, nil)
-				if err != nil {
-					log.Error("unable to read int96", err)
-					return schema, data, err
-				}
-				for j := int64(0); j < parquetReader.NumRows(); j++ {
-					dataArr[j][c] = int96Values[j].String()
-				}
-			case parquet.Types.Boolean:
-				boolReader := colReader.(*file.BooleanColumnChunkReader)
-				boolValues := make([]bool, parquetReader.NumRows())
-				_, _, err := boolReader.ReadBatch(parquetReader.NumRows(), boolValues, nil, nil)
-				if err != nil {
-					log.Error("unable to read boolean", err)
-					return schema, data, err
-				}
-				for j := int64(0); j < parquetReader.NumRows(); j++ {
-					dataArr[j][c] = boolValues[j]
+// parquetLogicalType returns format and converter of values for columns with timestamp/date/decimal logical type,
+// nil format is returned for rest of the columns
+func parquetLogicalType(col *schema.Column) (format df.Format, convert func(v any) any) {
+	switch t := col.LogicalType().(type) {
+	case *schema.TimestampLogicalType:
+		unit := t.TimeUnit()
+		return df.DateTimeFormat, func(v any) any {
+			i, ok := v.(int64)
+			if !ok {
+				return v
+			}
+			switch unit {
+			case schema.TimeUnitMillis:
+				return time.UnixMilli(i).UTC()
+			case schema.TimeUnitMicros:
+				return time.UnixMicro(i).UTC()
+			}
+			return time.Unix(0, i).UTC()
+		}
+	case schema.DateLogicalType:
+		return df.DateTimeFormat, func(v any) any {
+			i, ok := v.(int64)
+			if !ok {
+				return v
+			}
+			return time.Unix(i*24*60*60, 0).UTC()
+		}
+	case *schema.DecimalLogicalType:
+		scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(t.Scale())), nil))
+		return df.DoubleFormat, func(v any) any {
+			unscaled := new(big.Int)
+			switch i := v.(type) {
+			case int64:
+				unscaled.SetInt64(i)
+			case string:
+				// fixed/byte arrays are big-endian two's complement
+				unscaled.SetBytes([]byte(i))
+				if len(i) > 0 && i[0]&0x80 != 0 {
+					unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(i)*8)))
 				}
+			default:
+				return v
 			}
-
+			f, _ := new(big.Float).Quo(new(big.Float).SetInt(unscaled), scale).Float64()
+			return f
 		}
 	}
+	return nil, nil
+}
 
-	schema = df.NewSchema(dfSchema)
-	rows := make([]df.Row, len(dataArr))
-	for i, r := range dataArr {
-		rows[i] = inmemory.NewRowFromAny(&schema, &r)
-	}
-	return schema, rows, err
+// parquetNopCloser hides Close of underlying reader, closing it is responsibility of the caller
+type parquetNopCloser struct {
+	parquet.ReaderAtSeeker
 }
 
 // ConfigParquetSingleLine While parsing Input, treat eachline as parquet object or Single Object/Array in the file
 const ConfigParquetSingleLine = "parquet.objectOnEachLine"
 
+// ConfigParquetCompression compression codec used while writing - uncompressed/snappy/gzip/zstd/brotli
+const ConfigParquetCompression = "parquet.compression"
+
+// ConfigParquetRowGroupSize max number of rows in each rowgroup while writing
+const ConfigParquetRowGroupSize = "parquet.rowGroupSize"
+
+// ConfigParquetDictionary While writing, use dictionary encoding for columns
+const ConfigParquetDictionary = "parquet.dictionary"
+
+// ConfigParquetStatistics While writing, store min/max/null count statistics of columns
+const ConfigParquetStatistics = "parquet.statistics"
+
+// ConfigParquetDatetimeType parquet type used for datetime columns while writing - timestamp_millis/timestamp_micros/date
+const ConfigParquetDatetimeType = "parquet.datetimeType"
+
+// ConfigParquetNestedJSON While writing, string columns with json objects/arrays are written as nested (struct/list) columns, Default = false
+const ConfigParquetNestedJSON = "parquet.nestedJson"
+
 var parquetConfig = map[string]string{
-	ConfigParquetSingleLine: "false",
+	ConfigParquetSingleLine:   "false",
+	ConfigParquetNestedJSON:   "false",
+	ConfigParquetCompression:  "snappy",
+	ConfigParquetRowGroupSize: "100000",
+	ConfigParquetDictionary:   "true",
+	ConfigParquetStatistics:   "true",
+	ConfigParquetDatetimeType: "timestamp_micros",
+}
+
+// parquetDatetimeTypes timestamps are written as utc adjusted values
+var parquetDatetimeTypes = map[string]arrow.DataType{
+	"timestamp_millis": &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"},
+	"timestamp_micros": &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"},
+	"date":             arrow.FixedWidthTypes.Date32,
+}
+
+var parquetCompressionCodecs = map[string]compress.Compression{
+	"uncompressed": compress.Codecs.Uncompressed,
+	"none":         compress.Codecs.Uncompressed,
+	"snappy":       compress.Codecs.Snappy,
+	"gzip":         compress.Codecs.Gzip,
+	"zstd":         compress.Codecs.Zstd,
+	"brotli":       compress.Codecs.Brotli,
 }
 
 type ParquetDataSource struct {
@@ -246,9 +318,33 @@ func (t *ParquetDataSource) Writer(data df.DataFrame, args map[string]string) (F
 }
 
 func (t *ParquetDataSource) Reader(reader io.Reader, args map[string]string) (FormatReader, error) {
+	streamReader, err := t.StreamReader(reader, args)
+	if err != nil {
+		return nil, err
+	}
+	return ReadAll(streamReader)
+}
+
+// StreamReader reads one rowgroup at a time, if reader supports random access (ex - os.File) then it is used directly
+// else whole content is buffered in memory
+func (t *ParquetDataSource) StreamReader(reader io.Reader, args map[string]string) (FormatStreamReader, error) {
+	return t.PushdownStreamReader(reader, args, nil, nil)
+}
+
+// PushdownStreamReader reads only given columns and skips rowgroups whose statistics dont match filters,
+// rows of remaining rowgroups are returned without filtering
+func (t *ParquetDataSource) PushdownStreamReader(reader io.Reader, args map[string]string, cols []int, filters []df.Filter) (FormatStreamReader, error) {
 	parquetReader := &parquetDataSourceReader{args: args}
+	// columns of user provided schema dont map to parquet columns
+	if args[ConfigSchema] == "" {
+		parquetReader.selected = cols
+		parquetReader.filters = filters
+	}
 	err := parquetReader.init(reader)
-	return parquetReader, err
+	if err != nil {
+		return nil, err
+	}
+	return newFormatStreamReader(parquetReader, args)
 }
 
 type parquetDataSourceWriter struct {
@@ -257,84 +353,39 @@ type parquetDataSourceWriter struct {
 }
 
 func (t *parquetDataSourceWriter) Write(writer io.Writer) (err error) {
-	dfSchema := t.data.Schema()
-	fields := make([]schema.Node, dfSchema.Len())
-	for i, f := range dfSchema.Series() {
-		fields[i], _ = schema.NewPrimitiveNode(f.Name, parquet.Repetitions.Optional, parquetKindToParquetTypeMap[f.Format.Type()], int32(i), -1)
-	}
-
-	nodeGroup, _ := schema.NewGroupNode("root", parquet.Repetitions.Optional, fields, -1)
-	descr := schema.NewSchema(nodeGroup)
-
-	opts := make([]parquet.WriterProperty, 0)
-	for i := 0; i < descr.NumColumns(); i++ {
-		opts = append(opts, parquet.WithCompressionFor(descr.Column(i).Name(), compress.Codecs.Uncompressed))
+	nestedJSON, err := parquetIsNestedJSON(t.args)
+	if err != nil {
+		return err
 	}
-	opts = append(opts, parquet.WithDataPageSize(t.data.Len()))
-	opts = append(opts, parquet.WithVersion(parquet.V2_LATEST))
-	opts = append(opts, parquet.WithDataPageVersion(parquet.DataPageV2))
-	opts = append(opts, parquet.WithDictionaryDefault(false))
-
-	props := parquet.NewWriterProperties(opts...)
 
-	parquertWriter := file.NewParquetWriter(writer, nodeGroup, file.WithWriterProps(props))
-	rowGroupWriter := parquertWriter.AppendBufferedRowGroup()
-
-	for col := 0; col < descr.NumColumns(); col++ {
-		columnChunkWriter, err := rowGroupWriter.Column(col)
-		if err != nil {
-			log.Error("unable to get next coulmn ", err)
-			return err
-		}
-
-		defValues := make([]int16, 3)
-		for idx := range defValues {
-			defValues[idx] = 1
-		}
-
-		var writerValue any
-
-		switch dfSchema.Get(col).Format.Type() {
-		case reflect.Int64:
-			writerValue = make([]int64, t.data.Len())
-		case reflect.Float64:
-			writerValue = make([]float64, t.data.Len())
-		case reflect.String:
-			writerValue = make([]string, t.data.Len())
-		case reflect.Bool:
-			writerValue = make([]bool, t.data.Len())
+	datetimeType, ok := parquetDatetimeTypes[t.args[ConfigParquetDatetimeType]]
+	if !ok {
+		if t.args[ConfigParquetDatetimeType] != "" {
+			return errors.New("invalid " + ConfigParquetDatetimeType + " - " + t.args[ConfigParquetDatetimeType])
 		}
+		datetimeType = parquetDatetimeTypes[parquetConfig[ConfigParquetDatetimeType]]
+	}
 
-		for i := int64(0); i < t.data.Len(); i++ {
-			r := t.data.GetRow(i)
-			if r.Get(col) == nil {
-				continue
-			}
+	arrowSchema, nested := arrowSchemaFromDataFrame(t.data, datetimeType, nestedJSON)
 
-			switch arr := writerValue.(type) {
-			case []bool:
-				arr[i] = r.GetAsBool(col)
-			case []int64:
-				arr[i] = r.GetAsInt(col)
-			case []string:
-				arr[i] = r.GetAsString(col)
-			case []float64:
-				arr[i] = r.GetAsDouble(col)
-			}
-		}
+	props, rowGroupSize, err := parquetWriterProperties(t.args)
+	if err != nil {
+		return err
+	}
 
-		_, err = parquetWriteBatchValues(columnChunkWriter, writerValue, defValues, nil)
-		if err != nil {
-			log.Error("unable to write data", err)
-			return err
-		}
-		columnChunkWriter.Close()
+	parquertWriter, err := pqarrow.NewFileWriter(arrowSchema, writer, props, pqarrow.DefaultWriterProps())
+	if err != nil {
+		log.Error("unable to create parquet writer ", err)
+		return err
 	}
-	err = rowGroupWriter.Close()
+
+	// each record is written as separate rowgroup
+	err = arrowWriteRecords(t.data, arrowSchema, nested, rowGroupSize, parquertWriter.Write)
 	if err != nil {
-		log.Error("Unable to close rowGroupWriter", err)
-		return
+		log.Error("unable to write data", err)
+		return err
 	}
+
 	err = parquertWriter.Close()
 	if err != nil {
 		log.Error("Unable to close parquertWriter", err)
@@ -345,50 +396,213 @@ func (t *parquetDataSourceWriter) Write(writer io.Writer) (err error) {
 }
 
 type parquetDataSourceReader struct {
-	args    map[string]string
-	cols    df.DataFrameSchema
-	records []df.Row
+	args       map[string]string
+	lineReader *bufio.Reader
+	fileReader *file.Reader
+	cols       df.DataFrameSchema
+	fields     []parquetField
+	rowGroup   int
+	records    [][]any
+	index      int
+	// selected columns and filters used for skipping columns/rowgroups, columns is nil if all the columns are read
+	selected []int
+	filters  []df.Filter
+	columns  []bool
 }
 
 func (t *parquetDataSourceReader) Schema() (columns df.DataFrameSchema) {
 	return t.cols
 }
 
-func (t *parquetDataSourceReader) Data() *[]df.Row {
-	return &t.records
+func (t *parquetDataSourceReader) Next() (r df.Row, err error) {
+	for t.index >= len(t.records) {
+		if t.fileReader == nil {
+			return r, io.EOF
+		}
 
-}
+		if t.rowGroup < t.fileReader.NumRowGroups() {
+			if !t.matchRowGroup(t.rowGroup) {
+				log.Debugf("skipping rowgroup %d", t.rowGroup)
+				t.rowGroup = t.rowGroup + 1
+				continue
+			}
+			t.records, err = parquetReadRowGroup(t.fileReader, t.rowGroup, t.fields, t.columns)
+			if err != nil {
+				return r, err
+			}
+			t.rowGroup = t.rowGroup + 1
+			t.index = 0
+			continue
+		}
 
-func (t *parquetDataSourceReader) readParquet(reader io.Reader) (schema df.DataFrameSchema, data []df.Row, err error) {
+		err = t.fileReader.Close()
+		t.fileReader = nil
+		if err != nil {
+			return r, err
+		}
+		if t.lineReader != nil {
+			err = t.openNextLine()
+			if err != nil {
+				return r, err
+			}
+		}
+	}
 
-	singlelineParse, err := parquetIsSingleLineParse(t.args)
-	if err != nil {
-		return schema, data, err
+	r = inmemory.NewRowFromAny(&t.cols, &t.records[t.index])
+	t.records[t.index] = nil
+	t.index = t.index + 1
+	return r, err
+}
+
+func (t *parquetDataSourceReader) Close() (err error) {
+	t.records = nil
+	if t.fileReader != nil {
+		err = t.fileReader.Close()
+		t.fileReader = nil
 	}
-	if singlelineParse {
-		return parquetReadByLine(reader)
+	return err
+}
+
+// openNextLine opens parquet content from next line, fileReader is nil if there are no more lines
+func (t *parquetDataSourceReader) openNextLine() (err error) {
+	// in somecases line size gets bigger than default scanner settings
+	// so using reader to handle those scenarios
+	parquetData := make([]byte, 0, 10000)
+	for {
+		parquetTextArr, isPrefix, err := t.lineReader.ReadLine()
+		if err == io.EOF {
+			if len(parquetData) == 0 {
+				return nil
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		parquetData = append(parquetData, parquetTextArr...)
+		if !isPrefix {
+			break
+		}
 	}
-	buf := new(strings.Builder)
-	_, err = io.Copy(buf, reader)
+
+	t.rowGroup = 0
+	t.fileReader, err = file.NewParquetReader(bytes.NewReader(parquetData))
 	if err != nil {
-		return
+		log.Error("unable to read parquet", err)
 	}
-	parquetStr := buf.String()
-	return parquetReadToArray(parquetStr)
-
+	return err
 }
 
 func (t *parquetDataSourceReader) init(reader io.Reader) (err error) {
-	schema, records, err := t.readParquet(reader)
+	singlelineParse, err := parquetIsSingleLineParse(t.args)
 	if err != nil {
 		return err
 	}
 
-	t.cols = schema
-	t.records = records
+	if singlelineParse {
+		t.lineReader = bufio.NewReader(reader)
+		err = t.openNextLine()
+		if err != nil {
+			return err
+		}
+		if t.fileReader == nil {
+			t.cols = df.NewSchema([]df.SeriesSchema{})
+			return
+		}
+	} else {
+		source, ok := reader.(parquet.ReaderAtSeeker)
+		if ok {
+			source = parquetNopCloser{source}
+		} else {
+			buf, err := io.ReadAll(reader)
+			if err != nil {
+				return err
+			}
+			source = bytes.NewReader(buf)
+		}
+
+		t.fileReader, err = file.NewParquetReader(source)
+		if err != nil {
+			log.Error("unable to read parquet", err)
+			return err
+		}
+	}
+
+	t.cols, t.fields, err = parquetReadSchema(t.fileReader)
+	if err != nil || t.selected == nil {
+		return err
+	}
+
+	// columns used in filters are read so that rows can be filtered
+	t.columns = make([]bool, t.cols.Len())
+	for _, c := range t.selected {
+		if c < len(t.columns) {
+			t.columns[c] = true
+		}
+	}
+	for _, f := range t.filters {
+		if f.Column < len(t.columns) {
+			t.columns[f.Column] = true
+		}
+	}
 	return err
 }
 
+// matchRowGroup returns false if statistics of rowgroup show that none of its rows match filters
+func (t *parquetDataSourceReader) matchRowGroup(rowGroup int) bool {
+	rowGroupMeta := t.fileReader.MetaData().RowGroup(rowGroup)
+	for _, f := range t.filters {
+		// statistics are not used for nested fields
+		if f.Column >= len(t.fields) || t.fields[f.Column].nested {
+			continue
+		}
+		chunk, err := rowGroupMeta.ColumnChunk(t.fields[f.Column].leaves[0])
+		if err != nil {
+			continue
+		}
+		stats, err := chunk.Statistics()
+		if err != nil || stats == nil {
+			continue
+		}
+
+		// datetime values are compared as strings by filters
+		if t.cols.Get(f.Column).Format == df.DateTimeFormat {
+			continue
+		}
+
+		min, max := parquetStatsMinMax(stats)
+		if convert := t.fields[f.Column].convert; convert != nil && min != nil && max != nil {
+			min, max = convert(min), convert(max)
+		}
+		hasNulls := !stats.HasNullCount() || stats.NullCount() > 0
+		allNulls := stats.HasNullCount() && stats.NullCount() == rowGroupMeta.NumRows()
+		if !f.MatchRange(t.cols.Get(f.Column).Format, min, max, hasNulls, allNulls) {
+			return false
+		}
+	}
+	return true
+}
+
+// parquetStatsMinMax returns min/max from statistics, nil is returned if they are not available
+func parquetStatsMinMax(stats metadata.TypedStatistics) (min any, max any) {
+	if !stats.HasMinMax() {
+		return nil, nil
+	}
+	switch s := stats.(type) {
+	case *metadata.Int32Statistics:
+		return int64(s.Min()), int64(s.Max())
+	case *metadata.Int64Statistics:
+		return s.Min(), s.Max()
+	case *metadata.Float32Statistics:
+		return float64(s.Min()), float64(s.Max())
+	case *metadata.Float64Statistics:
+		return s.Min(), s.Max()
+	case *metadata.ByteArrayStatistics:
+		return string(s.Min()), string(s.Max())
+	}
+	return nil, nil
+}
+
 func parquetIsSingleLineParse(config map[string]string) (singlelineParse bool, err error) {
 	singleline, ok := config[ConfigParquetSingleLine]
 	singlelineParse = false
@@ -402,6 +616,60 @@ func parquetIsSingleLineParse(config map[string]string) (singlelineParse bool, e
 	return
 }
 
+func parquetIsNestedJSON(config map[string]string) (nestedJSON bool, err error) {
+	nested, ok := config[ConfigParquetNestedJSON]
+	if ok && nested != "" {
+		nestedJSON, err = strconv.ParseBool(nested)
+	}
+	return
+}
+
+// parquetWriterProperties returns writer properties and rowgroup size based on config, defaults are used for missing values
+func parquetWriterProperties(config map[string]string) (props *parquet.WriterProperties, rowGroupSize int64, err error) {
+	arg := func(key string) string {
+		if v, ok := config[key]; ok && v != "" {
+			return v
+		}
+		return parquetConfig[key]
+	}
+
+	codecName := strings.ToLower(arg(ConfigParquetCompression))
+	codec, ok := parquetCompressionCodecs[codecName]
+	if !ok && (codecName == "lz4" || codecName == "lz4_raw") {
+		// arrow v7 parquet library doesnt provide lz4 codec and doesnt allow registering codecs
+		return nil, 0, errors.New("parquet compression " + codecName + " is not supported by parquet writer, use snappy or zstd")
+	}
+	if !ok {
+		return nil, 0, errors.New("unsupported parquet compression - " + codecName)
+	}
+
+	rowGroupSize, err = strconv.ParseInt(arg(ConfigParquetRowGroupSize), 10, 64)
+	if err != nil || rowGroupSize <= 0 {
+		return nil, 0, errors.New("invalid " + ConfigParquetRowGroupSize + " - " + arg(ConfigParquetRowGroupSize))
+	}
+
+	dictionary, err := strconv.ParseBool(arg(ConfigParquetDictionary))
+	if err != nil {
+		return nil, 0, errors.New("invalid " + ConfigParquetDictionary + " - " + arg(ConfigParquetDictionary))
+	}
+
+	stats, err := strconv.ParseBool(arg(ConfigParquetStatistics))
+	if err != nil {
+		return nil, 0, errors.New("invalid " + ConfigParquetStatistics + " - " + arg(ConfigParquetStatistics))
+	}
+
+	opts := make([]parquet.WriterProperty, 0)
+	opts = append(opts, parquet.WithCompression(codec))
+	opts = append(opts, parquet.WithMaxRowGroupLength(rowGroupSize))
+	opts = append(opts, parquet.WithDictionaryDefault(dictionary))
+	opts = append(opts, parquet.WithStats(stats))
+	opts = append(opts, parquet.WithVersion(parquet.V2_LATEST))
+	// v1 data pages are supported by most of the readers (ex - spark/trino)
+	opts = append(opts, parquet.WithDataPageVersion(parquet.DataPageV1))
+
+	return parquet.NewWriterProperties(opts...), rowGroupSize, nil
+}
+
 func parquetWriteBatchValues(writer file.ColumnChunkWriter, vals any, defLevels, repLevels []int16) (int64, error) {
 
 	switch w := writer.(type) {
@@ -434,37 +702,6 @@ func parquetWriteBatchValues(writer file.ColumnChunkWriter, vals any, defLevels,
 	}
 }
 
-func parquetReadBatch(reader file.ColumnChunkReader, batch int64, valueOut any, valuesRead int64, defLevels, repLevels []int16) int64 {
-	switch r := reader.(type) {
-	case *file.Int32ColumnChunkReader:
-		_, read, _ := r.ReadBatch(batch, valueOut.([]int32)[valuesRead:], defLevels, repLevels)
-		return int64(read)
-	case *file.Int64ColumnChunkReader:
-		_, read, _ := r.ReadBatch(batch, valueOut.([]int64)[valuesRead:], defLevels, repLevels)
-		return int64(read)
-	case *file.Float32ColumnChunkReader:
-		_, read, _ := r.ReadBatch(batch, valueOut.([]float32)[valuesRead:], defLevels, repLevels)
-		return int64(read)
-	case *file.Float64ColumnChunkReader:
-		_, read, _ := r.ReadBatch(batch, valueOut.([]float64)[valuesRead:], defLevels, repLevels)
-		return int64(read)
-	case *file.Int96ColumnChunkReader:
-		_, read, _ := r.ReadBatch(batch, valueOut.([]parquet.Int96)[valuesRead:], defLevels, repLevels)
-		return int64(read)
-	case *file.ByteArrayColumnChunkReader:
-		_, read, _ := r.ReadBatch(batch, valueOut.([]parquet.ByteArray)[valuesRead:], defLevels, repLevels)
-		return int64(read)
-	case *file.BooleanColumnChunkReader:
-		_, read, _ := r.ReadBatch(batch, valueOut.([]bool)[valuesRead:], defLevels, repLevels)
-		return int64(read)
-	case *file.FixedLenByteArrayColumnChunkReader:
-		_, read, _ := r.ReadBatch(batch, valueOut.([]parquet.FixedLenByteArray)[valuesRead:], defLevels, repLevels)
-		return int64(read)
-	default:
-		panic("unimplemented")
-	}
-}
-
 var parquetKindToParquetTypeMap = map[reflect.Kind]parquet.Type{
 	reflect.Bool:    parquet.Types.Boolean,
 	reflect.Int32:   parquet.Types.Int32,
@@ -3,14 +3,20 @@ package formats
 import (
 	"bytes"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/blue4209211/pq/df/inmemory"
 
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/array"
+	"github.com/apache/arrow/go/v7/arrow/memory"
 	"github.com/apache/arrow/go/v7/parquet"
 	"github.com/apache/arrow/go/v7/parquet/compress"
 	"github.com/apache/arrow/go/v7/parquet/file"
+	"github.com/apache/arrow/go/v7/parquet/pqarrow"
 	"github.com/apache/arrow/go/v7/parquet/schema"
 	"github.com/blue4209211/pq/df"
 	"github.com/stretchr/testify/assert"
@@ -185,3 +191,389 @@ func BenchmarkParquetParsing(b *testing.B) {
 		}
 	})
 }
+
+// parquetTestFile writes each of the given values as separate rowgroup with columns a (int64) and b (string)
+func parquetTestFile(t *testing.T, rowGroups ...[]int64) []byte {
+	fields := []schema.Node{
+		schema.NewInt64Node("a", parquet.Repetitions.Optional, -1),
+		schema.NewByteArrayNode("b", parquet.Repetitions.Optional, -1),
+	}
+	nodeGroup, _ := schema.NewGroupNode("root", parquet.Repetitions.Required, fields, -1)
+
+	var buff bytes.Buffer
+	parquertWriter := file.NewParquetWriter(&buff, nodeGroup)
+	for _, values := range rowGroups {
+		rowGroupWriter := parquertWriter.AppendRowGroup()
+		defLevels := make([]int16, len(values))
+		strValues := make([]parquet.ByteArray, len(values))
+		for i, v := range values {
+			defLevels[i] = 1
+			strValues[i] = parquet.ByteArray("b" + strconv.FormatInt(v, 10))
+		}
+
+		columnWriter, err := rowGroupWriter.NextColumn()
+		assert.NoError(t, err)
+		_, err = columnWriter.(*file.Int64ColumnChunkWriter).WriteBatch(values, defLevels, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, columnWriter.Close())
+
+		columnWriter, err = rowGroupWriter.NextColumn()
+		assert.NoError(t, err)
+		_, err = columnWriter.(*file.ByteArrayColumnChunkWriter).WriteBatch(strValues, defLevels, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, columnWriter.Close())
+		assert.NoError(t, rowGroupWriter.Close())
+	}
+	assert.NoError(t, parquertWriter.Close())
+	return buff.Bytes()
+}
+
+func TestParquetDataSourcePushdown(t *testing.T) {
+	data := parquetTestFile(t, []int64{1, 2, 3}, []int64{4, 5, 6}, []int64{7, 8, 9})
+	source := ParquetDataSource{}
+
+	readAll := func(cols []int, filters []df.Filter) []df.Row {
+		reader, err := source.PushdownStreamReader(bytes.NewReader(data), map[string]string{}, cols, filters)
+		assert.NoError(t, err)
+		defer reader.Close()
+		rows := make([]df.Row, 0)
+		for {
+			r, err := reader.Next()
+			if err != nil {
+				break
+			}
+			rows = append(rows, r)
+		}
+		return rows
+	}
+
+	// rowgroups are skipped based on statistics
+	rows := readAll([]int{1}, []df.Filter{{Column: 0, Op: ">", Value: int64(5)}})
+	assert.Equal(t, 6, len(rows))
+	assert.Equal(t, int64(4), rows[0].GetRaw(0))
+	assert.Equal(t, "b4", rows[0].GetRaw(1))
+
+	rows = readAll([]int{0}, []df.Filter{{Column: 1, Op: "=", Value: "b8"}})
+	assert.Equal(t, 3, len(rows))
+	assert.Equal(t, int64(7), rows[0].GetRaw(0))
+
+	// columns which are not selected are nil
+	rows = readAll([]int{1}, nil)
+	assert.Equal(t, 9, len(rows))
+	assert.True(t, rows[0].IsNil(0))
+	assert.Equal(t, "b1", rows[0].GetRaw(1))
+
+	rows = readAll(nil, []df.Filter{{Column: 0, Op: "isnull"}})
+	assert.Equal(t, 0, len(rows))
+}
+
+func TestParquetDataSourceNestedReader(t *testing.T) {
+	mem := memory.DefaultAllocator
+	arrowSchema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+		{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String), Nullable: true},
+		{Name: "attrs", Type: arrow.MapOf(arrow.BinaryTypes.String, arrow.PrimitiveTypes.Int64), Nullable: true},
+		{Name: "address", Type: arrow.StructOf(
+			arrow.Field{Name: "city", Type: arrow.BinaryTypes.String, Nullable: true},
+			arrow.Field{Name: "zip", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+		), Nullable: true},
+	}, nil)
+
+	recordBuilder := array.NewRecordBuilder(mem, arrowSchema)
+	defer recordBuilder.Release()
+
+	recordBuilder.Field(0).(*array.Int64Builder).AppendValues([]int64{1, 2}, nil)
+
+	tags := recordBuilder.Field(1).(*array.ListBuilder)
+	tags.Append(true)
+	tags.ValueBuilder().(*array.StringBuilder).AppendValues([]string{"x", "y"}, nil)
+	tags.AppendNull()
+
+	attrs := recordBuilder.Field(2).(*array.MapBuilder)
+	attrs.Append(true)
+	attrs.KeyBuilder().(*array.StringBuilder).Append("k")
+	attrs.ItemBuilder().(*array.Int64Builder).Append(1)
+	attrs.Append(true)
+
+	address := recordBuilder.Field(3).(*array.StructBuilder)
+	address.Append(true)
+	address.FieldBuilder(0).(*array.StringBuilder).Append("c1")
+	address.FieldBuilder(1).(*array.Int64Builder).Append(100)
+	address.Append(true)
+	address.FieldBuilder(0).(*array.StringBuilder).Append("c2")
+	address.FieldBuilder(1).(*array.Int64Builder).AppendNull()
+
+	record := recordBuilder.NewRecord()
+	defer record.Release()
+
+	var buff bytes.Buffer
+	parquertWriter, err := pqarrow.NewFileWriter(arrowSchema, &buff, nil, pqarrow.DefaultWriterProps())
+	assert.NoError(t, err)
+	assert.NoError(t, parquertWriter.Write(record))
+	assert.NoError(t, parquertWriter.Close())
+
+	source := ParquetDataSource{}
+	parquetReader, err := source.Reader(bytes.NewReader(buff.Bytes()), map[string]string{})
+	assert.NoError(t, err)
+
+	schema := parquetReader.Schema()
+	assert.Equal(t, 4, schema.Len())
+	assert.Equal(t, "tags", schema.Get(1).Name)
+	assert.Equal(t, df.StringFormat, schema.Get(1).Format)
+	assert.Equal(t, "address", schema.Get(3).Name)
+	assert.Equal(t, df.StringFormat, schema.Get(3).Format)
+
+	dfData := *(parquetReader.Data())
+	assert.Equal(t, 2, len(dfData))
+	assert.Equal(t, int64(1), dfData[0].GetRaw(0))
+	assert.Equal(t, `["x","y"]`, dfData[0].GetRaw(1))
+	assert.Equal(t, `{"k":1}`, dfData[0].GetRaw(2))
+	assert.Equal(t, `{"city":"c1","zip":100}`, dfData[0].GetRaw(3))
+	assert.Equal(t, int64(2), dfData[1].GetRaw(0))
+	assert.True(t, dfData[1].IsNil(1))
+	assert.Equal(t, `{}`, dfData[1].GetRaw(2))
+	assert.Equal(t, `{"city":"c2","zip":null}`, dfData[1].GetRaw(3))
+
+	// nested columns are read only when selected
+	reader, err := source.PushdownStreamReader(bytes.NewReader(buff.Bytes()), map[string]string{}, []int{3}, []df.Filter{{Column: 0, Op: "=", Value: int64(2)}})
+	assert.NoError(t, err)
+	r, err := reader.Next()
+	assert.NoError(t, err)
+	assert.True(t, r.IsNil(1))
+	assert.Equal(t, `{"city":"c1","zip":100}`, r.GetRaw(3))
+	assert.NoError(t, reader.Close())
+}
+
+func TestParquetDataSourceNestedWriter(t *testing.T) {
+	dfSchema := df.NewSchema([]df.SeriesSchema{{Name: "a", Format: df.IntegerFormat}, {Name: "b", Format: df.StringFormat}, {Name: "c", Format: df.StringFormat}, {Name: "d", Format: df.StringFormat}})
+	rows := []df.Row{
+		inmemory.NewRow(&dfSchema, &([]df.Value{inmemory.NewIntValueConst(1), inmemory.NewStringValueConst(`{"x":1,"y":[1,2],"z":{"k":"v"}}`), inmemory.NewStringValueConst(`[1,2.5]`), inmemory.NewStringValueConst(`{"x":1}`)})),
+		inmemory.NewRow(&dfSchema, &([]df.Value{inmemory.NewIntValueConst(2), inmemory.NewStringValueConst(`{"y":[],"w":true}`), inmemory.NewStringValueConst(``), inmemory.NewStringValueConst(`abc`)})),
+	}
+	dataframe := inmemory.NewDataframeFromRow(dfSchema, &rows)
+
+	source := ParquetDataSource{}
+	writer, err := source.Writer(dataframe, map[string]string{ConfigParquetNestedJSON: "true"})
+	assert.NoError(t, err)
+	var buff bytes.Buffer
+	assert.NoError(t, writer.Write(&buff))
+
+	parquetReader, err := file.NewParquetReader(bytes.NewReader(buff.Bytes()))
+	assert.NoError(t, err)
+	root := parquetReader.MetaData().Schema.Root()
+	assert.Equal(t, dfSchema.Len(), root.NumFields())
+	assert.Equal(t, true, root.Field(1).Type() == schema.Group)
+	assert.Equal(t, true, root.Field(2).Type() == schema.Group)
+	// columns with values other than json objects/arrays are written as string
+	assert.Equal(t, false, root.Field(3).Type() == schema.Group)
+	assert.NoError(t, parquetReader.Close())
+
+	reader, err := source.Reader(bytes.NewReader(buff.Bytes()), map[string]string{})
+	assert.NoError(t, err)
+	dfData := *(reader.Data())
+	assert.Equal(t, 2, len(dfData))
+	assert.Equal(t, `{"x":1,"y":[1,2],"z":{"k":"v"},"w":null}`, dfData[0].GetRaw(1))
+	assert.Equal(t, `{"x":null,"y":[],"z":null,"w":true}`, dfData[1].GetRaw(1))
+	assert.Equal(t, `[1,2.5]`, dfData[0].GetRaw(2))
+	assert.True(t, dfData[1].IsNil(2))
+	assert.Equal(t, `abc`, dfData[1].GetRaw(3))
+
+	// json strings are written as string columns by default
+	writer, err = source.Writer(dataframe, map[string]string{})
+	assert.NoError(t, err)
+	buff.Reset()
+	assert.NoError(t, writer.Write(&buff))
+	parquetReader, err = file.NewParquetReader(bytes.NewReader(buff.Bytes()))
+	assert.NoError(t, err)
+	root = parquetReader.MetaData().Schema.Root()
+	assert.Equal(t, false, root.Field(1).Type() == schema.Group)
+	assert.Equal(t, false, root.Field(2).Type() == schema.Group)
+	assert.NoError(t, parquetReader.Close())
+	reader, err = source.Reader(bytes.NewReader(buff.Bytes()), map[string]string{})
+	assert.NoError(t, err)
+	dfData = *(reader.Data())
+	assert.Equal(t, `{"x":1,"y":[1,2],"z":{"k":"v"}}`, dfData[0].GetRaw(1))
+	assert.Equal(t, ``, dfData[1].GetRaw(2))
+}
+
+func TestParquetDataSourceWriterOptions(t *testing.T) {
+	dfSchema := df.NewSchema([]df.SeriesSchema{{Name: "a", Format: df.IntegerFormat}, {Name: "b", Format: df.StringFormat}})
+	rows := make([]df.Row, 10)
+	for i := range rows {
+		rows[i] = inmemory.NewRow(&dfSchema, &([]df.Value{inmemory.NewIntValueConst(int64(i)), inmemory.NewStringValueConst("b")}))
+	}
+	dataframe := inmemory.NewDataframeFromRow(dfSchema, &rows)
+	source := ParquetDataSource{}
+
+	writeBytes := func(args map[string]string) ([]byte, error) {
+		writer, err := source.Writer(dataframe, args)
+		assert.NoError(t, err)
+		var buff bytes.Buffer
+		err = writer.Write(&buff)
+		return buff.Bytes(), err
+	}
+	write := func(args map[string]string) (*file.Reader, error) {
+		data, err := writeBytes(args)
+		if err != nil {
+			return nil, err
+		}
+		return file.NewParquetReader(bytes.NewReader(data))
+	}
+
+	// defaults
+	parquetReader, err := write(map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, parquetReader.NumRowGroups())
+	chunk, err := parquetReader.MetaData().RowGroup(0).ColumnChunk(0)
+	assert.NoError(t, err)
+	assert.Equal(t, compress.Codecs.Snappy, chunk.Compression())
+	assert.True(t, chunk.HasDictionaryPage())
+	stats, err := chunk.Statistics()
+	assert.NoError(t, err)
+	assert.NotNil(t, stats)
+	assert.NoError(t, parquetReader.Close())
+
+	parquetReader, err = write(map[string]string{
+		ConfigParquetCompression:  "gzip",
+		ConfigParquetRowGroupSize: "4",
+		ConfigParquetDictionary:   "false",
+		ConfigParquetStatistics:   "false",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, parquetReader.NumRowGroups())
+	assert.Equal(t, int64(2), parquetReader.MetaData().RowGroup(2).NumRows())
+	chunk, err = parquetReader.MetaData().RowGroup(0).ColumnChunk(0)
+	assert.NoError(t, err)
+	assert.Equal(t, compress.Codecs.Gzip, chunk.Compression())
+	assert.False(t, chunk.HasDictionaryPage())
+	stats, err = chunk.Statistics()
+	assert.NoError(t, err)
+	assert.Nil(t, stats)
+	assert.NoError(t, parquetReader.Close())
+
+	for _, codec := range []string{"zstd", "brotli", "uncompressed", "snappy"} {
+		data, err := writeBytes(map[string]string{ConfigParquetCompression: codec, ConfigParquetRowGroupSize: "3"})
+		assert.NoError(t, err)
+		reader, err := source.Reader(bytes.NewReader(data), map[string]string{})
+		assert.NoError(t, err)
+		dfData := *(reader.Data())
+		assert.Equal(t, 10, len(dfData))
+		assert.Equal(t, int64(9), dfData[9].GetRaw(0))
+		assert.Equal(t, "b", dfData[9].GetRaw(1))
+	}
+
+	_, err = write(map[string]string{ConfigParquetCompression: "lz4"})
+	assert.ErrorContains(t, err, "parquet compression lz4 is not supported by parquet writer")
+	_, err = write(map[string]string{ConfigParquetCompression: "lzo"})
+	assert.ErrorContains(t, err, "unsupported parquet compression - lzo")
+	_, err = write(map[string]string{ConfigParquetRowGroupSize: "0"})
+	assert.Error(t, err)
+}
+
+func TestParquetDataSourceLogicalTypes(t *testing.T) {
+	decimalInt, err := schema.NewPrimitiveNodeLogical("d1", parquet.Repetitions.Optional, schema.NewDecimalLogicalType(9, 2), parquet.Types.Int32, -1, -1)
+	assert.NoError(t, err)
+	decimalFixed, err := schema.NewPrimitiveNodeLogical("d2", parquet.Repetitions.Optional, schema.NewDecimalLogicalType(9, 3), parquet.Types.FixedLenByteArray, 4, -1)
+	assert.NoError(t, err)
+	timestamp, err := schema.NewPrimitiveNodeLogical("t", parquet.Repetitions.Optional, schema.NewTimestampLogicalType(true, schema.TimeUnitMillis), parquet.Types.Int64, -1, -1)
+	assert.NoError(t, err)
+	date, err := schema.NewPrimitiveNodeLogical("dt", parquet.Repetitions.Optional, schema.DateLogicalType{}, parquet.Types.Int32, -1, -1)
+	assert.NoError(t, err)
+	nodeGroup, _ := schema.NewGroupNode("root", parquet.Repetitions.Required, []schema.Node{decimalInt, decimalFixed, timestamp, date}, -1)
+
+	var buff bytes.Buffer
+	parquertWriter := file.NewParquetWriter(&buff, nodeGroup)
+	rowGroupWriter := parquertWriter.AppendRowGroup()
+	defLevels := []int16{1, 1}
+
+	columnWriter, _ := rowGroupWriter.NextColumn()
+	_, err = columnWriter.(*file.Int32ColumnChunkWriter).WriteBatch([]int32{12345, -5}, defLevels, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, columnWriter.Close())
+
+	columnWriter, _ = rowGroupWriter.NextColumn()
+	_, err = columnWriter.(*file.FixedLenByteArrayColumnChunkWriter).WriteBatch([]parquet.FixedLenByteArray{{0, 0, 0x30, 0x39}, {0xff, 0xff, 0xff, 0xfb}}, defLevels, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, columnWriter.Close())
+
+	columnWriter, _ = rowGroupWriter.NextColumn()
+	_, err = columnWriter.(*file.Int64ColumnChunkWriter).WriteBatch([]int64{1609459200123, 0}, defLevels, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, columnWriter.Close())
+
+	columnWriter, _ = rowGroupWriter.NextColumn()
+	_, err = columnWriter.(*file.Int32ColumnChunkWriter).WriteBatch([]int32{18628, -1}, defLevels, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, columnWriter.Close())
+	assert.NoError(t, rowGroupWriter.Close())
+	assert.NoError(t, parquertWriter.Close())
+
+	source := ParquetDataSource{}
+	parquetReader, err := source.Reader(bytes.NewReader(buff.Bytes()), map[string]string{})
+	assert.NoError(t, err)
+	dfSchema := parquetReader.Schema()
+	assert.Equal(t, df.DoubleFormat, dfSchema.Get(0).Format)
+	assert.Equal(t, df.DoubleFormat, dfSchema.Get(1).Format)
+	assert.Equal(t, df.DateTimeFormat, dfSchema.Get(2).Format)
+	assert.Equal(t, df.DateTimeFormat, dfSchema.Get(3).Format)
+
+	dfData := *(parquetReader.Data())
+	assert.Equal(t, 123.45, dfData[0].GetRaw(0))
+	assert.Equal(t, -0.05, dfData[1].GetRaw(0))
+	assert.Equal(t, 12.345, dfData[0].GetRaw(1))
+	assert.Equal(t, -0.005, dfData[1].GetRaw(1))
+	assert.Equal(t, time.Date(2021, 1, 1, 0, 0, 0, 123000000, time.UTC), dfData[0].GetRaw(2))
+	assert.Equal(t, time.Unix(0, 0).UTC(), dfData[1].GetRaw(2))
+	assert.Equal(t, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), dfData[0].GetRaw(3))
+	assert.Equal(t, time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC), dfData[1].GetRaw(3))
+
+	// decimal statistics are compared using converted values
+	reader, err := source.PushdownStreamReader(bytes.NewReader(buff.Bytes()), map[string]string{}, []int{0}, []df.Filter{{Column: 0, Op: ">", Value: 200.0}})
+	assert.NoError(t, err)
+	_, err = reader.Next()
+	assert.Error(t, err)
+	assert.NoError(t, reader.Close())
+}
+
+func TestParquetDataSourceDatetimeWriter(t *testing.T) {
+	dfSchema := df.NewSchema([]df.SeriesSchema{{Name: "a", Format: df.DateTimeFormat}})
+	value := time.Date(2021, 3, 4, 5, 6, 7, 891234000, time.FixedZone("IST", 19800))
+	rows := []df.Row{
+		inmemory.NewRow(&dfSchema, &([]df.Value{inmemory.NewDatetimeValueConst(value)})),
+		inmemory.NewRowFromAny(&dfSchema, &([]any{nil})),
+	}
+	dataframe := inmemory.NewDataframeFromRow(dfSchema, &rows)
+	source := ParquetDataSource{}
+
+	cases := []struct {
+		datetimeType string
+		logicalType  string
+		expected     time.Time
+	}{
+		{"", "Timestamp(isAdjustedToUTC=true, timeUnit=microseconds, is_from_converted_type=false, force_set_converted_type=false)", value.UTC().Truncate(time.Microsecond)},
+		{"timestamp_millis", "Timestamp(isAdjustedToUTC=true, timeUnit=milliseconds, is_from_converted_type=false, force_set_converted_type=false)", value.UTC().Truncate(time.Millisecond)},
+		{"date", "Date", time.Date(2021, 3, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		writer, err := source.Writer(dataframe, map[string]string{ConfigParquetDatetimeType: c.datetimeType})
+		assert.NoError(t, err)
+		var buff bytes.Buffer
+		assert.NoError(t, writer.Write(&buff))
+
+		parquetReader, err := file.NewParquetReader(bytes.NewReader(buff.Bytes()))
+		assert.NoError(t, err)
+		assert.Equal(t, c.logicalType, parquetReader.MetaData().Schema.Column(0).LogicalType().String())
+		assert.NoError(t, parquetReader.Close())
+
+		reader, err := source.Reader(bytes.NewReader(buff.Bytes()), map[string]string{})
+		assert.NoError(t, err)
+		assert.Equal(t, df.DateTimeFormat, reader.Schema().Get(0).Format)
+		dfData := *(reader.Data())
+		assert.Equal(t, c.expected, dfData[0].GetRaw(0))
+		assert.True(t, dfData[1].IsNil(0))
+	}
+
+	writer, err := source.Writer(dataframe, map[string]string{ConfigParquetDatetimeType: "nanos"})
+	assert.NoError(t, err)
+	assert.Error(t, writer.Write(new(bytes.Buffer)))
+}
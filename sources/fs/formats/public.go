@@ -1,28 +1,51 @@
 package formats
 
 import (
+	"errors"
 	"io"
+	"strconv"
 	"strings"
 
 	"github.com/blue4209211/pq/df"
 )
 
+// ConfigSchemaSampleSize number of records read ahead to derive schema for formats without fixed schema (json/xml), <= 0 reads all records
+const ConfigSchemaSampleSize = "schema.sampleSize"
+
 // FormatSource Provides interface for all the data sources
 type FormatSource interface {
 	Name() string
 	// TODO remove reader, use filepaths, currently hard to decide when to close reader
 	Reader(reader io.Reader, args map[string]string) (FormatReader, error)
+	// StreamReader returns reader which reads rows from underlying reader on demand
+	StreamReader(reader io.Reader, args map[string]string) (FormatStreamReader, error)
 	// TODO remove reader, use filepaths
 	Writer(data df.DataFrame, args map[string]string) (FormatWriter, error)
 	Args() map[string]string
 }
 
+// PushdownFormatSource formats which can skip columns and blocks of rows (ex - parquet row groups) while reading,
+// returned rows can contain rows which dont match filters and values of columns which are not selected are nil
+type PushdownFormatSource interface {
+	FormatSource
+	PushdownStreamReader(reader io.Reader, args map[string]string, cols []int, filters []df.Filter) (FormatStreamReader, error)
+}
+
 // FormatReader Reads dataframe
 type FormatReader interface {
 	Schema() df.DataFrameSchema
 	Data() *[]df.Row
 }
 
+// FormatStreamReader Reads dataframe one row at a time
+type FormatStreamReader interface {
+	Schema() df.DataFrameSchema
+	// Next returns next row, io.EOF is returned once all the rows are read
+	Next() (df.Row, error)
+	// Close releases resources held by reader, underlying io.Reader is not closed
+	Close() error
+}
+
 // FormatWriter Writes dataframe to write
 type FormatWriter interface {
 	Write(writer io.Writer) error
@@ -39,9 +62,74 @@ func GetFormatHandler(fmt string) (src FormatSource, err error) {
 		return &XmlDataSource{}, err
 	} else if fmt == "parquet" {
 		return &ParquetDataSource{}, err
+	} else if fmt == "arrow" || fmt == "feather" || fmt == "ipc" {
+		return &ArrowDataSource{}, err
+	} else if fmt == "arrows" {
+		return &ArrowDataSource{stream: true}, err
+	} else if fmt == "avro" {
+		return &AvroDataSource{}, err
+	} else if fmt == "orc" {
+		return &OrcDataSource{}, err
+	} else if fmt == "xlsx" {
+		return &XlsxDataSource{}, err
+	} else if fmt == "yaml" || fmt == "yml" {
+		return &YamlDataSource{}, err
+	} else if fmt == "toml" {
+		return &TomlDataSource{}, err
+	} else if fmt == "fixed" || fmt == "fwf" {
+		return &FixedDataSource{}, err
 	} else if fmt == "table" {
 		return &TableDataSource{}, err
+	} else if fmt == "box" {
+		return &TableDataSource{style: tableStyleBox}, err
+	} else if fmt == "markdown" || fmt == "md" {
+		return &TableDataSource{style: tableStyleMarkdown}, err
+	} else if fmt == "html" {
+		return &TableDataSource{style: tableStyleHTML}, err
 	} else {
 		return &TextDataSource{}, err
 	}
 }
+
+// ReadAll reads all the rows from stream reader and closes it
+func ReadAll(streamReader FormatStreamReader) (FormatReader, error) {
+	defer streamReader.Close()
+
+	records := make([]df.Row, 0, 1000)
+	for {
+		r, err := streamReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return &streamDataReader{schema: streamReader.Schema(), records: records}, nil
+}
+
+type streamDataReader struct {
+	schema  df.DataFrameSchema
+	records []df.Row
+}
+
+func (t *streamDataReader) Schema() df.DataFrameSchema {
+	return t.schema
+}
+
+func (t *streamDataReader) Data() *[]df.Row {
+	return &t.records
+}
+
+func schemaSampleSize(args map[string]string) (size int, err error) {
+	sizeStr, ok := args[ConfigSchemaSampleSize]
+	size = 1000
+	if ok && sizeStr != "" {
+		size, err = strconv.Atoi(sizeStr)
+		if err != nil {
+			return size, errors.New("invalid " + ConfigSchemaSampleSize + " - " + sizeStr)
+		}
+	}
+	return
+}
@@ -3,6 +3,7 @@ package formats
 import (
 	"testing"
 
+	"github.com/blue4209211/pq/df"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -15,3 +16,14 @@ func TestGetSource(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "text", source.Name())
 }
+
+// testRowValues returns raw values of row, nulls are returned as nil
+func testRowValues(r df.Row) []any {
+	values := make([]any, r.Len())
+	for i := range values {
+		if !r.IsNil(i) {
+			values[i] = r.GetRaw(i)
+		}
+	}
+	return values
+}
@@ -0,0 +1,214 @@
+package formats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/blue4209211/pq/internal/log"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigSchema Path of schema file (json/yaml) listing columns of the source, when provided
+// columns are read in the given order and values are converted to the given formats
+const ConfigSchema = "schema"
+
+// schemaColumn column definition in schema file, ex - {"name": "c1", "format": "datetime", "nullable": false, "layout": "2006-01-02"}
+type schemaColumn struct {
+	Name     string `json:"name" yaml:"name"`
+	Format   string `json:"format" yaml:"format"`
+	Nullable *bool  `json:"nullable" yaml:"nullable"`
+	Layout   string `json:"layout" yaml:"layout"`
+}
+
+type schemaFile struct {
+	Columns []schemaColumn `json:"columns" yaml:"columns"`
+}
+
+// readSchemaFile reads columns from schema file, file can contain list of columns or object with columns key
+func readSchemaFile(path string) (cols []schemaColumn, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cols, err
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	unmarshal := json.Unmarshal
+	if ext == ".yaml" || ext == ".yml" {
+		unmarshal = yaml.Unmarshal
+	}
+
+	err = unmarshal(data, &cols)
+	if err != nil {
+		file := schemaFile{}
+		err = unmarshal(data, &file)
+		cols = file.Columns
+	}
+	if err != nil {
+		return cols, errors.New("schema : unable to parse - " + path + ", " + err.Error())
+	}
+	if len(cols) == 0 {
+		return cols, errors.New("schema : no columns found - " + path)
+	}
+	return cols, err
+}
+
+// userSchema columns of schema file along with their formats, used by readers to convert values
+type userSchema struct {
+	schema df.DataFrameSchema
+	cols   []schemaColumn
+}
+
+// readUserSchema reads schema from ConfigSchema file, nil is returned if schema is not configured
+func readUserSchema(args map[string]string) (*userSchema, error) {
+	path, ok := args[ConfigSchema]
+	if !ok || path == "" {
+		return nil, nil
+	}
+
+	cols, err := readSchemaFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	series := make([]df.SeriesSchema, len(cols))
+	for i, c := range cols {
+		format, err := df.GetFormat(c.Format)
+		if err != nil {
+			return nil, errors.New("schema : unsupported format for column " + c.Name + " - " + c.Format)
+		}
+		series[i] = df.SeriesSchema{Name: c.Name, Format: format}
+	}
+	return &userSchema{schema: df.NewSchema(series), cols: cols}, nil
+}
+
+// convertRow converts values of row to schema formats, value returns raw value of column (nil if column is missing)
+func (t *userSchema) convertRow(rowNumber int, value func(i int, name string) any) (row []df.Value, err error) {
+	row = make([]df.Value, t.schema.Len())
+	for i, c := range t.schema.Series() {
+		row[i], err = t.convert(c.Format, t.cols[i], value(i, c.Name))
+		if err != nil {
+			return row, fmt.Errorf("schema : row %d, column %s - %w", rowNumber, c.Name, err)
+		}
+	}
+	return row, nil
+}
+
+func (t *userSchema) convert(format df.Format, col schemaColumn, v any) (val df.Value, err error) {
+	// empty strings are nulls for non string columns
+	if s, ok := v.(string); ok && s == "" && format != df.StringFormat {
+		v = nil
+	}
+	if v == nil {
+		if col.Nullable != nil && !*col.Nullable {
+			return val, errors.New("null value for non nullable column")
+		}
+		return inmemory.NewValue(format, nil), nil
+	}
+
+	if s, ok := v.(string); ok && format == df.DateTimeFormat {
+		layout := col.Layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		v, err = time.Parse(layout, s)
+		if err != nil {
+			return val, errors.New("unable to convert '" + s + "' to datetime using layout " + layout)
+		}
+	}
+
+	converted, err := format.Convert(v)
+	if err != nil {
+		return val, fmt.Errorf("unable to convert '%v' to %s", v, format.Name())
+	}
+	return inmemory.NewValue(format, converted), nil
+}
+
+// userSchemaReader readers which convert raw values using user provided schema instead of inferring formats
+type userSchemaReader interface {
+	hasUserSchema() bool
+}
+
+// schemaStreamReader reads rows from underlying reader and converts them to user provided schema,
+// used for formats whose values are already typed (ex - parquet)
+type schemaStreamReader struct {
+	reader    FormatStreamReader
+	schema    *userSchema
+	indexes   []int
+	rowNumber int
+}
+
+func (t *schemaStreamReader) Schema() df.DataFrameSchema {
+	return t.schema.schema
+}
+
+func (t *schemaStreamReader) Next() (r df.Row, err error) {
+	source, err := t.reader.Next()
+	if err == io.EOF {
+		return r, err
+	}
+	t.rowNumber = t.rowNumber + 1
+	if err != nil {
+		return r, err
+	}
+
+	row, err := t.schema.convertRow(t.rowNumber, func(i int, name string) any {
+		if t.indexes[i] >= 0 && t.indexes[i] < source.Len() && source.Get(t.indexes[i]) != nil {
+			return source.GetRaw(t.indexes[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return r, t.malformed(source, err)
+	}
+	return inmemory.NewRow(&t.schema.schema, &row), nil
+}
+
+// malformed returns error with source row as json record
+func (t *schemaStreamReader) malformed(source df.Row, err error) error {
+	record := make(map[string]any, source.Len())
+	for i, c := range t.reader.Schema().Series() {
+		if i < source.Len() && source.Get(i) != nil {
+			record[c.Name] = source.GetRaw(i)
+		}
+	}
+	return malformedMapRecord(record, err)
+}
+
+func (t *schemaStreamReader) Close() error {
+	return t.reader.Close()
+}
+
+// withSchema wraps reader with schema from ConfigSchema file, reader is returned as it is if schema is not configured
+// or reader has already applied the schema
+func withSchema(reader FormatStreamReader, args map[string]string) (FormatStreamReader, error) {
+	if r, ok := reader.(userSchemaReader); ok && r.hasUserSchema() {
+		return reader, nil
+	}
+	schema, err := readUserSchema(args)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	if schema == nil {
+		return reader, nil
+	}
+
+	sourceSchema := reader.Schema()
+	indexes := make([]int, schema.schema.Len())
+	for i, c := range schema.cols {
+		indexes[i] = sourceSchema.GetIndexByName(c.Name)
+		if indexes[i] < 0 {
+			log.Debugf("schema : column %s not found in source", c.Name)
+		}
+	}
+
+	return &schemaStreamReader{reader: reader, schema: schema, indexes: indexes}, nil
+}
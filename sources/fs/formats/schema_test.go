@@ -0,0 +1,148 @@
+package formats
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSchemaFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestSchemaFileJSON(t *testing.T) {
+	schemaPath := writeSchemaFile(t, "schema.json", `[
+		{"name": "b", "format": "integer"},
+		{"name": "a", "format": "double"},
+		{"name": "e", "format": "string"},
+		{"name": "d", "format": "datetime", "layout": "2006-01-02"}
+	]`)
+
+	source := JsonDataSource{}
+	jsonString := `[{"a":1, "b":2, "c":"c1", "d":"2022-01-02"},{"a":3, "b":4, "c":"c2", "d":""}]`
+	reader, err := source.Reader(strings.NewReader(jsonString), map[string]string{
+		ConfigJSONSingleLine: "false",
+		ConfigSchema:         schemaPath,
+	})
+	assert.NoError(t, err)
+
+	schema := reader.Schema()
+	assert.Equal(t, []string{"b", "a", "e", "d"}, schema.Names())
+	assert.Equal(t, df.IntegerFormat, schema.Get(0).Format)
+	assert.Equal(t, df.DateTimeFormat, schema.Get(3).Format)
+
+	data := *reader.Data()
+	assert.Equal(t, 2, len(data))
+	assert.Equal(t, int64(2), data[0].GetRaw(0))
+	assert.Equal(t, 1.0, data[0].GetRaw(1))
+	assert.True(t, data[0].IsNil(2))
+	assert.Equal(t, time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC), data[0].GetRaw(3))
+	assert.True(t, data[1].IsNil(3))
+}
+
+func TestSchemaFileYAML(t *testing.T) {
+	schemaPath := writeSchemaFile(t, "schema.yaml", `
+columns:
+  - name: c1
+    format: integer
+    nullable: false
+  - name: c2
+    format: boolean
+`)
+
+	source := CsvDataSource{}
+	reader, err := source.StreamReader(strings.NewReader("c1,c2\n1,true\n2,\nx,false\n,true\n"), map[string]string{
+		ConfigSchema: schemaPath,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, df.BoolFormat, reader.Schema().Get(1).Format)
+
+	r, err := reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), r.GetRaw(0))
+	assert.Equal(t, true, r.GetRaw(1))
+
+	r, err = reader.Next()
+	assert.NoError(t, err)
+	assert.True(t, r.IsNil(1))
+
+	_, err = reader.Next()
+	assert.ErrorContains(t, err, "row 3, column c1")
+
+	_, err = reader.Next()
+	assert.ErrorContains(t, err, "row 4, column c1 - null value")
+}
+
+func TestSchemaFileInvalid(t *testing.T) {
+	source := CsvDataSource{}
+
+	_, err := source.Reader(strings.NewReader("c1,c2\n1,2\n"), map[string]string{
+		ConfigSchema: writeSchemaFile(t, "schema.json", `[{"name": "c1", "format": "decimal"}]`),
+	})
+	assert.ErrorContains(t, err, "unsupported format")
+
+	_, err = source.Reader(strings.NewReader("c1,c2\n1,2\n"), map[string]string{
+		ConfigSchema: writeSchemaFile(t, "schema.json", `{"columns": []}`),
+	})
+	assert.ErrorContains(t, err, "no columns found")
+
+	_, err = source.Reader(strings.NewReader("c1,c2\n1,2\n"), map[string]string{
+		ConfigSchema: filepath.Join(t.TempDir(), "missing.json"),
+	})
+	assert.Error(t, err)
+}
+
+func TestSchemaFileReplacesInference(t *testing.T) {
+	schemaPath := writeSchemaFile(t, "schema.json", `[
+		{"name": "a", "format": "string"},
+		{"name": "b", "format": "integer"}
+	]`)
+
+	// b is missing from sampled records and a is numeric in sampled records
+	source := JsonDataSource{}
+	jsonString := `[{"a":1},{"a":"x","b":2}]`
+	reader, err := source.Reader(strings.NewReader(jsonString), map[string]string{
+		ConfigJSONSingleLine:   "false",
+		ConfigSchemaSampleSize: "1",
+		ConfigSchema:           schemaPath,
+	})
+	assert.NoError(t, err)
+
+	data := *reader.Data()
+	assert.Equal(t, 2, len(data))
+	assert.Equal(t, "1", data[0].GetRaw(0))
+	assert.True(t, data[0].IsNil(1))
+	assert.Equal(t, "x", data[1].GetRaw(0))
+	assert.Equal(t, int64(2), data[1].GetRaw(1))
+
+	// csv values are converted from raw strings even when inference is enabled
+	csvSource := CsvDataSource{}
+	reader, err = csvSource.Reader(strings.NewReader("a,b\n01,2\n"), map[string]string{
+		ConfigCsvInferSchema: "true",
+		ConfigSchema:         schemaPath,
+	})
+	assert.NoError(t, err)
+	data = *reader.Data()
+	assert.Equal(t, "01", data[0].GetRaw(0))
+	assert.Equal(t, int64(2), data[0].GetRaw(1))
+}
+
+func TestSchemaFileDatetimeOfNumbers(t *testing.T) {
+	schemaPath := writeSchemaFile(t, "schema.json", `[{"name": "a", "format": "datetime"}]`)
+
+	source := JsonDataSource{}
+	reader, err := source.StreamReader(strings.NewReader(`{"a":1}`), map[string]string{
+		ConfigSchema: schemaPath,
+	})
+	assert.NoError(t, err)
+
+	_, err = reader.Next()
+	assert.ErrorContains(t, err, "row 1, column a - unable to convert '1' to datetime")
+}
@@ -1,54 +1,399 @@
 package formats
 
 import (
+	"bufio"
+	"errors"
+	"html"
 	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
 	"github.com/olekukonko/tablewriter"
 )
 
-var tableConfig = map[string]string{}
+// ConfigTableMaxWidth Max number of characters of each value, longer values are truncated with ..., <= 0 disables truncation
+const ConfigTableMaxWidth = "table.maxWidth"
 
+// ConfigTableNilValue Text used to render null values, while reading cells with this text are read as null
+const ConfigTableNilValue = "table.nilValue"
+
+// tableStyle styles supported by table writer
+const (
+	tableStylePlain    = ""
+	tableStyleBox      = "box"
+	tableStyleMarkdown = "markdown"
+	tableStyleHTML     = "html"
+)
+
+var tableConfig = map[string]string{
+	ConfigTableMaxWidth: "0",
+	ConfigTableNilValue: "<nil>",
+}
+
+// tableDatetimeFormats layouts used by engine and go while rendering datetime values
+var tableDatetimeFormats = []string{"2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999 -0700 MST"}
+
+// TableDataSource renders data for humans, style decides table (borderless), box, markdown or html rendering
 type TableDataSource struct {
+	style string
 }
 
 func (t *TableDataSource) Args() map[string]string {
-	return textConfig
+	return tableConfig
 }
 
 func (t *TableDataSource) Name() string {
-	return "table"
+	if t.style == tableStylePlain {
+		return "table"
+	}
+	return t.style
 }
 
 func (t *TableDataSource) Reader(reader io.Reader, args map[string]string) (FormatReader, error) {
-	panic("not supported")
+	streamReader, err := t.StreamReader(reader, args)
+	if err != nil {
+		return nil, err
+	}
+	return ReadAll(streamReader)
+}
+
+// StreamReader parses table, box and markdown tables, column types are derived from all the values
+func (t *TableDataSource) StreamReader(reader io.Reader, args map[string]string) (FormatStreamReader, error) {
+	if t.style == tableStyleHTML {
+		return nil, errors.New("html : reader is not supported")
+	}
+	tableReader, err := newTableDataSourceReader(reader, args)
+	if err != nil {
+		return nil, err
+	}
+	return newFormatStreamReader(tableReader, args)
 }
 
 func (t *TableDataSource) Writer(data df.DataFrame, args map[string]string) (w FormatWriter, err error) {
-	return &tableDataSourceWriter{data: data, args: args}, err
+	return &tableDataSourceWriter{data: data, args: args, style: t.style}, err
 }
 
 type tableDataSourceWriter struct {
-	data df.DataFrame
-	args map[string]string
+	data  df.DataFrame
+	args  map[string]string
+	style string
 }
 
 func (t *tableDataSourceWriter) Write(writer io.Writer) (err error) {
-	tw := tablewriter.NewWriter(writer)
-	tw.SetHeader(t.data.Schema().Names())
-	tw.SetBorder(false)
-	tw.SetAutoFormatHeaders(false)
+	maxWidth := 0
+	if s, ok := t.args[ConfigTableMaxWidth]; ok && s != "" {
+		maxWidth, err = strconv.Atoi(s)
+		if err != nil {
+			return errors.New("invalid " + ConfigTableMaxWidth + " - " + s)
+		}
+	}
+	nilValue, ok := t.args[ConfigTableNilValue]
+	if !ok {
+		nilValue = tableConfig[ConfigTableNilValue]
+	}
+
+	rows := make([][]string, 0, t.data.Len())
 	t.data.ForEachRow(func(r df.Row) {
 		sa := make([]string, r.Len())
 		for i := 0; i < r.Len(); i++ {
 			if r.IsNil(i) {
-				sa[i] = "<nil>"
+				sa[i] = nilValue
 			} else {
-				sa[i] = r.GetAsString(i)
+				sa[i] = tableTruncate(r.GetAsString(i), maxWidth)
 			}
 		}
-		tw.Append(sa)
+		rows = append(rows, sa)
 	})
+
+	switch t.style {
+	case tableStyleMarkdown:
+		return t.writeMarkdown(writer, rows)
+	case tableStyleHTML:
+		return t.writeHTML(writer, rows)
+	}
+
+	// | and new lines are escaped so that output can be read back
+	for _, r := range rows {
+		for i := range r {
+			r[i] = tableEscape(r[i])
+		}
+	}
+	schema := t.data.Schema()
+	tw := tablewriter.NewWriter(writer)
+	tw.SetHeader(schema.Names())
+	tw.SetBorder(t.style == tableStyleBox)
+	tw.SetAutoFormatHeaders(false)
+	// wrapped values cant be read back, maxWidth can be used to limit width
+	tw.SetAutoWrapText(false)
+	alignments := make([]int, schema.Len())
+	for i, c := range schema.Series() {
+		switch tableAlignment(c.Format) {
+		case "right":
+			alignments[i] = tablewriter.ALIGN_RIGHT
+		case "center":
+			alignments[i] = tablewriter.ALIGN_CENTER
+		default:
+			alignments[i] = tablewriter.ALIGN_LEFT
+		}
+	}
+	tw.SetColumnAlignment(alignments)
+	tw.AppendBulk(rows)
 	tw.Render()
 	return
 }
+
+func (t *tableDataSourceWriter) writeMarkdown(writer io.Writer, rows [][]string) (err error) {
+	schema := t.data.Schema()
+	names := make([]string, schema.Len())
+	widths := make([]int, schema.Len())
+	for i, name := range schema.Names() {
+		names[i] = tableEscape(name)
+		widths[i] = utf8.RuneCountInString(names[i])
+		if widths[i] < 3 {
+			widths[i] = 3
+		}
+	}
+	for _, r := range rows {
+		for i := range r {
+			r[i] = tableEscape(r[i])
+			if w := utf8.RuneCountInString(r[i]); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+
+	bufWriter := bufio.NewWriter(writer)
+	writeLine := func(cells []string) {
+		bufWriter.WriteString("|")
+		for i, c := range cells {
+			padding := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(c))
+			if tableAlignment(schema.Get(i).Format) == "right" {
+				bufWriter.WriteString(" " + padding + c + " |")
+			} else {
+				bufWriter.WriteString(" " + c + padding + " |")
+			}
+		}
+		bufWriter.WriteString("\n")
+	}
+
+	writeLine(names)
+	separators := make([]string, schema.Len())
+	for i, c := range schema.Series() {
+		switch tableAlignment(c.Format) {
+		case "right":
+			separators[i] = strings.Repeat("-", widths[i]-1) + ":"
+		case "center":
+			separators[i] = ":" + strings.Repeat("-", widths[i]-2) + ":"
+		default:
+			separators[i] = ":" + strings.Repeat("-", widths[i]-1)
+		}
+	}
+	writeLine(separators)
+	for _, r := range rows {
+		writeLine(r)
+	}
+	return bufWriter.Flush()
+}
+
+func (t *tableDataSourceWriter) writeHTML(writer io.Writer, rows [][]string) (err error) {
+	schema := t.data.Schema()
+	bufWriter := bufio.NewWriter(writer)
+	bufWriter.WriteString("<table>\n  <thead>\n    <tr>")
+	for _, name := range schema.Names() {
+		bufWriter.WriteString("<th>" + html.EscapeString(name) + "</th>")
+	}
+	bufWriter.WriteString("</tr>\n  </thead>\n  <tbody>\n")
+	for _, r := range rows {
+		bufWriter.WriteString("    <tr>")
+		for i, c := range r {
+			bufWriter.WriteString(`<td style="text-align: ` + tableAlignment(schema.Get(i).Format) + `">` + html.EscapeString(c) + "</td>")
+		}
+		bufWriter.WriteString("</tr>\n")
+	}
+	bufWriter.WriteString("  </tbody>\n</table>\n")
+	return bufWriter.Flush()
+}
+
+// tableAlignment numbers are aligned right, booleans center and rest left
+func tableAlignment(format df.Format) string {
+	switch format {
+	case df.IntegerFormat, df.DoubleFormat:
+		return "right"
+	case df.BoolFormat:
+		return "center"
+	}
+	return "left"
+}
+
+func tableTruncate(s string, maxWidth int) string {
+	if maxWidth <= 0 || utf8.RuneCountInString(s) <= maxWidth {
+		return s
+	}
+	if maxWidth <= 3 {
+		return string([]rune(s)[:maxWidth])
+	}
+	return string([]rune(s)[:maxWidth-3]) + "..."
+}
+
+// tableEscape escapes | and writes new lines as <br> so that each row is rendered in single line
+func tableEscape(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	s = strings.ReplaceAll(s, "\r\n", "<br>")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// tableUnescape converts <br> back to new lines, | are unescaped while splitting cells
+func tableUnescape(s string) string {
+	return strings.ReplaceAll(s, "<br>", "\n")
+}
+
+// tableIsSeparator line between header and rows (----, +--+, |:--|)
+func tableIsSeparator(line string) bool {
+	return strings.Contains(line, "-") && strings.Trim(line, "-+|: ") == ""
+}
+
+// tableIsBorder top/bottom border and separator of box tables (+--+)
+func tableIsBorder(line string) bool {
+	return len(line) > 1 && strings.HasPrefix(line, "+") && strings.HasSuffix(line, "+") && strings.Trim(line, "-+") == ""
+}
+
+// tableDataSourceReader reads rendered tables, first line is header and cells are separated by |.
+// separator is detected by its position (line after header) and borders of box tables (+--+) are skipped,
+// so that rows having only dashes are read as values
+type tableDataSourceReader struct {
+	schema  df.DataFrameSchema
+	layouts []string
+	rows    [][]*string
+}
+
+func newTableDataSourceReader(reader io.Reader, args map[string]string) (t *tableDataSourceReader, err error) {
+	nilValue, ok := args[ConfigTableNilValue]
+	if !ok {
+		nilValue = tableConfig[ConfigTableNilValue]
+	}
+
+	t = &tableDataSourceReader{}
+	var header []string
+	boxed := false
+	separator := false
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if boxed && tableIsBorder(line) {
+			continue
+		}
+		if header == nil {
+			// top border of box table
+			if tableIsBorder(line) {
+				boxed = true
+				continue
+			}
+			header = tableSplit(line)
+			for i := range header {
+				header[i] = tableUnescape(header[i])
+			}
+			continue
+		}
+		if !separator {
+			separator = true
+			if tableIsSeparator(line) {
+				continue
+			}
+		}
+		cells := tableSplit(line)
+		if len(cells) != len(header) {
+			return t, errors.New("table : expected " + strconv.Itoa(len(header)) + " cells, found " + strconv.Itoa(len(cells)) + " - " + line)
+		}
+		row := make([]*string, len(cells))
+		for i := range cells {
+			if cells[i] != nilValue {
+				cells[i] = tableUnescape(cells[i])
+				row[i] = &cells[i]
+			}
+		}
+		t.rows = append(t.rows, row)
+	}
+	if err = scanner.Err(); err != nil {
+		return t, err
+	}
+	if header == nil {
+		return t, errors.New("table : header not found")
+	}
+
+	inferer := newSchemaInferer(map[string]string{ConfigSchemaDatetimeFormats: strings.Join(append(datetimeFormats(args), tableDatetimeFormats...), ",")})
+	for _, row := range t.rows {
+		for i, v := range row {
+			if v != nil {
+				inferer.add(header[i], *v)
+			}
+		}
+	}
+	cols := make([]df.SeriesSchema, len(header))
+	t.layouts = make([]string, len(header))
+	for i, name := range header {
+		cols[i] = df.SeriesSchema{Name: name}
+		cols[i].Format, t.layouts[i] = inferer.column(name)
+	}
+	t.schema = df.NewSchema(cols)
+	return t, nil
+}
+
+// tableSplit splits line on |, escaped \| are part of the cell
+func tableSplit(line string) (cells []string) {
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = strings.TrimSuffix(line, "|")
+	}
+	var sb strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) && line[i+1] == '|' {
+			sb.WriteByte('|')
+			i++
+		} else if line[i] == '|' {
+			cells = append(cells, strings.TrimSpace(sb.String()))
+			sb.Reset()
+		} else {
+			sb.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(sb.String()))
+}
+
+func (t *tableDataSourceReader) Schema() df.DataFrameSchema {
+	return t.schema
+}
+
+func (t *tableDataSourceReader) Next() (r df.Row, err error) {
+	if len(t.rows) == 0 {
+		return r, io.EOF
+	}
+	cells := t.rows[0]
+	t.rows[0] = nil
+	t.rows = t.rows[1:]
+
+	row := make([]df.Value, len(cells))
+	for i, v := range cells {
+		format := t.schema.Get(i).Format
+		if v == nil {
+			row[i] = inmemory.NewValue(format, nil)
+			continue
+		}
+		row[i], err = parseInferredValue(format, t.layouts[i], *v)
+		if err != nil {
+			return r, errors.New("table : column " + t.schema.Get(i).Name + ", " + err.Error())
+		}
+	}
+	return inmemory.NewRow(&t.schema, &row), nil
+}
+
+func (t *tableDataSourceReader) Close() error {
+	t.rows = nil
+	return nil
+}
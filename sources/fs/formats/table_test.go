@@ -0,0 +1,142 @@
+package formats
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableDataSource(t *testing.T) {
+	source := TableDataSource{}
+	assert.Equal(t, source.Name(), "table")
+	source = TableDataSource{style: tableStyleMarkdown}
+	assert.Equal(t, source.Name(), "markdown")
+}
+
+func tableTestDataframe() df.DataFrame {
+	dfSchema := df.NewSchema([]df.SeriesSchema{
+		{Name: "a", Format: df.IntegerFormat},
+		{Name: "b", Format: df.DoubleFormat},
+		{Name: "c", Format: df.StringFormat},
+		{Name: "d", Format: df.BoolFormat},
+		{Name: "e", Format: df.DateTimeFormat},
+	})
+	value := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	rows := []df.Row{
+		inmemory.NewRowFromAny(&dfSchema, &([]any{int64(1), 1.5, "c|1 <x>", true, value})),
+		inmemory.NewRowFromAny(&dfSchema, &([]any{int64(20), nil, "a long value", false, nil})),
+	}
+	return inmemory.NewDataframeFromRow(dfSchema, &rows)
+}
+
+func TestTableDataSourceWriter(t *testing.T) {
+	dataframe := tableTestDataframe()
+	cases := []struct {
+		style    string
+		args     map[string]string
+		expected string
+	}{
+		{tableStylePlain, map[string]string{}, "" +
+			"  a  |  b  |      c       |   d   |               e                \n" +
+			"-----+-----+--------------+-------+--------------------------------\n" +
+			"   1 | 1.5 | c\\|1 <x>     | true  | 2021-03-04 05:06:07 +0000 UTC  \n" +
+			"  20 | nil | a long value | false | nil                            \n"},
+		{tableStyleBox, map[string]string{ConfigTableMaxWidth: "6"}, "" +
+			"+----+-----+---------+-------+--------+\n" +
+			"| a  |  b  |    c    |   d   |   e    |\n" +
+			"+----+-----+---------+-------+--------+\n" +
+			"|  1 | 1.5 | c\\|1... | true  | 202... |\n" +
+			"| 20 | nil | a l...  | false | nil    |\n" +
+			"+----+-----+---------+-------+--------+\n"},
+		{tableStyleMarkdown, map[string]string{ConfigTableNilValue: ""}, "" +
+			"|   a |   b | c            | d     | e                             |\n" +
+			"| --: | --: | :----------- | :---: | :---------------------------- |\n" +
+			"|   1 | 1.5 | c\\|1 <x>     | true  | 2021-03-04 05:06:07 +0000 UTC |\n" +
+			"|  20 |     | a long value | false |                               |\n"},
+		{tableStyleHTML, map[string]string{ConfigTableMaxWidth: "4"}, "" +
+			"<table>\n  <thead>\n    <tr><th>a</th><th>b</th><th>c</th><th>d</th><th>e</th></tr>\n  </thead>\n  <tbody>\n" +
+			`    <tr><td style="text-align: right">1</td><td style="text-align: right">1.5</td><td style="text-align: left">c...</td><td style="text-align: center">true</td><td style="text-align: left">2...</td></tr>` + "\n" +
+			`    <tr><td style="text-align: right">20</td><td style="text-align: right">nil</td><td style="text-align: left">a...</td><td style="text-align: center">f...</td><td style="text-align: left">nil</td></tr>` + "\n" +
+			"  </tbody>\n</table>\n"},
+	}
+	for _, c := range cases {
+		if _, ok := c.args[ConfigTableNilValue]; !ok {
+			c.args[ConfigTableNilValue] = "nil"
+		}
+		source := TableDataSource{style: c.style}
+		writer, err := source.Writer(dataframe, c.args)
+		assert.NoError(t, err)
+		var buff bytes.Buffer
+		assert.NoError(t, writer.Write(&buff))
+		assert.Equal(t, c.expected, buff.String(), c.style)
+	}
+
+	writer, err := (&TableDataSource{}).Writer(dataframe, map[string]string{ConfigTableMaxWidth: "x"})
+	assert.NoError(t, err)
+	assert.Error(t, writer.Write(new(bytes.Buffer)))
+}
+
+func TestTableDataSourceReader(t *testing.T) {
+	dataframe := tableTestDataframe()
+	value := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	for _, style := range []string{tableStylePlain, tableStyleBox, tableStyleMarkdown} {
+		source := TableDataSource{style: style}
+		writer, err := source.Writer(dataframe, map[string]string{})
+		assert.NoError(t, err)
+		var buff bytes.Buffer
+		assert.NoError(t, writer.Write(&buff))
+
+		reader, err := source.Reader(&buff, map[string]string{})
+		assert.NoError(t, err, style)
+		assert.Equal(t, dataframe.Schema().Names(), reader.Schema().Names(), style)
+		for i, c := range reader.Schema().Series() {
+			assert.Equal(t, dataframe.Schema().Get(i).Format, c.Format, style)
+		}
+		data := *(reader.Data())
+		assert.Equal(t, 2, len(data))
+		assert.Equal(t, []any{int64(1), 1.5, "c|1 <x>", true, value}, testRowValues(data[0]), style)
+		assert.Equal(t, []any{int64(20), nil, "a long value", false, nil}, testRowValues(data[1]), style)
+	}
+
+	_, err := (&TableDataSource{style: tableStyleHTML}).Reader(strings.NewReader("<table></table>"), map[string]string{})
+	assert.Error(t, err)
+	_, err = (&TableDataSource{}).Reader(strings.NewReader(" a | b \n---+---\n 1 \n"), map[string]string{})
+	assert.Error(t, err)
+	_, err = (&TableDataSource{}).Reader(strings.NewReader(""), map[string]string{})
+	assert.Error(t, err)
+}
+
+func TestTableDataSourceReaderMultiLine(t *testing.T) {
+	dfSchema := df.NewSchema([]df.SeriesSchema{
+		{Name: "a", Format: df.StringFormat},
+		{Name: "b", Format: df.StringFormat},
+	})
+	rows := []df.Row{
+		inmemory.NewRowFromAny(&dfSchema, &([]any{"line1\nline2", "x"})),
+		inmemory.NewRowFromAny(&dfSchema, &([]any{"---", "--"})),
+		inmemory.NewRowFromAny(&dfSchema, &([]any{"+--+", "-"})),
+	}
+	dataframe := inmemory.NewDataframeFromRow(dfSchema, &rows)
+
+	for _, style := range []string{tableStylePlain, tableStyleBox, tableStyleMarkdown} {
+		source := TableDataSource{style: style}
+		writer, err := source.Writer(dataframe, map[string]string{})
+		assert.NoError(t, err)
+		var buff bytes.Buffer
+		assert.NoError(t, writer.Write(&buff))
+		assert.Contains(t, buff.String(), "line1<br>line2", style)
+
+		reader, err := source.Reader(&buff, map[string]string{})
+		assert.NoError(t, err, style)
+		data := *(reader.Data())
+		assert.Equal(t, 3, len(data), style)
+		assert.Equal(t, []any{"line1\nline2", "x"}, testRowValues(data[0]), style)
+		assert.Equal(t, []any{"---", "--"}, testRowValues(data[1]), style)
+		assert.Equal(t, []any{"+--+", "-"}, testRowValues(data[2]), style)
+	}
+}
@@ -4,12 +4,29 @@ import (
 	"bufio"
 	"errors"
 	"io"
+	"regexp"
+	"time"
 
 	"github.com/blue4209211/pq/df"
 	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/blue4209211/pq/internal/log"
 )
 
-var textConfig = map[string]string{}
+// ConfigTextPattern regex with named groups or grok expression (ex - %{IP:client} %{GREEDYDATA:message}) used to
+// split each record into columns, built-in patterns can be used by name - apache/nginx/combined, common, syslog, json
+const ConfigTextPattern = "text.pattern"
+
+// ConfigTextMultilineStart regex matching first line of record, lines which dont match are appended to previous record (ex - stack traces)
+const ConfigTextMultilineStart = "text.multilineStart"
+
+// ConfigTextInferSchema Derive types of pattern columns without explicit grok type from sampled records, Default = false (string)
+const ConfigTextInferSchema = "text.inferSchema"
+
+var textConfig = map[string]string{
+	ConfigTextPattern:        "",
+	ConfigTextMultilineStart: "",
+	ConfigTextInferSchema:    "false",
+}
 
 type TextDataSource struct {
 }
@@ -23,19 +40,49 @@ func (t *TextDataSource) Name() string {
 }
 
 func (t *TextDataSource) Reader(reader io.Reader, args map[string]string) (FormatReader, error) {
-	textReader := textDataSourceReader{args: args}
-	err := textReader.init(reader)
-	return &textReader, err
+	streamReader, err := t.StreamReader(reader, args)
+	if err != nil {
+		return nil, err
+	}
+	return ReadAll(streamReader)
+}
+
+func (t *TextDataSource) StreamReader(reader io.Reader, args map[string]string) (FormatStreamReader, error) {
+	textReader := &textDataSourceReader{args: args, reader: bufio.NewReader(reader), textData: make([]byte, 0, 10000)}
+	if start := args[ConfigTextMultilineStart]; start != "" {
+		startRegex, err := regexp.Compile(start)
+		if err != nil {
+			return nil, errors.New("text : invalid " + ConfigTextMultilineStart + " - " + err.Error())
+		}
+		textReader.start = startRegex
+	}
+
+	pattern := args[ConfigTextPattern]
+	if pattern == "" {
+		return newFormatStreamReader(textReader, args)
+	}
+	patternReader, err := newTextPatternReader(textReader, pattern, args)
+	if err != nil {
+		return nil, err
+	}
+	return newFormatStreamReader(patternReader, args)
 }
 
 func (t *TextDataSource) Writer(data df.DataFrame, args map[string]string) (w FormatWriter, err error) {
 	return w, errors.New("Unsupported")
 }
 
+// textDataSourceReader reads each line as record, when start regex is provided
+// lines which dont match it are appended to previous record
 type textDataSourceReader struct {
 	args     map[string]string
-	records  []df.Row
-	isHeader bool
+	reader   *bufio.Reader
+	textData []byte
+	cnt      int64
+	start    *regexp.Regexp
+	next     string
+	hasNext  bool
+	eof      bool
 }
 
 var textSchema df.DataFrameSchema = df.NewSchema([]df.SeriesSchema{
@@ -47,54 +94,274 @@ func (t *textDataSourceReader) Schema() df.DataFrameSchema {
 	return textSchema
 }
 
-func (t *textDataSourceReader) Data() *[]df.Row {
-	return &t.records
+func (t *textDataSourceReader) Next() (r df.Row, err error) {
+	text, rowNumber, err := t.nextRecord()
+	if err != nil {
+		return r, err
+	}
+	rowData := []df.Value{
+		inmemory.NewStringValueConst(text), inmemory.NewIntValueConst(rowNumber),
+	}
+	return inmemory.NewRow(&textSchema, &rowData), nil
 }
 
-func (t *textDataSourceReader) init(reader io.Reader) (err error) {
-	bufferedReader := bufio.NewReader(reader)
-	t.records = make([]df.Row, 0, 1000)
-	schema := t.Schema()
+// nextRecord returns text of record and line number of its first line
+func (t *textDataSourceReader) nextRecord() (text string, rowNumber int64, err error) {
+	if !t.hasNext {
+		t.next, err = t.readLine()
+		if err != nil {
+			return text, rowNumber, err
+		}
+	}
+	text = t.next
+	t.hasNext = false
+	t.cnt = t.cnt + 1
+	rowNumber = t.cnt
+	if t.start == nil {
+		return text, rowNumber, nil
+	}
+
+	for {
+		line, err := t.readLine()
+		if err == io.EOF {
+			return text, rowNumber, nil
+		}
+		if err != nil {
+			return text, rowNumber, err
+		}
+		if t.start.MatchString(line) {
+			t.next = line
+			t.hasNext = true
+			return text, rowNumber, nil
+		}
+		t.cnt = t.cnt + 1
+		text = text + "\n" + line
+	}
+}
 
+func (t *textDataSourceReader) readLine() (line string, err error) {
+	if t.eof {
+		return line, io.EOF
+	}
 	// in somecases line size gets bigger than default scanner settings
 	// so using reader to handle those scenarios
-	textData := make([]byte, 0, 10000)
-	cnt := int64(1)
-	for err == nil {
-		textArr, isPrefix, err := bufferedReader.ReadLine()
-		if isPrefix {
-			textData = append(textData, textArr...)
-			continue
-		}
+	t.textData = t.textData[:0]
+	for {
+		textArr, isPrefix, err := t.reader.ReadLine()
 		if err == io.EOF {
-			if len(textData) > 0 {
-				rowData := []df.Value{
-					inmemory.NewStringValueConst(string(textData) + string(textArr)), inmemory.NewIntValueConst(cnt),
-				}
-				t.records = append(t.records, inmemory.NewRow(&schema, &rowData))
-				textData = textData[:0]
-			} else if len(textArr) > 0 {
-				rowData := []df.Value{
-					inmemory.NewStringValueConst(string(textArr)), inmemory.NewIntValueConst(cnt),
-				}
-				t.records = append(t.records, inmemory.NewRow(&schema, &rowData))
+			t.eof = true
+			if len(t.textData) == 0 {
+				return line, err
 			}
 			break
 		}
+		if err != nil {
+			return line, err
+		}
+		t.textData = append(t.textData, textArr...)
+		if !isPrefix {
+			break
+		}
+	}
+	return string(t.textData), nil
+}
 
-		if len(textData) > 0 {
-			rowData := []df.Value{
-				inmemory.NewStringValueConst(string(textData) + string(textArr)), inmemory.NewIntValueConst(cnt),
-			}
-			t.records = append(t.records, inmemory.NewRow(&schema, &rowData))
-			textData = textData[:0]
-		} else {
-			rowData := []df.Value{
-				inmemory.NewStringValueConst(string(textArr)), inmemory.NewIntValueConst(cnt),
+func (t *textDataSourceReader) Close() error {
+	return nil
+}
+
+// textPatternColumn column derived from named group of pattern
+type textPatternColumn struct {
+	groups  []int
+	typed   bool
+	layouts []string
+}
+
+// textPatternRecord sampled record, err is set for malformed records so that it is returned when record is read
+type textPatternRecord struct {
+	text      string
+	values    []*string
+	rowNumber int64
+	err       error
+}
+
+// textPatternReader splits records of text reader into columns using named groups of pattern
+type textPatternReader struct {
+	reader  *textDataSourceReader
+	regex   *regexp.Regexp
+	schema  df.DataFrameSchema
+	columns []textPatternColumn
+	pending []textPatternRecord
+}
+
+func newTextPatternReader(reader *textDataSourceReader, pattern string, args map[string]string) (t *textPatternReader, err error) {
+	expr, fields, err := grokCompile(pattern)
+	if err != nil {
+		return t, err
+	}
+	regex, err := regexp.Compile(expr)
+	if err != nil {
+		return t, errors.New("text : invalid " + ConfigTextPattern + " - " + err.Error())
+	}
+
+	t = &textPatternReader{reader: reader, regex: regex}
+	cols := make([]df.SeriesSchema, 0)
+	colIndex := map[string]int{}
+	for i, name := range regex.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		field := grokField{name: name, format: df.StringFormat}
+		if f, ok := fields[name]; ok {
+			field = f
+		}
+		// same name can be used in alternations, first matched group is used as value
+		if index, ok := colIndex[field.name]; ok {
+			t.columns[index].groups = append(t.columns[index].groups, i)
+			continue
+		}
+		colIndex[field.name] = len(cols)
+		layouts := field.layouts
+		if field.format == df.DateTimeFormat && len(layouts) == 0 {
+			layouts = datetimeFormats(args)
+		}
+		cols = append(cols, df.SeriesSchema{Name: field.name, Format: field.format})
+		t.columns = append(t.columns, textPatternColumn{groups: []int{i}, typed: field.typed, layouts: layouts})
+	}
+	if len(cols) == 0 {
+		return t, errors.New("text : " + ConfigTextPattern + " should have atleast one named group")
+	}
+	cols = append(cols, df.SeriesSchema{Name: "rowNumber_", Format: df.IntegerFormat})
+
+	inferSchema, err := isInferSchemaEnabled(args, ConfigTextInferSchema)
+	if err != nil {
+		return t, err
+	}
+	if inferSchema {
+		err = t.inferSchema(cols, args)
+		if err != nil {
+			return t, err
+		}
+	}
+	t.schema = df.NewSchema(cols)
+	log.Debugf("text : pattern (%s), schema - %v", expr, cols)
+	return t, nil
+}
+
+// inferSchema samples records to derive formats of columns without explicit type
+func (t *textPatternReader) inferSchema(cols []df.SeriesSchema, args map[string]string) (err error) {
+	sampleSize, err := schemaSampleSize(args)
+	if err != nil {
+		return err
+	}
+	inferer := newSchemaInferer(args)
+	for sampleSize <= 0 || len(t.pending) < sampleSize {
+		r, err := t.nextRecord()
+		if err == io.EOF {
+			break
+		}
+		malformed := &malformedRecordError{}
+		if errors.As(err, &malformed) {
+			t.pending = append(t.pending, textPatternRecord{err: err})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		t.pending = append(t.pending, r)
+		for i, v := range r.values {
+			if v != nil {
+				inferer.add(cols[i].Name, *v)
 			}
-			t.records = append(t.records, inmemory.NewRow(&schema, &rowData))
 		}
-		cnt = cnt + 1
+	}
+
+	for i, c := range t.columns {
+		if !c.typed {
+			format, layout := inferer.column(cols[i].Name)
+			cols[i].Format = format
+			t.columns[i].layouts = []string{layout}
+		}
 	}
 	return nil
 }
+
+func (t *textPatternReader) nextRecord() (r textPatternRecord, err error) {
+	text, rowNumber, err := t.reader.nextRecord()
+	if err != nil {
+		return r, err
+	}
+	match := t.regex.FindStringSubmatchIndex(text)
+	if match == nil {
+		return r, &malformedRecordError{record: text, err: errors.New("text : record doesnt match pattern")}
+	}
+
+	r = textPatternRecord{text: text, values: make([]*string, len(t.columns)), rowNumber: rowNumber}
+	for i, c := range t.columns {
+		for _, g := range c.groups {
+			// groups which are not part of match are nil
+			if match[2*g] >= 0 {
+				v := text[match[2*g]:match[2*g+1]]
+				r.values[i] = &v
+				break
+			}
+		}
+	}
+	return r, nil
+}
+
+func (t *textPatternReader) Schema() df.DataFrameSchema {
+	return t.schema
+}
+
+func (t *textPatternReader) Next() (row df.Row, err error) {
+	var r textPatternRecord
+	if len(t.pending) > 0 {
+		r = t.pending[0]
+		t.pending[0] = textPatternRecord{}
+		t.pending = t.pending[1:]
+		err = r.err
+	} else {
+		r, err = t.nextRecord()
+	}
+	if err != nil {
+		return row, err
+	}
+
+	rowData := make([]df.Value, t.schema.Len())
+	for i, c := range t.columns {
+		format := t.schema.Get(i).Format
+		if r.values[i] == nil {
+			rowData[i] = inmemory.NewValue(format, nil)
+			continue
+		}
+		rowData[i], err = textParseValue(format, c.layouts, *r.values[i])
+		if err != nil {
+			return row, &malformedRecordError{record: r.text, err: errors.New("text : column " + t.schema.Get(i).Name + ", " + err.Error())}
+		}
+	}
+	rowData[len(t.columns)] = inmemory.NewIntValueConst(r.rowNumber)
+	return inmemory.NewRow(&t.schema, &rowData), nil
+}
+
+func (t *textPatternReader) Close() error {
+	t.pending = nil
+	return t.reader.Close()
+}
+
+// textParseValue converts value to format, datetime values are parsed using first matching layout and converted to UTC
+func textParseValue(format df.Format, layouts []string, v string) (val df.Value, err error) {
+	if format != df.DateTimeFormat || v == "" {
+		return parseInferredValue(format, "", v)
+	}
+	for _, layout := range layouts {
+		if layout == "" {
+			continue
+		}
+		datetime, err := time.Parse(layout, v)
+		if err == nil {
+			return inmemory.NewValue(format, datetime.UTC()), nil
+		}
+	}
+	return val, errors.New("unable to convert '" + v + "' to " + format.Name())
+}
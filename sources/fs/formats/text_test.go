@@ -3,7 +3,9 @@ package formats
 import (
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/blue4209211/pq/df"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -59,3 +61,92 @@ func BenchmarkTextParsing(b *testing.B) {
 	})
 
 }
+
+func TestTextDataSourceMultiline(t *testing.T) {
+	source := TextDataSource{}
+	textString := "continued\n2021-01-01 error\n  at a\n  at b\n2021-01-02 info\n"
+
+	textReader, err := source.Reader(strings.NewReader(textString), map[string]string{ConfigTextMultilineStart: `^\d{4}-`})
+	assert.NoError(t, err)
+	data := *(textReader.Data())
+	assert.Equal(t, 3, len(data))
+	assert.Equal(t, []any{"continued", int64(1)}, testRowValues(data[0]))
+	assert.Equal(t, []any{"2021-01-01 error\n  at a\n  at b", int64(2)}, testRowValues(data[1]))
+	assert.Equal(t, []any{"2021-01-02 info", int64(5)}, testRowValues(data[2]))
+
+	_, err = source.Reader(strings.NewReader(textString), map[string]string{ConfigTextMultilineStart: `(`})
+	assert.Error(t, err)
+}
+
+func TestTextDataSourcePattern(t *testing.T) {
+	source := TextDataSource{}
+	textString := `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"
+::1 - - [10/Oct/2000:13:55:37 +0000] "-" 400 - "-" "-"
+not a log line`
+
+	textReader, err := source.Reader(strings.NewReader(textString), map[string]string{ConfigTextPattern: "nginx", ConfigMode: ModeDropMalformed})
+	assert.NoError(t, err)
+	assert.Equal(t, df.NewSchema([]df.SeriesSchema{
+		{Name: "clientip", Format: df.StringFormat},
+		{Name: "ident", Format: df.StringFormat},
+		{Name: "auth", Format: df.StringFormat},
+		{Name: "timestamp", Format: df.DateTimeFormat},
+		{Name: "verb", Format: df.StringFormat},
+		{Name: "request", Format: df.StringFormat},
+		{Name: "httpversion", Format: df.StringFormat},
+		{Name: "rawrequest", Format: df.StringFormat},
+		{Name: "response", Format: df.IntegerFormat},
+		{Name: "bytes", Format: df.IntegerFormat},
+		{Name: "referrer", Format: df.StringFormat},
+		{Name: "agent", Format: df.StringFormat},
+		{Name: "rowNumber_", Format: df.IntegerFormat},
+	}), textReader.Schema())
+	data := *(textReader.Data())
+	assert.Equal(t, 2, len(data))
+	assert.Equal(t, []any{"127.0.0.1", "-", "frank", time.Date(2000, 10, 10, 20, 55, 36, 0, time.UTC), "GET", "/apache_pb.gif", "1.0", nil, int64(200), int64(2326), "http://www.example.com/start.html", "Mozilla/4.08", int64(1)},
+		testRowValues(data[0]))
+	assert.Equal(t, []any{"::1", "-", "-", time.Date(2000, 10, 10, 13, 55, 37, 0, time.UTC), nil, nil, nil, "-", int64(400), nil, "-", "-", int64(2)}, testRowValues(data[1]))
+
+	_, err = source.Reader(strings.NewReader(textString), map[string]string{ConfigTextPattern: "nginx"})
+	assert.Error(t, err)
+}
+
+func TestTextDataSourcePatternTypes(t *testing.T) {
+	source := TextDataSource{}
+	textString := `<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed
+Oct 11 22:14:16 mymachine cron: job done`
+	textReader, err := source.Reader(strings.NewReader(textString), map[string]string{ConfigTextPattern: "syslog"})
+	assert.NoError(t, err)
+	data := *(textReader.Data())
+	assert.Equal(t, []any{int64(34), "Oct 11 22:14:15", "mymachine", "su", int64(230), "'su root' failed", int64(1)}, testRowValues(data[0]))
+	assert.Equal(t, []any{nil, "Oct 11 22:14:16", "mymachine", "cron", nil, "job done", int64(2)}, testRowValues(data[1]))
+
+	textString = "2021-03-04 05:06:07 INFO {\"a\":1}\nplain {}"
+	textReader, err = source.Reader(strings.NewReader(textString), map[string]string{ConfigTextPattern: "json"})
+	assert.NoError(t, err)
+	data = *(textReader.Data())
+	assert.Equal(t, []any{"2021-03-04 05:06:07 INFO", `{"a":1}`, int64(1)}, testRowValues(data[0]))
+	assert.Equal(t, []any{"plain", `{}`, int64(2)}, testRowValues(data[1]))
+
+	// plain regex with inferred types and grok with explicit types
+	textString = "a=1 b=1.5 c=2021-03-04\na=2 b=x c="
+	textReader, err = source.Reader(strings.NewReader(textString), map[string]string{
+		ConfigTextPattern:     `a=(?P<a>\d+) b=(?P<b>\S+) c=%{GREEDYDATA:c:datetime}`,
+		ConfigTextInferSchema: "true",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, df.NewSchema([]df.SeriesSchema{
+		{Name: "a", Format: df.IntegerFormat},
+		{Name: "b", Format: df.StringFormat},
+		{Name: "c", Format: df.DateTimeFormat},
+		{Name: "rowNumber_", Format: df.IntegerFormat},
+	}), textReader.Schema())
+	data = *(textReader.Data())
+	assert.Equal(t, []any{int64(1), "1.5", time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC), int64(1)}, testRowValues(data[0]))
+	assert.Equal(t, []any{int64(2), "x", nil, int64(2)}, testRowValues(data[1]))
+
+	for _, pattern := range []string{"%{UNKNOWN:a}", "%{INT:a:short}", "(?P<a>", "no groups"} {
+		_, err = source.Reader(strings.NewReader(textString), map[string]string{ConfigTextPattern: pattern})
+		assert.Error(t, err, pattern)
+	}
+}
@@ -0,0 +1,287 @@
+package formats
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/blue4209211/pq/df"
+)
+
+// ConfigTOMLRootNode root node (dot separated path) of array of tables to read as rows, when empty and document
+// has single top level array of tables then it is used as rows. While writing rows are written as array of tables
+// at this path (default rows)
+const ConfigTOMLRootNode = "toml.rootNode"
+
+// tomlDefaultRootNode key used to write rows when root node is not provided, toml documents need top level table
+const tomlDefaultRootNode = "rows"
+
+var tomlConfig = map[string]string{
+	ConfigTOMLRootNode: "",
+}
+
+type TomlDataSource struct {
+}
+
+func (t *TomlDataSource) Args() map[string]string {
+	return tomlConfig
+}
+
+func (t *TomlDataSource) Name() string {
+	return "toml"
+}
+
+func (t *TomlDataSource) Writer(data df.DataFrame, args map[string]string) (FormatWriter, error) {
+	return &tomlDataSourceWriter{data: data, args: args}, nil
+}
+
+func (t *TomlDataSource) Reader(reader io.Reader, args map[string]string) (FormatReader, error) {
+	streamReader, err := t.StreamReader(reader, args)
+	if err != nil {
+		return nil, err
+	}
+	return ReadAll(streamReader)
+}
+
+// StreamReader parses whole document, as tables can be defined in any order
+func (t *TomlDataSource) StreamReader(reader io.Reader, args map[string]string) (FormatStreamReader, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := tomlParse(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	rootNode := args[ConfigTOMLRootNode]
+	if rootNode == "" && len(doc) == 1 {
+		for k, v := range doc {
+			if tables, ok := v.([]any); ok && tomlIsTableArray(tables) {
+				rootNode = k
+			}
+		}
+	}
+
+	decoded := false
+	recordReader := &documentRecordReader{
+		format:   "toml",
+		rootNode: rootNode,
+		decode: func() (any, error) {
+			if decoded {
+				return nil, io.EOF
+			}
+			decoded = true
+			return doc, nil
+		},
+	}
+	mapReader, err := newMapStreamReader("toml", args, false, recordReader.next)
+	if err != nil {
+		return nil, err
+	}
+	return newFormatStreamReader(mapReader, args)
+}
+
+func tomlIsTableArray(items []any) bool {
+	for _, item := range items {
+		if _, ok := item.(map[string]any); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+type tomlDataSourceWriter struct {
+	data df.DataFrame
+	args map[string]string
+}
+
+func (t *tomlDataSourceWriter) Write(writer io.Writer) (err error) {
+	rootNode := t.args[ConfigTOMLRootNode]
+	if rootNode == "" {
+		rootNode = tomlDefaultRootNode
+	}
+	keys := strings.Split(rootNode, ".")
+	for i, k := range keys {
+		keys[i] = tomlKey(k)
+	}
+	header := "[[" + strings.Join(keys, ".") + "]]\n"
+
+	schema := t.data.Schema()
+	bufWriter := bufio.NewWriter(writer)
+	if t.data.Len() == 0 {
+		// empty array keeps root node, so that document can be read back
+		bufWriter.WriteString(strings.Join(keys, ".") + " = []\n")
+	}
+	for index := int64(0); index < t.data.Len(); index++ {
+		row := t.data.GetRow(index)
+		if index > 0 {
+			bufWriter.WriteString("\n")
+		}
+		bufWriter.WriteString(header)
+		for i, c := range schema.Series() {
+			value := row.Get(i).Get()
+			if value == nil {
+				// toml has no null values, missing keys are read as null
+				continue
+			}
+			str, err := tomlValue(documentWriterValue(c.Format, value))
+			if err != nil {
+				return errors.New("toml : column " + c.Name + ", " + err.Error())
+			}
+			bufWriter.WriteString(tomlKey(c.Name) + " = " + str + "\n")
+		}
+	}
+	return bufWriter.Flush()
+}
+
+// tomlKey returns bare key when possible otherwise quoted key
+func tomlKey(key string) string {
+	if key == "" {
+		return `""`
+	}
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return tomlString(key)
+		}
+	}
+	return key
+}
+
+func tomlString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, c := range s {
+		switch c {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				sb.WriteString(fmt.Sprintf(`\u%04X`, c))
+			} else {
+				sb.WriteRune(c)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func tomlValue(v any) (string, error) {
+	switch d := v.(type) {
+	case string:
+		return tomlString(d), nil
+	case bool:
+		return strconv.FormatBool(d), nil
+	case int64:
+		return strconv.FormatInt(d, 10), nil
+	case float64:
+		if math.IsInf(d, 1) {
+			return "inf", nil
+		} else if math.IsInf(d, -1) {
+			return "-inf", nil
+		} else if math.IsNaN(d) {
+			return "nan", nil
+		}
+		s := strconv.FormatFloat(d, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eEn") {
+			s = s + ".0"
+		}
+		return s, nil
+	case time.Time:
+		return d.UTC().Format(time.RFC3339Nano), nil
+	case []any:
+		items := make([]string, len(d))
+		for i, item := range d {
+			if item == nil {
+				return "", errors.New("null values in arrays are not supported")
+			}
+			s, err := tomlValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case map[string]any:
+		keys := make([]string, 0, len(d))
+		for k, item := range d {
+			if item != nil {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		items := make([]string, len(keys))
+		for i, k := range keys {
+			s, err := tomlValue(d[k])
+			if err != nil {
+				return "", err
+			}
+			items[i] = tomlKey(k) + " = " + s
+		}
+		if len(items) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(items, ", ") + " }", nil
+	default:
+		return tomlString(fmt.Sprint(d)), nil
+	}
+}
+
+// tomlParse parses toml (v1.0) document into maps, arrays of tables and arrays are stored as []any,
+// offset date-times, local date-times and local dates are parsed as time (UTC), local times as string
+func tomlParse(data string) (doc map[string]any, err error) {
+	_, err = toml.Decode(strings.TrimPrefix(data, "\uFEFF"), &doc)
+	if err != nil {
+		return doc, errors.New("toml : " + err.Error())
+	}
+	return tomlNormalize(doc).(map[string]any), nil
+}
+
+// tomlNormalize converts decoded values to the types used by map reader, local date-times are decoded by
+// library in local timezone, their wall clock is kept as UTC
+func tomlNormalize(v any) any {
+	switch d := v.(type) {
+	case map[string]any:
+		for k, item := range d {
+			d[k] = tomlNormalize(item)
+		}
+		return d
+	case []map[string]any:
+		items := make([]any, len(d))
+		for i, item := range d {
+			items[i] = tomlNormalize(item)
+		}
+		return items
+	case []any:
+		for i, item := range d {
+			d[i] = tomlNormalize(item)
+		}
+		return d
+	case time.Time:
+		switch d.Location().String() {
+		case "time-local":
+			return d.Format("15:04:05.999999999")
+		case "datetime-local", "date-local":
+			return time.Date(d.Year(), d.Month(), d.Day(), d.Hour(), d.Minute(), d.Second(), d.Nanosecond(), time.UTC)
+		}
+		return d.UTC()
+	default:
+		return d
+	}
+}
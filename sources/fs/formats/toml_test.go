@@ -0,0 +1,183 @@
+package formats
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTomlDataSource(t *testing.T) {
+	source := TomlDataSource{}
+	assert.Equal(t, source.Name(), "toml")
+}
+
+func TestTomlParse(t *testing.T) {
+	doc, err := tomlParse(`# comment
+title = "TOML \"Example\"\u00e9" # trailing comment
+path = 'C:\Users'
+"quoted key" = 1_000
+hex = 0xDEAD_beef
+oct = 0o755
+bin = 0b1101
+float = -6.626e-34
+infinity = -inf
+odt = 1979-05-27T07:32:00.5-07:00
+ldt = 1979-05-27 07:32:00
+ld = 1979-05-27
+lt = 07:32:00
+multi = """
+Roses \
+    are red
+Violets ""are"" blue"""
+lines = '''
+raw\n text'''
+site."google.com" = true
+arr = [
+  1, # one
+  "two",
+  [3],
+]
+inline = { x = 1, y.z = "w" }
+
+[server.alpha]
+ip = "10.0.0.1"
+
+[[products]]
+name = "Hammer"
+
+[[products]]
+
+[[products]]
+name = "Nail"
+[products.dim]
+w = 1
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, `TOML "Example"é`, doc["title"])
+	assert.Equal(t, `C:\Users`, doc["path"])
+	assert.Equal(t, int64(1000), doc["quoted key"])
+	assert.Equal(t, int64(0xdeadbeef), doc["hex"])
+	assert.Equal(t, int64(0755), doc["oct"])
+	assert.Equal(t, int64(13), doc["bin"])
+	assert.Equal(t, -6.626e-34, doc["float"])
+	assert.Equal(t, math.Inf(-1), doc["infinity"])
+	assert.Equal(t, time.Date(1979, 5, 27, 14, 32, 0, 500000000, time.UTC), doc["odt"])
+	assert.Equal(t, time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC), doc["ldt"])
+	assert.Equal(t, time.Date(1979, 5, 27, 0, 0, 0, 0, time.UTC), doc["ld"])
+	assert.Equal(t, "07:32:00", doc["lt"])
+	assert.Equal(t, "Roses are red\nViolets \"\"are\"\" blue", doc["multi"])
+	assert.Equal(t, `raw\n text`, doc["lines"])
+	assert.Equal(t, map[string]any{"google.com": true}, doc["site"])
+	assert.Equal(t, []any{int64(1), "two", []any{int64(3)}}, doc["arr"])
+	assert.Equal(t, map[string]any{"x": int64(1), "y": map[string]any{"z": "w"}}, doc["inline"])
+	assert.Equal(t, map[string]any{"alpha": map[string]any{"ip": "10.0.0.1"}}, doc["server"])
+	assert.Equal(t, []any{
+		map[string]any{"name": "Hammer"},
+		map[string]any{},
+		map[string]any{"name": "Nail", "dim": map[string]any{"w": int64(1)}},
+	}, doc["products"])
+
+	for _, invalid := range []string{
+		"a = 1\na = 2",
+		"[a]\n[a]",
+		"a = [1]\n[[a]]",
+		"a = \"unterminated",
+		"a = 1 b = 2",
+		"a = 1__0",
+		"a = 1979-13-27",
+		"a = \"\\q\"",
+		"= 1",
+	} {
+		_, err = tomlParse(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestTomlDataSourceReader(t *testing.T) {
+	source := TomlDataSource{}
+	tomlString := `
+[[rows]]
+a = 1
+b = "b1"
+c = { k = [1, 2] }
+d = 2021-03-04T05:06:07Z
+
+[[rows]]
+a = 2
+`
+	reader, err := source.Reader(strings.NewReader(tomlString), map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, df.NewSchema([]df.SeriesSchema{
+		{Name: "a", Format: df.IntegerFormat},
+		{Name: "b", Format: df.StringFormat},
+		{Name: "c", Format: df.StringFormat},
+		{Name: "d", Format: df.DateTimeFormat},
+	}), reader.Schema())
+	data := *(reader.Data())
+	assert.Equal(t, 2, len(data))
+	assert.Equal(t, []any{int64(1), "b1", `{"k":[1,2]}`, time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)}, testRowValues(data[0]))
+	assert.Equal(t, []any{int64(2), nil, nil, nil}, testRowValues(data[1]))
+
+	// root node and whole document as single row
+	tomlString = "title = \"t\"\n[data]\nitems = [{ a = \"x\" }, { a = \"y\" }]\n"
+	reader, err = source.Reader(strings.NewReader(tomlString), map[string]string{ConfigTOMLRootNode: "data.items"})
+	assert.NoError(t, err)
+	data = *(reader.Data())
+	assert.Equal(t, 2, len(data))
+	assert.Equal(t, "y", data[1].GetRaw(0))
+
+	reader, err = source.Reader(strings.NewReader(tomlString), map[string]string{})
+	assert.NoError(t, err)
+	data = *(reader.Data())
+	assert.Equal(t, 1, len(data))
+	assert.Equal(t, []any{`{"items":[{"a":"x"},{"a":"y"}]}`, "t"}, testRowValues(data[0]))
+
+	_, err = source.Reader(strings.NewReader(tomlString), map[string]string{ConfigTOMLRootNode: "title.x"})
+	assert.Error(t, err)
+}
+
+func TestTomlDataSourceWriter(t *testing.T) {
+	dfSchema := df.NewSchema([]df.SeriesSchema{
+		{Name: "b", Format: df.IntegerFormat},
+		{Name: "a.1", Format: df.StringFormat},
+		{Name: "c", Format: df.DoubleFormat},
+		{Name: "d", Format: df.DateTimeFormat},
+	})
+	value := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	rows := []df.Row{
+		inmemory.NewRowFromAny(&dfSchema, &([]any{int64(1), `{"k":[1,"v\n"]}`, 2.0, value})),
+		inmemory.NewRowFromAny(&dfSchema, &([]any{nil, "x", math.Inf(1), nil})),
+	}
+	dataframe := inmemory.NewDataframeFromRow(dfSchema, &rows)
+
+	source := TomlDataSource{}
+	writer, err := source.Writer(dataframe, map[string]string{})
+	assert.NoError(t, err)
+	var buff bytes.Buffer
+	assert.NoError(t, writer.Write(&buff))
+	assert.Equal(t, "[[rows]]\nb = 1\n\"a.1\" = { k = [1, \"v\\n\"] }\nc = 2.0\nd = 2021-03-04T05:06:07Z\n\n[[rows]]\n\"a.1\" = \"x\"\nc = inf\n", buff.String())
+
+	reader, err := source.Reader(&buff, map[string]string{})
+	assert.NoError(t, err)
+	data := *(reader.Data())
+	assert.Equal(t, 2, len(data))
+	assert.Equal(t, []any{`{"k":[1,"v\n"]}`, int64(1), 2.0, value}, testRowValues(data[0]))
+	assert.Equal(t, []any{"x", nil, math.Inf(1), nil}, testRowValues(data[1]))
+
+	// empty dataframe keeps root node
+	empty := inmemory.NewDataframeFromRow(dfSchema, &([]df.Row{}))
+	writer, err = source.Writer(empty, map[string]string{ConfigTOMLRootNode: "data.items"})
+	assert.NoError(t, err)
+	buff.Reset()
+	assert.NoError(t, writer.Write(&buff))
+	assert.Equal(t, "data.items = []\n", buff.String())
+	reader, err = source.Reader(&buff, map[string]string{ConfigTOMLRootNode: "data.items"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(*reader.Data()))
+}
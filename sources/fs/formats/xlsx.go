@@ -0,0 +1,693 @@
+package formats
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/blue4209211/pq/internal/log"
+)
+
+// ConfigXlsxSheet Name or 1 based index of the sheet to read, first sheet is read by default. While writing, name of the sheet
+const ConfigXlsxSheet = "xlsx.sheet"
+
+// ConfigXlsxHeader Is First row of the range header
+const ConfigXlsxHeader = "xlsx.hasHeader"
+
+// ConfigXlsxRange Range of cells to read, ex - A1:D100, B2:D (all the rows from 2nd row), default is whole sheet
+const ConfigXlsxRange = "xlsx.range"
+
+var xlsxConfig = map[string]string{
+	ConfigXlsxSheet:  "",
+	ConfigXlsxHeader: "true",
+	ConfigXlsxRange:  "",
+}
+
+// xlsxDefaultSheet name of the sheet while writing
+const xlsxDefaultSheet = "Sheet1"
+
+// xlsxEpoch excel stores dates as days from 1899-12-30, or from 1904-01-01 for 1904 date system
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+var xlsxEpoch1904 = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// XlsxDataSource reads/writes excel (xlsx) workbooks
+type XlsxDataSource struct {
+}
+
+func (t *XlsxDataSource) Args() map[string]string {
+	return xlsxConfig
+}
+
+func (t *XlsxDataSource) Name() string {
+	return "xlsx"
+}
+
+func (t *XlsxDataSource) Writer(data df.DataFrame, args map[string]string) (FormatWriter, error) {
+	return &xlsxDataSourceWriter{data: data, args: args}, nil
+}
+
+func (t *XlsxDataSource) Reader(reader io.Reader, args map[string]string) (FormatReader, error) {
+	streamReader, err := t.StreamReader(reader, args)
+	if err != nil {
+		return nil, err
+	}
+	return ReadAll(streamReader)
+}
+
+// StreamReader reads whole sheet in memory to derive column formats, if reader doesnt support random access (ex - stdin)
+// whole workbook is buffered in memory
+func (t *XlsxDataSource) StreamReader(reader io.Reader, args map[string]string) (FormatStreamReader, error) {
+	xlsxReader := &xlsxDataSourceReader{args: args}
+	err := xlsxReader.init(reader)
+	if err != nil {
+		return nil, err
+	}
+	return newFormatStreamReader(xlsxReader, args)
+}
+
+type xlsxRichText struct {
+	T string `xml:"t"`
+	R []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t *xlsxRichText) text() string {
+	text := t.T
+	for _, r := range t.R {
+		text = text + r.T
+	}
+	return text
+}
+
+type xlsxWorkbook struct {
+	WorkbookPr struct {
+		Date1904 bool `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxRichText `xml:"si"`
+}
+
+type xlsxStyles struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+type xlsxCell struct {
+	R  string       `xml:"r,attr"`
+	T  string       `xml:"t,attr"`
+	S  int          `xml:"s,attr"`
+	V  string       `xml:"v"`
+	Is xlsxRichText `xml:"is"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		R     int        `xml:"r,attr"`
+		Cells []xlsxCell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// xlsxCellRange range of cells, end row/column are 0 if range doesnt have end
+type xlsxCellRange struct {
+	startCol int
+	startRow int
+	endCol   int
+	endRow   int
+}
+
+func (t *xlsxCellRange) contains(col int, row int) bool {
+	return col >= t.startCol && row >= t.startRow && (t.endCol == 0 || col <= t.endCol) && (t.endRow == 0 || row <= t.endRow)
+}
+
+// xlsxParseCellRef parses cell reference (ex - B12) into 1 based column and row, row is 0 if reference has only column
+func xlsxParseCellRef(ref string) (col int, row int, err error) {
+	ref = strings.ToUpper(strings.TrimSpace(ref))
+	i := 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		col = col*26 + int(ref[i]-'A'+1)
+	}
+	if i < len(ref) {
+		row, err = strconv.Atoi(ref[i:])
+		if err != nil || row <= 0 {
+			return 0, 0, errors.New("invalid cell reference - " + ref)
+		}
+	}
+	return col, row, nil
+}
+
+// xlsxColumnName returns name of 1 based column, ex - 28 is AB
+func xlsxColumnName(col int) string {
+	name := ""
+	for col > 0 {
+		col = col - 1
+		name = string(rune('A'+col%26)) + name
+		col = col / 26
+	}
+	return name
+}
+
+func xlsxParseRange(args map[string]string) (cellRange xlsxCellRange, err error) {
+	cellRange = xlsxCellRange{startCol: 1, startRow: 1}
+	rangeStr := args[ConfigXlsxRange]
+	if rangeStr == "" {
+		return
+	}
+	parts := strings.Split(rangeStr, ":")
+	if len(parts) > 2 {
+		return cellRange, errors.New("invalid " + ConfigXlsxRange + " - " + rangeStr)
+	}
+	cellRange.startCol, cellRange.startRow, err = xlsxParseCellRef(parts[0])
+	if err != nil {
+		return cellRange, err
+	}
+	if cellRange.startCol == 0 {
+		cellRange.startCol = 1
+	}
+	if cellRange.startRow == 0 {
+		cellRange.startRow = 1
+	}
+	if len(parts) == 2 {
+		cellRange.endCol, cellRange.endRow, err = xlsxParseCellRef(parts[1])
+	}
+	return
+}
+
+func xlsxIsHeaderEnabled(args map[string]string) (header bool, err error) {
+	headerStr, ok := args[ConfigXlsxHeader]
+	if !ok || headerStr == "" {
+		headerStr = xlsxConfig[ConfigXlsxHeader]
+	}
+	header, err = strconv.ParseBool(headerStr)
+	if err != nil {
+		return header, errors.New("invalid " + ConfigXlsxHeader + " - " + headerStr)
+	}
+	return
+}
+
+// xlsxIsDateFormat checks if number format is date/time, text in quotes and brackets (ex - colors) is ignored
+func xlsxIsDateFormat(id int, code string) bool {
+	if (id >= 14 && id <= 22) || (id >= 45 && id <= 47) {
+		return true
+	}
+	quoted := false
+	bracket := false
+	for _, c := range strings.ToLower(code) {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '[':
+			bracket = true
+		case c == ']':
+			bracket = false
+		case bracket:
+		case c == 'd' || c == 'm' || c == 'y' || c == 'h' || c == 's':
+			return true
+		}
+	}
+	return false
+}
+
+type xlsxDataSourceReader struct {
+	args    map[string]string
+	schema  df.DataFrameSchema
+	records [][]any
+	index   int
+}
+
+func (t *xlsxDataSourceReader) Schema() (columns df.DataFrameSchema) {
+	return t.schema
+}
+
+func (t *xlsxDataSourceReader) Next() (r df.Row, err error) {
+	if t.index >= len(t.records) {
+		return r, io.EOF
+	}
+	r = inmemory.NewRowFromAny(&t.schema, &t.records[t.index])
+	t.records[t.index] = nil
+	t.index = t.index + 1
+	return r, nil
+}
+
+func (t *xlsxDataSourceReader) Close() error {
+	t.records = nil
+	return nil
+}
+
+func xlsxReadXML(files map[string]*zip.File, name string, v any) (bool, error) {
+	f, ok := files[name]
+	if !ok {
+		return false, nil
+	}
+	reader, err := f.Open()
+	if err != nil {
+		return true, err
+	}
+	defer reader.Close()
+	return true, xml.NewDecoder(reader).Decode(v)
+}
+
+func (t *xlsxDataSourceReader) init(reader io.Reader) (err error) {
+	cellRange, err := xlsxParseRange(t.args)
+	if err != nil {
+		return err
+	}
+	isHeader, err := xlsxIsHeaderEnabled(t.args)
+	if err != nil {
+		return err
+	}
+
+	source, ok := reader.(io.ReaderAt)
+	seeker, isSeeker := reader.(io.Seeker)
+	ok = ok && isSeeker
+	var size int64
+	if ok {
+		// pipes (ex - stdin) dont support random access
+		size, err = seeker.Seek(0, io.SeekEnd)
+		ok = err == nil
+	}
+	if !ok {
+		buf, err := io.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		source = bytes.NewReader(buf)
+		size = int64(len(buf))
+	}
+	zipReader, err := zip.NewReader(source, size)
+	if err != nil {
+		log.Error("unable to read xlsx", err)
+		return err
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zipReader.File {
+		files[strings.TrimPrefix(f.Name, "/")] = f
+	}
+
+	workbook := xlsxWorkbook{}
+	found, err := xlsxReadXML(files, "xl/workbook.xml", &workbook)
+	if err != nil {
+		return err
+	}
+	if !found || len(workbook.Sheets) == 0 {
+		return errors.New("invalid xlsx file, missing sheets")
+	}
+
+	sheetName := t.args[ConfigXlsxSheet]
+	sheetID := ""
+	for _, s := range workbook.Sheets {
+		if sheetName == "" || s.Name == sheetName {
+			sheetID = s.ID
+			break
+		}
+	}
+	// sheet can also be given as 1 based index
+	if i, err := strconv.Atoi(sheetName); sheetID == "" && err == nil && i > 0 && i <= len(workbook.Sheets) {
+		sheetID = workbook.Sheets[i-1].ID
+	}
+	if sheetID == "" {
+		return errors.New("sheet not found - " + sheetName)
+	}
+
+	relationships := xlsxRelationships{}
+	_, err = xlsxReadXML(files, "xl/_rels/workbook.xml.rels", &relationships)
+	if err != nil {
+		return err
+	}
+	sheetPath := ""
+	for _, r := range relationships.Relationships {
+		if r.ID == sheetID {
+			sheetPath = r.Target
+			if strings.HasPrefix(sheetPath, "/") {
+				sheetPath = strings.TrimPrefix(sheetPath, "/")
+			} else {
+				sheetPath = path.Join("xl", sheetPath)
+			}
+		}
+	}
+
+	sharedStrings := xlsxSharedStrings{}
+	_, err = xlsxReadXML(files, "xl/sharedStrings.xml", &sharedStrings)
+	if err != nil {
+		return err
+	}
+	styles := xlsxStyles{}
+	_, err = xlsxReadXML(files, "xl/styles.xml", &styles)
+	if err != nil {
+		return err
+	}
+	customFormats := map[int]string{}
+	for _, f := range styles.NumFmts {
+		customFormats[f.ID] = f.Code
+	}
+	dateStyles := make([]bool, len(styles.CellXfs))
+	for i, xf := range styles.CellXfs {
+		dateStyles[i] = xlsxIsDateFormat(xf.NumFmtID, customFormats[xf.NumFmtID])
+	}
+
+	sheet := xlsxWorksheet{}
+	found, err = xlsxReadXML(files, sheetPath, &sheet)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("invalid xlsx file, missing sheet - " + sheetPath)
+	}
+
+	epoch := xlsxEpoch
+	if workbook.WorkbookPr.Date1904 {
+		epoch = xlsxEpoch1904
+	}
+
+	// cells of the range, rows and columns are relative to start of the range
+	rows := [][]any{}
+	cols := 0
+	rowNum := 0
+	for _, row := range sheet.Rows {
+		if row.R > 0 {
+			rowNum = row.R
+		} else {
+			rowNum = rowNum + 1
+		}
+		colNum := 0
+		values := []any{}
+		for _, c := range row.Cells {
+			if c.R != "" {
+				colNum, _, err = xlsxParseCellRef(c.R)
+				if err != nil {
+					return err
+				}
+			} else {
+				colNum = colNum + 1
+			}
+			if !cellRange.contains(colNum, rowNum) {
+				continue
+			}
+
+			value, err := xlsxCellValue(c, sharedStrings.Items, dateStyles, epoch)
+			if err != nil {
+				return err
+			}
+			col := colNum - cellRange.startCol
+			for len(values) <= col {
+				values = append(values, nil)
+			}
+			values[col] = value
+		}
+		if !cellRange.contains(cellRange.startCol, rowNum) {
+			continue
+		}
+
+		r := rowNum - cellRange.startRow
+		for len(rows) <= r {
+			rows = append(rows, nil)
+		}
+		rows[r] = values
+		if len(values) > cols {
+			cols = len(values)
+		}
+	}
+	if cellRange.endCol > 0 {
+		cols = cellRange.endCol - cellRange.startCol + 1
+	}
+
+	names := make([]string, cols)
+	for i := range names {
+		names[i] = "c" + strconv.Itoa(i)
+	}
+	if isHeader && len(rows) > 0 {
+		for i, v := range rows[0] {
+			if i < cols && v != nil {
+				names[i] = xlsxString(v)
+			}
+		}
+		rows = rows[1:]
+	}
+
+	series := make([]df.SeriesSchema, cols)
+	for i := range series {
+		series[i] = df.SeriesSchema{Name: names[i], Format: xlsxColumnFormat(rows, i)}
+	}
+	t.schema = df.NewSchema(series)
+
+	t.records = make([][]any, len(rows))
+	for r, row := range rows {
+		record := make([]any, cols)
+		for i := range record {
+			if i >= len(row) || row[i] == nil {
+				continue
+			}
+			record[i] = xlsxConvert(row[i], series[i].Format)
+		}
+		t.records[r] = record
+	}
+	return nil
+}
+
+// xlsxCellValue returns value of the cell as string, float64, bool or time.Time, nil is returned for empty and error cells
+func xlsxCellValue(c xlsxCell, sharedStrings []xlsxRichText, dateStyles []bool, epoch time.Time) (any, error) {
+	switch c.T {
+	case "s":
+		i, err := strconv.Atoi(strings.TrimSpace(c.V))
+		if err != nil || i < 0 || i >= len(sharedStrings) {
+			return nil, errors.New("invalid shared string in cell " + c.R)
+		}
+		return sharedStrings[i].text(), nil
+	case "inlineStr":
+		return c.Is.text(), nil
+	case "str":
+		return c.V, nil
+	case "b":
+		return c.V == "1" || strings.EqualFold(c.V, "true"), nil
+	case "e":
+		return nil, nil
+	case "d":
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+			if v, err := time.Parse(layout, c.V); err == nil {
+				return v.UTC(), nil
+			}
+		}
+		return c.V, nil
+	}
+
+	if c.V == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(c.V), 64)
+	if err != nil {
+		return nil, errors.New("invalid number in cell " + c.R + " - " + c.V)
+	}
+	if c.S >= 0 && c.S < len(dateStyles) && dateStyles[c.S] {
+		// values are rounded to milliseconds as serial dates are not precise
+		return epoch.Add(time.Duration(math.Round(f*24*60*60*1000)) * time.Millisecond), nil
+	}
+	return f, nil
+}
+
+// xlsxColumnFormat returns format which can hold all the values of the column, mixed values are read as string
+func xlsxColumnFormat(rows [][]any, col int) df.Format {
+	var format df.Format
+	for _, row := range rows {
+		if col >= len(row) || row[col] == nil {
+			continue
+		}
+		var valueFormat df.Format
+		switch v := row[col].(type) {
+		case bool:
+			valueFormat = df.BoolFormat
+		case time.Time:
+			valueFormat = df.DateTimeFormat
+		case float64:
+			valueFormat = df.DoubleFormat
+			if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+				valueFormat = df.IntegerFormat
+			}
+		default:
+			return df.StringFormat
+		}
+
+		if format == nil || format == valueFormat {
+			format = valueFormat
+		} else if (format == df.IntegerFormat && valueFormat == df.DoubleFormat) || (format == df.DoubleFormat && valueFormat == df.IntegerFormat) {
+			format = df.DoubleFormat
+		} else {
+			return df.StringFormat
+		}
+	}
+	if format == nil {
+		return df.StringFormat
+	}
+	return format
+}
+
+func xlsxConvert(v any, format df.Format) any {
+	switch format {
+	case df.IntegerFormat:
+		return int64(v.(float64))
+	case df.StringFormat:
+		return xlsxString(v)
+	}
+	return v
+}
+
+func xlsxString(v any) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(s)
+	case time.Time:
+		return s.Format(time.RFC3339)
+	}
+	return ""
+}
+
+type xlsxDataSourceWriter struct {
+	data df.DataFrame
+	args map[string]string
+}
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`
+
+const xlsxRootRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+const xlsxWorkbookRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`
+
+// xlsxStylesXML second cell style is used for datetime values
+const xlsxStylesXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts><fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs></styleSheet>`
+
+func xlsxEscape(s string) string {
+	var buff bytes.Buffer
+	_ = xml.EscapeText(&buff, []byte(s))
+	return buff.String()
+}
+
+func (t *xlsxDataSourceWriter) Write(writer io.Writer) (err error) {
+	sheetName := t.args[ConfigXlsxSheet]
+	if sheetName == "" {
+		sheetName = xlsxDefaultSheet
+	}
+
+	zipWriter := zip.NewWriter(writer)
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRelationships},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRelationships},
+		{"xl/styles.xml", xlsxStylesXML},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="` + xlsxEscape(sheetName) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	}
+	for _, p := range parts {
+		w, err := zipWriter.Create(p.name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, p.content)
+		if err != nil {
+			return err
+		}
+	}
+
+	w, err := zipWriter.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	err = t.writeSheet(w)
+	if err != nil {
+		log.Error("unable to write xlsx sheet", err)
+		return err
+	}
+	return zipWriter.Close()
+}
+
+// writeSheet writes header and rows, strings are written inline and datetime as serial date with datetime style
+func (t *xlsxDataSourceWriter) writeSheet(writer io.Writer) (err error) {
+	var buff bytes.Buffer
+	buff.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	buff.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	schema := t.data.Schema()
+	buff.WriteString(`<row r="1">`)
+	for i, s := range schema.Series() {
+		buff.WriteString(`<c r="` + xlsxColumnName(i+1) + `1" t="inlineStr"><is><t>` + xlsxEscape(s.Name) + `</t></is></c>`)
+	}
+	buff.WriteString(`</row>`)
+
+	for i := int64(0); i < t.data.Len(); i++ {
+		rowNum := strconv.FormatInt(i+2, 10)
+		r := t.data.GetRow(i)
+		buff.WriteString(`<row r="` + rowNum + `">`)
+		for col := 0; col < r.Len(); col++ {
+			if r.IsNil(col) {
+				continue
+			}
+			ref := xlsxColumnName(col+1) + rowNum
+			switch schema.Get(col).Format {
+			case df.BoolFormat:
+				v := "0"
+				if r.GetAsBool(col) {
+					v = "1"
+				}
+				buff.WriteString(`<c r="` + ref + `" t="b"><v>` + v + `</v></c>`)
+			case df.IntegerFormat:
+				buff.WriteString(`<c r="` + ref + `"><v>` + strconv.FormatInt(r.GetAsInt(col), 10) + `</v></c>`)
+			case df.DoubleFormat:
+				buff.WriteString(`<c r="` + ref + `"><v>` + strconv.FormatFloat(r.GetAsDouble(col), 'g', -1, 64) + `</v></c>`)
+			case df.DateTimeFormat:
+				serial := float64(r.GetAsDatetime(col).UTC().Sub(xlsxEpoch)) / float64(24*time.Hour)
+				buff.WriteString(`<c r="` + ref + `" s="1"><v>` + strconv.FormatFloat(serial, 'f', -1, 64) + `</v></c>`)
+			default:
+				buff.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">` + xlsxEscape(r.GetAsString(col)) + `</t></is></c>`)
+			}
+		}
+		buff.WriteString(`</row>`)
+
+		if buff.Len() > 64*1024 {
+			_, err = writer.Write(buff.Bytes())
+			if err != nil {
+				return err
+			}
+			buff.Reset()
+		}
+	}
+	buff.WriteString(`</sheetData></worksheet>`)
+	_, err = writer.Write(buff.Bytes())
+	return err
+}
@@ -0,0 +1,140 @@
+package formats
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXlsxDataSource(t *testing.T) {
+	source := XlsxDataSource{}
+	assert.Equal(t, source.Name(), "xlsx")
+}
+
+func TestXlsxDataSourceWriter(t *testing.T) {
+	dfSchema := df.NewSchema([]df.SeriesSchema{
+		{Name: "a", Format: df.IntegerFormat},
+		{Name: "b", Format: df.DoubleFormat},
+		{Name: "c", Format: df.StringFormat},
+		{Name: "d", Format: df.BoolFormat},
+		{Name: "e", Format: df.DateTimeFormat},
+	})
+	value := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	rows := []df.Row{
+		inmemory.NewRowFromAny(&dfSchema, &([]any{int64(1), 1.5, "c1 & <c>", true, value})),
+		inmemory.NewRowFromAny(&dfSchema, &([]any{nil, nil, nil, nil, nil})),
+		inmemory.NewRowFromAny(&dfSchema, &([]any{int64(3), 3.25, " c3 ", false, value})),
+	}
+	dataframe := inmemory.NewDataframeFromRow(dfSchema, &rows)
+
+	source := XlsxDataSource{}
+	writer, err := source.Writer(dataframe, map[string]string{ConfigXlsxSheet: "data"})
+	assert.NoError(t, err)
+	var buff bytes.Buffer
+	assert.NoError(t, writer.Write(&buff))
+
+	// reading from both random access and non seekable readers
+	for _, reader := range []io.Reader{bytes.NewReader(buff.Bytes()), io.MultiReader(bytes.NewReader(buff.Bytes()))} {
+		xlsxReader, err := source.Reader(reader, map[string]string{ConfigXlsxSheet: "data"})
+		assert.NoError(t, err)
+		assert.Equal(t, dfSchema, xlsxReader.Schema())
+
+		dfData := *(xlsxReader.Data())
+		assert.Equal(t, 3, len(dfData))
+		assert.Equal(t, []any{int64(1), 1.5, "c1 & <c>", true, value}, testRowValues(dfData[0]))
+		assert.Equal(t, []any{nil, nil, nil, nil, nil}, testRowValues(dfData[1]))
+		assert.Equal(t, []any{int64(3), 3.25, " c3 ", false, value}, testRowValues(dfData[2]))
+	}
+
+	_, err = source.Reader(bytes.NewReader(buff.Bytes()), map[string]string{ConfigXlsxSheet: "missing"})
+	assert.Error(t, err)
+}
+
+// xlsxTestFile writes workbook with given sheets, sheets refer shared strings and styles
+func xlsxTestFile(t *testing.T, date1904 bool, sheets map[string]string, names ...string) []byte {
+	var buff bytes.Buffer
+	zipWriter := zip.NewWriter(&buff)
+	write := func(name string, content string) {
+		w, err := zipWriter.Create(name)
+		assert.NoError(t, err)
+		_, err = io.WriteString(w, content)
+		assert.NoError(t, err)
+	}
+
+	workbook := `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`
+	if date1904 {
+		workbook = workbook + `<workbookPr date1904="1"/>`
+	}
+	workbook = workbook + `<sheets>`
+	rels := `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`
+	for i, name := range names {
+		id := "rId" + xlsxColumnName(i+1)
+		workbook = workbook + `<sheet name="` + name + `" sheetId="1" r:id="` + id + `"/>`
+		rels = rels + `<Relationship Id="` + id + `" Target="/xl/worksheets/` + name + `.xml"/>`
+		write("xl/worksheets/"+name+".xml", `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`+sheets[name]+`</sheetData></worksheet>`)
+	}
+	write("xl/workbook.xml", workbook+`</sheets></workbook>`)
+	write("xl/_rels/workbook.xml.rels", rels+`</Relationships>`)
+	write("xl/sharedStrings.xml", `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><si><t>name</t></si><si><r><t>Rich </t></r><r><t>Text</t></r></si><si><t>when</t></si></sst>`)
+	write("xl/styles.xml", `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><numFmts><numFmt numFmtId="164" formatCode="[Red]dd/mm/yyyy"/><numFmt numFmtId="165" formatCode="&quot;Day&quot; 0.00"/></numFmts>`+
+		`<cellXfs><xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="164"/><xf numFmtId="165"/></cellXfs></styleSheet>`)
+	assert.NoError(t, zipWriter.Close())
+	return buff.Bytes()
+}
+
+func TestXlsxDataSourceReader(t *testing.T) {
+	sheets := map[string]string{
+		"first": `<row r="1"><c r="A1"><v>1</v></c></row>`,
+		"second": `<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>2</v></c><c r="C1" t="inlineStr"><is><t>n</t></is></c><c r="D1" t="str"><v>mixed</v></c><c r="E1" t="str"><v>flag</v></c></row>` +
+			`<row r="2"><c r="A2" t="s"><v>1</v></c><c r="B2" s="1"><v>44259.5</v></c><c r="C2" s="3"><v>1</v></c><c r="D2"><v>1.5</v></c><c r="E2" t="b"><v>1</v></c></row>` +
+			`<row r="4"><c r="B4" s="2"><v>44260</v></c><c r="C4"><v>2.5</v></c><c r="D4" t="b"><v>0</v></c><c r="E4" t="e"><v>#N/A</v></c><c r="F4"><v>9</v></c></row>`,
+	}
+	data := xlsxTestFile(t, false, sheets, "first", "second")
+	source := XlsxDataSource{}
+
+	reader, err := source.Reader(bytes.NewReader(data), map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, df.NewSchema([]df.SeriesSchema{{Name: "1", Format: df.StringFormat}}), reader.Schema())
+	assert.Equal(t, 0, len(*reader.Data()))
+
+	reader, err = source.Reader(bytes.NewReader(data), map[string]string{ConfigXlsxSheet: "second"})
+	assert.NoError(t, err)
+	assert.Equal(t, df.NewSchema([]df.SeriesSchema{
+		{Name: "name", Format: df.StringFormat},
+		{Name: "when", Format: df.DateTimeFormat},
+		{Name: "n", Format: df.DoubleFormat},
+		{Name: "mixed", Format: df.StringFormat},
+		{Name: "flag", Format: df.BoolFormat},
+		{Name: "c5", Format: df.IntegerFormat},
+	}), reader.Schema())
+	dfData := *(reader.Data())
+	assert.Equal(t, 3, len(dfData))
+	assert.Equal(t, []any{"Rich Text", time.Date(2021, 3, 4, 12, 0, 0, 0, time.UTC), 1.0, "1.5", true, nil}, testRowValues(dfData[0]))
+	assert.Equal(t, []any{nil, nil, nil, nil, nil, nil}, testRowValues(dfData[1]))
+	assert.Equal(t, []any{nil, time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC), 2.5, "false", nil, int64(9)}, testRowValues(dfData[2]))
+
+	// range without header, sheet by index
+	reader, err = source.Reader(bytes.NewReader(data), map[string]string{ConfigXlsxSheet: "2", ConfigXlsxHeader: "false", ConfigXlsxRange: "C2:D"})
+	assert.NoError(t, err)
+	assert.Equal(t, df.NewSchema([]df.SeriesSchema{{Name: "c0", Format: df.DoubleFormat}, {Name: "c1", Format: df.StringFormat}}), reader.Schema())
+	dfData = *(reader.Data())
+	assert.Equal(t, 3, len(dfData))
+	assert.Equal(t, []any{1.0, "1.5"}, testRowValues(dfData[0]))
+	assert.Equal(t, []any{2.5, "false"}, testRowValues(dfData[2]))
+
+	data = xlsxTestFile(t, true, map[string]string{"s": `<row r="1"><c r="A1" s="1"><v>0</v></c></row>`}, "s")
+	reader, err = source.Reader(bytes.NewReader(data), map[string]string{ConfigXlsxHeader: "false"})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC), (*reader.Data())[0].GetRaw(0))
+
+	_, err = source.Reader(bytes.NewReader(data), map[string]string{ConfigXlsxRange: "1A:B"})
+	assert.Error(t, err)
+	_, err = source.Reader(bytes.NewReader([]byte("a,b\n1,2\n")), map[string]string{})
+	assert.Error(t, err)
+}
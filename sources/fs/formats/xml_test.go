@@ -3,6 +3,7 @@ package formats
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/blue4209211/pq/df/inmemory"
 	"github.com/stretchr/testify/assert"
@@ -140,3 +141,29 @@ func BenchmarkXMLParsing(b *testing.B) {
 	})
 
 }
+
+func TestXMLDataSourceInferSchema(t *testing.T) {
+	source := XmlDataSource{}
+	xmlString := `<root><element a="1"><b>2.5</b><c>c1</c><d>2022-01-02T10:00:00Z</d></element><element a="3"><b>4</b><c>c2</c><d>2022-01-03T10:00:00Z</d></element><element a="5"><b></b><c></c></element></root>`
+
+	xmlReader, err := source.Reader(strings.NewReader(xmlString), map[string]string{
+		ConfigXMLElementName: "element",
+		ConfigXMLInferSchema: "true",
+	})
+	assert.NoError(t, err)
+
+	schema := xmlReader.Schema()
+	assert.Equal(t, 4, schema.Len())
+	assert.Equal(t, "integer", schema.Get(0).Format.Name())
+	assert.Equal(t, "double", schema.Get(1).Format.Name())
+	assert.Equal(t, "string", schema.Get(2).Format.Name())
+	assert.Equal(t, "datetime", schema.Get(3).Format.Name())
+
+	data := *(xmlReader.Data())
+	assert.Equal(t, 3, len(data))
+	assert.Equal(t, int64(3), data[1].GetRaw(0))
+	assert.Equal(t, 4.0, data[1].GetRaw(1))
+	assert.True(t, data[2].IsNil(1))
+	assert.Equal(t, "", data[2].GetRaw(2))
+	assert.Equal(t, time.Date(2022, 1, 3, 10, 0, 0, 0, time.UTC), data[1].GetRaw(3))
+}
@@ -0,0 +1,100 @@
+package formats
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/blue4209211/pq/df"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigYAMLRootNode root node (dot separated path) of list to read as rows, while writing rows are nested under this path
+const ConfigYAMLRootNode = "yaml.rootNode"
+
+var yamlConfig = map[string]string{
+	ConfigYAMLRootNode: "",
+}
+
+type YamlDataSource struct {
+}
+
+func (t *YamlDataSource) Args() map[string]string {
+	return yamlConfig
+}
+
+func (t *YamlDataSource) Name() string {
+	return "yaml"
+}
+
+func (t *YamlDataSource) Writer(data df.DataFrame, args map[string]string) (FormatWriter, error) {
+	return &yamlDataSourceWriter{data: data, args: args}, nil
+}
+
+func (t *YamlDataSource) Reader(reader io.Reader, args map[string]string) (FormatReader, error) {
+	streamReader, err := t.StreamReader(reader, args)
+	if err != nil {
+		return nil, err
+	}
+	return ReadAll(streamReader)
+}
+
+// StreamReader reads documents one by one, so rows of multi document streams are read from all the documents
+func (t *YamlDataSource) StreamReader(reader io.Reader, args map[string]string) (FormatStreamReader, error) {
+	decoder := yaml.NewDecoder(reader)
+	recordReader := &documentRecordReader{
+		format:   "yaml",
+		rootNode: args[ConfigYAMLRootNode],
+		decode: func() (doc any, err error) {
+			err = decoder.Decode(&doc)
+			if err != nil && err != io.EOF {
+				return doc, errors.New("yaml : " + err.Error())
+			}
+			return doc, err
+		},
+	}
+	mapReader, err := newMapStreamReader("yaml", args, false, recordReader.next)
+	if err != nil {
+		return nil, err
+	}
+	return newFormatStreamReader(mapReader, args)
+}
+
+type yamlDataSourceWriter struct {
+	data df.DataFrame
+	args map[string]string
+}
+
+func (t *yamlDataSourceWriter) Write(writer io.Writer) (err error) {
+	schema := t.data.Schema()
+	rows := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for index := int64(0); index < t.data.Len(); index++ {
+		row := t.data.GetRow(index)
+		obj := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for i, c := range schema.Series() {
+			value := &yaml.Node{}
+			err = value.Encode(documentWriterValue(c.Format, row.Get(i).Get()))
+			if err != nil {
+				return err
+			}
+			obj.Content = append(obj.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: c.Name}, value)
+		}
+		rows.Content = append(rows.Content, obj)
+	}
+
+	doc := rows
+	if rootNode := t.args[ConfigYAMLRootNode]; rootNode != "" {
+		keys := strings.Split(rootNode, ".")
+		for i := len(keys) - 1; i >= 0; i-- {
+			doc = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!str", Value: keys[i]}, doc}}
+		}
+	}
+
+	encoder := yaml.NewEncoder(writer)
+	encoder.SetIndent(2)
+	err = encoder.Encode(doc)
+	if err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,94 @@
+package formats
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYamlDataSource(t *testing.T) {
+	source := YamlDataSource{}
+	assert.Equal(t, source.Name(), "yaml")
+}
+
+func TestYamlDataSourceReader(t *testing.T) {
+	source := YamlDataSource{}
+	yamlString := `
+- a: 1
+  b: 1.5
+  c: c1
+  d: true
+  e: [1, 2]
+  f: {k: v}
+  g: !!timestamp 2021-03-04T05:06:07Z
+- a: 2
+  b: null
+  c: "002"
+- 3
+`
+	reader, err := source.Reader(strings.NewReader(yamlString), map[string]string{ConfigMode: ModeDropMalformed})
+	assert.NoError(t, err)
+	assert.Equal(t, df.NewSchema([]df.SeriesSchema{
+		{Name: "a", Format: df.IntegerFormat},
+		{Name: "b", Format: df.DoubleFormat},
+		{Name: "c", Format: df.StringFormat},
+		{Name: "d", Format: df.BoolFormat},
+		{Name: "e", Format: df.StringFormat},
+		{Name: "f", Format: df.StringFormat},
+		{Name: "g", Format: df.DateTimeFormat},
+	}), reader.Schema())
+	data := *(reader.Data())
+	assert.Equal(t, 2, len(data))
+	assert.Equal(t, []any{int64(1), 1.5, "c1", true, "[1,2]", `{"k":"v"}`, time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)}, testRowValues(data[0]))
+	assert.Equal(t, []any{int64(2), nil, "002", nil, nil, nil, nil}, testRowValues(data[1]))
+
+	// root node and multiple documents
+	yamlString = "data:\n  items:\n    - a: x\n---\ndata:\n  items:\n    - a: y\n    - a: z\n"
+	reader, err = source.Reader(strings.NewReader(yamlString), map[string]string{ConfigYAMLRootNode: "data.items"})
+	assert.NoError(t, err)
+	data = *(reader.Data())
+	assert.Equal(t, 3, len(data))
+	assert.Equal(t, "z", data[2].GetRaw(0))
+
+	// unquoted timestamps are read as datetime, quoted as string
+	reader, err = source.Reader(strings.NewReader("- a: 2021-01-01\n  b: \"2021-01-01\"\n"), map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, []any{time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), "2021-01-01"}, testRowValues((*reader.Data())[0]))
+
+	_, err = source.Reader(strings.NewReader(yamlString), map[string]string{ConfigYAMLRootNode: "data.missing"})
+	assert.Error(t, err)
+	_, err = source.Reader(strings.NewReader("a: [1"), map[string]string{})
+	assert.Error(t, err)
+}
+
+func TestYamlDataSourceWriter(t *testing.T) {
+	dfSchema := df.NewSchema([]df.SeriesSchema{
+		{Name: "b", Format: df.IntegerFormat},
+		{Name: "a", Format: df.StringFormat},
+		{Name: "c", Format: df.StringFormat},
+	})
+	rows := []df.Row{
+		inmemory.NewRowFromAny(&dfSchema, &([]any{int64(1), "123", `{"k":[1,2.5]}`})),
+		inmemory.NewRowFromAny(&dfSchema, &([]any{nil, "x: y", nil})),
+	}
+	dataframe := inmemory.NewDataframeFromRow(dfSchema, &rows)
+
+	source := YamlDataSource{}
+	writer, err := source.Writer(dataframe, map[string]string{ConfigYAMLRootNode: "data.items"})
+	assert.NoError(t, err)
+	var buff bytes.Buffer
+	assert.NoError(t, writer.Write(&buff))
+	assert.Equal(t, "data:\n  items:\n    - b: 1\n      a: \"123\"\n      c:\n        k:\n          - 1\n          - 2.5\n    - b: null\n      a: 'x: y'\n      c: null\n", buff.String())
+
+	reader, err := source.Reader(&buff, map[string]string{ConfigYAMLRootNode: "data.items"})
+	assert.NoError(t, err)
+	data := *(reader.Data())
+	assert.Equal(t, 2, len(data))
+	assert.Equal(t, []any{"123", int64(1), `{"k":[1,2.5]}`}, testRowValues(data[0]))
+	assert.Equal(t, []any{"x: y", nil, nil}, testRowValues(data[1]))
+}
@@ -9,15 +9,14 @@ import (
 	"io"
 	"io/fs"
 	"net/url"
-	"os"
 	"path"
 	"path/filepath"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/blue4209211/pq/df"
 	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/blue4209211/pq/df/lazy"
 	"github.com/blue4209211/pq/internal/log"
 	"github.com/blue4209211/pq/sources/fs/formats"
 	"github.com/blue4209211/pq/sources/fs/vfs"
@@ -32,7 +31,7 @@ type DataSource struct {
 
 //IsSupported IsSupported returns supported protocols by file sources
 func (t *DataSource) IsSupported(protocol string) bool {
-	return protocol == "file" || protocol == "gs" || protocol == "s3" || protocol == "csv" || protocol == "xml" || protocol == "json" || protocol == "parquet" || protocol == "text" || protocol == "log"
+	return protocol == "file" || protocol == "gs" || protocol == "s3" || protocol == "csv" || protocol == "xml" || protocol == "json" || protocol == "parquet" || protocol == "arrow" || protocol == "feather" || protocol == "avro" || protocol == "orc" || protocol == "xlsx" || protocol == "yaml" || protocol == "yml" || protocol == "toml" || protocol == "fixed" || protocol == "fwf" || protocol == "table" || protocol == "box" || protocol == "markdown" || protocol == "md" || protocol == "html" || protocol == "text" || protocol == "log"
 }
 
 func updateConfigFromSourceURL(sourceURL string, config map[string]string) map[string]string {
@@ -63,6 +62,10 @@ func (t *DataSource) Read(context context.Context, sourceURL string, config map[
 	if err != nil {
 		return data, err
 	}
+	// format of .txt files is detected from data of each file
+	if isCsvSniffCandidate(sourceURL, format, config) {
+		format = ""
+	}
 
 	filesystem, err := vfs.GetVFS(sourceURL)
 	if err != nil {
@@ -123,11 +126,7 @@ func (t *DataSource) Read(context context.Context, sourceURL string, config map[
 
 	startTime := time.Now()
 	log.Debug("Reading data from FS - ", files)
-	if len(files) <= 1 {
-		mergedDf, err = readSourcesToDataframeSync(filesystem, fileOrDirName, files, &config)
-	} else {
-		mergedDf, err = readSourcesToDataframeAsync(filesystem, fileOrDirName, files, &config)
-	}
+	mergedDf, err = readSourcesToDataframe(filesystem, fileOrDirName, files, &config)
 	if err != nil {
 		return data, err
 	}
@@ -173,24 +172,35 @@ func (t *DataSource) Write(context context.Context, data df.DataFrame, path stri
 func getDataframeFromSource(name string, ext string, reader io.Reader, config *map[string]string) (data df.DataFrame, err error) {
 	startTime := time.Now()
 
-	log.Debug()
 	streamSource, err := formats.GetFormatHandler(ext)
 	if err != nil {
 		return data, err
 	}
 
-	skippedBomReader := utfbom.SkipOnly(reader)
-	dataframeReader, err := streamSource.Reader(skippedBomReader, *config)
+	// binary formats need random access on underlying file
+	if !isBinaryFormat(ext) {
+		reader = utfbom.SkipOnly(reader)
+	}
+	streamReader, err := streamSource.StreamReader(reader, *config)
 	if err != nil {
 		return data, err
 	}
-	schema := dataframeReader.Schema()
-	rows := dataframeReader.Data()
-	log.Debug("time to read data from source ", name, " ", time.Since(startTime).String())
-	startTime = time.Now()
-	datsourceDf := inmemory.NewDataframeFromRowAndName(name, schema, rows)
-	log.Debug("time to create dataframe from source ", name, " ", time.Since(startTime).String())
-	return datsourceDf, nil
+	defer streamReader.Close()
+
+	schema := streamReader.Schema()
+	rows := make([]df.Row, 0, 1000)
+	for {
+		r, err := streamReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return data, err
+		}
+		rows = append(rows, r)
+	}
+	log.Debug("time to read data from source ", name, " ", time.Since(startTime).String(), " records ", len(rows))
+	return inmemory.NewDataframeFromRowAndName(name, schema, &rows), nil
 }
 
 func getFileDetails(fileName string) (path string, name string, format string, comrpression string, err error) {
@@ -219,6 +229,30 @@ func getFileDetails(fileName string) (path string, name string, format string, c
 			format = "xml"
 		} else if strings.Contains(path, ".parquet") {
 			format = "parquet"
+		} else if strings.Contains(path, ".arrows") {
+			format = "arrows"
+		} else if strings.Contains(path, ".arrow") {
+			format = "arrow"
+		} else if strings.Contains(path, ".feather") {
+			format = "feather"
+		} else if strings.Contains(path, ".ipc") {
+			format = "ipc"
+		} else if strings.Contains(path, ".avro") {
+			format = "avro"
+		} else if strings.Contains(path, ".orc") {
+			format = "orc"
+		} else if strings.Contains(path, ".xlsx") {
+			format = "xlsx"
+		} else if strings.Contains(path, ".yaml") || strings.Contains(path, ".yml") {
+			format = "yaml"
+		} else if strings.Contains(path, ".toml") {
+			format = "toml"
+		} else if strings.Contains(path, ".fwf") {
+			format = "fixed"
+		} else if strings.Contains(path, ".md") {
+			format = "markdown"
+		} else if strings.Contains(path, ".htm") {
+			format = "html"
 		} else if strings.Contains(path, ".txt") || strings.Contains(path, ".text") || strings.Contains(path, ".log") {
 			format = "text"
 		}
@@ -237,160 +271,214 @@ func getFileDetails(fileName string) (path string, name string, format string, c
 	return
 }
 
-func readSourceToDataframeAsyncWorker(jobs <-chan string, results chan<- asyncReaderResult, wg *sync.WaitGroup, config *map[string]string, filesystem vfs.VFS) {
-	defer wg.Done()
-
-	for f := range jobs {
-		path, name, ext, compression, err := getFileDetails(f)
+// csvSniffedFormat .txt files are read as csv when csv separator is detected from data, unless format is given explicitly.
+// format is changed only when sniffer finds same number of fields in most of the sampled lines
+func csvSniffedFormat(filesystem vfs.VFS, fileName string, path string, format string, compression string, config map[string]string) (string, error) {
+	if !isCsvSniffCandidate(fileName, format, config) {
+		return format, nil
+	}
+	f, err := filesystem.Open(path)
+	if err != nil {
+		return format, err
+	}
+	defer f.Close()
 
+	var reader io.Reader = f
+	if compression == "gz" {
+		gzipReader, err := gzip.NewReader(f)
 		if err != nil {
-			results <- asyncReaderResult{err: err}
-			break
+			return format, err
 		}
-
-		if ext == "" {
-			ext = "json"
+		defer gzipReader.Close()
+		reader = gzipReader
+	} else if compression == "snappy" {
+		reader = snappy.NewReader(f)
+	} else if compression == "zip" {
+		// sample is taken from first entry of zip
+		buff, err := io.ReadAll(f)
+		if err != nil {
+			return format, err
+		}
+		zipReader, err := zip.NewReader(bytes.NewReader(buff), int64(len(buff)))
+		if err != nil || len(zipReader.File) == 0 {
+			return format, err
 		}
+		zipFile, err := zipReader.File[0].Open()
+		if err != nil {
+			return format, err
+		}
+		defer zipFile.Close()
+		reader = zipFile
+	}
 
-		log.Debugf("reading file path(%s), name(%s), ext(%s), compression(%s)", path, name, ext, compression)
+	isCsv, err := formats.CsvSniffConsistent(utfbom.SkipOnly(reader), config)
+	if err != nil || !isCsv {
+		return format, err
+	}
+	log.Debugf("csv separator detected in (%s), reading as csv", fileName)
+	return "csv", nil
+}
 
-		if path == "-" {
-			ext1, ok := (*config)["fmt.std.type"]
-			if !ok {
-				ext1 = "json"
-			}
-			ext = ext1
-			name = "stdin"
+// isCsvSniffCandidate .txt files without explicit format are sniffed when csv separator is auto detected
+func isCsvSniffCandidate(fileName string, format string, config map[string]string) bool {
+	if format != "text" || config[formats.ConfigCsvSep] != formats.CsvSepAuto {
+		return false
+	}
+	parsedURL, err := url.Parse(fileName)
+	return err == nil && !parsedURL.Query().Has("format") && parsedURL.Scheme != "text" && strings.Contains(parsedURL.Path, ".txt")
+}
 
-			ds, err := getDataframeFromSource(name, ext, os.Stdin, config)
-			if err != nil {
-				results <- asyncReaderResult{err: err}
-				break
-			}
-			results <- asyncReaderResult{data: ds}
-		} else if compression == "gz" {
-			f, err := filesystem.Open(path)
-			if err != nil {
-				results <- asyncReaderResult{err: err}
-				break
-			}
-			defer f.Close()
-			reader, err := gzip.NewReader(f)
-			if err != nil {
-				results <- asyncReaderResult{err: err}
-				break
-			}
+// fileRowStream closes underlying file and decompressors along with format reader
+type fileRowStream struct {
+	formats.FormatStreamReader
+	closers []io.Closer
+}
 
-			ds, err := getDataframeFromSource(name, ext, reader, config)
-			if err != nil {
-				results <- asyncReaderResult{err: err}
-				break
-			}
-			results <- asyncReaderResult{data: ds}
-		} else if compression == "snappy" {
-			f, err := filesystem.Open(path)
-			if err != nil {
-				results <- asyncReaderResult{err: err}
-				break
-			}
-			defer f.Close()
-			reader := snappy.NewReader(f)
-			ds, err := getDataframeFromSource(name, ext, reader, config)
-			if err != nil {
-				results <- asyncReaderResult{err: err}
-				break
-			}
-			results <- asyncReaderResult{data: ds}
-		} else if compression == "zip" {
-			f, err := filesystem.Open(path)
-			if err != nil {
-				results <- asyncReaderResult{err: err}
-				break
-			}
-			defer f.Close()
-			buff := bytes.NewBuffer([]byte{})
-			_, err = io.Copy(buff, f)
-			if err != nil {
-				results <- asyncReaderResult{err: err}
-				break
-			}
+func (t *fileRowStream) Close() (err error) {
+	err = t.FormatStreamReader.Close()
+	for i := len(t.closers) - 1; i >= 0; i-- {
+		closeErr := t.closers[i].Close()
+		if err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
 
-			zipReader, err := zip.NewReader(bytes.NewReader(buff.Bytes()), int64(buff.Len()))
-			if err != nil {
-				results <- asyncReaderResult{err: err}
-				break
-			}
-			for _, f := range zipReader.File {
-				zipFile, err := f.Open()
-				if err != nil {
-					results <- asyncReaderResult{err: err}
-					break
-				}
-				defer zipFile.Close()
+func closeAll(closers []io.Closer) {
+	for i := len(closers) - 1; i >= 0; i-- {
+		closers[i].Close()
+	}
+}
 
-				ds, err := getDataframeFromSource(name, ext, zipFile, config)
-				if err != nil {
-					results <- asyncReaderResult{err: err}
-					break
-				}
-				results <- asyncReaderResult{data: ds}
-			}
-		} else {
-			f, err := filesystem.Open(path)
-			if err != nil {
-				results <- asyncReaderResult{err: err}
-				break
-			}
-			defer f.Close()
+// openFileStream opens format reader on file, columns and filters are passed to formats which support pushdown
+func openFileStream(filesystem vfs.VFS, path string, ext string, compression string, config *map[string]string, cols []int, filters []df.Filter) (stream *fileRowStream, err error) {
+	f, err := filesystem.Open(path)
+	if err != nil {
+		return stream, err
+	}
+	closers := []io.Closer{f}
 
-			ds, err := getDataframeFromSource(name, ext, f, config)
-			if err != nil {
-				results <- asyncReaderResult{err: err}
-				break
-			}
-			results <- asyncReaderResult{data: ds}
+	var reader io.Reader = f
+	if compression == "gz" {
+		gzipReader, err := gzip.NewReader(f)
+		if err != nil {
+			closeAll(closers)
+			return stream, err
+		}
+		closers = append(closers, gzipReader)
+		reader = gzipReader
+	} else if compression == "snappy" {
+		reader = snappy.NewReader(f)
+	}
+
+	streamSource, err := formats.GetFormatHandler(ext)
+	if err != nil {
+		closeAll(closers)
+		return stream, err
+	}
+
+	// binary formats need random access on underlying file
+	if !isBinaryFormat(ext) {
+		reader = utfbom.SkipOnly(reader)
+	}
+	var streamReader formats.FormatStreamReader
+	if pushdownSource, ok := streamSource.(formats.PushdownFormatSource); ok {
+		streamReader, err = pushdownSource.PushdownStreamReader(reader, *config, cols, filters)
+	} else {
+		streamReader, err = streamSource.StreamReader(reader, *config)
+	}
+	if err != nil {
+		closeAll(closers)
+		return stream, err
+	}
+	return &fileRowStream{FormatStreamReader: streamReader, closers: closers}, nil
+}
+
+// getLazyDataframeFromSource returns dataframe which reads file everytime its rows are requested, file is opened once to read the schema.
+// for formats which support pushdown, rows are filtered after reading as formats skip only blocks of rows
+func getLazyDataframeFromSource(filesystem vfs.VFS, path string, name string, ext string, compression string, config *map[string]string) (data df.DataFrame, err error) {
+	open := func(cols []int, filters []df.Filter) (df.RowStream, error) {
+		stream, err := openFileStream(filesystem, path, ext, compression, config, cols, filters)
+		if err != nil {
+			return nil, err
 		}
+		return lazy.NewFilterStream(stream, filters), nil
+	}
+
+	stream, err := openFileStream(filesystem, path, ext, compression, config, nil, nil)
+	if err != nil {
+		return data, err
+	}
+	schema := stream.Schema()
+	err = stream.Close()
+	if err != nil {
+		return data, err
 	}
 
+	if isPushdownFormat(ext) {
+		return lazy.NewPushdownDataframe(name, schema, open), nil
+	}
+	return lazy.NewDataframe(name, schema, func() (df.RowStream, error) {
+		return open(nil, nil)
+	}), nil
 }
 
-type asyncReaderResult struct {
-	data df.DataFrame
-	err  error
+func isBinaryFormat(ext string) bool {
+	streamSource, err := formats.GetFormatHandler(ext)
+	if err != nil {
+		return false
+	}
+	switch streamSource.(type) {
+	case *formats.ParquetDataSource, *formats.ArrowDataSource, *formats.AvroDataSource, *formats.OrcDataSource, *formats.XlsxDataSource:
+		return true
+	}
+	return false
 }
 
-func readSourcesToDataframeAsync(filesystem vfs.VFS, aliasName string, sources []string, config *map[string]string) (data df.DataFrame, err error) {
-	// TODO fornow static value and we are not reading data in seprate channel
-	if len(sources) > 200 {
-		return data, errors.New("more than 200 files are not supported")
+func isPushdownFormat(ext string) bool {
+	streamSource, err := formats.GetFormatHandler(ext)
+	if err != nil {
+		return false
 	}
-	jobs := make(chan string, len(sources))
-	results := make(chan asyncReaderResult, len(sources))
-	wg := new(sync.WaitGroup)
+	_, ok := streamSource.(formats.PushdownFormatSource)
+	return ok
+}
 
-	for w := 0; w < len(sources); w++ {
-		wg.Add(1)
-		go readSourceToDataframeAsyncWorker(jobs, results, wg, config, filesystem)
+// getZipDataframesFromSource reads all entries of zip file in memory, zip requires whole file to access its entries
+func getZipDataframesFromSource(filesystem vfs.VFS, path string, name string, ext string, config *map[string]string) (data []df.DataFrame, err error) {
+	f, err := filesystem.Open(path)
+	if err != nil {
+		return data, err
+	}
+	defer f.Close()
+	buff := bytes.NewBuffer([]byte{})
+	_, err = io.Copy(buff, f)
+	if err != nil {
+		return data, err
 	}
 
-	for _, f := range sources {
-		jobs <- f
+	zipReader, err := zip.NewReader(bytes.NewReader(buff.Bytes()), int64(buff.Len()))
+	if err != nil {
+		return data, err
 	}
+	for _, f := range zipReader.File {
+		zipFile, err := f.Open()
+		if err != nil {
+			return data, err
+		}
+		defer zipFile.Close()
 
-	close(jobs)
-	wg.Wait()
-	close(results)
-	dfsFiles := make([]df.DataFrame, 0)
-	for ds := range results {
-		if ds.err != nil {
-			return data, ds.err
+		ds, err := getDataframeFromSource(name, ext, zipFile, config)
+		if err != nil {
+			return data, err
 		}
-		dfsFiles = append(dfsFiles, ds.data)
+		data = append(data, ds)
 	}
-	return inmemory.NewMergeDataframe(aliasName, dfsFiles...)
+	return data, err
 }
 
-func readSourcesToDataframeSync(filesystem vfs.VFS, aliasName string, sources []string, config *map[string]string) (data df.DataFrame, err error) {
+func readSourcesToDataframe(filesystem vfs.VFS, aliasName string, sources []string, config *map[string]string) (data df.DataFrame, err error) {
 
 	dfsFiles := make([]df.DataFrame, 0, len(sources))
 	for _, f := range sources {
@@ -404,67 +492,24 @@ func readSourcesToDataframeSync(filesystem vfs.VFS, aliasName string, sources []
 			log.Warnf("unable to detect fileType for (%s), falling back to json", f)
 			ext = "json"
 		}
+		ext, err = csvSniffedFormat(filesystem, f, path, ext, compression, *config)
+		if err != nil {
+			return data, err
+		}
 
-		if compression == "gz" {
-			f, err := filesystem.Open(path)
-			if err != nil {
-				return data, err
-			}
-			defer f.Close()
-			reader, err := gzip.NewReader(f)
-			if err != nil {
-				return data, err
-			}
-
-			ds, err := getDataframeFromSource(name, ext, reader, config)
-			if err != nil {
-				return data, err
-			}
-			dfsFiles = append(dfsFiles, ds)
-
-		} else if compression == "zip" {
-			f, err := filesystem.Open(path)
-			if err != nil {
-				return data, err
-			}
-			defer f.Close()
-			buff := bytes.NewBuffer([]byte{})
-			_, err = io.Copy(buff, f)
-			if err != nil {
-				return data, err
-			}
-
-			zipReader, err := zip.NewReader(bytes.NewReader(buff.Bytes()), int64(buff.Len()))
+		if compression == "zip" {
+			ds, err := getZipDataframesFromSource(filesystem, path, name, ext, config)
 			if err != nil {
 				return data, err
 			}
-			for _, f := range zipReader.File {
-				zipFile, err := f.Open()
-				if err != nil {
-					return data, err
-				}
-				defer zipFile.Close()
-
-				ds, err := getDataframeFromSource(name, ext, zipFile, config)
-				if err != nil {
-					return data, err
-				}
-				dfsFiles = append(dfsFiles, ds)
-
-			}
+			dfsFiles = append(dfsFiles, ds...)
 		} else {
-			f, err := filesystem.Open(path)
-			if err != nil {
-				return data, err
-			}
-			defer f.Close()
-
-			ds, err := getDataframeFromSource(name, ext, f, config)
+			ds, err := getLazyDataframeFromSource(filesystem, path, name, ext, compression, config)
 			if err != nil {
 				return data, err
 			}
 			dfsFiles = append(dfsFiles, ds)
 		}
 	}
-	return inmemory.NewMergeDataframe(aliasName, dfsFiles...)
+	return lazy.NewMergeDataframe(aliasName, dfsFiles...)
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"io"
 	"net/url"
+	"sort"
 	"strings"
 
 	// initialize PG driver
@@ -14,16 +16,21 @@ import (
 	// initialize sqlite3
 	_ "github.com/mattn/go-sqlite3"
 
+	"github.com/samber/lo"
 	"github.com/xo/dburl"
 
 	"github.com/blue4209211/pq/df"
 	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/blue4209211/pq/df/lazy"
 	"github.com/blue4209211/pq/internal/log"
 )
 
 // ConfigDBQuery source format for StdIn/Out
 const ConfigDBQuery = "db.query"
 
+// ConfigDBTable table to read, table can also be given as url fragment (ex - postgresql://host/db#orders) or table query param
+const ConfigDBTable = "db.table"
+
 //DataSource exposes functionality to read/write RDBMS
 type DataSource struct {
 }
@@ -33,10 +40,11 @@ func (t *DataSource) IsSupported(protocol string) bool {
 	return protocol == "mysql" || protocol == "maria" || protocol == "postgres" || protocol == "postgresql" || protocol == "sqlite"
 }
 
-func (t *DataSource) Read(context context.Context, dbURL string, args map[string]string) (data df.DataFrame, err error) {
-	u, err := url.Parse(dbURL)
+// openDB opens connection for given url, query params and fragment (table name) are removed from url
+func openDB(dbURL string) (db *sql.DB, u *url.URL, err error) {
+	u, err = url.Parse(dbURL)
 	if err != nil {
-		return data, err
+		return db, u, err
 	}
 
 	if u.RawQuery != "" {
@@ -53,48 +61,287 @@ func (t *DataSource) Read(context context.Context, dbURL string, args map[string
 		dbURL = dbURL + "&parseTime=true"
 	}
 
-	db, err := dburl.Open(dbURL)
+	db, err = dburl.Open(dbURL)
+	return db, u, err
+}
+
+// getConfig returns config from args, if not available then from url query params
+func getConfig(args map[string]string, u *url.URL, key string) string {
+	value, ok := args[key]
+	if !ok || value == "" {
+		value = u.Query().Get(key)
+	}
+	return value
+}
+
+// getTable returns table from args/url query params, if not available then url fragment is used as table name
+func getTable(args map[string]string, u *url.URL) string {
+	table := getConfig(args, u, ConfigDBTable)
+	if table == "" {
+		table = u.Query().Get("table")
+	}
+	if table == "" {
+		table = u.Fragment
+	}
+	return table
+}
+
+// Read reads rows of db.query or table, query is registered with url fragment as name
+// and table is registered with its name (without schema)
+func (t *DataSource) Read(context context.Context, dbURL string, args map[string]string) (data df.DataFrame, err error) {
+	db, u, err := openDB(dbURL)
 	if err != nil {
 		return nil, err
 	}
-	query, ok := args[ConfigDBQuery]
-	if !ok || query == "" {
-		query = u.Query().Get(ConfigDBQuery)
+	defer db.Close()
+
+	d, err := getDialect(u.Scheme)
+	if err != nil {
+		return data, err
+	}
+
+	// query is used as subquery so that filters and columns from sql engine can be applied on db
+	query := getConfig(args, u, ConfigDBQuery)
+	if query != "" {
+		return readSource(db, &sqlSource{dbURL: dbURL, dialect: d, from: subquery(query)}, u.Fragment)
+	}
+
+	table := getTable(args, u)
+	if table == "" {
+		return data, errors.New(ConfigDBQuery + " or table is required, ex - " + u.Scheme + "://host/db#table")
+	}
+	return readTable(db, dbURL, d, table)
+}
+
+// subquery wraps query so that it can be used in from clause, trailing ; are removed and
+// closing bracket is on new line so that trailing line comments dont comment it out
+func subquery(query string) string {
+	return "(" + strings.TrimRight(query, "; \t\r\n") + "\n) pq_src"
+}
+
+// ReadAll reads all the tables and views of database if query or table is not provided
+func (t *DataSource) ReadAll(context context.Context, dbURL string, args map[string]string) (data []df.DataFrame, err error) {
+	db, u, err := openDB(dbURL)
+	if err != nil {
+		return nil, err
 	}
-	if query == "" {
-		return data, errors.New("db.query Is required")
+	defer db.Close()
+
+	if getConfig(args, u, ConfigDBQuery) != "" || getTable(args, u) != "" {
+		d, err := t.Read(context, dbURL, args)
+		if err != nil {
+			return data, err
+		}
+		return []df.DataFrame{d}, nil
 	}
 
-	schema, records, err := queryInternal(db, query)
+	d, err := getDialect(u.Scheme)
+	if err != nil {
+		return data, err
+	}
 
+	tables, err := listTables(db, d)
 	if err != nil {
 		return data, err
 	}
+	if len(tables) == 0 {
+		return data, errors.New("no tables found in database - " + u.Path)
+	}
 
-	if u.Fragment != "" {
-		return inmemory.NewDataframeFromRowAndName(u.Fragment, schema, &records), nil
+	data = make([]df.DataFrame, len(tables))
+	for i, table := range tables {
+		data[i], err = readTable(db, dbURL, d, table)
+		if err != nil {
+			return data, err
+		}
 	}
-	return inmemory.NewDataframeFromRow(schema, &records), nil
+	return data, nil
+}
 
+func listTables(db *sql.DB, d dialect) (tables []string, err error) {
+	rows, err := db.Query(d.tablesQuery)
+	if err != nil {
+		return tables, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table string
+		err = rows.Scan(&table)
+		if err != nil {
+			return tables, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
 }
 
-func (t *DataSource) Write(context context.Context, data df.DataFrame, path string, args map[string]string) (err error) {
-	return errors.New("Unsupported")
+func readTable(db *sql.DB, dbURL string, d dialect, table string) (data df.DataFrame, err error) {
+	name := table[strings.LastIndex(table, ".")+1:]
+	return readSource(db, &sqlSource{dbURL: dbURL, dialect: d, from: d.quoteTable(table)}, name)
 }
 
-func queryInternal(db *sql.DB, query string) (schema df.DataFrameSchema, data []df.Row, err error) {
-	preparedQuery, err := db.Prepare(query)
+func readSource(db *sql.DB, source *sqlSource, name string) (data df.DataFrame, err error) {
+	schema, err := source.schema(db)
 	if err != nil {
-		return schema, data, err
+		return data, err
 	}
-	defer preparedQuery.Close()
 
-	rows, err := preparedQuery.Query()
+	return lazy.NewPushdownDataframe(name, schema, func(cols []int, filters []df.Filter) (df.RowStream, error) {
+		return source.open(schema, cols, filters)
+	}), nil
+}
+
+// sqlSource reads rows from table or subquery given in from
+type sqlSource struct {
+	dbURL   string
+	dialect dialect
+	from    string
+}
+
+// schema returns columns of source without reading rows
+func (t *sqlSource) schema(db *sql.DB) (schema df.DataFrameSchema, err error) {
+	rows, err := db.Query("SELECT * FROM " + t.from + " WHERE 1 = 0")
 	if err != nil {
-		return
+		return schema, err
 	}
 	defer rows.Close()
 
+	return rowsSchema(rows)
+}
+
+// selectQuery returns query for given columns and filters along with filter values
+func (t *sqlSource) selectQuery(schema df.DataFrameSchema, cols []int, filters []df.Filter) (query string, params []any) {
+	colStr := "*"
+	if len(cols) > 0 && len(cols) < schema.Len() {
+		quoted := make([]string, len(cols))
+		for i, c := range cols {
+			quoted[i] = t.dialect.quote(schema.Get(c).Name)
+		}
+		colStr = strings.Join(quoted, ", ")
+	}
+
+	query = "SELECT " + colStr + " FROM " + t.from
+	conditions := make([]string, 0, len(filters))
+	params = make([]any, 0, len(filters))
+	for _, f := range filters {
+		col := t.dialect.quote(schema.Get(f.Column).Name)
+		switch f.Op {
+		case "isnull":
+			conditions = append(conditions, col+" IS NULL")
+		case "notnull":
+			conditions = append(conditions, col+" IS NOT NULL")
+		default:
+			params = append(params, f.Value)
+			conditions = append(conditions, col+" "+f.Op+" "+t.dialect.placeholder(len(params)))
+		}
+	}
+	if len(conditions) > 0 {
+		query = query + " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return query, params
+}
+
+func (t *sqlSource) open(schema df.DataFrameSchema, cols []int, filters []df.Filter) (stream df.RowStream, err error) {
+	// duplicate column names can not be selected by name
+	if len(cols) > 0 && len(schema.Names()) != len(lo.Uniq(schema.Names())) {
+		cols = nil
+	}
+	if len(cols) == 0 || len(cols) == schema.Len() {
+		cols = make([]int, schema.Len())
+		for i := range cols {
+			cols[i] = i
+		}
+	}
+
+	// string comparisons of database depend on collation (ex - case insensitive by default in mysql), only = is sent
+	// to database as it returns superset of matching rows, string filters are evaluated again on rows
+	dbFilters := make([]df.Filter, 0, len(filters))
+	rowFilters := make([]df.Filter, 0)
+	for _, f := range filters {
+		if schema.Get(f.Column).Format != df.StringFormat || f.Op == "isnull" || f.Op == "notnull" {
+			dbFilters = append(dbFilters, f)
+			continue
+		}
+		if f.Op == "=" {
+			dbFilters = append(dbFilters, f)
+		}
+		rowFilters = append(rowFilters, f)
+		if !lo.Contains(cols, f.Column) {
+			cols = append(cols, f.Column)
+			// all the columns are selected using * which returns columns in order of schema
+			sort.Ints(cols)
+		}
+	}
+
+	query, params := t.selectQuery(schema, cols, dbFilters)
+	log.Debug("db query - ", query, params)
+
+	db, _, err := openDB(t.dbURL)
+	if err != nil {
+		return stream, err
+	}
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		db.Close()
+		return stream, err
+	}
+	return lazy.NewFilterStream(&sqlRowStream{db: db, rows: rows, schema: schema, cols: cols}, rowFilters), nil
+}
+
+// sqlRowStream converts sql rows to dataframe rows, values of columns which are not selected are nil
+type sqlRowStream struct {
+	db     *sql.DB
+	rows   *sql.Rows
+	schema df.DataFrameSchema
+	cols   []int
+}
+
+func (t *sqlRowStream) Next() (r df.Row, err error) {
+	if !t.rows.Next() {
+		err = t.rows.Err()
+		if err == nil {
+			err = io.EOF
+		}
+		return r, err
+	}
+
+	dataRowPtrs := make([]any, len(t.cols))
+	for i := range dataRowPtrs {
+		var dataCell any
+		dataRowPtrs[i] = &dataCell
+	}
+	err = t.rows.Scan(dataRowPtrs...)
+	if err != nil {
+		return r, err
+	}
+
+	dataRow := make([]df.Value, t.schema.Len())
+	for i, c := range t.schema.Series() {
+		dataRow[i] = inmemory.NewValue(c.Format, nil)
+	}
+	for i, cellPtr := range dataRowPtrs {
+		format := t.schema.Get(t.cols[i]).Format
+		cell := *(cellPtr.(*any))
+		// drivers return text/numeric columns as bytes
+		if b, ok := cell.([]byte); ok && format != df.BoolFormat {
+			cell = string(b)
+		}
+		v, err := format.Convert(cell)
+		if err != nil {
+			return r, err
+		}
+		dataRow[t.cols[i]] = inmemory.NewValue(format, v)
+	}
+	return inmemory.NewRow(&t.schema, &dataRow), nil
+}
+
+func (t *sqlRowStream) Close() error {
+	t.rows.Close()
+	return t.db.Close()
+}
+
+func rowsSchema(rows *sql.Rows) (schema df.DataFrameSchema, err error) {
 	sqlCols, err := rows.Columns()
 	if err != nil {
 		return
@@ -107,47 +354,35 @@ func queryInternal(db *sql.DB, query string) (schema df.DataFrameSchema, data []
 	cols := make([]df.SeriesSchema, len(sqlCols))
 
 	for i, c := range sqlCols {
-		dfFormat, err := df.GetFormat(sqlColTypes[i].DatabaseTypeName())
-		if err != nil {
-			log.Debugf("sql format error for - %s, %s, %s", c, sqlColTypes[i].DatabaseTypeName(), err)
-			dfFormat, err = df.GetFormat("string")
-			if err != nil {
-				return schema, data, err
-			}
-
-		}
-		cols[i] = df.SeriesSchema{Name: c, Format: dfFormat}
+		cols[i] = df.SeriesSchema{Name: c, Format: dbFormat(sqlColTypes[i].DatabaseTypeName())}
 	}
 
-	schema = df.NewSchema(cols)
-	data = make([]df.Row, 0, 100)
-
-	for rows.Next() {
-		dataRowPtrs := make([]any, len(sqlCols))
-		for i := range dataRowPtrs {
-			var dataCell any
-			dataRowPtrs[i] = &dataCell
-		}
-		err = rows.Scan(dataRowPtrs...)
-		if err != nil {
-			return schema, data, err
-		}
+	return df.NewSchema(cols), nil
+}
 
-		dataRow := make([]df.Value, len(sqlCols))
-		for i, cellPtr := range dataRowPtrs {
-			v, err := cols[i].Format.Convert(*(cellPtr.(*any)))
-			if err != nil {
-				return schema, data, err
-			}
-			dataRow[i] = inmemory.NewValue(cols[i].Format, v)
-		}
+// dbFormat returns format for database column type, unknown types are read as string
+func dbFormat(dbType string) df.Format {
+	dbType = strings.ToUpper(dbType)
+	if i := strings.Index(dbType, "("); i > 0 {
+		dbType = strings.TrimSpace(dbType[:i])
+	}
+	dbType = strings.TrimPrefix(dbType, "UNSIGNED ")
 
-		data = append(data, inmemory.NewRow(&schema, &dataRow))
+	switch dbType {
+	case "BOOL", "BOOLEAN":
+		return df.BoolFormat
+	case "REAL", "FLOAT", "FLOAT4", "FLOAT8", "DOUBLE", "DOUBLE PRECISION", "NUMERIC", "DECIMAL", "MONEY":
+		return df.DoubleFormat
+	case "DATE", "DATETIME", "TIMESTAMP", "TIMESTAMPTZ":
+		return df.DateTimeFormat
+	case "INT", "INTEGER", "TINYINT", "SMALLINT", "MEDIUMINT", "BIGINT", "INT2", "INT4", "INT8", "SMALLSERIAL", "SERIAL", "BIGSERIAL":
+		return df.IntegerFormat
 	}
 
-	err = rows.Err()
+	format, err := df.GetFormat(dbType)
 	if err != nil {
-		return schema, data, err
+		log.Debugf("sql format error for - %s, %s", dbType, err)
+		return df.StringFormat
 	}
-	return schema, data, nil
+	return format
 }
@@ -3,11 +3,14 @@ package rdbms
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"testing"
 
 	"database/sql"
 
+	"github.com/blue4209211/pq/df"
+
 	// initialize sqlite3
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
@@ -42,4 +45,164 @@ func TestRdbmsReader(t *testing.T) {
 	assert.Equal(t, "string", data.Schema().Get(0).Format.Name())
 	assert.Equal(t, int64(1), data.Len())
 
+	// query ending with ;
+	data, err = source.Read(context.TODO(), fmt.Sprintf("sqlite:%s#t", tempfile.Name()), map[string]string{ConfigDBQuery: "select * from t_123;"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), data.Len())
+
+}
+
+func TestRdbmsReaderPushdown(t *testing.T) {
+	tempfile, err := os.CreateTemp("", "test*.sql")
+	assert.NoError(t, err)
+	defer os.Remove(tempfile.Name())
+	tempfile.Close()
+
+	db, err := sql.Open("sqlite3", tempfile.Name())
+	assert.NoError(t, err)
+	_, err = db.Exec("create table t1 (a text, b integer, c double)")
+	assert.NoError(t, err)
+	_, err = db.Exec("insert into t1 values('x', 1, 1.5), ('y', 2, null), ('z', 3, 3.5)")
+	assert.NoError(t, err)
+	db.Close()
+
+	source := DataSource{}
+	data, err := source.Read(context.TODO(), fmt.Sprintf("sqlite:%s?db.query=select * from t1#t", tempfile.Name()), map[string]string{})
+	assert.NoError(t, err)
+
+	pushdownData, ok := data.(df.PushdownDataFrame)
+	assert.True(t, ok)
+
+	stream, err := pushdownData.StreamWith([]int{0}, []df.Filter{{Column: 1, Op: ">=", Value: int64(2)}, {Column: 2, Op: "notnull"}})
+	assert.NoError(t, err)
+	defer stream.Close()
+
+	r, err := stream.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "z", r.GetRaw(0))
+	assert.True(t, r.IsNil(1))
+	_, err = stream.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestRdbmsReaderPushdownCollation(t *testing.T) {
+	tempfile, err := os.CreateTemp("", "test*.sql")
+	assert.NoError(t, err)
+	defer os.Remove(tempfile.Name())
+	tempfile.Close()
+
+	// nocase column behaves like case insensitive collation of mysql
+	db, err := sql.Open("sqlite3", tempfile.Name())
+	assert.NoError(t, err)
+	_, err = db.Exec("create table t1 (a text collate nocase, b integer)")
+	assert.NoError(t, err)
+	_, err = db.Exec("insert into t1 values('abc', 1), ('ABC', 2), ('b', 3)")
+	assert.NoError(t, err)
+	db.Close()
+
+	source := DataSource{}
+	data, err := source.Read(context.TODO(), fmt.Sprintf("sqlite:%s#t", tempfile.Name()), map[string]string{ConfigDBTable: "t1"})
+	assert.NoError(t, err)
+	pushdownData := data.(df.PushdownDataFrame)
+
+	// string comparisons match file sources (case sensitive), filter columns are read even when not selected
+	for _, c := range []struct {
+		filter   df.Filter
+		expected []any
+	}{
+		{df.Filter{Column: 0, Op: "=", Value: "abc"}, []any{int64(1)}},
+		{df.Filter{Column: 0, Op: "<>", Value: "abc"}, []any{int64(2), int64(3)}},
+		{df.Filter{Column: 0, Op: ">", Value: "B"}, []any{int64(1), int64(3)}},
+	} {
+		stream, err := pushdownData.StreamWith([]int{1}, []df.Filter{c.filter})
+		assert.NoError(t, err)
+		values := []any{}
+		for {
+			r, err := stream.Next()
+			if err != nil {
+				assert.Equal(t, io.EOF, err)
+				break
+			}
+			values = append(values, r.GetRaw(1))
+		}
+		assert.NoError(t, stream.Close())
+		assert.Equal(t, c.expected, values, c.filter)
+	}
+}
+
+func TestRdbmsSelectQuery(t *testing.T) {
+	schema := df.NewSchema([]df.SeriesSchema{
+		{Name: "a", Format: df.StringFormat},
+		{Name: "b", Format: df.IntegerFormat},
+	})
+
+	source := sqlSource{dialect: postgresDialect, from: "t"}
+	query, params := source.selectQuery(schema, []int{1}, []df.Filter{{Column: 0, Op: "=", Value: "x"}, {Column: 1, Op: "isnull"}})
+	assert.Equal(t, `SELECT "b" FROM t WHERE "a" = $1 AND "b" IS NULL`, query)
+	assert.Equal(t, []any{"x"}, params)
+
+	query, params = source.selectQuery(schema, nil, nil)
+	assert.Equal(t, `SELECT * FROM t`, query)
+	assert.Empty(t, params)
+}
+
+func TestRdbmsSubquery(t *testing.T) {
+	assert.Equal(t, "(select * from t\n) pq_src", subquery("select * from t"))
+	assert.Equal(t, "(select * from t\n) pq_src", subquery("select * from t ; \n"))
+	assert.Equal(t, "(select * from t -- all rows\n) pq_src", subquery("select * from t -- all rows"))
+}
+
+func TestRdbmsReaderTables(t *testing.T) {
+	tempfile, err := os.CreateTemp("", "test*.sql")
+	assert.NoError(t, err)
+	defer os.Remove(tempfile.Name())
+	tempfile.Close()
+
+	db, err := sql.Open("sqlite3", tempfile.Name())
+	assert.NoError(t, err)
+	_, err = db.Exec("create table orders (id bigint, amount decimal(10, 2), created timestamp, paid boolean, note varchar(10))")
+	assert.NoError(t, err)
+	_, err = db.Exec("insert into orders values(1, 10.5, '2022-01-02 03:04:05', true, 'n1')")
+	assert.NoError(t, err)
+	_, err = db.Exec("create view paid_orders as select id from orders where paid")
+	assert.NoError(t, err)
+	db.Close()
+
+	source := DataSource{}
+
+	// table from fragment
+	data, err := source.Read(context.TODO(), fmt.Sprintf("sqlite:%s#orders", tempfile.Name()), map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, "orders", data.Name())
+	assert.Equal(t, []df.Format{df.IntegerFormat, df.DoubleFormat, df.DateTimeFormat, df.BoolFormat, df.StringFormat}, []df.Format{
+		data.Schema().Get(0).Format, data.Schema().Get(1).Format, data.Schema().Get(2).Format, data.Schema().Get(3).Format, data.Schema().Get(4).Format,
+	})
+	assert.Equal(t, int64(1), data.Len())
+	assert.Equal(t, 10.5, data.GetRow(0).GetRaw(1))
+
+	// table from query param
+	data, err = source.Read(context.TODO(), fmt.Sprintf("sqlite:%s?table=paid_orders", tempfile.Name()), map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, "paid_orders", data.Name())
+	assert.Equal(t, int64(1), data.Len())
+
+	// all tables
+	dfs, err := source.ReadAll(context.TODO(), fmt.Sprintf("sqlite:%s", tempfile.Name()), map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(dfs))
+	assert.Equal(t, "orders", dfs[0].Name())
+	assert.Equal(t, "paid_orders", dfs[1].Name())
+
+	_, err = source.Read(context.TODO(), fmt.Sprintf("sqlite:%s", tempfile.Name()), map[string]string{})
+	assert.ErrorContains(t, err, "table is required")
+}
+
+func TestRdbmsFormat(t *testing.T) {
+	assert.Equal(t, df.IntegerFormat, dbFormat("INT4"))
+	assert.Equal(t, df.IntegerFormat, dbFormat("UNSIGNED BIGINT"))
+	assert.Equal(t, df.DoubleFormat, dbFormat("numeric(10,2)"))
+	assert.Equal(t, df.DateTimeFormat, dbFormat("TIMESTAMPTZ"))
+	assert.Equal(t, df.BoolFormat, dbFormat("BOOL"))
+	assert.Equal(t, df.StringFormat, dbFormat("POINT"))
+	assert.Equal(t, df.StringFormat, dbFormat(""))
 }
@@ -0,0 +1,432 @@
+package rdbms
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/lazy"
+	"github.com/blue4209211/pq/internal/log"
+)
+
+// ConfigDBMode write mode for table - append(default), overwrite, upsert
+const ConfigDBMode = "db.mode"
+
+// ConfigDBUpsertKeys Comma separated columns used to match existing rows in upsert mode
+const ConfigDBUpsertKeys = "db.upsertKeys"
+
+const (
+	// ModeAppend rows are inserted in existing table
+	ModeAppend = "append"
+	// ModeOverwrite existing table is dropped and recreated
+	ModeOverwrite = "overwrite"
+	// ModeUpsert rows with same upsert keys are updated, others are inserted
+	ModeUpsert = "upsert"
+)
+
+// maximum number of rows and bind params in single insert statement
+const (
+	writeBatchSize = 1000
+	writeMaxParams = 30000
+)
+
+// dialect handles differences in ddl/dml across databases
+type dialect struct {
+	name string
+	// types maps df format to column type
+	types map[df.Format]string
+	// keyType used for string columns which are part of primary key (mysql doesnt support text keys)
+	keyType string
+	// tablesQuery lists tables and views of current schema
+	tablesQuery string
+	// uniqueKeysQuery lists (index, column) of primary key and unique indexes of table, params are schema (empty for current schema) and table,
+	// used to check that upsert keys match a unique constraint
+	uniqueKeysQuery string
+}
+
+var postgresDialect = dialect{
+	name: "postgres",
+	types: map[df.Format]string{
+		df.StringFormat:   "TEXT",
+		df.IntegerFormat:  "BIGINT",
+		df.DoubleFormat:   "DOUBLE PRECISION",
+		df.BoolFormat:     "BOOLEAN",
+		df.DateTimeFormat: "TIMESTAMP",
+	},
+	keyType:     "TEXT",
+	tablesQuery: "SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() ORDER BY table_name",
+	uniqueKeysQuery: "SELECT ic.relname, a.attname FROM pg_index i JOIN pg_class c ON c.oid = i.indrelid JOIN pg_class ic ON ic.oid = i.indexrelid " +
+		"JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum = ANY(i.indkey) " +
+		"JOIN pg_namespace n ON n.oid = c.relnamespace " +
+		"WHERE n.nspname = COALESCE(NULLIF($1, ''), current_schema()) AND c.relname = $2 AND i.indisunique AND i.indpred IS NULL",
+}
+
+var mysqlDialect = dialect{
+	name: "mysql",
+	types: map[df.Format]string{
+		df.StringFormat:   "TEXT",
+		df.IntegerFormat:  "BIGINT",
+		df.DoubleFormat:   "DOUBLE",
+		df.BoolFormat:     "BOOLEAN",
+		df.DateTimeFormat: "DATETIME",
+	},
+	keyType:         "VARCHAR(255)",
+	tablesQuery:     "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() ORDER BY table_name",
+	uniqueKeysQuery: "SELECT index_name, column_name FROM information_schema.statistics WHERE table_schema = COALESCE(NULLIF(?, ''), DATABASE()) AND table_name = ? AND non_unique = 0",
+}
+
+var sqliteDialect = dialect{
+	name: "sqlite",
+	types: map[df.Format]string{
+		df.StringFormat:   "TEXT",
+		df.IntegerFormat:  "INTEGER",
+		df.DoubleFormat:   "DOUBLE",
+		df.BoolFormat:     "BOOLEAN",
+		df.DateTimeFormat: "DATETIME",
+	},
+	keyType:     "TEXT",
+	tablesQuery: "SELECT name FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%' ORDER BY name",
+	// integer primary key is alias of rowid and is not listed in indexes
+	uniqueKeysQuery: "SELECT il.name, ii.name FROM pragma_index_list(?2, NULLIF(?1, '')) il JOIN pragma_index_info(il.name, NULLIF(?1, '')) ii WHERE il.\"unique\" = 1 " +
+		"UNION ALL SELECT 'pk', name FROM pragma_table_info(?2, NULLIF(?1, '')) WHERE pk > 0",
+}
+
+func getDialect(protocol string) (d dialect, err error) {
+	switch protocol {
+	case "postgres", "postgresql":
+		return postgresDialect, nil
+	case "mysql", "maria":
+		return mysqlDialect, nil
+	case "sqlite":
+		return sqliteDialect, nil
+	}
+	return d, errors.New("unsupported db - " + protocol)
+}
+
+func (t dialect) quote(name string) string {
+	if t.name == "mysql" {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteTable quotes table name, schema qualified names (ex - public.orders) are quoted per part
+func (t dialect) quoteTable(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = t.quote(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+// splitTable splits schema qualified table name into schema and table, schema is empty for unqualified names
+func splitTable(name string) (schema string, table string) {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return "", name
+	}
+	return name[:i], name[i+1:]
+}
+
+func (t dialect) placeholder(i int) string {
+	if t.name == "postgres" {
+		return "$" + strconv.Itoa(i)
+	}
+	return "?"
+}
+
+func (t dialect) createTable(table string, schema df.DataFrameSchema, keys []string) string {
+	cols := make([]string, schema.Len())
+	for i, c := range schema.Series() {
+		colType := t.types[c.Format]
+		if colType == "" {
+			colType = t.types[df.StringFormat]
+		}
+		if c.Format == df.StringFormat && containsName(keys, c.Name) {
+			colType = t.keyType
+		}
+		cols[i] = t.quote(c.Name) + " " + colType
+	}
+	if len(keys) > 0 {
+		quotedKeys := make([]string, len(keys))
+		for i, k := range keys {
+			quotedKeys[i] = t.quote(k)
+		}
+		cols = append(cols, "PRIMARY KEY ("+strings.Join(quotedKeys, ", ")+")")
+	}
+	return "CREATE TABLE IF NOT EXISTS " + t.quoteTable(table) + " (" + strings.Join(cols, ", ") + ")"
+}
+
+// insert returns insert statement for given number of rows, upsert clause is added if keys are provided
+func (t dialect) insert(table string, schema df.DataFrameSchema, rows int, keys []string) string {
+	cols := make([]string, schema.Len())
+	for i, c := range schema.Series() {
+		cols[i] = t.quote(c.Name)
+	}
+
+	values := make([]string, rows)
+	param := 1
+	for r := 0; r < rows; r++ {
+		placeholders := make([]string, schema.Len())
+		for i := range placeholders {
+			placeholders[i] = t.placeholder(param)
+			param = param + 1
+		}
+		values[r] = "(" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	stmt := "INSERT INTO " + t.quoteTable(table) + " (" + strings.Join(cols, ", ") + ") VALUES " + strings.Join(values, ", ")
+	if len(keys) == 0 {
+		return stmt
+	}
+
+	updates := make([]string, 0, schema.Len())
+	for _, c := range schema.Series() {
+		if containsName(keys, c.Name) {
+			continue
+		}
+		if t.name == "mysql" {
+			updates = append(updates, t.quote(c.Name)+" = VALUES("+t.quote(c.Name)+")")
+		} else {
+			updates = append(updates, t.quote(c.Name)+" = excluded."+t.quote(c.Name))
+		}
+	}
+
+	if t.name == "mysql" {
+		if len(updates) == 0 {
+			updates = append(updates, t.quote(keys[0])+" = "+t.quote(keys[0]))
+		}
+		return stmt + " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+	}
+
+	quotedKeys := make([]string, len(keys))
+	for i, k := range keys {
+		quotedKeys[i] = t.quote(k)
+	}
+	if len(updates) == 0 {
+		return stmt + " ON CONFLICT (" + strings.Join(quotedKeys, ", ") + ") DO NOTHING"
+	}
+	return stmt + " ON CONFLICT (" + strings.Join(quotedKeys, ", ") + ") DO UPDATE SET " + strings.Join(updates, ", ")
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasUniqueKey returns true if table has primary key or unique index on exactly the given columns,
+// current schema is used if schema is empty
+func hasUniqueKey(ctx context.Context, tx *sql.Tx, d dialect, schema string, table string, keys []string) (bool, error) {
+	rows, err := tx.QueryContext(ctx, d.uniqueKeysQuery, schema, table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	indexes := map[string][]string{}
+	for rows.Next() {
+		var index, column string
+		err = rows.Scan(&index, &column)
+		if err != nil {
+			return false, err
+		}
+		indexes[index] = append(indexes[index], column)
+	}
+	if err = rows.Err(); err != nil {
+		return false, err
+	}
+
+	for _, columns := range indexes {
+		if len(columns) != len(keys) {
+			continue
+		}
+		matched := true
+		for _, c := range columns {
+			matched = matched && containsName(keys, c)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Write writes dataframe to table given as url fragment (ex - postgresql://host/db#table),
+// table is created if it doesnt exist and rows are inserted in batches in single transaction.
+// mysql commits ddl (drop/create table) implicitly, so overwrite and table creation are not rolled back on errors
+func (t *DataSource) Write(ctx context.Context, data df.DataFrame, path string, args map[string]string) (err error) {
+	db, u, err := openDB(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	table := u.Fragment
+	if table == "" {
+		return errors.New("table name is required, ex - " + u.Scheme + "://host/db#table")
+	}
+
+	d, err := getDialect(u.Scheme)
+	if err != nil {
+		return err
+	}
+
+	mode := strings.ToLower(getConfig(args, u, ConfigDBMode))
+	if mode == "" {
+		mode = ModeAppend
+	}
+	if mode != ModeAppend && mode != ModeOverwrite && mode != ModeUpsert {
+		return errors.New("unsupported " + ConfigDBMode + " - " + mode)
+	}
+
+	schema := data.Schema()
+	if schema.Len() == 0 {
+		return errors.New("no columns to write")
+	}
+
+	keys := make([]string, 0)
+	if mode == ModeUpsert {
+		for _, k := range strings.Split(getConfig(args, u, ConfigDBUpsertKeys), ",") {
+			k = strings.TrimSpace(k)
+			if k == "" {
+				continue
+			}
+			if !schema.HasName(k) {
+				return errors.New("upsert key not found in data - " + k)
+			}
+			keys = append(keys, k)
+		}
+		if len(keys) == 0 {
+			return errors.New(ConfigDBUpsertKeys + " is required for upsert mode")
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if mode == ModeOverwrite {
+		_, err = tx.ExecContext(ctx, "DROP TABLE IF EXISTS "+d.quoteTable(table))
+		if err != nil {
+			return err
+		}
+	}
+
+	createStmt := d.createTable(table, schema, keys)
+	log.Debug("create table - ", createStmt)
+	_, err = tx.ExecContext(ctx, createStmt)
+	if err != nil {
+		return err
+	}
+
+	// existing table can be created without constraint on upsert keys
+	if mode == ModeUpsert {
+		var ok bool
+		tableSchema, tableName := splitTable(table)
+		ok, err = hasUniqueKey(ctx, tx, d, tableSchema, tableName, keys)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			err = errors.New("upsert keys (" + strings.Join(keys, ", ") + ") should be primary key or unique index of table " + table)
+			return err
+		}
+	}
+
+	count, err := insertRows(ctx, tx, d, table, data, keys)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err == nil {
+		log.Debugf("written %d rows to %s", count, table)
+	}
+	return err
+}
+
+// insertRows inserts rows in batches, number of rows in batch is limited by max bind params
+func insertRows(ctx context.Context, tx *sql.Tx, d dialect, table string, data df.DataFrame, keys []string) (count int, err error) {
+	schema := data.Schema()
+	batchSize := writeBatchSize
+	if batchSize*schema.Len() > writeMaxParams {
+		batchSize = writeMaxParams / schema.Len()
+	}
+
+	stream, err := lazy.NewRowStream(data)
+	if err != nil {
+		return count, err
+	}
+	defer stream.Close()
+
+	var stmt *sql.Stmt
+	defer func() {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}()
+
+	params := make([]any, 0, batchSize*schema.Len())
+	rows := 0
+	flush := func() error {
+		if rows == 0 {
+			return nil
+		}
+		var err error
+		if rows == batchSize {
+			if stmt == nil {
+				stmt, err = tx.PrepareContext(ctx, d.insert(table, schema, rows, keys))
+				if err != nil {
+					return err
+				}
+			}
+			_, err = stmt.ExecContext(ctx, params...)
+		} else {
+			_, err = tx.ExecContext(ctx, d.insert(table, schema, rows, keys), params...)
+		}
+		count = count + rows
+		params = params[:0]
+		rows = 0
+		return err
+	}
+
+	for {
+		r, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+		for i := 0; i < schema.Len(); i++ {
+			v := r.Get(i)
+			if v == nil || v.IsNil() {
+				params = append(params, nil)
+			} else {
+				params = append(params, v.Get())
+			}
+		}
+		rows = rows + 1
+		if rows == batchSize {
+			err = flush()
+			if err != nil {
+				return count, err
+			}
+		}
+	}
+	return count, flush()
+}
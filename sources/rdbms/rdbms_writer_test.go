@@ -0,0 +1,166 @@
+package rdbms
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/blue4209211/pq/df"
+	"github.com/blue4209211/pq/df/inmemory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRdbmsWriter(t *testing.T) {
+	tempfile, err := os.CreateTemp("", "test*.sql")
+	assert.NoError(t, err)
+	defer os.Remove(tempfile.Name())
+	tempfile.Close()
+
+	ts := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	data := inmemory.NewDataframeWithNameFromSeries("df1", []string{"id", "name", "price", "ok", "ts"}, &[]df.Series{
+		inmemory.NewIntSeriesVarArg(1, 2, 3),
+		inmemory.NewStringSeriesVarArg("a", "b", "c"),
+		inmemory.NewDoubleSeriesVarArg(1.5, 2.5, 3.5),
+		inmemory.NewBoolSeriesVarArg(true, false, true),
+		inmemory.NewDatetimeSeriesVarArg(ts, ts, ts),
+	})
+
+	source := DataSource{}
+	tableURL := fmt.Sprintf("sqlite:%s#t1", tempfile.Name())
+
+	// table is created
+	err = source.Write(context.TODO(), data, tableURL, map[string]string{})
+	assert.NoError(t, err)
+
+	read := func() df.DataFrame {
+		d, err := source.Read(context.TODO(), fmt.Sprintf("sqlite:%s?db.query=select * from t1 order by id", tempfile.Name()), map[string]string{})
+		assert.NoError(t, err)
+		return d
+	}
+	d := read()
+	assert.Equal(t, int64(3), d.Len())
+	assert.Equal(t, df.IntegerFormat, d.Schema().Get(0).Format)
+	assert.Equal(t, df.DoubleFormat, d.Schema().Get(2).Format)
+	assert.Equal(t, df.DateTimeFormat, d.Schema().Get(4).Format)
+	assert.Equal(t, ts, d.GetRow(0).GetRaw(4))
+
+	// append
+	err = source.Write(context.TODO(), data, tableURL, map[string]string{ConfigDBMode: ModeAppend})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), read().Len())
+
+	// overwrite
+	err = source.Write(context.TODO(), data, tableURL, map[string]string{ConfigDBMode: ModeOverwrite})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), read().Len())
+
+	// upsert
+	tableURL = fmt.Sprintf("sqlite:%s?db.mode=upsert&db.upsertKeys=id#t2", tempfile.Name())
+	err = source.Write(context.TODO(), data, tableURL, map[string]string{})
+	assert.NoError(t, err)
+	updated := inmemory.NewDataframeWithNameFromSeries("df2", []string{"id", "name"}, &[]df.Series{
+		inmemory.NewIntSeriesVarArg(3, 4),
+		inmemory.NewStringSeriesVarArg("c1", "d1"),
+	})
+	err = source.Write(context.TODO(), updated, tableURL, map[string]string{})
+	assert.NoError(t, err)
+	d, err = source.Read(context.TODO(), fmt.Sprintf("sqlite:%s?db.query=select id, name, price from t2 order by id", tempfile.Name()), map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), d.Len())
+	assert.Equal(t, "c1", d.GetRow(2).GetRaw(1))
+	assert.Equal(t, 3.5, d.GetRow(2).GetRaw(2))
+	assert.Equal(t, "d1", d.GetRow(3).GetRaw(1))
+
+	// errors
+	err = source.Write(context.TODO(), data, fmt.Sprintf("sqlite:%s", tempfile.Name()), map[string]string{})
+	assert.ErrorContains(t, err, "table name is required")
+	err = source.Write(context.TODO(), data, tableURL, map[string]string{ConfigDBUpsertKeys: "c"})
+	assert.ErrorContains(t, err, "upsert key not found")
+	err = source.Write(context.TODO(), data, tableURL, map[string]string{ConfigDBMode: "merge"})
+	assert.Error(t, err)
+
+	// upsert on table without constraint on keys
+	err = source.Write(context.TODO(), data, fmt.Sprintf("sqlite:%s?db.mode=upsert&db.upsertKeys=name#t1", tempfile.Name()), map[string]string{})
+	assert.ErrorContains(t, err, "upsert keys (name) should be primary key or unique index of table t1")
+	err = source.Write(context.TODO(), data, fmt.Sprintf("sqlite:%s?db.mode=upsert&db.upsertKeys=id,name#t2", tempfile.Name()), map[string]string{})
+	assert.ErrorContains(t, err, "should be primary key or unique index")
+	assert.Equal(t, int64(3), read().Len())
+
+	// unique index created outside of writer
+	db, err := sql.Open("sqlite3", tempfile.Name())
+	assert.NoError(t, err)
+	_, err = db.Exec("CREATE UNIQUE INDEX t1_name ON t1 (name)")
+	assert.NoError(t, err)
+	db.Close()
+	err = source.Write(context.TODO(), data, fmt.Sprintf("sqlite:%s?db.mode=upsert&db.upsertKeys=name#t1", tempfile.Name()), map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), read().Len())
+}
+
+func TestRdbmsWriterSchemaTable(t *testing.T) {
+	tempfile, err := os.CreateTemp("", "test*.sql")
+	assert.NoError(t, err)
+	defer os.Remove(tempfile.Name())
+	tempfile.Close()
+
+	data := inmemory.NewDataframeWithNameFromSeries("df1", []string{"id", "name"}, &[]df.Series{
+		inmemory.NewIntSeriesVarArg(1, 2, 3),
+		inmemory.NewStringSeriesVarArg("a", "b", "c"),
+	})
+	source := DataSource{}
+	read := func() df.DataFrame {
+		d, err := source.Read(context.TODO(), fmt.Sprintf("sqlite:%s#main.t1", tempfile.Name()), map[string]string{})
+		assert.NoError(t, err)
+		return d
+	}
+
+	// table is created in schema, not with dotted name
+	err = source.Write(context.TODO(), data, fmt.Sprintf("sqlite:%s#main.t1", tempfile.Name()), map[string]string{})
+	assert.NoError(t, err)
+	err = source.Write(context.TODO(), data, fmt.Sprintf("sqlite:%s#main.t1", tempfile.Name()), map[string]string{ConfigDBMode: ModeAppend})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), read().Len())
+	err = source.Write(context.TODO(), data, fmt.Sprintf("sqlite:%s#main.t1", tempfile.Name()), map[string]string{ConfigDBMode: ModeOverwrite})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), read().Len())
+	d, err := source.Read(context.TODO(), fmt.Sprintf("sqlite:%s?db.query=select name from sqlite_master where type = 'table'#tables", tempfile.Name()), map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), d.Len())
+	assert.Equal(t, "t1", d.GetRow(0).GetRaw(0))
+
+	// upsert keys are matched with indexes of table in schema
+	tableURL := fmt.Sprintf("sqlite:%s?db.mode=upsert&db.upsertKeys=id#main.t2", tempfile.Name())
+	err = source.Write(context.TODO(), data, tableURL, map[string]string{})
+	assert.NoError(t, err)
+	updated := inmemory.NewDataframeWithNameFromSeries("df2", []string{"id", "name"}, &[]df.Series{
+		inmemory.NewIntSeriesVarArg(3, 4),
+		inmemory.NewStringSeriesVarArg("c1", "d1"),
+	})
+	err = source.Write(context.TODO(), updated, tableURL, map[string]string{})
+	assert.NoError(t, err)
+	d, err = source.Read(context.TODO(), fmt.Sprintf("sqlite:%s?db.query=select id, name from main.t2 order by id#t2", tempfile.Name()), map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), d.Len())
+	assert.Equal(t, "c1", d.GetRow(2).GetRaw(1))
+	err = source.Write(context.TODO(), data, fmt.Sprintf("sqlite:%s?db.mode=upsert&db.upsertKeys=name#main.t1", tempfile.Name()), map[string]string{})
+	assert.ErrorContains(t, err, "should be primary key or unique index of table main.t1")
+	err = source.Write(context.TODO(), data, fmt.Sprintf("sqlite:%s?db.mode=upsert&db.upsertKeys=id#other.t2", tempfile.Name()), map[string]string{})
+	assert.ErrorContains(t, err, "other")
+}
+
+func TestRdbmsDialect(t *testing.T) {
+	schema := df.NewSchema([]df.SeriesSchema{
+		{Name: "id", Format: df.StringFormat},
+		{Name: "v", Format: df.DoubleFormat},
+	})
+
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `t` (`id` VARCHAR(255), `v` DOUBLE, PRIMARY KEY (`id`))", mysqlDialect.createTable("t", schema, []string{"id"}))
+	assert.Equal(t, "INSERT INTO `t` (`id`, `v`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `v` = VALUES(`v`)", mysqlDialect.insert("t", schema, 1, []string{"id"}))
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "t" ("id" TEXT, "v" DOUBLE PRECISION)`, postgresDialect.createTable("t", schema, []string{}))
+	assert.Equal(t, `INSERT INTO "t" ("id", "v") VALUES ($1, $2), ($3, $4) ON CONFLICT ("id") DO UPDATE SET "v" = excluded."v"`, postgresDialect.insert("t", schema, 2, []string{"id"}))
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "public"."t" ("id" TEXT, "v" DOUBLE PRECISION)`, postgresDialect.createTable("public.t", schema, []string{}))
+	assert.Equal(t, "INSERT INTO `db`.`t` (`id`, `v`) VALUES (?, ?)", mysqlDialect.insert("db.t", schema, 1, []string{}))
+}
@@ -25,7 +25,7 @@ func writeDataFrame(data df.DataFrame, src string, config map[string]string) (er
 
 // ReadDataFrames on given files or directories
 func readDataFrames(config map[string]string, sourceUrls ...string) (data []df.DataFrame, err error) {
-	dfs := make([]df.DataFrame, len(sourceUrls))
+	dfs := make([][]df.DataFrame, len(sourceUrls))
 	ers := make([]error, len(sourceUrls))
 
 	var wg sync.WaitGroup
@@ -40,12 +40,17 @@ func readDataFrames(config map[string]string, sourceUrls ...string) (data []df.D
 				return
 			}
 
+			if multiSource, ok := dfSource.(df.MultiDataFrameSource); ok {
+				dfs[idx], ers[idx] = multiSource.ReadAll(context.Background(), sourceURL, config)
+				return
+			}
+
 			mergedDf, err := dfSource.Read(context.Background(), sourceURL, config)
 			if err != nil {
 				ers[idx] = err
 				return
 			}
-			dfs[idx] = mergedDf
+			dfs[idx] = []df.DataFrame{mergedDf}
 		}(i, sourceURL)
 	}
 	wg.Wait()
@@ -56,7 +61,10 @@ func readDataFrames(config map[string]string, sourceUrls ...string) (data []df.D
 		}
 	}
 
-	return dfs, nil
+	for _, d := range dfs {
+		data = append(data, d...)
+	}
+	return data, nil
 }
 
 var sources = []df.DataFrameSource{
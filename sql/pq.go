@@ -19,3 +19,12 @@ func QuerySources(query string, config map[string]string, srcs ...string) (data
 func QueryDataFrames(query string, config map[string]string, dfs ...df.DataFrame) (data df.DataFrame, err error) {
 	return engine.QueryDataFrames(query, dfs, config)
 }
+
+// NewSession reads given sources once and registers them in engine, session can be used to execute multiple queries
+func NewSession(config map[string]string, srcs ...string) (session *engine.Session, err error) {
+	dfs, err := sources.ReadSources(config, srcs...)
+	if err != nil {
+		return session, err
+	}
+	return engine.NewCachedSession(config, dfs...)
+}
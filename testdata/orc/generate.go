@@ -0,0 +1,404 @@
+//go:build ignore
+
+// generate writes orders_<codec>.orc files following layout of orc java writer - row index streams,
+// column statistics, metadata section, compression chunks of 1kb and stripe writer timezone
+//
+//	go run testdata/orc/generate.go
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	rows      = 1200
+	stripes   = 2
+	blockSize = 1024
+	timezone  = "America/Los_Angeles"
+)
+
+var names = []string{"alpha", "beta", "gamma"}
+
+// message fields are given as number, value pairs
+func message(fields ...any) []byte {
+	var b []byte
+	for i := 0; i < len(fields); i = i + 2 {
+		num := protowire.Number(fields[i].(int))
+		switch v := fields[i+1].(type) {
+		case uint64:
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, v)
+		case int64:
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, protowire.EncodeZigZag(v))
+		case bool:
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, protowire.EncodeBool(v))
+		case string:
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendString(b, v)
+		case []byte:
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendBytes(b, v)
+		}
+	}
+	return b
+}
+
+func packed(values ...uint64) []byte {
+	var b []byte
+	for _, v := range values {
+		b = protowire.AppendVarint(b, v)
+	}
+	return b
+}
+
+// compress splits data in chunks of blockSize, chunks which dont get smaller are kept as original
+func compress(codec uint64, data []byte) []byte {
+	if codec == 0 {
+		return data
+	}
+	var out []byte
+	for start := 0; start < len(data); start = start + blockSize {
+		end := start + blockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := data[start:end]
+		var compressed []byte
+		switch codec {
+		case 1:
+			var buf bytes.Buffer
+			w, _ := flate.NewWriter(&buf, flate.BestCompression)
+			w.Write(chunk)
+			w.Close()
+			compressed = buf.Bytes()
+		case 2:
+			compressed = snappy.Encode(nil, chunk)
+		case 5:
+			enc, _ := zstd.NewWriter(nil)
+			compressed = enc.EncodeAll(chunk, nil)
+		}
+		header := len(compressed) << 1
+		if len(compressed) >= len(chunk) {
+			compressed = chunk
+			header = len(chunk)<<1 | 1
+		}
+		out = append(out, byte(header), byte(header>>8), byte(header>>16))
+		out = append(out, compressed...)
+	}
+	return out
+}
+
+// rle v2 encodings
+
+func zigzag(v int64, signed bool) uint64 {
+	if signed {
+		return protowire.EncodeZigZag(v)
+	}
+	return uint64(v)
+}
+
+var widths = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 26, 28, 30, 32, 40, 48, 56, 64}
+
+func widthCode(v uint64) (int, int) {
+	for code, w := range widths {
+		if w >= bits.Len64(v) {
+			return code, w
+		}
+	}
+	return len(widths) - 1, 64
+}
+
+func pack(values []uint64, width int) []byte {
+	var out []byte
+	var current uint64
+	used := 0
+	for _, v := range values {
+		for b := width - 1; b >= 0; b-- {
+			current = current<<1 | (v>>uint(b))&1
+			used++
+			if used == 8 {
+				out = append(out, byte(current))
+				current, used = 0, 0
+			}
+		}
+	}
+	if used > 0 {
+		out = append(out, byte(current<<uint(8-used)))
+	}
+	return out
+}
+
+// rleV2 writes runs of equal values as short repeat (<= 10) or fixed delta, increasing by 1 as fixed delta
+// and rest as direct
+func rleV2(values []int64, signed bool) []byte {
+	var out []byte
+	for i := 0; i < len(values); {
+		// run of equal or increasing by 1 values
+		delta := int64(0)
+		if i+1 < len(values) {
+			delta = values[i+1] - values[i]
+		}
+		n := 1
+		for i+n < len(values) && n < 512 && values[i+n]-values[i+n-1] == delta && (delta == 0 || delta == 1) {
+			n++
+		}
+		switch {
+		case delta == 0 && n >= 3 && n <= 10:
+			v := zigzag(values[i], signed)
+			size := (bits.Len64(v) + 7) / 8
+			if size == 0 {
+				size = 1
+			}
+			out = append(out, byte((size-1)<<3|(n-3)))
+			for b := size - 1; b >= 0; b-- {
+				out = append(out, byte(v>>(8*uint(b))))
+			}
+		case n >= 3:
+			out = append(out, byte(0xc0|(n-1)>>8), byte(n-1))
+			if signed {
+				out = protowire.AppendVarint(out, protowire.EncodeZigZag(values[i]))
+			} else {
+				out = protowire.AppendVarint(out, uint64(values[i]))
+			}
+			out = protowire.AppendVarint(out, protowire.EncodeZigZag(delta))
+		default:
+			n = 0
+			for i+n < len(values) && n < 512 {
+				if i+n+2 < len(values) && values[i+n+1] == values[i+n] && values[i+n+2] == values[i+n] {
+					break
+				}
+				n++
+			}
+			if n == 0 {
+				n = 1
+			}
+			run := make([]uint64, n)
+			max := uint64(0)
+			for j := range run {
+				run[j] = zigzag(values[i+j], signed)
+				if run[j] > max {
+					max = run[j]
+				}
+			}
+			code, width := widthCode(max)
+			out = append(out, byte(0x40|code<<1|(n-1)>>8), byte(n-1))
+			out = append(out, pack(run, width)...)
+		}
+		i = i + n
+	}
+	return out
+}
+
+// byteRle writes runs of same byte as repeat and rest as literals
+func byteRle(data []byte) []byte {
+	var out []byte
+	for i := 0; i < len(data); {
+		n := 1
+		for i+n < len(data) && n < 130 && data[i+n] == data[i] {
+			n++
+		}
+		if n >= 3 {
+			out = append(out, byte(n-3), data[i])
+			i = i + n
+			continue
+		}
+		n = 0
+		for i+n < len(data) && n < 128 && !(i+n+2 < len(data) && data[i+n] == data[i+n+1] && data[i+n] == data[i+n+2]) {
+			n++
+		}
+		out = append(out, byte(-n))
+		out = append(out, data[i:i+n]...)
+		i = i + n
+	}
+	return out
+}
+
+func boolRle(values []bool) []byte {
+	data := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			data[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return byteRle(data)
+}
+
+func nanos(n int64) uint64 {
+	if n == 0 {
+		return 0
+	}
+	if n%100 != 0 {
+		return uint64(n) << 3
+	}
+	n = n / 100
+	zeros := uint64(1)
+	for n%10 == 0 && zeros < 7 {
+		n = n / 10
+		zeros++
+	}
+	return uint64(n)<<3 | zeros
+}
+
+type stream struct {
+	kind   uint64
+	column uint64
+	data   []byte
+}
+
+func main() {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		panic(err)
+	}
+	base := time.Date(2015, 1, 1, 0, 0, 0, 0, loc).Unix()
+	start := time.Date(2021, 3, 4, 5, 6, 7, 0, loc)
+	day := time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC).Unix() / 86400
+
+	for _, codec := range []struct {
+		id   uint64
+		name string
+	}{{1, "zlib"}, {2, "snappy"}, {5, "zstd"}} {
+		var file bytes.Buffer
+		file.WriteString("ORC")
+
+		stripeInfos := []any{}
+		stripeStats := []any{}
+		perStripe := rows / stripes
+		for s := 0; s < stripes; s++ {
+			var ids, lengths, dictIndexes, days, seconds []int64
+			var nanoValues []int64
+			var cities bytes.Buffer
+			var present, active []bool
+			var scores []byte
+			nulls := 0
+			for r := s * perStripe; r < (s+1)*perStripe; r++ {
+				ids = append(ids, int64(r+1))
+				present = append(present, r%7 != 3)
+				if r%7 != 3 {
+					dictIndexes = append(dictIndexes, int64(r%3))
+				} else {
+					nulls++
+				}
+				city := fmt.Sprintf("city-%d", r%10)
+				cities.WriteString(city)
+				lengths = append(lengths, int64(len(city)))
+				scores = binary.LittleEndian.AppendUint64(scores, math.Float64bits(float64(r)*0.5))
+				active = append(active, r%2 == 0)
+				ts := start.Add(time.Duration(r)*time.Second + time.Duration(r%1000)*time.Millisecond)
+				seconds = append(seconds, ts.Unix()-base)
+				nanoValues = append(nanoValues, int64(nanos(int64(ts.Nanosecond()))))
+				days = append(days, day+int64(r%30))
+			}
+			dictLengths := make([]int64, len(names))
+			var dict bytes.Buffer
+			for i, n := range names {
+				dict.WriteString(n)
+				dictLengths[i] = int64(len(n))
+			}
+
+			count := uint64(perStripe)
+			stats := [][]byte{
+				message(1, count),
+				message(1, count, 2, message(1, int64(s*perStripe+1), 2, int64((s+1)*perStripe), 3, int64((s*perStripe+1+(s+1)*perStripe)*perStripe/2))),
+				message(1, count-uint64(nulls), 10, true),
+				message(1, count),
+				message(1, count),
+				message(1, count),
+				message(1, count),
+				message(1, count),
+			}
+			streams := []stream{}
+			for c := range stats {
+				streams = append(streams, stream{6, uint64(c), message(1, message(1, packed(0, 0, 0), 2, stats[c]))})
+			}
+			streams = append(streams,
+				stream{1, 1, rleV2(ids, true)},
+				stream{0, 2, boolRle(present)},
+				stream{1, 2, rleV2(dictIndexes, false)},
+				stream{2, 2, rleV2(dictLengths, false)},
+				stream{3, 2, dict.Bytes()},
+				stream{1, 3, cities.Bytes()},
+				stream{2, 3, rleV2(lengths, false)},
+				stream{1, 4, scores},
+				stream{1, 5, boolRle(active)},
+				stream{1, 6, rleV2(seconds, true)},
+				stream{5, 6, rleV2(nanoValues, false)},
+				stream{1, 7, rleV2(days, true)},
+			)
+
+			offset := uint64(file.Len())
+			footerFields := []any{}
+			indexLength, dataLength := 0, 0
+			for _, st := range streams {
+				data := compress(codec.id, st.data)
+				file.Write(data)
+				if st.kind == 6 {
+					indexLength = indexLength + len(data)
+				} else {
+					dataLength = dataLength + len(data)
+				}
+				footerFields = append(footerFields, 1, message(1, st.kind, 2, st.column, 3, uint64(len(data))))
+			}
+			for _, e := range []uint64{0, 2, 3, 2, 0, 0, 2, 2} {
+				footerFields = append(footerFields, 2, message(1, e, 2, uint64(len(names))*uint64(e/3)))
+			}
+			footerFields = append(footerFields, 3, timezone)
+			footer := compress(codec.id, message(footerFields...))
+			file.Write(footer)
+
+			stripeInfos = append(stripeInfos, 3, message(1, offset, 2, uint64(indexLength), 3, uint64(dataLength), 4, uint64(len(footer)), 5, count))
+			colStats := []any{}
+			for _, st := range stats {
+				colStats = append(colStats, 1, st)
+			}
+			stripeStats = append(stripeStats, 1, message(colStats...))
+		}
+
+		metadata := compress(codec.id, message(stripeStats...))
+		file.Write(metadata)
+
+		contentLength := uint64(file.Len())
+		footerFields := []any{1, uint64(3), 2, contentLength}
+		footerFields = append(footerFields, stripeInfos...)
+		footerFields = append(footerFields,
+			4, message(1, uint64(12), 2, packed(1, 2, 3, 4, 5, 6, 7), 3, "id", 3, "name", 3, "city", 3, "score", 3, "active", 3, "ts", 3, "day"),
+			4, message(1, uint64(4)),
+			4, message(1, uint64(7)),
+			4, message(1, uint64(7)),
+			4, message(1, uint64(6)),
+			4, message(1, uint64(0)),
+			4, message(1, uint64(9)),
+			4, message(1, uint64(15)),
+			5, message(1, "generator", 2, []byte("pq testdata")),
+			6, uint64(rows),
+			7, message(1, uint64(rows)),
+			8, uint64(10000),
+			9, uint64(1),
+		)
+		footer := compress(codec.id, message(footerFields...))
+		file.Write(footer)
+
+		postscript := message(1, uint64(len(footer)), 2, codec.id, 3, uint64(blockSize), 4, packed(0, 12), 5, uint64(len(metadata)), 6, uint64(9), 8000, "ORC")
+		file.Write(postscript)
+		file.WriteByte(byte(len(postscript)))
+
+		err = os.WriteFile(filepath.Join("testdata", "orc", "orders_"+codec.name+".orc"), file.Bytes(), 0644)
+		if err != nil {
+			panic(err)
+		}
+	}
+}